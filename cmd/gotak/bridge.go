@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/bridge"
+	"github.com/angry-kivi/gotak/pkg/filter"
+	"github.com/angry-kivi/gotak/pkg/tak"
+	"github.com/sirupsen/logrus"
+)
+
+// endpointConfig builds a client configuration from an endpoint such as
+// tcp://takserver:8087, tls://takserver:8089, udp://host:8087 or multicast://239.2.3.1:6969
+func endpointConfig(endpoint string) (tak.ClientConfig, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return tak.ClientConfig{}, fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+	host, portText, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return tak.ClientConfig{}, fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil {
+		return tak.ClientConfig{}, fmt.Errorf("invalid port in endpoint %q", endpoint)
+	}
+
+	config := tak.ClientConfig{
+		Address:      host,
+		Port:         port,
+		DialTimeout:  10 * time.Second,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	switch u.Scheme {
+	case "tcp":
+		config.ConnectionType = tak.ConnectionTypeTCP
+	case "tls":
+		config.ConnectionType = tak.ConnectionTypeTLS
+	case "udp":
+		config.ConnectionType = tak.ConnectionTypeUDP
+	case "multicast":
+		config.ConnectionType = tak.ConnectionTypeMulticast
+		config.MulticastAddr = host
+		config.MulticastPort = port
+	default:
+		return tak.ClientConfig{}, fmt.Errorf("invalid endpoint %q: scheme must be tcp, tls, udp or multicast", endpoint)
+	}
+	return config, nil
+}
+
+// endpointFormat returns the format of events sent to an endpoint: xml, or proto for
+// the TAK protocol with the header its connection type uses
+func endpointFormat(format string, config tak.ClientConfig) (bridge.Format, error) {
+	switch format {
+	case "xml":
+		return bridge.FormatXML, nil
+	case "proto":
+		if config.ConnectionType == tak.ConnectionTypeUDP || config.ConnectionType == tak.ConnectionTypeMulticast {
+			return bridge.FormatMesh, nil
+		}
+		return bridge.FormatStream, nil
+	default:
+		return bridge.FormatXML, fmt.Errorf("invalid format %q: must be xml or proto", format)
+	}
+}
+
+// runBridge implements the bridge subcommand, which relays events between two endpoints
+func runBridge(args []string) {
+	flags := flag.NewFlagSet("bridge", flag.ExitOnError)
+	endpointA := flags.String("a", "multicast://239.2.3.1:6969", "First endpoint (tcp://, tls://, udp:// or multicast://host:port)")
+	endpointB := flags.String("b", "", "Second endpoint (tcp://, tls://, udp:// or multicast://host:port)")
+	id := flags.String("id", "gotak-bridge", "Bridge identifier used in flow tags")
+	aToBFilter := flags.String("a-to-b-filter", "", "Only forward events from a to b matching the expression")
+	bToAFilter := flags.String("b-to-a-filter", "", "Only forward events from b to a matching the expression")
+	aToBPrefix := flags.String("a-to-b-uid-prefix", "", "Prefix prepended to the UID of events forwarded from a to b")
+	bToAPrefix := flags.String("b-to-a-uid-prefix", "", "Prefix prepended to the UID of events forwarded from b to a")
+	formatA := flags.String("a-format", "xml", "Format of events sent to the first endpoint (xml or proto)")
+	formatB := flags.String("b-format", "xml", "Format of events sent to the second endpoint (xml or proto)")
+	maxHops := flags.Int("max-hops", bridge.DefaultMaxHops, "Drop events that passed through more relays")
+	logLevel := addLogFlag(flags)
+
+	// TLS certificate flags, used by tls endpoints
+	tlsOptions := addTLSFlags(flags)
+
+	flags.Parse(args)
+
+	log := newLogger(*logLevel)
+
+	if *endpointB == "" {
+		log.Fatal("The -b endpoint is required")
+	}
+
+	var err error
+	config := bridge.Config{
+		ID:      *id,
+		AToB:    bridge.Route{UIDPrefix: *aToBPrefix},
+		BToA:    bridge.Route{UIDPrefix: *bToAPrefix},
+		MaxHops: *maxHops,
+		Logger:  log,
+	}
+	if *aToBFilter != "" {
+		if config.AToB.Filter, err = filter.Parse(*aToBFilter); err != nil {
+			log.Fatalf("Invalid a-to-b filter: %v", err)
+		}
+	}
+	if *bToAFilter != "" {
+		if config.BToA.Filter, err = filter.Parse(*bToAFilter); err != nil {
+			log.Fatalf("Invalid b-to-a filter: %v", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	clients := make([]tak.Client, 2)
+	// Events sent to a arrive through the b-to-a route and the other way around
+	formats := []string{*formatA, *formatB}
+	routes := []*bridge.Route{&config.BToA, &config.AToB}
+	for i, endpoint := range []string{*endpointA, *endpointB} {
+		clientConfig, err := endpointConfig(endpoint)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if routes[i].Format, err = endpointFormat(formats[i], clientConfig); err != nil {
+			log.Fatal(err)
+		}
+		clientConfig.ClientID = *id
+		tlsOptions.apply(&clientConfig)
+		clientConfig.Logger = log
+
+		client, err := tak.NewClient(clientConfig)
+		if err != nil {
+			log.WithError(err).Fatalf("Failed to create client for %s", endpoint)
+		}
+		if err := client.Connect(ctx); err != nil {
+			log.WithError(err).Fatalf("Failed to connect to %s", endpoint)
+		}
+		defer client.Disconnect()
+		clients[i] = client
+		log.WithField("endpoint", endpoint).Info("Connected")
+	}
+
+	br, err := bridge.New(clients[0], clients[1], config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Info("Bridging; press Ctrl+C to stop")
+	if err := br.Run(ctx); err != nil && ctx.Err() == nil {
+		log.WithError(err).Error("Bridge stopped")
+	}
+
+	aToB, bToA := br.Stats()
+	log.WithFields(logrus.Fields{
+		"a_to_b_forwarded": aToB.Forwarded,
+		"a_to_b_filtered":  aToB.Filtered,
+		"a_to_b_looped":    aToB.Looped,
+		"b_to_a_forwarded": bToA.Forwarded,
+		"b_to_a_filtered":  bToA.Filtered,
+		"b_to_a_looped":    bToA.Looped,
+	}).Info("Bridge statistics")
+}
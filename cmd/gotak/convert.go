@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runConvert implements the convert subcommand, which translates event files between
+// XML, TAK protocol (protobuf) and GeoJSON
+func runConvert(args []string) {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: gotak convert -to <format> [flags] [file ...]")
+		fmt.Fprintln(flags.Output(), "Converts the events of the files, or of stdin when no file is given. '-' reads stdin.")
+		fmt.Fprintln(flags.Output(), "Protobuf output holds TAK protocol messages with streaming headers.")
+		flags.PrintDefaults()
+	}
+	from := flags.String("from", formatAuto, "Format of the input (auto, xml, proto, geojson)")
+	to := flags.String("to", formatXML, "Format of the output (xml, proto, geojson)")
+	out := flags.String("o", "", "Output file (defaults to stdout)")
+	logLevel := addLogFlag(flags)
+	flags.Parse(args)
+
+	log := newLogger(*logLevel)
+
+	inputs := flags.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+	events, err := readEvents(inputs, *from, "from")
+	if err != nil {
+		log.Fatalf("Failed to read events: %v", err)
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		if w, err = os.Create(*out); err != nil {
+			log.Fatalf("Failed to create output file: %v", err)
+		}
+	}
+	if err := encodeEvents(w, events, *to); err != nil {
+		log.Fatalf("Failed to write events: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		log.Fatalf("Failed to write events: %v", err)
+	}
+	log.WithField("events", len(events)).Debug("Converted events")
+}
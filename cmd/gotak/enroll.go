@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/angry-kivi/gotak/pkg/enrollment"
+	"github.com/sirupsen/logrus"
+)
+
+// runEnroll implements the enroll subcommand, which obtains a client certificate from a
+// TAK Server with a username and password
+func runEnroll(args []string) {
+	flags := flag.NewFlagSet("enroll", flag.ExitOnError)
+	serverAddr := flags.String("server", "takserver", "TAK server address")
+	serverPort := flags.Int("port", enrollment.DefaultPort, "TAK server enrollment port")
+	username := flags.String("user", "", "Enrollment username")
+	password := flags.String("password", "", "Enrollment password")
+	clientUID := flags.String("uid", "", "Client UID reported to the server (defaults to the username)")
+	out := flags.String("out", "", "Output file for the client certificate (.p12, or .pem with .key and -ca.pem alongside)")
+	p12Password := flags.String("p12-password", enrollment.DefaultP12Password, "Password protecting the .p12 output")
+	caFile := flags.String("ca", "", "CA certificate file (.pem) trusted for the enrollment connection")
+	skipVerify := flags.Bool("skip-verify", false, "Skip TLS certificate verification of the enrollment connection")
+	flags.Parse(args)
+
+	log := logrus.New()
+	if *username == "" || *password == "" {
+		log.Fatal("The -user and -password flags are required")
+	}
+	if *out == "" {
+		*out = *username + ".p12"
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: *skipVerify}
+	if *caFile != "" {
+		ca, err := os.ReadFile(*caFile)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to read CA certificate")
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			log.Fatal("Failed to parse CA certificate")
+		}
+	}
+
+	result, err := enrollment.Enroll(context.Background(), enrollment.Config{
+		Address:   *serverAddr,
+		Port:      *serverPort,
+		Username:  *username,
+		Password:  *password,
+		ClientUID: *clientUID,
+		TLSConfig: tlsConfig,
+	})
+	if err != nil {
+		log.WithError(err).Fatal("Enrollment failed")
+	}
+
+	if ext := filepath.Ext(*out); strings.EqualFold(ext, ".pem") {
+		base := (*out)[:len(*out)-len(ext)]
+		err = result.WritePEM(*out, base+".key", base+"-ca.pem")
+	} else {
+		err = result.WriteP12(*out, *p12Password)
+	}
+	if err != nil {
+		log.WithError(err).Fatal("Failed to store certificate")
+	}
+
+	log.WithFields(logrus.Fields{
+		"file":    *out,
+		"subject": result.Certificate.Subject.String(),
+		"expires": result.Certificate.NotAfter,
+	}).Info("Enrolled")
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/angry-kivi/gotak/pkg/parser"
+)
+
+// Event file formats
+const (
+	formatAuto    = "auto"
+	formatXML     = "xml"
+	formatProto   = "proto"
+	formatGeoJSON = "geojson"
+)
+
+// errUnknownFormat is returned when the format of event data cannot be detected
+var errUnknownFormat = errors.New("unknown event format")
+
+// detectFormat guesses the format of event data from its first byte
+func detectFormat(data []byte) (string, error) {
+	data = bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if len(data) == 0 {
+		return "", errors.New("no event data")
+	}
+	switch data[0] {
+	case '<':
+		return formatXML, nil
+	case '{':
+		return formatGeoJSON, nil
+	case 0xbf:
+		return formatProto, nil
+	}
+	return "", errUnknownFormat
+}
+
+// decodeEvents parses event data in the given format. XML data may hold several
+// concatenated events, protobuf data several messages with streaming headers and
+// GeoJSON a feature collection.
+func decodeEvents(data []byte, format string) ([]*cot.Event, error) {
+	if format == formatAuto {
+		var err error
+		if format, err = detectFormat(data); err != nil {
+			return nil, err
+		}
+	}
+
+	switch format {
+	case formatXML:
+		var events []*cot.Event
+		decoder := xml.NewDecoder(bytes.NewReader(data))
+		for {
+			var event cot.Event
+			err := decoder.Decode(&event)
+			if err == io.EOF {
+				return events, nil
+			} else if err != nil {
+				return nil, fmt.Errorf("invalid XML event: %w", err)
+			}
+			events = append(events, &event)
+		}
+	case formatProto:
+		p := parser.NewProtoParser()
+		messages := [][]byte{data}
+		// Messages with the mesh header or without header stand alone
+		if len(data) > 2 && data[0] == 0xbf && data[1] != 0x01 {
+			var err error
+			if messages, err = parser.SplitProtoStream(data); err != nil {
+				return nil, err
+			}
+		}
+		events := make([]*cot.Event, 0, len(messages))
+		for _, message := range messages {
+			event, err := p.ParseCoT(message)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+		}
+		return events, nil
+	case formatGeoJSON:
+		return parser.NewGeoJSONParser().ParseFeatureCollection(data)
+	}
+	return nil, fmt.Errorf("unknown format %q; valid formats are xml, proto and geojson", format)
+}
+
+// encodeEvents writes events in the given format: indented XML events, protobuf
+// messages with streaming headers or a GeoJSON feature collection
+func encodeEvents(w io.Writer, events []*cot.Event, format string) error {
+	switch format {
+	case formatXML:
+		p := parser.NewXMLParser()
+		for _, event := range events {
+			data, err := p.SerializePrettyCoT(event)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(append(data, '\n')); err != nil {
+				return err
+			}
+		}
+		return nil
+	case formatProto:
+		p := parser.NewProtoParser()
+		for _, event := range events {
+			data, err := p.SerializeStreamCoT(event)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+		}
+		return nil
+	case formatGeoJSON:
+		data, err := parser.NewGeoJSONParser().SerializeFeatureCollection(events)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	}
+	return fmt.Errorf("unknown format %q; valid formats are xml, proto and geojson", format)
+}
+
+// readEvents reads the events of files, where "-" is stdin. formatFlag names the flag
+// setting format, for the error when it cannot be detected.
+func readEvents(paths []string, format, formatFlag string) ([]*cot.Event, error) {
+	var events []*cot.Event
+	for _, path := range paths {
+		var data []byte
+		var err error
+		if path == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		fileEvents, err := decodeEvents(data, format)
+		if errors.Is(err, errUnknownFormat) {
+			return nil, fmt.Errorf("%s: %w; use -%s", path, err, formatFlag)
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		events = append(events, fileEvents...)
+	}
+	return events, nil
+}
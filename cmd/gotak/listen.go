@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/angry-kivi/gotak/pkg/parser"
+	"github.com/angry-kivi/gotak/pkg/tak"
+)
+
+// Output formats of the listen subcommand
+const (
+	outputXML   = "xml"
+	outputJSON  = "json"
+	outputTable = "table"
+)
+
+// runListen implements the listen subcommand, which prints received events
+func runListen(args []string) {
+	flags := flag.NewFlagSet("listen", flag.ExitOnError)
+	conn := addConnectionFlags(flags, "gotak-listener")
+	logLevel := addLogFlag(flags)
+	output := flags.String("output", outputTable, "Output format (xml, json, table)")
+	count := flags.Int("count", 0, "Stop after this many events; 0 listens until interrupted")
+	duration := flags.Duration("duration", 0, "Stop after this long; 0 listens until interrupted")
+	flags.Parse(args)
+
+	log := newLogger(*logLevel)
+
+	var print func(w io.Writer, event *cot.Event) error
+	switch *output {
+	case outputXML:
+		xmlParser := parser.NewXMLParser()
+		print = func(w io.Writer, event *cot.Event) error {
+			data, err := xmlParser.SerializePrettyCoT(event)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(append(data, '\n'))
+			return err
+		}
+	case outputJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		print = func(w io.Writer, event *cot.Event) error {
+			return encoder.Encode(event)
+		}
+	case outputTable:
+		fmt.Printf("%-9s %-20s %-36s %-16s %-12s %s\n", "TIME", "TYPE", "UID", "CALLSIGN", "LAT", "LON")
+		print = printRow
+	default:
+		log.Fatalf("Invalid output format: %s. Valid options are: xml, json, table", *output)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	conn.readTimeout = time.Second
+	client, err := conn.connect(ctx, log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Disconnect()
+
+	received := 0
+	err = receiveEvents(ctx, client, func(event *cot.Event) bool {
+		if err := print(os.Stdout, event); err != nil {
+			log.WithError(err).Error("Failed to print event")
+		}
+		received++
+		return *count <= 0 || received < *count
+	}, func(raw []byte, err error) {
+		log.WithError(err).WithField("raw_data", string(raw)).Warn("Received unparseable data")
+	})
+	if err != nil && ctx.Err() == nil {
+		log.WithError(err).Fatal("Error receiving data")
+	}
+	log.WithField("events", received).Info("Stopped listening")
+}
+
+// receiveEvents passes the events received by a client to handle until handle returns
+// false, ctx is done or receiving fails. XML events split across or sharing reads are
+// reassembled; TAK protocol messages are decoded as well. Data that does not parse is
+// passed to invalid.
+func receiveEvents(ctx context.Context, client tak.Client, handle func(*cot.Event) bool, invalid func([]byte, error)) error {
+	var splitter parser.EventSplitter
+	xmlParser := parser.NewXMLParser()
+	protoParser := parser.NewProtoParser()
+
+	for ctx.Err() == nil {
+		data, err := client.Receive()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() || errors.Is(err, tak.ErrMessageSkipped) {
+				continue
+			}
+			return err
+		}
+
+		// The splitter cuts XML events and TAK protocol messages, which may share a
+		// read or continue in the next one
+		for _, raw := range splitter.Feed(data) {
+			var event *cot.Event
+			var err error
+			if raw[0] == 0xbf {
+				event, err = protoParser.ParseCoT(raw)
+			} else {
+				event, err = xmlParser.ParseCoT(raw)
+			}
+			if err != nil {
+				invalid(raw, err)
+				continue
+			}
+			if !handle(event) {
+				return nil
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// printRow prints an event as a row of the table output
+func printRow(w io.Writer, event *cot.Event) error {
+	callsign := ""
+	if event.Detail.Contact != nil {
+		callsign = event.Detail.Contact.Callsign
+	}
+	_, err := fmt.Fprintf(w, "%-9s %-20s %-36s %-16s %-12.6f %.6f\n",
+		event.Time.Time().Local().Format(time.TimeOnly),
+		truncate(event.Type, 20), truncate(event.UID, 36), truncate(callsign, 16),
+		event.Point.Lat, event.Point.Lon)
+	return err
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
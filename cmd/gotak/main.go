@@ -2,131 +2,167 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
-	"github.com/angry-kivi/gotak/pkg/cot"
-	"github.com/angry-kivi/gotak/pkg/parser"
+	"github.com/angry-kivi/gotak/pkg/certmanager"
+	"github.com/angry-kivi/gotak/pkg/datapackage"
+	"github.com/angry-kivi/gotak/pkg/filter"
 	"github.com/angry-kivi/gotak/pkg/tak"
 	"github.com/sirupsen/logrus"
 )
 
-func spotEvent(uid string) *cot.Event {
-	spotEvent := cot.NewEvent("a-f-S-C", "spot-1"+uid)
-	spotEvent.Point.Lat = 38.85606343062312
-	spotEvent.Point.Lon = -77.0563755018233
-	spotEvent.SetType("b-m-p-s-m")
-
-	// Set the Europe/Stockholm timezone
-	loc, _ := time.LoadLocation("Europe/Stockholm")
-	now := cot.CotTime(time.Now().In(loc))
-	staleTime := now.Add(10 * time.Minute)
+// command is a gotak subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
 
-	point := cot.NewPoint(38.85606343062312, -77.0563755018233)
+var commands = []command{
+	{"send", "Send events from files, stdin or flags", runSend},
+	{"listen", "Print received events as XML, JSON or a table", runListen},
+	{"convert", "Convert events between XML, protobuf and GeoJSON", runConvert},
+	{"record", "Record received data to a capture file", runRecord},
+	{"replay", "Replay a capture file with its recorded timing", runReplay},
+	{"bridge", "Relay events between two endpoints", runBridge},
+	{"enroll", "Obtain a client certificate from a TAK Server", runEnroll},
+}
 
-	detail := cot.Detail{
-		Status:  &cot.Status{Readiness: true},
-		Archive: &cot.Archive{},
-		Contact: &cot.Contact{Callsign: "R1"},
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
 	}
-	detail.AddLink(
-		&cot.Link{
-			UID:        "spot-1.Link",
-			Type:       "a-f-G-U-C",
-			Parent:     "spot-1",
-			Relation:   "p-p",
-			Production: &now,
-		},
-	)
-	return &cot.Event{
-		Version: "2.0",
-		UID:     fmt.Sprintf("spot-1-%s", uid),
-		Type:    "b-m-p-s-m",
-		Time:    now,
-		Start:   now,
-		Stale:   staleTime,
-		How:     "h-g-i-g-o",
-		Point:   point,
-		Detail:  detail,
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		usage()
+		return
 	}
-}
-
-func createRect(uid string) *cot.Event {
-
-	// Set the Europe/Stockholm timezone
-	loc, _ := time.LoadLocation("Europe/Stockholm")
-	now := cot.CotTime(time.Now().In(loc))
-	staleTime := now.Add(10 * time.Minute)
-
-	point := cot.NewPoint(38.83771744357615, 77.06824708113128)
-
-	detail := cot.Detail{
-		Archive: &cot.Archive{},
-		Contact: &cot.Contact{Callsign: "Rectangle"},
+	for _, cmd := range commands {
+		if cmd.name == name {
+			cmd.run(os.Args[2:])
+			return
+		}
 	}
-	detail.AddPointLink("38.83884480020009,-77.06896916307281")
-	detail.AddPointLink("38.83878017039543,-77.06737849735573")
-	detail.AddPointLink("38.8365895820601,-77.0675250569016")
-	detail.AddPointLink("38.83665521881576,-77.06911560643489")
-	detail.SetLabelsOn(true)
-	detail.SetTog(false)
-	detail.SetPrecisionLocation("???")
-	detail.SetStrokeColor(-1)
-	detail.SetStrokeWeight(3.0)
-	detail.SetFillColor(-1761607681)
+	fmt.Fprintf(os.Stderr, "gotak: unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
 
-	return &cot.Event{
-		Version: "2.0",
-		UID:     fmt.Sprintf("rect-1-%s", uid),
-		Type:    "u-d-r",
-		Time:    now,
-		Start:   now,
-		Stale:   staleTime,
-		How:     "h-e",
-		Point:   point,
-		Detail:  detail,
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: gotak <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'gotak <command> -h' for the flags of a command.")
 }
 
-func main() {
-	// Define command-line flags
-	serverAddr := flag.String("server", "takserver", "TAK server address")
-	serverPort := flag.Int("port", tak.DefaultTCPPort, "TAK server port")
-	connectionType := flag.String("connection", "tcp", "Connection type (tcp, tls, udp, multicast)")
-	multicastAddr := flag.String("multicast-addr", tak.DefaultMulticastAddr, "Multicast group address")
-	multicastPort := flag.Int("multicast-port", tak.DefaultMulticastPort, "Multicast port")
-	clientID := flag.String("id", "gotak-client", "Client identifier")
-	logLevel := flag.String("log-level", "info", "Log level (trace, debug, info, warn, error, fatal, panic)")
-
-	// TLS certificate flags
-	certFile := flag.String("cert", "", "Client certificate file (.pem or .p12)")
-	keyFile := flag.String("key", "", "Client private key file (.key) - not needed for .p12")
-	p12Password := flag.String("password", "", "Password for .p12 certificate file")
-	caFile := flag.String("ca", "", "CA certificate file (.pem)")
-	skipVerify := flag.Bool("skip-verify", false, "Skip TLS certificate verification")
-
-	flag.Parse()
+// addLogFlag registers the -log-level flag
+func addLogFlag(flags *flag.FlagSet) *string {
+	return flags.String("log-level", "info", "Log level (trace, debug, info, warn, error, fatal, panic)")
+}
 
-	// Configure logger
+// newLogger creates the logger of a subcommand. It writes to stderr so that event
+// output on stdout stays clean.
+func newLogger(logLevel string) *logrus.Logger {
 	log := logrus.New()
 	log.SetFormatter(&logrus.TextFormatter{
 		FullTimestamp:   true,
 		TimestampFormat: "2006-01-02 15:04:05",
 	})
-
-	// Set log level from command line flag
-	level, err := logrus.ParseLevel(strings.ToLower(*logLevel))
+	level, err := logrus.ParseLevel(strings.ToLower(logLevel))
 	if err != nil {
 		log.Fatalf("Invalid log level: %v", err)
 	}
 	log.SetLevel(level)
-	log.Debugf("Log level set to: %s", level)
+	return log
+}
 
-	// Determine connection type from the flag
+// tlsFlags are the TLS certificate flags shared by the subcommands
+type tlsFlags struct {
+	certFile    *string
+	keyFile     *string
+	p12Password *string
+	caFile      *string
+	caPassword  *string
+	serverName  *string
+	pins        *string
+	skipVerify  *bool
+}
+
+// addTLSFlags registers the TLS certificate flags
+func addTLSFlags(flags *flag.FlagSet) *tlsFlags {
+	return &tlsFlags{
+		certFile:    flags.String("cert", "", "Client certificate file (.pem or .p12)"),
+		keyFile:     flags.String("key", "", "Client private key file (.key) - not needed for .p12"),
+		p12Password: flags.String("password", "", "Password for .p12 certificate file"),
+		caFile:      flags.String("ca", "", "CA certificate file (.pem) or truststore (.p12)"),
+		caPassword:  flags.String("ca-password", "", "Password for a .p12 truststore"),
+		serverName:  flags.String("server-name", "", "Name to verify the server certificate against, when it differs from the server address"),
+		pins:        flags.String("pin", "", "Comma-separated SHA-256 public key pins (base64) of which the server chain must contain one"),
+		skipVerify:  flags.Bool("skip-verify", false, "Skip TLS certificate verification"),
+	}
+}
+
+// apply sets the TLS settings of a client configuration
+func (f *tlsFlags) apply(config *tak.ClientConfig) {
+	config.CertFile = *f.certFile
+	config.KeyFile = *f.keyFile
+	config.P12Password = *f.p12Password
+	config.CAFile = *f.caFile
+	config.CAPassword = *f.caPassword
+	config.ServerName = *f.serverName
+	config.PinnedKeys = splitList(*f.pins)
+	config.SkipTLSVerify = *f.skipVerify
+}
+
+// connectionFlags are the flags selecting the connection of a subcommand
+type connectionFlags struct {
+	serverAddr     *string
+	serverPort     *int
+	connectionType *string
+	multicastAddr  *string
+	multicastPort  *int
+	clientID       *string
+	packageFile    *string
+	receiveFilter  *string
+	sendFilter     *string
+	tls            *tlsFlags
+
+	// readTimeout bounds each Receive; subcommands that receive until interrupted
+	// shorten it so that they notice the interrupt
+	readTimeout time.Duration
+}
+
+// addConnectionFlags registers the connection flags with the given default client ID
+func addConnectionFlags(flags *flag.FlagSet, clientID string) *connectionFlags {
+	return &connectionFlags{
+		serverAddr:     flags.String("server", "takserver", "TAK server address"),
+		serverPort:     flags.Int("port", tak.DefaultTCPPort, "TAK server port"),
+		connectionType: flags.String("connection", "tcp", "Connection type (tcp, tls, udp, multicast)"),
+		multicastAddr:  flags.String("multicast-addr", tak.DefaultMulticastAddr, "Multicast group address"),
+		multicastPort:  flags.Int("multicast-port", tak.DefaultMulticastPort, "Multicast port"),
+		clientID:       flags.String("id", clientID, "Client identifier"),
+		packageFile:    flags.String("package", "", "Connection data package (.zip) with config.pref and certificates, replaces the server and TLS flags"),
+		receiveFilter:  flags.String("receive-filter", "", `Only accept received events matching the expression, e.g. 'type ~ "a-h-*"'`),
+		sendFilter:     flags.String("send-filter", "", "Only send events matching the expression"),
+		tls:            addTLSFlags(flags),
+		readTimeout:    30 * time.Second,
+	}
+}
+
+// clientConfig builds the client configuration selected by the flags
+func (f *connectionFlags) clientConfig(log *logrus.Logger) (tak.ClientConfig, error) {
 	var connType tak.ConnectionType
-	switch strings.ToLower(*connectionType) {
+	switch strings.ToLower(*f.connectionType) {
 	case "tcp":
 		connType = tak.ConnectionTypeTCP
 	case "tls":
@@ -136,164 +172,112 @@ func main() {
 	case "multicast":
 		connType = tak.ConnectionTypeMulticast
 	default:
-		log.Fatalf("Invalid connection type: %s. Valid options are: tcp, tls, udp, multicast", *connectionType)
+		return tak.ClientConfig{}, fmt.Errorf("invalid connection type: %s. Valid options are: tcp, tls, udp, multicast", *f.connectionType)
 	}
 
-	// Configure client
 	config := tak.ClientConfig{
-		Address:        *serverAddr,
-		Port:           *serverPort,
-		ClientID:       *clientID,
+		Address:        *f.serverAddr,
+		Port:           *f.serverPort,
+		ClientID:       *f.clientID,
 		ConnectionType: connType,
 		DialTimeout:    10 * time.Second,
-		ReadTimeout:    30 * time.Second,
+		ReadTimeout:    f.readTimeout,
 		WriteTimeout:   10 * time.Second,
-
-		// TLS certificate configuration
-		CertFile:      *certFile,
-		KeyFile:       *keyFile,
-		P12Password:   *p12Password,
-		CAFile:        *caFile,
-		SkipTLSVerify: *skipVerify,
-
-		// Multicast configuration
-		MulticastAddr: *multicastAddr,
-		MulticastPort: *multicastPort,
-
-		// Add logger to client config
-		Logger: log,
-	}
-
-	client, err := tak.NewClient(config)
-	if err != nil {
-		log.WithError(err).Fatal(fmt.Sprintf("Failed to create %s client", connType))
+		MulticastAddr:  *f.multicastAddr,
+		MulticastPort:  *f.multicastPort,
+		Logger:         log,
 	}
-
-	switch connType {
-	case tak.ConnectionTypeTLS:
-		log.Info("Using TLS connection")
-	case tak.ConnectionTypeUDP:
-		log.Info("Using UDP connection")
-	case tak.ConnectionTypeMulticast:
-		log.WithFields(logrus.Fields{
-			"address": *multicastAddr,
-			"port":    *multicastPort,
-		}).Info("Using multicast UDP connection")
-	default: // TCP
-		log.Info("Using TCP connection")
+	f.tls.apply(&config)
+
+	// A data package supplies the server and the TLS configuration
+	if *f.packageFile != "" {
+		pkgConfig, err := datapackage.LoadClientConfig(*f.packageFile)
+		if errors.Is(err, datapackage.ErrEnrollmentRequired) {
+			return tak.ClientConfig{}, fmt.Errorf("data package %s has no client certificate; enroll with 'gotak enroll' first", *f.packageFile)
+		} else if err != nil {
+			return tak.ClientConfig{}, fmt.Errorf("failed to load data package: %w", err)
+		}
+		config.Address = pkgConfig.Address
+		config.Port = pkgConfig.Port
+		config.ConnectionType = pkgConfig.ConnectionType
+		config.TLSConfig = pkgConfig.TLSConfig
 	}
 
-	// Connect to server
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	log.WithFields(logrus.Fields{
-		"server": *serverAddr,
-		"port":   *serverPort,
-	}).Info("Connecting to TAK server...")
-
-	if err := client.Connect(ctx); err != nil {
-		log.WithError(err).Fatal("Failed to connect")
+	if *f.receiveFilter != "" {
+		expr, err := filter.Parse(*f.receiveFilter)
+		if err != nil {
+			return tak.ClientConfig{}, fmt.Errorf("invalid receive filter: %w", err)
+		}
+		config.ReceiveFilter = filter.Message(expr)
 	}
-	defer func() {
-		if err := client.Disconnect(); err != nil {
-			log.WithError(err).Error("Error during disconnect")
+	if *f.sendFilter != "" {
+		expr, err := filter.Parse(*f.sendFilter)
+		if err != nil {
+			return tak.ClientConfig{}, fmt.Errorf("invalid send filter: %w", err)
 		}
-	}()
-
-	log.Info("Connected successfully")
-
-	// Create and send a simple ping event
-	// pingEvent := cot.NewPingEvent(*clientID)
-
-	posEvent := cot.NewEvent("a-f-S-C", "pos-1-"+*connectionType)
-	posEvent.Point.Lat = 41.04911339629699
-	posEvent.Point.Lon = -102.97315828649731
-	posEvent.Detail = cot.Detail{
-		Contact: &cot.Contact{
-			Callsign:     "GOTAK",
-			Phone:        "555-555-5555",
-			EmailAddress: "test@gotak.com",
-		},
+		config.SendFilter = filter.Message(expr)
 	}
+	return config, nil
+}
 
-	xmlParser := parser.NewXMLParser()
-	posData, err := xmlParser.SerializePrettyCoT(posEvent)
+// connect creates the client selected by the flags and connects it. The connection
+// and the renewal of a client certificate given by file last until ctx is done.
+func (f *connectionFlags) connect(ctx context.Context, log *logrus.Logger) (tak.Client, error) {
+	config, err := f.clientConfig(log)
 	if err != nil {
-		log.WithError(err).Fatal("Failed to serialize CoT event")
+		return nil, err
 	}
 
-	// Print the cot event
-	log.Info("Sending CoT event:")
-	fmt.Println(string(posData))
-
-	log.Debug("Sending CoT event...")
-	if err := client.Send(posData); err != nil {
-		log.WithError(err).Fatal("Failed to send data")
-	}
-	log.Info("CoT event sent successfully")
-	// Wait for a response
-	if connType == tak.ConnectionTypeUDP {
-		log.Info("Waiting for response (UDP mode may not receive a response)...")
-	} else {
-		log.Info("Waiting for response...")
+	// Keep the client certificate current and warn before it expires
+	if config.ConnectionType == tak.ConnectionTypeTLS && config.CertFile != "" && *f.packageFile == "" {
+		// The server is verified against the name it was dialed by unless one is given
+		serverName := config.ServerName
+		if serverName == "" {
+			serverName = config.Address
+		}
+		manager, err := certmanager.New(certmanager.Config{
+			CertFile:   config.CertFile,
+			KeyFile:    config.KeyFile,
+			Password:   config.P12Password,
+			CAFile:     config.CAFile,
+			CAPassword: config.CAPassword,
+			ServerName: serverName,
+			SkipVerify: config.SkipTLSVerify,
+			Logger:     log,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.TLSConfig = manager.TLSConfig()
+		go manager.Run(ctx)
 	}
 
-	// Set a shorter timeout for UDP
-	responseTimeout := 30 * time.Second
-	if connType == tak.ConnectionTypeUDP {
-		responseTimeout = 5 * time.Second
+	client, err := tak.NewClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s client: %w", config.ConnectionType, err)
 	}
 
-	// If using multicast, update the receive timeout
-	if connType == tak.ConnectionTypeMulticast {
-		responseTimeout = 60 * time.Second
-		log.Info("Multicast mode: listening for 60 seconds...")
+	fields := logrus.Fields{"server": config.Address, "port": config.Port, "connection": config.ConnectionType}
+	if config.ConnectionType == tak.ConnectionTypeMulticast {
+		fields = logrus.Fields{"address": config.MulticastAddr, "port": config.MulticastPort, "connection": config.ConnectionType}
 	}
+	log.WithFields(fields).Info("Connecting...")
 
-	// Create context with timeout for receive operation
-	receiveCtx, receiveCancel := context.WithTimeout(context.Background(), responseTimeout)
-	defer receiveCancel()
-
-	// Use a channel to handle the receive operation
-	respChan := make(chan []byte)
-	errChan := make(chan error)
-
-	go func() {
-		resp, err := client.Receive()
-		if err != nil {
-			errChan <- err
-			return
-		}
-		respChan <- resp
-	}()
-
-	// Wait for response or timeout
-	select {
-	case response := <-respChan:
-		// Try to parse the response
-		responseEvent, err := xmlParser.ParseCoT(response)
-		if err != nil {
-			log.WithField("raw_data", string(response)).Warn("Received unparseable data")
-		} else {
-			log.WithFields(logrus.Fields{
-				"type": responseEvent.Type,
-				"uid":  responseEvent.UID,
-			}).Info("Received CoT event")
+	// The connection lives until ctx is done; DialTimeout bounds connecting
+	if err := client.Connect(ctx); err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	log.Info("Connected successfully")
+	return client, nil
+}
 
-			if log.IsLevelEnabled(logrus.DebugLevel) {
-				fmt.Printf("Full CoT event details: %+v\n", responseEvent)
-			}
-		}
-	case err := <-errChan:
-		log.WithError(err).Error("Error receiving data")
-	case <-receiveCtx.Done():
-		if connType == tak.ConnectionTypeUDP {
-			log.Info("No response received within timeout (expected for UDP)")
-		} else {
-			log.Fatal("No response received within timeout")
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
-
-	log.Info("Demonstration complete")
+	return items
 }
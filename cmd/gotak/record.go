@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/capture"
+	"github.com/angry-kivi/gotak/pkg/tak"
+	"github.com/sirupsen/logrus"
+)
+
+// runRecord implements the record subcommand, which writes the data received from a
+// connection to a capture file
+func runRecord(args []string) {
+	flags := flag.NewFlagSet("record", flag.ExitOnError)
+	conn := addConnectionFlags(flags, "gotak-recorder")
+	logLevel := addLogFlag(flags)
+	out := flags.String("o", "", "Capture file (defaults to gotak-<time>.cap)")
+	count := flags.Int("count", 0, "Stop after this many frames; 0 records until interrupted")
+	duration := flags.Duration("duration", 0, "Stop after this long; 0 records until interrupted")
+	note := flags.String("note", "", "Description stored in the capture metadata")
+	flags.Parse(args)
+
+	log := newLogger(*logLevel)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	conn.readTimeout = time.Second
+	config, err := conn.clientConfig(log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	metadata := capture.Metadata{
+		Created:        time.Now().UTC(),
+		ConnectionType: string(config.ConnectionType),
+		Address:        config.Address,
+		Port:           config.Port,
+		ClientID:       config.ClientID,
+		Note:           *note,
+	}
+	if config.ConnectionType == tak.ConnectionTypeMulticast {
+		metadata.Address, metadata.Port = config.MulticastAddr, config.MulticastPort
+	}
+
+	path := *out
+	if path == "" {
+		path = fmt.Sprintf("gotak-%s.cap", metadata.Created.Format("20060102-150405"))
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("Failed to create capture file: %v", err)
+	}
+	defer file.Close()
+	writer, err := capture.NewWriter(file, metadata)
+	if err != nil {
+		log.Fatalf("Failed to write capture file: %v", err)
+	}
+
+	client, err := conn.connect(ctx, log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Disconnect()
+
+	recorder := capture.NewRecorder(client, writer)
+	log.WithField("file", path).Info("Recording; press Ctrl+C to stop")
+	for ctx.Err() == nil && (*count <= 0 || recorder.Frames() < uint64(*count)) {
+		if _, err := recorder.Receive(); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() || errors.Is(err, tak.ErrMessageSkipped) {
+				continue
+			}
+			if ctx.Err() == nil {
+				log.WithError(err).Error("Stopped recording")
+			}
+			break
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		log.Fatalf("Failed to write capture file: %v", err)
+	}
+	log.WithFields(logrus.Fields{"file": path, "frames": recorder.Frames()}).Info("Stopped recording")
+}
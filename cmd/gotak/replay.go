@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/angry-kivi/gotak/pkg/capture"
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/sirupsen/logrus"
+)
+
+// runReplay implements the replay subcommand, which sends the frames of a capture file
+// with their recorded timing
+func runReplay(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: gotak replay [flags] <capture file>")
+		fmt.Fprintln(flags.Output(), "Sends the recorded frames with their original timing, scaled by -speed or -step apart.")
+		flags.PrintDefaults()
+	}
+	conn := addConnectionFlags(flags, "gotak-replay")
+	logLevel := addLogFlag(flags)
+	speed := flags.Float64("speed", 1, "Replay speed; 10 replays ten times faster")
+	step := flags.Duration("step", 0, "Send the frames this far apart instead of their recorded timing")
+	shiftTimes := flags.Bool("shift-times", false, "Move the time, start and stale of events to the moment they are sent")
+	offsetDistance := flags.Float64("offset-distance", 0, "Move positions by this many meters")
+	offsetBearing := flags.Float64("offset-bearing", 0, "Bearing in degrees to move positions along")
+	moveTo := flags.String("move-to", "", "Relocate the capture so that its first position is at lat,lon")
+	flags.Parse(args)
+
+	log := newLogger(*logLevel)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	config := capture.ReplayConfig{
+		Speed:      *speed,
+		Step:       *step,
+		ShiftTimes: *shiftTimes,
+		Offset:     capture.Offset{Distance: *offsetDistance, Bearing: *offsetBearing},
+		Logger:     log,
+	}
+	if *moveTo != "" {
+		point, err := parseLatLon(*moveTo)
+		if err != nil {
+			log.Fatalf("Invalid -move-to: %v", err)
+		}
+		config.MoveTo = &point
+	}
+	replayer, err := capture.NewReplayer(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to open capture file: %v", err)
+	}
+	defer file.Close()
+	reader, err := capture.NewReader(file)
+	if err != nil {
+		log.Fatalf("Failed to read capture file: %v", err)
+	}
+	metadata := reader.Metadata()
+	log.WithFields(logrus.Fields{
+		"created":    metadata.Created,
+		"connection": metadata.ConnectionType,
+		"address":    metadata.Address,
+		"port":       metadata.Port,
+		"note":       metadata.Note,
+	}).Info("Opened capture")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, err := conn.connect(ctx, log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Disconnect()
+
+	stats, err := replayer.Replay(ctx, reader, client)
+	if err != nil && ctx.Err() == nil {
+		log.WithError(err).Error("Replay stopped")
+	}
+	log.WithFields(logrus.Fields{
+		"frames":  stats.Frames,
+		"events":  stats.Events,
+		"invalid": stats.Invalid,
+	}).Info("Finished replay")
+}
+
+// parseLatLon parses a position given as lat,lon
+func parseLatLon(s string) (cot.Point, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return cot.Point{}, fmt.Errorf("%q is not lat,lon", s)
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return cot.Point{}, fmt.Errorf("invalid latitude %q", parts[0])
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return cot.Point{}, fmt.Errorf("invalid longitude %q", parts[1])
+	}
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return cot.Point{}, fmt.Errorf("position %q is out of range", s)
+	}
+	return cot.NewPoint(lat, lon), nil
+}
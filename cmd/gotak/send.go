@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/angry-kivi/gotak/pkg/parser"
+	"github.com/sirupsen/logrus"
+)
+
+// eventFlags describe a single event given on the command line
+var eventFlags = map[string]bool{
+	"type": true, "uid": true, "lat": true, "lon": true, "hae": true,
+	"how": true, "callsign": true, "remarks": true, "stale": true,
+}
+
+// runSend implements the send subcommand, which sends events read from files or stdin,
+// or a single event described by flags
+func runSend(args []string) {
+	flags := flag.NewFlagSet("send", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: gotak send [flags] [file ...]")
+		fmt.Fprintln(flags.Output(), "Sends the events of the files, or of stdin when no file is given and no event flag is set. '-' reads stdin.")
+		flags.PrintDefaults()
+	}
+	conn := addConnectionFlags(flags, "gotak-client")
+	logLevel := addLogFlag(flags)
+	format := flags.String("format", formatAuto, "Format of the input (auto, xml, proto, geojson)")
+	interval := flags.Duration("interval", 0, "Pause between events")
+	repeat := flags.Duration("repeat", 0, "Send the events again at this interval until interrupted, refreshing their times")
+
+	eventType := flags.String("type", "a-f-G-U-C", "Type of the event built from flags")
+	uid := flags.String("uid", "", "UID of the event built from flags (defaults to the client identifier)")
+	lat := flags.Float64("lat", 0, "Latitude of the event built from flags")
+	lon := flags.Float64("lon", 0, "Longitude of the event built from flags")
+	hae := flags.Float64("hae", cot.DefaultValue, "Height above ellipsoid of the event built from flags")
+	how := flags.String("how", "h-g-i-g-o", "How the position of the event built from flags was obtained")
+	callsign := flags.String("callsign", "", "Callsign of the event built from flags")
+	remarks := flags.String("remarks", "", "Remarks of the event built from flags")
+	stale := flags.Duration("stale", 10*time.Minute, "Time until the event built from flags is stale")
+	flags.Parse(args)
+
+	log := newLogger(*logLevel)
+
+	fromFlags := false
+	flags.Visit(func(f *flag.Flag) {
+		fromFlags = fromFlags || eventFlags[f.Name]
+	})
+
+	var events []*cot.Event
+	switch {
+	case flags.NArg() > 0:
+		var err error
+		if events, err = readEvents(flags.Args(), *format, "format"); err != nil {
+			log.Fatalf("Failed to read events: %v", err)
+		}
+	case fromFlags:
+		id := *uid
+		if id == "" {
+			id = *conn.clientID
+		}
+		event := cot.NewEvent(*eventType, id)
+		event.SetHow(*how)
+		event.SetStale(event.Time.Add(*stale).Time())
+		point := cot.NewPoint(*lat, *lon)
+		point.SetHae(*hae).SetCe(cot.DefaultValue).SetLe(cot.DefaultValue)
+		event.SetPoint(point)
+		if *callsign != "" {
+			event.Detail.AddContact(*callsign)
+		}
+		if *remarks != "" {
+			event.Detail.Remarks = &cot.Remarks{Text: *remarks}
+		}
+		events = []*cot.Event{event}
+	default:
+		var err error
+		if events, err = readEvents([]string{"-"}, *format, "format"); err != nil {
+			log.Fatalf("Failed to read events: %v", err)
+		}
+	}
+	if len(events) == 0 {
+		log.Fatal("No events to send")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, err := conn.connect(ctx, log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Disconnect()
+
+	xmlParser := parser.NewXMLParser()
+	for {
+		for i, event := range events {
+			if i > 0 && *interval > 0 {
+				select {
+				case <-time.After(*interval):
+				case <-ctx.Done():
+					return
+				}
+			}
+			data, err := xmlParser.SerializeCoT(event)
+			if err != nil {
+				log.WithError(err).WithField("uid", event.UID).Error("Failed to serialize event")
+				continue
+			}
+			if err := client.Send(data); err != nil {
+				log.WithError(err).Fatal("Failed to send event")
+			}
+			log.WithFields(logrus.Fields{"type": event.Type, "uid": event.UID}).Info("Sent event")
+		}
+		if *repeat <= 0 {
+			return
+		}
+
+		select {
+		case <-time.After(*repeat):
+		case <-ctx.Done():
+			return
+		}
+		for _, event := range events {
+			refreshTimes(event, time.Now())
+		}
+	}
+}
+
+// refreshTimes moves the time, start and stale of an event to now, keeping the time
+// until it is stale
+func refreshTimes(event *cot.Event, now time.Time) {
+	shift := now.Sub(event.Time.Time())
+	event.SetTime(now)
+	event.SetStart(event.Start.Time().Add(shift))
+	event.SetStale(event.Stale.Time().Add(shift))
+}
@@ -10,6 +10,7 @@ require (
 	golang.org/x/crypto v0.36.0
 	golang.org/x/sys v0.31.0
 	google.golang.org/protobuf v1.36.5
+	software.sslmate.com/src/go-pkcs12 v0.7.3
 )
 
 require (
@@ -0,0 +1,292 @@
+// Package bridge relays CoT events between two TAK clients, such as a mesh multicast
+// group and a TAK server, with loop prevention and per-direction filtering.
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/angry-kivi/gotak/pkg/filter"
+	"github.com/angry-kivi/gotak/pkg/parser"
+	"github.com/angry-kivi/gotak/pkg/tak"
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults for the bridge configuration
+const (
+	DefaultMaxHops   = 8
+	DefaultDedupTime = time.Minute
+)
+
+// Format is the encoding of events sent to a client
+type Format int
+
+// Formats of forwarded events
+const (
+	// FormatXML sends CoT XML events
+	FormatXML Format = iota
+	// FormatMesh sends TAK protocol messages with the mesh header, for UDP and multicast
+	FormatMesh
+	// FormatStream sends TAK protocol messages with the streaming header, for TCP and TLS
+	FormatStream
+)
+
+// Route configures the traffic forwarded in one direction
+type Route struct {
+	// Filter selects the events forwarded; nil forwards all events
+	Filter filter.Filter
+	// UIDPrefix is prepended to the UID of forwarded events
+	UIDPrefix string
+	// Rewrite modifies forwarded events after filtering. It returns false to drop the event.
+	Rewrite func(e *cot.Event) bool
+	// Format is the encoding the receiving client expects; events are received in
+	// either encoding
+	Format Format
+}
+
+// Config holds the configuration of a bridge
+type Config struct {
+	// ID identifies the bridge in flow tags; it must be unique among connected bridges
+	ID string
+	// AToB and BToA configure the two directions
+	AToB Route
+	BToA Route
+	// MaxHops drops events that have passed through more relays
+	MaxHops int
+	// DedupTime is how long a forwarded event is remembered to stop it from returning
+	// through the other direction when relays strip flow tags
+	DedupTime time.Duration
+
+	Logger logrus.FieldLogger
+}
+
+// Stats counts the events handled in one direction
+type Stats struct {
+	Forwarded uint64
+	Filtered  uint64
+	Looped    uint64
+	Invalid   uint64
+}
+
+// direction is the state of one direction of the bridge
+type direction struct {
+	name  string
+	from  tak.Client
+	to    tak.Client
+	route Route
+	// back is the opposite direction, whose forwarded events must not return
+	back *direction
+
+	forwarded atomic.Uint64
+	filtered  atomic.Uint64
+	looped    atomic.Uint64
+	invalid   atomic.Uint64
+
+	mu        sync.Mutex
+	sent      map[string]time.Time
+	lastPrune time.Time
+}
+
+// Bridge forwards events between two clients in both directions
+type Bridge struct {
+	config Config
+	aToB   *direction
+	bToA   *direction
+	parser *parser.XMLParser
+	proto  *parser.ProtoParser
+	now    func() time.Time
+}
+
+// New creates a bridge between two connected clients
+func New(a, b tak.Client, config Config) (*Bridge, error) {
+	if config.ID == "" {
+		return nil, errors.New("bridge ID is required")
+	}
+	if config.MaxHops <= 0 {
+		config.MaxHops = DefaultMaxHops
+	}
+	if config.DedupTime <= 0 {
+		config.DedupTime = DefaultDedupTime
+	}
+	if config.Logger == nil {
+		config.Logger = logrus.StandardLogger()
+	}
+
+	br := &Bridge{
+		config: config,
+		aToB:   &direction{name: "a->b", from: a, to: b, route: config.AToB, sent: make(map[string]time.Time)},
+		bToA:   &direction{name: "b->a", from: b, to: a, route: config.BToA, sent: make(map[string]time.Time)},
+		parser: parser.NewXMLParser(),
+		proto:  parser.NewProtoParser(),
+		now:    time.Now,
+	}
+	br.aToB.back, br.bToA.back = br.bToA, br.aToB
+	return br, nil
+}
+
+// Run forwards events in both directions until the context is canceled or a client
+// fails. Receives in progress are not interrupted; disconnect the clients to end them.
+func (br *Bridge) Run(ctx context.Context) error {
+	errs := make(chan error, 2)
+	for _, d := range []*direction{br.aToB, br.bToA} {
+		go func(d *direction) {
+			errs <- br.pump(ctx, d)
+		}(d)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errs:
+		return err
+	}
+}
+
+// pump receives from one client and forwards to the other
+func (br *Bridge) pump(ctx context.Context, d *direction) error {
+	var splitter parser.EventSplitter
+	for ctx.Err() == nil {
+		data, err := d.from.Receive()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() || errors.Is(err, tak.ErrMessageSkipped) {
+				continue
+			}
+			return fmt.Errorf("%s: receive: %w", d.name, err)
+		}
+		for _, raw := range splitter.Feed(data) {
+			out, ok := br.forward(d, raw)
+			if !ok {
+				continue
+			}
+			if err := d.to.Send(out); err != nil {
+				return fmt.Errorf("%s: send: %w", d.name, err)
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// forward applies loop prevention, the route filter and rewriting to a received event
+// and returns the data to send
+func (br *Bridge) forward(d *direction, raw []byte) ([]byte, bool) {
+	var e *cot.Event
+	var err error
+	if len(raw) > 0 && raw[0] == 0xbf {
+		e, err = br.proto.ParseCoT(raw)
+	} else {
+		e, err = br.parser.ParseCoT(raw)
+	}
+	if err != nil {
+		d.invalid.Add(1)
+		br.config.Logger.WithError(err).Debugf("%s: dropping unparseable event", d.name)
+		return nil, false
+	}
+
+	if br.looped(d, e) {
+		d.looped.Add(1)
+		br.config.Logger.WithField("uid", e.UID).Tracef("%s: dropping looped event", d.name)
+		return nil, false
+	}
+	if d.route.Filter != nil && !d.route.Filter(e) {
+		d.filtered.Add(1)
+		return nil, false
+	}
+
+	if tags := e.Detail.FlowTags; tags != nil {
+		tags.AddHop(br.config.ID)
+	} else {
+		e.Detail.AddFlowTags(br.config.ID).AddHop(br.config.ID)
+	}
+	e.UID = d.route.UIDPrefix + e.UID
+	if d.route.Rewrite != nil && !d.route.Rewrite(e) {
+		d.filtered.Add(1)
+		return nil, false
+	}
+
+	out, err := br.serialize(e, d.route.Format)
+	if err != nil {
+		d.invalid.Add(1)
+		br.config.Logger.WithError(err).Warnf("%s: failed to serialize event", d.name)
+		return nil, false
+	}
+	d.remember(e, br.now(), br.config.DedupTime)
+	d.forwarded.Add(1)
+	return out, true
+}
+
+// serialize encodes an event in the given format
+func (br *Bridge) serialize(e *cot.Event, format Format) ([]byte, error) {
+	switch format {
+	case FormatMesh:
+		return br.proto.SerializeMeshCoT(e)
+	case FormatStream:
+		return br.proto.SerializeStreamCoT(e)
+	default:
+		return br.parser.SerializeCoT(e)
+	}
+}
+
+// looped reports whether an event has already passed through the bridge
+func (br *Bridge) looped(d *direction, e *cot.Event) bool {
+	if tags := e.Detail.FlowTags; tags != nil {
+		if tags.From == br.config.ID || len(tags.Hops) >= br.config.MaxHops {
+			return true
+		}
+		for _, hop := range tags.Hops {
+			if hop == br.config.ID {
+				return true
+			}
+		}
+	}
+	// Events whose flow tags were stripped are recognized by what was just sent back
+	return d.back.recentlySent(e, br.now())
+}
+
+// eventKey identifies one report of an item
+func eventKey(e *cot.Event) string {
+	return e.UID + "|" + e.Type + "|" + cot.FormatCotTime(e.Time.Time())
+}
+
+// remember records a forwarded event and prunes expired records
+func (d *direction) remember(e *cot.Event, now time.Time, ttl time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if now.Sub(d.lastPrune) > time.Second {
+		for key, expires := range d.sent {
+			if now.After(expires) {
+				delete(d.sent, key)
+			}
+		}
+		d.lastPrune = now
+	}
+	d.sent[eventKey(e)] = now.Add(ttl)
+}
+
+// recentlySent reports whether the event was forwarded in this direction recently
+func (d *direction) recentlySent(e *cot.Event, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	expires, ok := d.sent[eventKey(e)]
+	return ok && !now.After(expires)
+}
+
+func (d *direction) stats() Stats {
+	return Stats{
+		Forwarded: d.forwarded.Load(),
+		Filtered:  d.filtered.Load(),
+		Looped:    d.looped.Load(),
+		Invalid:   d.invalid.Load(),
+	}
+}
+
+// Stats returns the counts of events handled from a to b and from b to a
+func (br *Bridge) Stats() (aToB, bToA Stats) {
+	return br.aToB.stats(), br.bToA.stats()
+}
@@ -0,0 +1,240 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/angry-kivi/gotak/pkg/filter"
+	"github.com/angry-kivi/gotak/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClient delivers queued data and records sent data
+type fakeClient struct {
+	incoming chan []byte
+	mu       sync.Mutex
+	sent     [][]byte
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{incoming: make(chan []byte, 16)}
+}
+
+func (c *fakeClient) Connect(ctx context.Context) error { return nil }
+func (c *fakeClient) Disconnect() error                 { close(c.incoming); return nil }
+func (c *fakeClient) IsConnected() bool                 { return true }
+
+func (c *fakeClient) Send(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, data)
+	return nil
+}
+
+func (c *fakeClient) Receive() ([]byte, error) {
+	data, ok := <-c.incoming
+	if !ok {
+		return nil, errors.New("disconnected")
+	}
+	return data, nil
+}
+
+func (c *fakeClient) sentEvents(t *testing.T) []*cot.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var events []*cot.Event
+	for _, data := range c.sent {
+		e, err := parser.NewXMLParser().ParseCoT(data)
+		require.NoError(t, err)
+		events = append(events, e)
+	}
+	return events
+}
+
+func eventData(t *testing.T, eventType, uid string, tags *cot.FlowTags) []byte {
+	e := cot.NewEvent(eventType, uid)
+	e.SetTime(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	e.Detail.AddContact(uid)
+	e.Detail.FlowTags = tags
+	data, err := parser.NewXMLParser().SerializeCoT(e)
+	require.NoError(t, err)
+	return data
+}
+
+func newTestBridge(t *testing.T, config Config) *Bridge {
+	if config.ID == "" {
+		config.ID = "bridge-1"
+	}
+	br, err := New(newFakeClient(), newFakeClient(), config)
+	require.NoError(t, err)
+	return br
+}
+
+func TestNewRequiresID(t *testing.T) {
+	_, err := New(newFakeClient(), newFakeClient(), Config{})
+	assert.Error(t, err)
+}
+
+func TestForwardAddsFlowTags(t *testing.T) {
+	br := newTestBridge(t, Config{AToB: Route{UIDPrefix: "mesh-"}})
+
+	out, ok := br.forward(br.aToB, eventData(t, "a-f-G", "unit-1", nil))
+	require.True(t, ok)
+
+	e, err := parser.NewXMLParser().ParseCoT(out)
+	require.NoError(t, err)
+	assert.Equal(t, "mesh-unit-1", e.UID)
+	require.NotNil(t, e.Detail.FlowTags)
+	assert.Equal(t, "bridge-1", e.Detail.FlowTags.From)
+	assert.Equal(t, []string{"bridge-1"}, e.Detail.FlowTags.Hops)
+	assert.Equal(t, "unit-1", e.Detail.Contact.Callsign)
+
+	existing := &cot.FlowTags{From: "atak-1", MessageID: 4, Timestamp: 1}
+	out, ok = br.forward(br.aToB, eventData(t, "a-f-G", "unit-2", existing))
+	require.True(t, ok)
+	e, err = parser.NewXMLParser().ParseCoT(out)
+	require.NoError(t, err)
+	assert.Equal(t, "atak-1", e.Detail.FlowTags.From)
+	assert.Equal(t, []string{"bridge-1"}, e.Detail.FlowTags.Hops)
+}
+
+func TestForwardPreventsLoops(t *testing.T) {
+	br := newTestBridge(t, Config{MaxHops: 2})
+
+	tests := []struct {
+		name string
+		tags *cot.FlowTags
+	}{
+		{"from this bridge", &cot.FlowTags{From: "bridge-1"}},
+		{"through this bridge", &cot.FlowTags{From: "atak-1", Hops: []string{"bridge-2", "bridge-1"}}},
+		{"too many hops", &cot.FlowTags{From: "atak-1", Hops: []string{"bridge-2", "bridge-3"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok := br.forward(br.aToB, eventData(t, "a-f-G", "unit", tt.tags))
+			assert.False(t, ok)
+		})
+	}
+
+	// An event forwarded one way is not sent back even with its flow tags stripped
+	_, ok := br.forward(br.aToB, eventData(t, "a-f-G", "echo", nil))
+	require.True(t, ok)
+	_, ok = br.forward(br.bToA, eventData(t, "a-f-G", "echo", nil))
+	assert.False(t, ok)
+
+	// The dedup window expires
+	br.now = func() time.Time { return time.Now().Add(2 * DefaultDedupTime) }
+	_, ok = br.forward(br.bToA, eventData(t, "a-f-G", "echo", nil))
+	assert.True(t, ok)
+
+	aToB, bToA := br.Stats()
+	assert.Equal(t, uint64(1), aToB.Forwarded)
+	assert.Equal(t, uint64(3), aToB.Looped)
+	assert.Equal(t, uint64(1), bToA.Looped)
+	assert.Equal(t, uint64(1), bToA.Forwarded)
+}
+
+func TestForwardFiltersAndRewrites(t *testing.T) {
+	br := newTestBridge(t, Config{
+		AToB: Route{Filter: filter.TypePrefix("a-h-")},
+		BToA: Route{Rewrite: func(e *cot.Event) bool {
+			e.Detail.Contact.Callsign = "relayed " + e.Detail.Contact.Callsign
+			return e.UID != "secret"
+		}},
+	})
+
+	_, ok := br.forward(br.aToB, eventData(t, "a-f-G", "friendly", nil))
+	assert.False(t, ok)
+	_, ok = br.forward(br.aToB, eventData(t, "a-h-G", "hostile", nil))
+	assert.True(t, ok)
+
+	out, ok := br.forward(br.bToA, eventData(t, "a-f-G", "unit", nil))
+	require.True(t, ok)
+	e, err := parser.NewXMLParser().ParseCoT(out)
+	require.NoError(t, err)
+	assert.Equal(t, "relayed unit", e.Detail.Contact.Callsign)
+	_, ok = br.forward(br.bToA, eventData(t, "a-f-G", "secret", nil))
+	assert.False(t, ok)
+
+	_, ok = br.forward(br.bToA, []byte("<event><broken"))
+	assert.False(t, ok)
+
+	aToB, bToA := br.Stats()
+	assert.Equal(t, Stats{Forwarded: 1, Filtered: 1}, aToB)
+	assert.Equal(t, Stats{Forwarded: 1, Filtered: 1, Invalid: 1}, bToA)
+}
+
+func TestRun(t *testing.T) {
+	a, b := newFakeClient(), newFakeClient()
+	br, err := New(a, b, Config{ID: "bridge-1"})
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() { done <- br.Run(context.Background()) }()
+
+	// One event split across two reads and one sent back from the other side
+	data := eventData(t, "a-f-G", "unit-1", nil)
+	a.incoming <- data[:40]
+	a.incoming <- data[40:]
+	b.incoming <- eventData(t, "a-f-G", "unit-2", nil)
+
+	assert.Eventually(t, func() bool {
+		return len(b.sentEvents(t)) == 1 && len(a.sentEvents(t)) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "unit-1", b.sentEvents(t)[0].UID)
+	assert.Equal(t, "unit-2", a.sentEvents(t)[0].UID)
+
+	a.Disconnect()
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("bridge did not stop")
+	}
+	b.Disconnect()
+}
+
+func TestForwardProto(t *testing.T) {
+	br := newTestBridge(t, Config{AToB: Route{Format: FormatStream}, BToA: Route{Format: FormatMesh}})
+	proto := parser.NewProtoParser()
+
+	e := cot.NewEvent("a-f-G", "unit-1")
+	e.SetTime(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	e.Detail.AddContact("unit-1")
+	mesh, err := proto.SerializeMeshCoT(e)
+	require.NoError(t, err)
+
+	// A mesh message goes out as a streamed message carrying the flow tags
+	out, ok := br.forward(br.aToB, mesh)
+	require.True(t, ok)
+	frames, err := parser.SplitProtoStream(out)
+	require.NoError(t, err)
+	require.Len(t, frames, 1)
+	relayed, err := proto.ParseCoT(frames[0])
+	require.NoError(t, err)
+	assert.Equal(t, "unit-1", relayed.UID)
+	assert.Equal(t, "unit-1", relayed.Detail.Contact.Callsign)
+	require.NotNil(t, relayed.Detail.FlowTags)
+	assert.Equal(t, []string{"bridge-1"}, relayed.Detail.FlowTags.Hops)
+
+	// The relayed message is recognized when it comes back
+	_, ok = br.forward(br.bToA, out)
+	assert.False(t, ok)
+
+	// XML events are sent to a mesh destination with the mesh header
+	out, ok = br.forward(br.bToA, eventData(t, "a-f-G", "unit-2", nil))
+	require.True(t, ok)
+	assert.Equal(t, []byte{0xbf, 0x01, 0xbf}, out[:3])
+	relayed, err = proto.ParseCoT(out)
+	require.NoError(t, err)
+	assert.Equal(t, "unit-2", relayed.UID)
+
+	aToB, bToA := br.Stats()
+	assert.Equal(t, Stats{Forwarded: 1}, aToB)
+	assert.Equal(t, Stats{Forwarded: 1, Looped: 1}, bToA)
+}
@@ -0,0 +1,166 @@
+// Package capture records the data received by TAK clients to capture files and
+// replays captures with their original, scaled or stepped timing.
+//
+// A capture file starts with the magic "GOTAKCAP", a format version byte and the
+// connection metadata as a length-prefixed JSON object. Frames follow, each with the
+// receive time in Unix nanoseconds (int64), the data length (uint32) and the raw data
+// as returned by Receive. All integers are big-endian.
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Magic starts every capture file
+const Magic = "GOTAKCAP"
+
+// Version is the format version written by Writer
+const Version = 1
+
+// MaxFrameSize bounds the frames read from a capture
+const MaxFrameSize = 16 << 20
+
+var (
+	// ErrInvalidCapture is returned for data that is not a capture
+	ErrInvalidCapture = errors.New("not a capture file")
+	// ErrTruncated is returned when a capture ends inside a frame
+	ErrTruncated = errors.New("capture is truncated")
+	// ErrFrameTooLarge is returned for frames larger than MaxFrameSize
+	ErrFrameTooLarge = errors.New("capture frame is too large")
+)
+
+// Metadata describes the connection a capture was recorded from
+type Metadata struct {
+	// Created is when recording started
+	Created        time.Time `json:"created"`
+	ConnectionType string    `json:"connection_type,omitempty"`
+	Address        string    `json:"address,omitempty"`
+	Port           int       `json:"port,omitempty"`
+	ClientID       string    `json:"client_id,omitempty"`
+	// Note is a free text description, e.g. the exercise the capture belongs to
+	Note string `json:"note,omitempty"`
+}
+
+// Frame is the data of one Receive call and when it returned
+type Frame struct {
+	Time time.Time
+	Data []byte
+}
+
+// frameHeaderSize is the size of the time and length in front of frame data
+const frameHeaderSize = 12
+
+// Writer writes a capture. It is safe for concurrent use.
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriter writes the capture header with the metadata and returns a writer for the
+// frames. A zero Created time is set to now.
+func NewWriter(w io.Writer, metadata Metadata) (*Writer, error) {
+	if metadata.Created.IsZero() {
+		metadata.Created = time.Now().UTC()
+	}
+	meta, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, len(Magic)+5+len(meta))
+	header = append(header, Magic...)
+	header = append(header, Version)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(meta)))
+	header = append(header, meta...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w}, nil
+}
+
+// WriteFrame appends a frame. Each frame is written with a single Write, so a capture
+// cut off by a crash loses at most the frame being written.
+func (w *Writer) WriteFrame(frame Frame) error {
+	if len(frame.Data) > MaxFrameSize {
+		return ErrFrameTooLarge
+	}
+	buf := make([]byte, frameHeaderSize, frameHeaderSize+len(frame.Data))
+	binary.BigEndian.PutUint64(buf, uint64(frame.Time.UnixNano()))
+	binary.BigEndian.PutUint32(buf[8:], uint32(len(frame.Data)))
+	buf = append(buf, frame.Data...)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.w.Write(buf)
+	return err
+}
+
+// Reader reads a capture
+type Reader struct {
+	r        *bufio.Reader
+	metadata Metadata
+}
+
+// NewReader reads the capture header and returns a reader for the frames
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(Magic)+5)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, ErrInvalidCapture
+	}
+	if string(header[:len(Magic)]) != Magic {
+		return nil, ErrInvalidCapture
+	}
+	if version := header[len(Magic)]; version != Version {
+		return nil, fmt.Errorf("unsupported capture version %d", version)
+	}
+
+	size := binary.BigEndian.Uint32(header[len(Magic)+1:])
+	if size > MaxFrameSize {
+		return nil, ErrInvalidCapture
+	}
+	meta := make([]byte, size)
+	if _, err := io.ReadFull(br, meta); err != nil {
+		return nil, ErrTruncated
+	}
+	reader := &Reader{r: br}
+	if err := json.Unmarshal(meta, &reader.metadata); err != nil {
+		return nil, fmt.Errorf("invalid capture metadata: %w", err)
+	}
+	return reader, nil
+}
+
+// Metadata returns the metadata of the capture
+func (r *Reader) Metadata() Metadata {
+	return r.metadata
+}
+
+// ReadFrame returns the next frame, or io.EOF at the end of the capture
+func (r *Reader) ReadFrame() (Frame, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r.r, header[:]); err == io.EOF {
+		return Frame{}, io.EOF
+	} else if err != nil {
+		return Frame{}, ErrTruncated
+	}
+
+	size := binary.BigEndian.Uint32(header[8:])
+	if size > MaxFrameSize {
+		return Frame{}, ErrFrameTooLarge
+	}
+	frame := Frame{
+		Time: time.Unix(0, int64(binary.BigEndian.Uint64(header[:8]))),
+		Data: make([]byte, size),
+	}
+	if _, err := io.ReadFull(r.r, frame.Data); err != nil {
+		return Frame{}, ErrTruncated
+	}
+	return frame, nil
+}
@@ -0,0 +1,132 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteAndReadCapture(t *testing.T) {
+	var buf bytes.Buffer
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	w, err := NewWriter(&buf, Metadata{Created: created, ConnectionType: "multicast", Address: "239.2.3.1", Port: 6969, Note: "exercise"})
+	require.NoError(t, err)
+
+	frames := []Frame{
+		{Time: created.Add(time.Second), Data: []byte(`<event uid="1"/>`)},
+		{Time: created.Add(1500 * time.Millisecond), Data: []byte{0xbf, 0x01, 0xbf, 0x12}},
+	}
+	for _, f := range frames {
+		require.NoError(t, w.WriteFrame(f))
+	}
+
+	r, err := NewReader(&buf)
+	require.NoError(t, err)
+	meta := r.Metadata()
+	assert.True(t, created.Equal(meta.Created))
+	assert.Equal(t, "multicast", meta.ConnectionType)
+	assert.Equal(t, 6969, meta.Port)
+	assert.Equal(t, "exercise", meta.Note)
+
+	for _, expected := range frames {
+		f, err := r.ReadFrame()
+		require.NoError(t, err)
+		assert.True(t, expected.Time.Equal(f.Time))
+		assert.Equal(t, expected.Data, f.Data)
+	}
+	_, err = r.ReadFrame()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Metadata{ConnectionType: "tcp"})
+	require.NoError(t, err)
+	client := &fakeClient{received: [][]byte{[]byte(`<event uid="1">`), []byte(`</event>`)}}
+	recorder := NewRecorder(client, w)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	recorder.now = func() time.Time { now = now.Add(time.Second); return now }
+
+	for _, expected := range []string{`<event uid="1">`, `</event>`} {
+		data, err := recorder.Receive()
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(data))
+	}
+	_, err = recorder.Receive()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, uint64(2), recorder.Frames())
+
+	r, err := NewReader(&buf)
+	require.NoError(t, err)
+	f, err := r.ReadFrame()
+	require.NoError(t, err)
+	assert.Equal(t, `<event uid="1">`, string(f.Data))
+	assert.Equal(t, 12*time.Hour+time.Second, f.Time.UTC().Sub(f.Time.UTC().Truncate(24*time.Hour)))
+	f, err = r.ReadFrame()
+	require.NoError(t, err)
+	assert.Equal(t, `</event>`, string(f.Data))
+
+	// Sending goes to the wrapped client
+	require.NoError(t, recorder.Send([]byte("out")))
+	assert.Equal(t, [][]byte{[]byte("out")}, client.sent)
+}
+
+func TestRecorderWriteFailure(t *testing.T) {
+	w := &Writer{w: failingWriter{}}
+	recorder := NewRecorder(&fakeClient{received: [][]byte{[]byte("data")}}, w)
+
+	_, err := recorder.Receive()
+	assert.ErrorContains(t, err, "disk full")
+	assert.Zero(t, recorder.Frames())
+}
+
+func TestReadInvalidCapture(t *testing.T) {
+	_, err := NewReader(bytes.NewReader([]byte("<event/>")))
+	assert.ErrorIs(t, err, ErrInvalidCapture)
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Metadata{})
+	require.NoError(t, err)
+	require.NoError(t, w.WriteFrame(Frame{Time: time.Now(), Data: []byte("0123456789")}))
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()[:buf.Len()-3]))
+	require.NoError(t, err)
+	_, err = r.ReadFrame()
+	assert.ErrorIs(t, err, ErrTruncated)
+}
+
+// fakeClient returns queued data from Receive and records sent data
+type fakeClient struct {
+	received [][]byte
+	sent     [][]byte
+}
+
+func (c *fakeClient) Connect(ctx context.Context) error { return nil }
+
+func (c *fakeClient) Receive() ([]byte, error) {
+	if len(c.received) == 0 {
+		return nil, io.EOF
+	}
+	data := c.received[0]
+	c.received = c.received[1:]
+	return data, nil
+}
+
+func (c *fakeClient) Send(data []byte) error {
+	c.sent = append(c.sent, append([]byte(nil), data...))
+	return nil
+}
+
+func (c *fakeClient) Disconnect() error { return nil }
+func (c *fakeClient) IsConnected() bool { return true }
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
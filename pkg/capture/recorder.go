@@ -0,0 +1,51 @@
+package capture
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/tak"
+)
+
+// Recorder wraps a client and records the data returned by its Receive. All other
+// methods are passed to the wrapped client unchanged.
+type Recorder struct {
+	tak.Client
+	writer *Writer
+	frames atomic.Uint64
+	now    func() time.Time
+}
+
+// NewRecorder wraps a client, writing received data as frames to writer
+func NewRecorder(client tak.Client, writer *Writer) *Recorder {
+	return &Recorder{
+		Client: client,
+		writer: writer,
+		now:    time.Now,
+	}
+}
+
+// Receive returns the next received data after recording it. A failure to record is
+// returned as error, so that a capture does not silently miss data.
+func (r *Recorder) Receive() ([]byte, error) {
+	data, err := r.Client.Receive()
+	if err != nil || len(data) == 0 {
+		return data, err
+	}
+	if err := r.writer.WriteFrame(Frame{Time: r.now(), Data: data}); err != nil {
+		return nil, fmt.Errorf("failed to record frame: %w", err)
+	}
+	r.frames.Add(1)
+	return data, nil
+}
+
+// Frames returns the number of frames recorded
+func (r *Recorder) Frames() uint64 {
+	return r.frames.Load()
+}
+
+// Unwrap returns the wrapped client
+func (r *Recorder) Unwrap() tak.Client {
+	return r.Client
+}
@@ -0,0 +1,257 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/angry-kivi/gotak/pkg/parser"
+	"github.com/angry-kivi/gotak/pkg/tak"
+	"github.com/sirupsen/logrus"
+)
+
+// meshHeader starts TAK protocol messages sent to the mesh
+var meshHeader = []byte{0xbf, 0x01, 0xbf}
+
+// Offset moves positions by a distance in meters along a bearing in degrees
+type Offset struct {
+	Distance float64
+	Bearing  float64
+}
+
+// IsZero reports whether the offset leaves positions unchanged
+func (o Offset) IsZero() bool {
+	return o.Distance == 0
+}
+
+// Apply returns the point moved by the offset
+func (o Offset) Apply(p cot.Point) cot.Point {
+	if o.IsZero() {
+		return p
+	}
+	return p.Destination(o.Distance, o.Bearing)
+}
+
+// OffsetBetween returns the offset that moves from to to
+func OffsetBetween(from, to cot.Point) Offset {
+	return Offset{Distance: from.DistanceTo(to), Bearing: from.BearingTo(to)}
+}
+
+// ReplayConfig configures a Replayer
+type ReplayConfig struct {
+	// Speed scales the recorded timing: 10 replays ten times faster, 0.5 at half
+	// speed. Zero replays at the original speed.
+	Speed float64
+	// Step, when positive, sends the frames at this fixed interval instead of their
+	// recorded timing
+	Step time.Duration
+
+	// ShiftTimes moves the time, start and stale of each event to the moment it is
+	// sent, keeping the time until it is stale
+	ShiftTimes bool
+	// Offset moves the positions of all events
+	Offset Offset
+	// MoveTo, when set, replaces Offset with the offset that moves the position of the
+	// first event with a position to MoveTo, relocating the whole capture
+	MoveTo *cot.Point
+
+	Logger logrus.FieldLogger
+}
+
+// ReplayStats counts what a replay sent
+type ReplayStats struct {
+	Frames uint64
+	// Events counts the events rewritten; it stays zero when frames are sent unchanged
+	Events uint64
+	// Invalid counts events that could not be parsed for rewriting; they are sent as
+	// recorded
+	Invalid uint64
+}
+
+// Replayer sends the frames of captures to a client
+type Replayer struct {
+	config ReplayConfig
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// NewReplayer creates a replayer with the given configuration
+func NewReplayer(config ReplayConfig) (*Replayer, error) {
+	if config.Speed < 0 {
+		return nil, errors.New("replay speed must not be negative")
+	}
+	if config.Speed == 0 {
+		config.Speed = 1
+	}
+	if config.Logger == nil {
+		logger := logrus.New()
+		logger.SetOutput(io.Discard)
+		config.Logger = logger
+	}
+	return &Replayer{config: config, now: time.Now, sleep: sleep}, nil
+}
+
+// Replay sends the frames of a capture to client until the capture ends or ctx is done.
+// The first frame is sent at once and the following ones at their recorded distance,
+// scaled by Speed, or Step apart.
+func (r *Replayer) Replay(ctx context.Context, reader *Reader, client tak.Client) (ReplayStats, error) {
+	var stats ReplayStats
+	rw := rewriter{config: r.config, now: r.now, stats: &stats, offset: r.config.Offset}
+	if r.config.MoveTo != nil {
+		rw.offset = Offset{}
+	}
+
+	var start, first time.Time
+	for {
+		frame, err := reader.ReadFrame()
+		if err == io.EOF {
+			return stats, nil
+		} else if err != nil {
+			return stats, err
+		}
+
+		if stats.Frames == 0 {
+			start, first = r.now(), frame.Time
+		} else {
+			var due time.Time
+			if r.config.Step > 0 {
+				due = start.Add(time.Duration(stats.Frames) * r.config.Step)
+			} else {
+				due = start.Add(time.Duration(float64(frame.Time.Sub(first)) / r.config.Speed))
+			}
+			if err := r.sleep(ctx, due.Sub(r.now())); err != nil {
+				return stats, err
+			}
+		}
+
+		data := rw.rewrite(frame.Data)
+		stats.Frames++
+		if len(data) == 0 {
+			continue
+		}
+		if err := client.Send(data); err != nil {
+			return stats, fmt.Errorf("failed to send frame %d: %w", stats.Frames, err)
+		}
+		r.config.Logger.WithFields(logrus.Fields{
+			"frame":    stats.Frames,
+			"recorded": frame.Time,
+			"bytes":    len(data),
+		}).Debug("Replayed frame")
+	}
+}
+
+// rewriter applies the time and position changes of a replay to frames
+type rewriter struct {
+	config   ReplayConfig
+	now      func() time.Time
+	stats    *ReplayStats
+	offset   Offset
+	located  bool
+	splitter parser.EventSplitter
+}
+
+// rewrite returns the data to send for a recorded frame. Frames are sent as whole
+// events, so data of an event split across frames is sent with the frame completing it.
+func (rw *rewriter) rewrite(data []byte) []byte {
+	if !rw.config.ShiftTimes && rw.config.Offset.IsZero() && rw.config.MoveTo == nil {
+		return data
+	}
+
+	var out bytes.Buffer
+	for _, raw := range rw.splitter.Feed(data) {
+		rw.stats.Events++
+		var rewritten []byte
+		var err error
+		if raw[0] == 0xbf {
+			rewritten, err = rw.rewriteProto(raw)
+		} else {
+			rewritten, err = rw.rewriteXML(raw)
+		}
+		if err != nil {
+			rw.stats.Invalid++
+			out.Write(raw)
+			continue
+		}
+		out.Write(rewritten)
+	}
+	return out.Bytes()
+}
+
+// rewriteXML rewrites a CoT XML event
+func (rw *rewriter) rewriteXML(data []byte) ([]byte, error) {
+	xmlParser := parser.NewXMLParser()
+	event, err := xmlParser.ParseCoT(data)
+	if err != nil {
+		return nil, err
+	}
+	rw.apply(event)
+	return xmlParser.SerializeCoT(event)
+}
+
+// rewriteProto rewrites a TAK protocol message, keeping its mesh or streaming header
+func (rw *rewriter) rewriteProto(data []byte) ([]byte, error) {
+	protoParser := parser.NewProtoParser()
+	event, err := protoParser.ParseCoT(data)
+	if err != nil {
+		return nil, err
+	}
+	rw.apply(event)
+
+	if bytes.HasPrefix(data, meshHeader) {
+		return protoParser.SerializeMeshCoT(event)
+	}
+	return protoParser.SerializeStreamCoT(event)
+}
+
+// apply changes the times and positions of an event
+func (rw *rewriter) apply(event *cot.Event) {
+	if rw.config.ShiftTimes {
+		now := rw.now()
+		shift := now.Sub(event.Time.Time())
+		event.SetTime(now)
+		event.SetStart(event.Start.Time().Add(shift))
+		event.SetStale(event.Stale.Time().Add(shift))
+	}
+
+	if rw.config.MoveTo != nil && !rw.located && (event.Point.Lat != 0 || event.Point.Lon != 0) {
+		rw.offset = OffsetBetween(event.Point, *rw.config.MoveTo)
+		rw.located = true
+	}
+	if rw.offset.IsZero() {
+		return
+	}
+
+	event.Point = rw.offset.Apply(event.Point)
+	for _, link := range event.Detail.Links {
+		if p, err := link.LatLon(); err == nil {
+			link.SetLatLon(rw.offset.Apply(p))
+		}
+	}
+	if shape := event.Detail.Shape; shape != nil && shape.Polyline != nil {
+		if points, err := shape.Polyline.Coordinates(); err == nil {
+			for i := range points {
+				points[i] = rw.offset.Apply(points[i])
+			}
+			shape.Polyline.SetCoordinates(points)
+		}
+	}
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,199 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/angry-kivi/gotak/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var recorded = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// newCapture writes frames recorded at the given offsets from recorded
+func newCapture(t *testing.T, data [][]byte, offsets []time.Duration) *Reader {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Metadata{})
+	require.NoError(t, err)
+	for i := range data {
+		require.NoError(t, w.WriteFrame(Frame{Time: recorded.Add(offsets[i]), Data: data[i]}))
+	}
+	r, err := NewReader(&buf)
+	require.NoError(t, err)
+	return r
+}
+
+// fakeClock is a clock that sleeping advances
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) install(r *Replayer) {
+	r.now = func() time.Time { return c.now }
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		c.sleeps = append(c.sleeps, d)
+		c.now = c.now.Add(d)
+		return nil
+	}
+}
+
+func eventXML(t *testing.T, uid string, lat, lon float64) []byte {
+	event := cot.NewEvent("a-f-G-U-C", uid)
+	event.SetTime(recorded).SetStart(recorded).SetStale(recorded.Add(5 * time.Minute))
+	event.SetPoint(cot.NewPoint(lat, lon))
+	data, err := parser.NewXMLParser().SerializeCoT(event)
+	require.NoError(t, err)
+	return data
+}
+
+func TestReplayTiming(t *testing.T) {
+	data := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	offsets := []time.Duration{0, 2 * time.Second, 10 * time.Second}
+
+	testCases := []struct {
+		name     string
+		config   ReplayConfig
+		expected []time.Duration
+	}{
+		{"original", ReplayConfig{}, []time.Duration{2 * time.Second, 8 * time.Second}},
+		{"scaled", ReplayConfig{Speed: 10}, []time.Duration{200 * time.Millisecond, 800 * time.Millisecond}},
+		{"stepped", ReplayConfig{Step: time.Second}, []time.Duration{time.Second, time.Second}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			replayer, err := NewReplayer(tc.config)
+			require.NoError(t, err)
+			clock := &fakeClock{now: time.Now()}
+			clock.install(replayer)
+			client := &fakeClient{}
+
+			stats, err := replayer.Replay(context.Background(), newCapture(t, data, offsets), client)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, clock.sleeps)
+			assert.Equal(t, data, client.sent, "frames are sent unchanged without rewriting")
+			assert.Equal(t, uint64(3), stats.Frames)
+		})
+	}
+}
+
+func TestReplayShiftsTimesAndPositions(t *testing.T) {
+	origin := cot.NewPoint(59.0, 18.0)
+	moveTo := cot.NewPoint(60.0, 20.0)
+	first := eventXML(t, "a", origin.Lat, origin.Lon)
+	second := eventXML(t, "b", 59.01, 18.0)
+	// The second event is split across two reads, as on a stream connection
+	data := [][]byte{first, second[:40], second[40:]}
+
+	replayer, err := NewReplayer(ReplayConfig{ShiftTimes: true, MoveTo: &moveTo})
+	require.NoError(t, err)
+	clock := &fakeClock{now: time.Date(2026, 6, 1, 8, 0, 0, 0, time.UTC)}
+	clock.install(replayer)
+	client := &fakeClient{}
+
+	stats, err := replayer.Replay(context.Background(), newCapture(t, data, []time.Duration{0, time.Second, time.Second}), client)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), stats.Events)
+	assert.Zero(t, stats.Invalid)
+	require.Len(t, client.sent, 2)
+
+	xmlParser := parser.NewXMLParser()
+	a, err := xmlParser.ParseCoT(client.sent[0])
+	require.NoError(t, err)
+	assert.True(t, a.Time.Time().Equal(time.Date(2026, 6, 1, 8, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 5*time.Minute, a.Stale.Time().Sub(a.Time.Time()))
+	assert.InDelta(t, 60.0, a.Point.Lat, 1e-6)
+	assert.InDelta(t, 20.0, a.Point.Lon, 1e-6)
+
+	b, err := xmlParser.ParseCoT(client.sent[1])
+	require.NoError(t, err)
+	assert.True(t, b.Time.Time().Equal(time.Date(2026, 6, 1, 8, 0, 1, 0, time.UTC)))
+	// Positions keep their distance to the first event
+	assert.InDelta(t, origin.DistanceTo(cot.NewPoint(59.01, 18.0)), a.Point.DistanceTo(b.Point), 1)
+}
+
+func TestReplayOffsetMovesDrawings(t *testing.T) {
+	polygon, err := cot.NewPolygon("poly-1", "Area", []cot.Point{
+		cot.NewPoint(59.0, 18.0), cot.NewPoint(59.1, 18.0), cot.NewPoint(59.1, 18.1),
+	}, cot.DefaultDrawingStyle())
+	require.NoError(t, err)
+	data, err := parser.NewProtoParser().SerializeCoT(polygon)
+	require.NoError(t, err)
+	mesh := append([]byte{0xbf, 0x01, 0xbf}, data...)
+
+	replayer, err := NewReplayer(ReplayConfig{Offset: Offset{Distance: 1000, Bearing: 0}})
+	require.NoError(t, err)
+	client := &fakeClient{}
+	_, err = replayer.Replay(context.Background(), newCapture(t, [][]byte{mesh}, []time.Duration{0}), client)
+	require.NoError(t, err)
+
+	require.Len(t, client.sent, 1)
+	assert.True(t, bytes.HasPrefix(client.sent[0], []byte{0xbf, 0x01, 0xbf}), "the mesh header is kept")
+	moved, err := parser.NewProtoParser().ParseCoT(client.sent[0])
+	require.NoError(t, err)
+	vertices, err := moved.Vertices()
+	require.NoError(t, err)
+	require.Len(t, vertices, 4)
+	assert.InDelta(t, 1000, cot.NewPoint(59.0, 18.0).DistanceTo(vertices[0]), 0.01)
+	assert.InDelta(t, 0, cot.NewPoint(59.0, 18.0).BearingTo(vertices[0]), 1e-6)
+}
+
+func TestReplayRewritesProtoStream(t *testing.T) {
+	protoParser := parser.NewProtoParser()
+	var stream []byte
+	for i, uid := range []string{"a", "b", "c"} {
+		event, err := parser.NewXMLParser().ParseCoT(eventXML(t, uid, 59.0+float64(i)/100, 18.0))
+		require.NoError(t, err)
+		message, err := protoParser.SerializeStreamCoT(event)
+		require.NoError(t, err)
+		stream = append(stream, message...)
+	}
+	// Two whole messages and the start of the third in one read, the rest in the next
+	cut := len(stream) - 20
+	data := [][]byte{stream[:cut], stream[cut:]}
+
+	replayer, err := NewReplayer(ReplayConfig{Offset: Offset{Distance: 1000, Bearing: 90}})
+	require.NoError(t, err)
+	client := &fakeClient{}
+	stats, err := replayer.Replay(context.Background(), newCapture(t, data, []time.Duration{0, 0}), client)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), stats.Events)
+	assert.Zero(t, stats.Invalid)
+
+	require.Len(t, client.sent, 2)
+	var uids []string
+	for _, sent := range client.sent {
+		messages, err := parser.SplitProtoStream(sent)
+		require.NoError(t, err)
+		for _, message := range messages {
+			moved, err := protoParser.ParseCoT(message)
+			require.NoError(t, err)
+			assert.InDelta(t, 1000, cot.NewPoint(59.0+float64(len(uids))/100, 18.0).DistanceTo(moved.Point), 0.01)
+			uids = append(uids, moved.UID)
+		}
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, uids)
+}
+
+func TestReplayStopsWithContext(t *testing.T) {
+	replayer, err := NewReplayer(ReplayConfig{})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client := &fakeClient{}
+	_, err = replayer.Replay(ctx, newCapture(t, [][]byte{[]byte("a"), []byte("b")}, []time.Duration{0, time.Hour}), client)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, client.sent, 1)
+}
+
+func TestNewReplayerRejectsNegativeSpeed(t *testing.T) {
+	_, err := NewReplayer(ReplayConfig{Speed: -1})
+	assert.Error(t, err)
+}
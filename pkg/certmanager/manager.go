@@ -0,0 +1,375 @@
+// Package certmanager keeps a client certificate current: it reloads rotated certificate
+// files, warns before the certificate expires and can re-enroll with the TAK Server.
+//
+// The TLS configuration it provides selects the certificate with GetClientCertificate
+// and verifies the server against the current CA pool, so new handshakes use rotated
+// files while established connections are left alone.
+package certmanager
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/enrollment"
+	"github.com/angry-kivi/gotak/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults for the manager configuration
+const (
+	DefaultCheckInterval = time.Minute
+	DefaultWarnBefore    = 7 * 24 * time.Hour
+	DefaultRenewBefore   = 3 * 24 * time.Hour
+)
+
+// Config holds the files and timing of a certificate manager
+type Config struct {
+	// CertFile is a PEM certificate or a .p12/.pfx file holding the key and CA chain
+	CertFile string
+	// KeyFile is the PEM key, not needed for .p12 files
+	KeyFile string
+	// Password protects a .p12 file
+	Password string
+	// CAFile is an optional PEM file or .p12 truststore of CA certificates to trust
+	CAFile string
+	// CAPassword protects a .p12 truststore given as CAFile
+	CAPassword string
+	// ServerName is the name or IP address the server certificate is verified against,
+	// usually the host dialed. Without it the name sent for SNI is used, and servers
+	// dialed by IP address are refused.
+	ServerName string
+	// SkipVerify disables verification of the server certificate
+	SkipVerify bool
+
+	// CheckInterval is how often Run looks for rotated files and expiry
+	CheckInterval time.Duration
+	// WarnBefore is how long before expiry warnings start
+	WarnBefore time.Duration
+
+	// Enrollment, when set, re-enrolls RenewBefore the certificate expires and stores
+	// the new certificate in the configured files
+	Enrollment  *enrollment.Config
+	RenewBefore time.Duration
+
+	// OnExpiring is called once per certificate when it comes within WarnBefore of expiry,
+	// in addition to the logged warning
+	OnExpiring func(cert *x509.Certificate, remaining time.Duration)
+	// OnReload is called after a rotated certificate is loaded
+	OnReload func(cert *x509.Certificate)
+
+	Logger logrus.FieldLogger
+}
+
+// Manager holds the current client certificate. It is safe for concurrent use.
+type Manager struct {
+	config Config
+	now    func() time.Time
+
+	mu     sync.RWMutex
+	cert   *tls.Certificate
+	roots  *x509.CertPool
+	digest [sha256.Size]byte
+	warned *x509.Certificate
+}
+
+// New loads the certificate files and returns a manager for them
+func New(config Config) (*Manager, error) {
+	if config.CertFile == "" {
+		return nil, errors.New("certmanager: certificate file is required")
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = DefaultCheckInterval
+	}
+	if config.WarnBefore <= 0 {
+		config.WarnBefore = DefaultWarnBefore
+	}
+	if config.RenewBefore <= 0 {
+		config.RenewBefore = DefaultRenewBefore
+	}
+	if config.Logger == nil {
+		config.Logger = logrus.StandardLogger()
+	}
+
+	m := &Manager{config: config, now: time.Now}
+	if _, err := m.Reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// isP12 reports whether the certificate file is a PKCS#12 file
+func (m *Manager) isP12() bool {
+	return isP12File(m.config.CertFile)
+}
+
+// isP12File reports whether a file name has a PKCS#12 extension
+func isP12File(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".p12" || ext == ".pfx"
+}
+
+// files returns the certificate files that are in use
+func (m *Manager) files() []string {
+	files := []string{m.config.CertFile}
+	if !m.isP12() && m.config.KeyFile != "" {
+		files = append(files, m.config.KeyFile)
+	}
+	if m.config.CAFile != "" {
+		files = append(files, m.config.CAFile)
+	}
+	return files
+}
+
+// Reload loads the certificate files if their content changed and reports whether a
+// new certificate was loaded. On error the current certificate is kept.
+func (m *Manager) Reload() (bool, error) {
+	h := sha256.New()
+	for _, file := range m.files() {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return false, fmt.Errorf("certmanager: %w", err)
+		}
+		h.Write(data)
+	}
+	var digest [sha256.Size]byte
+	copy(digest[:], h.Sum(nil))
+
+	m.mu.RLock()
+	unchanged := m.cert != nil && digest == m.digest
+	m.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	loaded, err := util.NewTLSConfig(util.TLSOptions{
+		CertFile:   m.config.CertFile,
+		KeyFile:    m.config.KeyFile,
+		Password:   m.config.Password,
+		CAFile:     m.config.CAFile,
+		CAPassword: m.config.CAPassword,
+		SkipVerify: m.config.SkipVerify,
+	})
+	if err != nil {
+		return false, fmt.Errorf("certmanager: %w", err)
+	}
+	if len(loaded.Certificates) == 0 {
+		return false, errors.New("certmanager: no client certificate loaded")
+	}
+	cert := loaded.Certificates[0]
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return false, fmt.Errorf("certmanager: %w", err)
+		}
+	}
+
+	m.mu.Lock()
+	previous := m.cert
+	m.cert, m.roots, m.digest = &cert, loaded.RootCAs, digest
+	m.mu.Unlock()
+
+	if previous != nil {
+		m.config.Logger.WithFields(logrus.Fields{
+			"subject": cert.Leaf.Subject.String(),
+			"expires": cert.Leaf.NotAfter,
+		}).Info("Loaded rotated client certificate")
+		if m.config.OnReload != nil {
+			m.config.OnReload(cert.Leaf)
+		}
+	}
+	return true, nil
+}
+
+// Certificate returns the current client certificate
+func (m *Manager) Certificate() *x509.Certificate {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cert.Leaf
+}
+
+// TLSConfig returns a TLS configuration that always presents the current certificate
+// and verifies servers against the current CA pool and the configured server name
+func (m *Manager) TLSConfig() *tls.Config {
+	return &tls.Config{
+		ServerName: m.config.ServerName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			m.mu.RLock()
+			defer m.mu.RUnlock()
+			return m.cert, nil
+		},
+		// The CA pool may change, so verification is done in VerifyConnection
+		InsecureSkipVerify: true,
+		VerifyConnection:   m.verifyConnection,
+	}
+}
+
+// verifyConnection verifies the server certificate chain against the current roots and
+// its DNS or IP address SANs against the server name
+func (m *Manager) verifyConnection(state tls.ConnectionState) error {
+	if m.config.SkipVerify {
+		return nil
+	}
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("certmanager: server presented no certificate")
+	}
+
+	// state.ServerName is the name sent for SNI, which is empty for IP addresses
+	serverName := m.config.ServerName
+	if serverName == "" {
+		serverName = state.ServerName
+	}
+	if serverName == "" {
+		return errors.New("certmanager: no server name to verify the server certificate against")
+	}
+
+	m.mu.RLock()
+	roots := m.roots
+	m.mu.RUnlock()
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
+// Check warns when the certificate is close to expiry and re-enrolls when it is due.
+// It returns the time left before the certificate expires.
+func (m *Manager) Check(ctx context.Context) time.Duration {
+	cert := m.Certificate()
+	remaining := cert.NotAfter.Sub(m.now())
+
+	if remaining <= m.config.WarnBefore {
+		m.mu.Lock()
+		first := m.warned != cert
+		m.warned = cert
+		m.mu.Unlock()
+		if first {
+			m.config.Logger.WithFields(logrus.Fields{
+				"subject": cert.Subject.String(),
+				"expires": cert.NotAfter,
+			}).Warnf("Client certificate expires in %s", remaining.Round(time.Minute))
+			if m.config.OnExpiring != nil {
+				m.config.OnExpiring(cert, remaining)
+			}
+		}
+	}
+
+	if m.config.Enrollment != nil && remaining <= m.config.RenewBefore {
+		if err := m.Renew(ctx); err != nil {
+			m.config.Logger.WithError(err).Error("Failed to re-enroll client certificate")
+		}
+	}
+	return remaining
+}
+
+// Renew re-enrolls with the TAK Server, stores the new certificate in the configured
+// files and loads it
+func (m *Manager) Renew(ctx context.Context) error {
+	if m.config.Enrollment == nil {
+		return errors.New("certmanager: enrollment is not configured")
+	}
+	result, err := enrollment.Enroll(ctx, *m.config.Enrollment)
+	if err != nil {
+		return err
+	}
+
+	if m.isP12() {
+		data, err := result.P12(m.config.Password)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(m.config.CertFile, data, 0600); err != nil {
+			return err
+		}
+	} else if err := m.writePEM(result); err != nil {
+		return err
+	}
+
+	_, err = m.Reload()
+	return err
+}
+
+// writePEM stores an enrollment result in the configured PEM files
+func (m *Manager) writePEM(result *enrollment.Result) error {
+	dir, err := os.MkdirTemp(filepath.Dir(m.config.CertFile), ".renew-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// A .p12 truststore is kept; only PEM CA files are replaced
+	certFile, keyFile, caFile := filepath.Join(dir, "cert"), filepath.Join(dir, "key"), ""
+	if m.config.CAFile != "" && !isP12File(m.config.CAFile) {
+		caFile = filepath.Join(dir, "ca")
+	}
+	if err := result.WritePEM(certFile, keyFile, caFile); err != nil {
+		return err
+	}
+
+	// Replace the key first; a reload between the renames fails and is retried
+	renames := [][2]string{{keyFile, m.config.KeyFile}, {certFile, m.config.CertFile}}
+	if caFile != "" {
+		renames = append(renames, [2]string{caFile, m.config.CAFile})
+	}
+	for _, r := range renames {
+		if err := os.Rename(r[0], r[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic replaces a file so readers see either the old or the new content
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Run reloads rotated files and checks expiry every CheckInterval until the context is
+// canceled
+func (m *Manager) Run(ctx context.Context) {
+	m.Check(ctx)
+	ticker := time.NewTicker(m.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := m.Reload(); err != nil {
+				m.config.Logger.WithError(err).Warn("Failed to reload client certificate")
+			}
+			m.Check(ctx)
+		}
+	}
+}
@@ -0,0 +1,406 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/enrollment"
+	"github.com/angry-kivi/gotak/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA issues short-lived certificates for the tests
+type testCA struct {
+	key    *ecdsa.PrivateKey
+	cert   *x509.Certificate
+	serial int64
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{key: key, cert: cert, serial: 1}
+}
+
+// issue returns a certificate for the public key valid for the given duration
+func (ca *testCA) issue(t *testing.T, name string, pub any, validity time.Duration, usage x509.ExtKeyUsage) *x509.Certificate {
+	ca.serial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(ca.serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(validity),
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, pub, ca.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+// writeClientPEM writes a fresh client certificate, key and the CA to PEM files
+func (ca *testCA) writeClientPEM(t *testing.T, dir, name string, validity time.Duration) (certFile, keyFile, caFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	cert := ca.issue(t, name, &key.PublicKey, validity, x509.ExtKeyUsageClientAuth)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	certFile, keyFile, caFile = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key"), filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600))
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0600))
+	return certFile, keyFile, caFile
+}
+
+func TestNewRequiresCertificate(t *testing.T) {
+	_, err := New(Config{})
+	assert.Error(t, err)
+
+	_, err = New(Config{CertFile: filepath.Join(t.TempDir(), "missing.pem"), KeyFile: "missing.key"})
+	assert.Error(t, err)
+}
+
+func TestReloadRotatedCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile, caFile := ca.writeClientPEM(t, dir, "bot-1", time.Hour)
+
+	var reloaded []string
+	m, err := New(Config{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+		CAFile:   caFile,
+		OnReload: func(cert *x509.Certificate) { reloaded = append(reloaded, cert.Subject.CommonName) },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "bot-1", m.Certificate().Subject.CommonName)
+
+	changed, err := m.Reload()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	ca.writeClientPEM(t, dir, "bot-1-rotated", 30*24*time.Hour)
+	changed, err = m.Reload()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"bot-1-rotated"}, reloaded)
+
+	cert, err := m.TLSConfig().GetClientCertificate(&tls.CertificateRequestInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, "bot-1-rotated", cert.Leaf.Subject.CommonName)
+}
+
+func TestReloadKeepsCertificateOnError(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile, _ := ca.writeClientPEM(t, t.TempDir(), "bot-1", time.Hour)
+	m, err := New(Config{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("partially written"), 0600))
+	_, err = m.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, "bot-1", m.Certificate().Subject.CommonName)
+}
+
+func TestHandshakeUsesRotatedCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serverCert := ca.issue(t, "tak-server", &serverKey.PublicKey, time.Hour, x509.ExtKeyUsageServerAuth)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.Raw}, PrivateKey: serverKey}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	clients := make(chan string, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			tlsConn := conn.(*tls.Conn)
+			if tlsConn.Handshake() == nil {
+				clients <- tlsConn.ConnectionState().PeerCertificates[0].Subject.CommonName
+			}
+			conn.Close()
+		}
+	}()
+
+	dir := t.TempDir()
+	certFile, keyFile, caFile := ca.writeClientPEM(t, dir, "bot-1", time.Hour)
+	m, err := New(Config{CertFile: certFile, KeyFile: keyFile, CAFile: caFile, ServerName: "127.0.0.1"})
+	require.NoError(t, err)
+	config := m.TLSConfig()
+
+	dial := func() string {
+		conn, err := tls.Dial("tcp", listener.Addr().String(), config.Clone())
+		require.NoError(t, err)
+		require.NoError(t, conn.Handshake())
+		defer conn.Close()
+		select {
+		case name := <-clients:
+			return name
+		case <-time.After(5 * time.Second):
+			t.Fatal("server did not complete the handshake")
+			return ""
+		}
+	}
+
+	assert.Equal(t, "bot-1", dial())
+	ca.writeClientPEM(t, dir, "bot-1-rotated", time.Hour)
+	_, err = m.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, "bot-1-rotated", dial())
+}
+
+func TestVerifyRejectsUnknownServer(t *testing.T) {
+	ca := newTestCA(t)
+	other := newTestCA(t)
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serverCert := other.issue(t, "tak-server", &serverKey.PublicKey, time.Hour, x509.ExtKeyUsageServerAuth)
+
+	certFile, keyFile, caFile := ca.writeClientPEM(t, t.TempDir(), "bot-1", time.Hour)
+	m, err := New(Config{CertFile: certFile, KeyFile: keyFile, CAFile: caFile})
+	require.NoError(t, err)
+
+	err = m.verifyConnection(tls.ConnectionState{ServerName: "127.0.0.1", PeerCertificates: []*x509.Certificate{serverCert}})
+	assert.Error(t, err)
+
+	m.config.SkipVerify = true
+	err = m.verifyConnection(tls.ConnectionState{ServerName: "127.0.0.1", PeerCertificates: []*x509.Certificate{serverCert}})
+	assert.NoError(t, err)
+}
+
+func TestVerifyChecksServerName(t *testing.T) {
+	ca := newTestCA(t)
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	// The server certificate is issued for 127.0.0.1 only
+	serverCert := ca.issue(t, "tak-server", &serverKey.PublicKey, time.Hour, x509.ExtKeyUsageServerAuth)
+
+	certFile, keyFile, caFile := ca.writeClientPEM(t, t.TempDir(), "bot-1", time.Hour)
+	tests := []struct {
+		name       string
+		serverName string
+		sni        string
+		valid      bool
+	}{
+		{"configured IP address", "127.0.0.1", "", true},
+		{"other IP address", "10.0.0.1", "", false},
+		{"host name not in SANs", "tak.example.com", "", false},
+		{"SNI not in SANs", "", "tak.example.com", false},
+		{"no server name", "", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := New(Config{CertFile: certFile, KeyFile: keyFile, CAFile: caFile, ServerName: test.serverName})
+			require.NoError(t, err)
+
+			err = m.verifyConnection(tls.ConnectionState{ServerName: test.sni, PeerCertificates: []*x509.Certificate{serverCert}})
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestTrustStoreCA(t *testing.T) {
+	ca := newTestCA(t)
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serverCert := ca.issue(t, "tak-server", &serverKey.PublicKey, time.Hour, x509.ExtKeyUsageServerAuth)
+
+	dir := t.TempDir()
+	certFile, keyFile, _ := ca.writeClientPEM(t, dir, "bot-1", time.Hour)
+	trustStore, err := util.EncodeTrustStoreP12([]*x509.Certificate{ca.cert}, "trust-pass")
+	require.NoError(t, err)
+	caFile := filepath.Join(dir, "truststore.p12")
+	require.NoError(t, os.WriteFile(caFile, trustStore, 0600))
+
+	_, err = New(Config{CertFile: certFile, KeyFile: keyFile, CAFile: caFile, CAPassword: "wrong", ServerName: "127.0.0.1"})
+	assert.Error(t, err)
+
+	m, err := New(Config{CertFile: certFile, KeyFile: keyFile, CAFile: caFile, CAPassword: "trust-pass", ServerName: "127.0.0.1"})
+	require.NoError(t, err)
+	err = m.verifyConnection(tls.ConnectionState{PeerCertificates: []*x509.Certificate{serverCert}})
+	assert.NoError(t, err)
+}
+
+func TestCheckWarnsOncePerCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile, _ := ca.writeClientPEM(t, dir, "bot-1", 30*time.Minute)
+
+	var warnings []time.Duration
+	m, err := New(Config{
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		WarnBefore: time.Hour,
+		OnExpiring: func(cert *x509.Certificate, remaining time.Duration) {
+			assert.Equal(t, "bot-1", cert.Subject.CommonName)
+			warnings = append(warnings, remaining)
+		},
+	})
+	require.NoError(t, err)
+
+	m.Check(context.Background())
+	m.Check(context.Background())
+	require.Len(t, warnings, 1)
+	assert.InDelta(t, 30*time.Minute, warnings[0], float64(time.Minute))
+
+	// A certificate far from expiry does not warn
+	ca.writeClientPEM(t, dir, "bot-1", 30*24*time.Hour)
+	_, err = m.Reload()
+	require.NoError(t, err)
+	remaining := m.Check(context.Background())
+	assert.Len(t, warnings, 1)
+	assert.Greater(t, remaining, 29*24*time.Hour)
+
+	// Expiry is measured against the manager's clock
+	m.now = func() time.Time { return time.Now().Add(30 * 24 * time.Hour) }
+	m.Check(context.Background())
+	assert.Len(t, warnings, 2)
+}
+
+// newEnrollmentServer is a minimal TAK Server enrollment API signing with the test CA
+func newEnrollmentServer(t *testing.T, ca *testCA) enrollment.Config {
+	mux := http.NewServeMux()
+	mux.HandleFunc(enrollment.ConfigPath, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `<certificateConfig validityDays="30"><nameEntries><nameEntry name="O" value="TAK"/></nameEntries></certificateConfig>`)
+	})
+	mux.HandleFunc(enrollment.SignClientPath, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		der, err := base64.StdEncoding.DecodeString(string(body))
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		cert := ca.issue(t, csr.Subject.CommonName+"-renewed", csr.PublicKey, 30*24*time.Hour, x509.ExtKeyUsageClientAuth)
+		json.NewEncoder(w).Encode(map[string]string{
+			"signedCert": base64.StdEncoding.EncodeToString(cert.Raw),
+			"ca0":        base64.StdEncoding.EncodeToString(ca.cert.Raw),
+		})
+	})
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	host, port, err := net.SplitHostPort(u.Host)
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	return enrollment.Config{
+		Address:   host,
+		Port:      portNumber,
+		Username:  "bot-1",
+		Password:  "secret",
+		ClientUID: "ANDROID-bot-1",
+		TLSConfig: &tls.Config{RootCAs: roots},
+		KeyBits:   1024,
+	}
+}
+
+func TestRenewP12(t *testing.T) {
+	ca := newTestCA(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	cert := ca.issue(t, "bot-1", &key.PublicKey, time.Hour, x509.ExtKeyUsageClientAuth)
+	p12, err := util.EncodeP12(key, cert, []*x509.Certificate{ca.cert}, "atakatak")
+	require.NoError(t, err)
+	p12File := filepath.Join(t.TempDir(), "bot-1.p12")
+	require.NoError(t, os.WriteFile(p12File, p12, 0600))
+
+	enroll := newEnrollmentServer(t, ca)
+	m, err := New(Config{
+		CertFile:    p12File,
+		Password:    "atakatak",
+		Enrollment:  &enroll,
+		RenewBefore: 2 * time.Hour,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "bot-1", m.Certificate().Subject.CommonName)
+
+	m.Check(context.Background())
+	assert.Equal(t, "bot-1-renewed", m.Certificate().Subject.CommonName)
+
+	// The renewed certificate was stored and is not due again
+	loaded, err := util.LoadTLSConfigFromP12(p12File, "atakatak", "", false)
+	require.NoError(t, err)
+	assert.Equal(t, m.Certificate().Raw, loaded.Certificates[0].Certificate[0])
+	m.Check(context.Background())
+	assert.Equal(t, "bot-1-renewed", m.Certificate().Subject.CommonName)
+}
+
+func TestRenewPEM(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile, caFile := ca.writeClientPEM(t, t.TempDir(), "bot-1", time.Hour)
+
+	enroll := newEnrollmentServer(t, ca)
+	m, err := New(Config{CertFile: certFile, KeyFile: keyFile, CAFile: caFile, Enrollment: &enroll})
+	require.NoError(t, err)
+
+	require.NoError(t, m.Renew(context.Background()))
+	assert.Equal(t, "bot-1-renewed", m.Certificate().Subject.CommonName)
+
+	changed, err := m.Reload()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	err = (&Manager{config: Config{}}).Renew(context.Background())
+	assert.Error(t, err)
+}
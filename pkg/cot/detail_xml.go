@@ -0,0 +1,59 @@
+package cot
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+)
+
+// mappedElements holds the names of the detail children that have a field
+var mappedElements = func() map[string]bool {
+	names := make(map[string]bool)
+	t := reflect.TypeOf(Detail{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("xml"), ",")
+		if name != "" && name != "detail" {
+			names[name] = true
+		}
+	}
+	return names
+}()
+
+// MarshalXML writes the detail fields followed by the unmapped elements of RawXML, so
+// a parsed event is marshaled without duplicated elements
+func (d Detail) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type detail Detail
+	out := detail(d)
+	out.RawXML = unmappedXML(d.RawXML)
+	start.Name = xml.Name{Local: "detail"}
+	return e.EncodeElement(out, start)
+}
+
+// unmappedXML returns the top-level elements of raw detail XML that have no field
+func unmappedXML(raw []byte) []byte {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var result bytes.Buffer
+	decoder := xml.NewDecoder(bytes.NewReader(raw))
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if err := decoder.Skip(); err != nil {
+			break
+		}
+		if !mappedElements[start.Name.Local] {
+			result.Write(raw[offset:decoder.InputOffset()])
+		}
+	}
+	return result.Bytes()
+}
@@ -0,0 +1,141 @@
+package cot
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDetailRoundTripKeepsUnmappedElements(t *testing.T) {
+	// Given
+	input := `<detail><contact callsign="X"/><__custom a="1"><inner/></__custom><link uid="u1"/><marti><dest callsign="Y"/></marti></detail>`
+	var detail Detail
+	if err := xml.Unmarshal([]byte(input), &detail); err != nil {
+		t.Fatalf("Failed to unmarshal Detail: %v", err)
+	}
+
+	// When
+	detail.Contact.Callsign = "Z"
+	data, err := xml.Marshal(detail)
+	if err != nil {
+		t.Fatalf("Failed to marshal Detail to XML: %v", err)
+	}
+
+	// Then
+	expected := `<detail><contact callsign="Z"></contact><link uid="u1"></link><__custom a="1"><inner/></__custom><marti><dest callsign="Y"/></marti></detail>`
+	if string(data) != expected {
+		t.Errorf("Marshaled XML does not match expected.\nGot: %s\nExpected: %s", string(data), expected)
+	}
+}
+
+// detailChildren returns the top-level elements of the detail in event XML as sorted
+// strings of the name and attributes. Numbers and booleans are normalized and empty
+// attributes left out, as the fields write them differently but with the same meaning.
+// With distinct, repeats of elements a single field holds collapse into one.
+func detailChildren(t *testing.T, data []byte, distinct bool) []string {
+	t.Helper()
+	lists := make(map[string]bool)
+	detailType := reflect.TypeOf(Detail{})
+	for i := 0; i < detailType.NumField(); i++ {
+		if field := detailType.Field(i); field.Type.Kind() == reflect.Slice {
+			name, _, _ := strings.Cut(field.Tag.Get("xml"), ",")
+			lists[name] = true
+		}
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	seen := make(map[string]bool)
+	var children []string
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch tok := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth != 3 {
+				continue
+			}
+			var attrs []string
+			for _, a := range tok.Attr {
+				v := a.Value
+				if v == "" {
+					continue
+				}
+				switch v {
+				case "true":
+					v = "1"
+				case "false":
+					v = "0"
+				}
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					v = strconv.FormatFloat(f, 'g', -1, 64)
+				}
+				attrs = append(attrs, a.Name.Local+"="+v)
+			}
+			sort.Strings(attrs)
+			child := tok.Name.Local + " " + strings.Join(attrs, " ")
+			if !distinct || lists[tok.Name.Local] || !seen[child] {
+				seen[child] = true
+				children = append(children, child)
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	sort.Strings(children)
+	return children
+}
+
+func TestDetailRoundTripExamples(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "doc", "examples", "*.cot"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("No examples found: %v", err)
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			// Given
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("Failed to read example: %v", err)
+			}
+			var event Event
+			if err := xml.Unmarshal(data, &event); err != nil {
+				t.Fatalf("Failed to unmarshal example: %v", err)
+			}
+
+			// When
+			first, err := xml.Marshal(&event)
+			if err != nil {
+				t.Fatalf("Failed to marshal event: %v", err)
+			}
+			var parsed Event
+			if err := xml.Unmarshal(first, &parsed); err != nil {
+				t.Fatalf("Failed to unmarshal marshaled event: %v", err)
+			}
+			second, err := xml.Marshal(&parsed)
+			if err != nil {
+				t.Fatalf("Failed to marshal event: %v", err)
+			}
+
+			// Then every element is written once, mapped or not. Repeats in the examples,
+			// such as a second archive element, collapse into the field.
+			got, expected := detailChildren(t, first, false), detailChildren(t, data, true)
+			if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+				t.Errorf("Detail elements not correct.\nGot:\n%s\nExpected:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+			}
+			if !bytes.Equal(first, second) {
+				t.Errorf("Marshaling is not stable.\nFirst: %s\nSecond: %s", first, second)
+			}
+		})
+	}
+}
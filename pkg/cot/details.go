@@ -13,13 +13,34 @@ type Detail struct {
 	Remarks           *Remarks           `xml:"remarks,omitempty" json:"remarks,omitempty"`
 	Status            *Status            `xml:"status,omitempty" json:"status,omitempty"`
 	Takv              *Takv              `xml:"takv,omitempty" json:"takv,omitempty"`
+	Group             *Group             `xml:"__group,omitempty" json:"group,omitempty"`
 	Track             *Track             `xml:"track,omitempty" json:"track,omitempty"`
 	PrecisionLocation *PrecisionLocation `xml:"precisionlocation,omitempty" json:"precisionlocation,omitempty"`
 	Shape             *Shape             `xml:"shape,omitempty" json:"shape,omitempty"`
+	UserIcon          *UserIcon          `xml:"usericon,omitempty" json:"usericon,omitempty"`
+	Height            *Height            `xml:"height,omitempty" json:"height,omitempty"`
+	HeightUnit        *HeightUnitDetail  `xml:"height_unit,omitempty" json:"height_unit,omitempty"`
 
 	// Links can appear multiple times, especially for polygon points
 	Links []*Link `xml:"link,omitempty" json:"link,omitempty"`
 
+	// Route elements
+	LinkAttr  *LinkAttr  `xml:"link_attr,omitempty" json:"link_attr,omitempty"`
+	RouteInfo *RouteInfo `xml:"__routeinfo,omitempty" json:"routeinfo,omitempty"`
+
+	// Range & Bearing elements
+	Range        *Range        `xml:"range,omitempty" json:"range,omitempty"`
+	Bearing      *Bearing      `xml:"bearing,omitempty" json:"bearing,omitempty"`
+	Inclination  *Inclination  `xml:"inclination,omitempty" json:"inclination,omitempty"`
+	RangeUnits   *RangeUnits   `xml:"rangeUnits,omitempty" json:"range_units,omitempty"`
+	BearingUnits *BearingUnits `xml:"bearingUnits,omitempty" json:"bearing_units,omitempty"`
+	NorthRef     *NorthRef     `xml:"northRef,omitempty" json:"north_ref,omitempty"`
+	Bullseye     *Bullseye     `xml:"bullseye,omitempty" json:"bullseye,omitempty"`
+
+	// Geofence and alert elements
+	GeoFence  *GeoFence  `xml:"__geofence,omitempty" json:"geofence,omitempty"`
+	Emergency *Emergency `xml:"emergency,omitempty" json:"emergency,omitempty"`
+
 	// Drawing style elements - these are direct children of detail, not shape
 	Color        *Color        `xml:"color,omitempty" json:"color,omitempty"`
 	StrokeColor  *StrokeColor  `xml:"strokeColor,omitempty" json:"stroke_color,omitempty"`
@@ -29,9 +50,22 @@ type Detail struct {
 	Archive      *Archive      `xml:"archive,omitempty" json:"archive,omitempty"`
 	Tog          *Tog          `xml:"tog,omitempty" json:"tog,omitempty"`
 
+	// File transfer elements
+	FileShare   *FileShare   `xml:"fileshare,omitempty" json:"fileshare,omitempty"`
+	AckRequest  *AckRequest  `xml:"ackrequest,omitempty" json:"ackrequest,omitempty"`
+	AckResponse *AckResponse `xml:"ackresponse,omitempty" json:"ackresponse,omitempty"`
+
+	// Video feed and sensor field of view
+	Video  *Video  `xml:"__video,omitempty" json:"video,omitempty"`
+	Sensor *Sensor `xml:"sensor,omitempty" json:"sensor,omitempty"`
+
+	// Data sync mission notifications
+	Mission *Mission `xml:"mission,omitempty" json:"mission,omitempty"`
+
 	// Flow tags for mesh networking
 	FlowTags *FlowTags `xml:"_flow-tags_,omitempty" json:"flow_tags,omitempty"`
-	// Raw XML for any unmapped elements
+	// Raw XML of the detail as received. Only the elements without a field above are
+	// written back when the detail is marshaled; the fields are authoritative.
 	RawXML []byte `xml:",innerxml" json:"-"`
 }
 
@@ -58,6 +92,15 @@ func (d *Detail) AddTakv(platform, version string) *Takv {
 	return d.Takv
 }
 
+// AddGroup adds a __group element to the detail
+func (d *Detail) AddGroup(name, role string) *Group {
+	d.Group = &Group{
+		Name: name,
+		Role: role,
+	}
+	return d.Group
+}
+
 // AddRemarks adds a remarks element to the detail
 func (d *Detail) AddRemarks(text string) *Remarks {
 	d.Remarks = &Remarks{
@@ -107,6 +150,13 @@ func (d *Detail) AddPointLink(point string) *Link {
 	return link
 }
 
+// AddVertex adds a link element whose point attribute holds the given position
+func (d *Detail) AddVertex(p Point) *Link {
+	link := (&Link{}).SetLatLon(p)
+	d.Links = append(d.Links, link)
+	return link
+}
+
 // SetColor sets the color for the detail
 func (d *Detail) SetColor(value int64) *Color {
 	d.Color = &Color{
@@ -115,6 +165,16 @@ func (d *Detail) SetColor(value int64) *Color {
 	return d.Color
 }
 
+// SetShapeColor sets the color for the detail in the value attribute, as drawings,
+// routes and range & bearing lines use it
+func (d *Detail) SetShapeColor(value int64) *Color {
+	d.Color = &Color{
+		Value: value,
+		Attr:  ColorAttrValue,
+	}
+	return d.Color
+}
+
 // SetStrokeColor sets the stroke color for the detail
 func (d *Detail) SetStrokeColor(value int64) *StrokeColor {
 	d.StrokeColor = &StrokeColor{
@@ -183,9 +243,9 @@ type UID struct {
 	Droid string `xml:"Droid,attr,omitempty" json:"Droid,omitempty"`
 }
 
-// Group contains group information
+// Group contains the team information of a TAK user as defined in __group.xsd
 type Group struct {
-	XMLName xml.Name `xml:"group" json:"-"`
+	XMLName xml.Name `xml:"__group" json:"-"`
 
 	Name string `xml:"name,attr,omitempty" json:"name,omitempty"`
 	Role string `xml:"role,attr,omitempty" json:"role,omitempty"`
@@ -203,34 +263,3 @@ type Chat struct {
 	MessageID      string `xml:"messageID,attr,omitempty" json:"messageID,omitempty"`
 	ChatContent    string `xml:",chardata" json:"content,omitempty"`
 }
-
-// Sensor contains sensor information
-type Sensor struct {
-	XMLName xml.Name `xml:"sensor" json:"-"`
-
-	Type          string `xml:"type,attr,omitempty" json:"type,omitempty"`
-	Vfov          string `xml:"vfov,attr,omitempty" json:"vfov,omitempty"`
-	Hfov          string `xml:"hfov,attr,omitempty" json:"hfov,omitempty"`
-	Range         string `xml:"range,attr,omitempty" json:"range,omitempty"`
-	Azimuth       string `xml:"azimuth,attr,omitempty" json:"azimuth,omitempty"`
-	DisplayMagery string `xml:"displayMagery,attr,omitempty" json:"displayMagery,omitempty"`
-}
-
-// Video contains video information
-type Video struct {
-	XMLName xml.Name `xml:"video" json:"-"`
-
-	Url             string `xml:"url,attr,omitempty" json:"url,omitempty"`
-	NetworkTimeout  string `xml:"networkTimeout,attr,omitempty" json:"networkTimeout,omitempty"`
-	BufferTime      string `xml:"buffer,attr,omitempty" json:"buffer,omitempty"`
-	ConnectionToken string `xml:"connectionToken,attr,omitempty" json:"connectionToken,omitempty"`
-}
-
-// Height contains height information
-type Height struct {
-	XMLName xml.Name `xml:"height" json:"-"`
-
-	Value     float64 `xml:"value,attr,omitempty" json:"value,omitempty"`
-	Unit      string  `xml:"unit,attr,omitempty" json:"unit,omitempty"`
-	Reference string  `xml:"reference,attr,omitempty" json:"reference,omitempty"`
-}
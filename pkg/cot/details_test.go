@@ -428,3 +428,32 @@ func TestDetailSetTog(t *testing.T) {
 		t.Errorf("SetTog did not return the created tog")
 	}
 }
+
+func TestDetailAddGroup(t *testing.T) {
+	// Given
+	detail := Detail{}
+
+	// When
+	detail.AddGroup("Cyan", "Team Member")
+	data, err := xml.Marshal(detail)
+	if err != nil {
+		t.Fatalf("Failed to marshal Detail to XML: %v", err)
+	}
+
+	// Then
+	expected := `<detail><__group name="Cyan" role="Team Member"></__group></detail>`
+	if string(data) != expected {
+		t.Errorf("Marshaled XML does not match expected.\nGot: %s\nExpected: %s", string(data), expected)
+	}
+
+	// When
+	var parsed Detail
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("Failed to unmarshal Detail: %v", err)
+	}
+
+	// Then
+	if parsed.Group == nil || parsed.Group.Name != "Cyan" || parsed.Group.Role != "Team Member" {
+		t.Errorf("Unexpected group. Got: %+v", parsed.Group)
+	}
+}
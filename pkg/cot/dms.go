@@ -0,0 +1,146 @@
+package cot
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// formatDMS formats an angle in degrees as degrees, minutes and seconds with a hemisphere
+// letter, e.g. 38°53'51.72"N
+func formatDMS(deg float64, positive, negative byte, precision int) string {
+	if precision < 0 {
+		precision = 0
+	}
+
+	hemisphere := positive
+	if deg < 0 {
+		hemisphere = negative
+		deg = -deg
+	}
+
+	// Round at the requested precision first so that 59.999" does not print as 60"
+	scale := math.Pow(10, float64(precision))
+	totalSeconds := math.Round(deg*3600*scale) / scale
+	d := math.Floor(totalSeconds / 3600)
+	m := math.Floor((totalSeconds - d*3600) / 60)
+	s := totalSeconds - d*3600 - m*60
+
+	width := 2
+	if precision > 0 {
+		width = precision + 3
+	}
+	return fmt.Sprintf("%d°%02d'%0*.*f\"%c", int(d), int(m), width, precision, s, hemisphere)
+}
+
+// FormatLatDMS formats a latitude as degrees, minutes and seconds, e.g. 38°53'51.72"N.
+// Precision is the number of decimal places for the seconds.
+func FormatLatDMS(lat float64, precision int) string {
+	return formatDMS(lat, 'N', 'S', precision)
+}
+
+// FormatLonDMS formats a longitude as degrees, minutes and seconds, e.g. 77°02'11.40"W.
+// Precision is the number of decimal places for the seconds.
+func FormatLonDMS(lon float64, precision int) string {
+	return formatDMS(lon, 'E', 'W', precision)
+}
+
+// DMS returns the point as degrees, minutes and seconds, e.g. 38°53'51.72"N 77°02'11.40"W
+func (p Point) DMS(precision int) string {
+	return FormatLatDMS(p.Lat, precision) + " " + FormatLonDMS(p.Lon, precision)
+}
+
+// ParseDMS parses an angle given as degrees, minutes and seconds and returns decimal degrees.
+// Accepted forms include 38°53'51.72"N, 38 53 51.72 N, 38d53m51.72s, 38:53:51.72,
+// -77°02'11.4" and W77°2.19', as well as plain decimal degrees. A S or W hemisphere
+// letter makes the result negative.
+func ParseDMS(s string) (float64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	if str == "" {
+		return 0, fmt.Errorf("empty DMS string")
+	}
+
+	sign := 1.0
+	if str[0] == '-' {
+		sign = -1
+		str = str[1:]
+	} else if str[0] == '+' {
+		str = str[1:]
+	}
+
+	// With letter separators such as 38d53m51.72s, a trailing s right after a digit marks
+	// seconds rather than the southern hemisphere
+	secondsMarker := strings.Contains(str, "D") && len(str) > 1 && str[len(str)-1] == 'S' &&
+		(str[len(str)-2] >= '0' && str[len(str)-2] <= '9' || str[len(str)-2] == '.')
+
+	// Hemisphere may lead or trail
+	if str != "" && !secondsMarker {
+		switch c := str[len(str)-1]; c {
+		case 'N', 'S', 'E', 'W':
+			if c == 'S' || c == 'W' {
+				sign = -sign
+			}
+			str = str[:len(str)-1]
+		default:
+			switch c := str[0]; c {
+			case 'N', 'S', 'E', 'W':
+				if c == 'S' || c == 'W' {
+					sign = -sign
+				}
+				str = str[1:]
+			}
+		}
+	}
+
+	// Replace all separators with spaces and split into numeric parts
+	str = strings.Map(func(r rune) rune {
+		switch r {
+		case '°', 'º', '\'', '"', '′', '″', ':', 'D', 'M', 'S':
+			return ' '
+		}
+		return r
+	}, str)
+	parts := strings.Fields(str)
+	if len(parts) == 0 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid DMS string: %q", s)
+	}
+
+	var result float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid DMS string %q: %w", s, err)
+		}
+		if v < 0 {
+			return 0, fmt.Errorf("invalid DMS string %q: negative component", s)
+		}
+		if i > 0 && v >= 60 {
+			return 0, fmt.Errorf("invalid DMS string %q: minutes and seconds must be below 60", s)
+		}
+		result += v / math.Pow(60, float64(i))
+	}
+
+	return sign * result, nil
+}
+
+// PointFromDMS parses a latitude and longitude given in degrees, minutes and seconds
+func PointFromDMS(lat, lon string) (Point, error) {
+	latDeg, err := ParseDMS(lat)
+	if err != nil {
+		return Point{}, fmt.Errorf("invalid latitude: %w", err)
+	}
+	if latDeg < -90 || latDeg > 90 {
+		return Point{}, fmt.Errorf("latitude out of range: %f", latDeg)
+	}
+
+	lonDeg, err := ParseDMS(lon)
+	if err != nil {
+		return Point{}, fmt.Errorf("invalid longitude: %w", err)
+	}
+	if lonDeg < -180 || lonDeg > 180 {
+		return Point{}, fmt.Errorf("longitude out of range: %f", lonDeg)
+	}
+
+	return NewPoint(latDeg, lonDeg), nil
+}
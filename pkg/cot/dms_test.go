@@ -0,0 +1,89 @@
+package cot
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPointDMS(t *testing.T) {
+	// Given
+	point := NewPoint(38.8977, -77.0365)
+
+	// When
+	dms := point.DMS(2)
+
+	// Then
+	expected := `38°53'51.72"N 77°02'11.40"W`
+	if dms != expected {
+		t.Errorf("DMS not correct. Got: %s, Expected: %s", dms, expected)
+	}
+}
+
+func TestFormatDMSRounding(t *testing.T) {
+	// Given 59.99999" rounds up to the next degree at one decimal place
+	lat := 10.9999999
+
+	// When
+	dms := FormatLatDMS(lat, 1)
+
+	// Then
+	expected := `11°00'00.0"N`
+	if dms != expected {
+		t.Errorf("DMS not correct. Got: %s, Expected: %s", dms, expected)
+	}
+	if got := FormatLonDMS(-0.5, 0); got != `0°30'00"W` {
+		t.Errorf("DMS without decimals not correct. Got: %s", got)
+	}
+}
+
+func TestParseDMS(t *testing.T) {
+	tests := []struct {
+		input     string
+		expected  float64
+		expectErr bool
+	}{
+		{input: `38°53'51.72"N`, expected: 38.8977},
+		{input: `38 53 51.72 N`, expected: 38.8977},
+		{input: `38d53m51.72s`, expected: 38.8977},
+		{input: `38d53m51.72sS`, expected: -38.8977},
+		{input: `38:53:51.72`, expected: 38.8977},
+		{input: `77°02'11.40"W`, expected: -77.0365},
+		{input: `-77°02'11.4"`, expected: -77.0365},
+		{input: `W77°2.19'`, expected: -77.0365},
+		{input: `-77.0365`, expected: -77.0365},
+		{input: ``, expectErr: true},
+		{input: `38°75'00"N`, expectErr: true},
+		{input: `38°53'51.72"X`, expectErr: true},
+		{input: `1 2 3 4`, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDMS(tt.input)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("ParseDMS() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if !tt.expectErr && math.Abs(got-tt.expected) > 1e-9 {
+				t.Errorf("ParseDMS() = %f, want %f", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPointFromDMS(t *testing.T) {
+	// When
+	point, err := PointFromDMS(`38°53'51.72"N`, `77°02'11.40"W`)
+	if err != nil {
+		t.Fatalf("Failed to parse DMS: %v", err)
+	}
+
+	// Then
+	if math.Abs(point.Lat-38.8977) > 1e-9 || math.Abs(point.Lon+77.0365) > 1e-9 {
+		t.Errorf("Point not correct. Got: %f,%f", point.Lat, point.Lon)
+	}
+
+	// Out of range latitude
+	if _, err := PointFromDMS(`91°00'00"N`, `0°00'00"E`); err == nil {
+		t.Errorf("Expected an error for latitude out of range")
+	}
+}
@@ -0,0 +1,224 @@
+package cot
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/util"
+)
+
+// Drawing shape event types as used by ATAK
+const (
+	TypeDrawingCircle       = "u-d-c-c"
+	TypeDrawingEllipse      = "u-d-c-e"
+	TypeDrawingRectangle    = "u-d-r"
+	TypeDrawingFreeForm     = "u-d-f"
+	TypeDrawingTelestration = "u-d-f-m"
+
+	// TypeKMLStyle is the link type of the KML style nested in a shape
+	TypeKMLStyle = "b-x-KmlStyle"
+)
+
+// DrawingStaleTime is how long drawing shapes stay valid; ATAK uses one day
+const DrawingStaleTime = 24 * time.Hour
+
+// xmlDeclaration is prepended to CoT events embedded in other events, as ATAK does
+const xmlDeclaration = "<?xml version='1.0' encoding='UTF-8' standalone='yes'?>"
+
+// DrawingStyle describes the stroke, fill and label settings of a drawing shape
+type DrawingStyle struct {
+	StrokeColor  color.Color
+	StrokeWeight float64
+	// FillColor is the fill of closed shapes; nil leaves the shape unfilled
+	FillColor color.Color
+	LabelsOn  bool
+}
+
+// DefaultDrawingStyle returns ATAK's default style: a white 4.0 stroke with a translucent white fill
+func DefaultDrawingStyle() DrawingStyle {
+	return DrawingStyle{
+		StrokeColor:  color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF},
+		StrokeWeight: 4.0,
+		FillColor:    color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0x96},
+	}
+}
+
+// colorToARGB converts a color to the packed ARGB value used by CoT
+func colorToARGB(c color.Color) uint32 {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return util.NewColorConverter().RGBAToInt(n.R, n.G, n.B, n.A)
+}
+
+// signedColor converts a color to the signed integer format used by CoT color details
+func signedColor(c color.Color) int64 {
+	signed, _ := util.NewColorConverter().GetSignedInt(colorToARGB(c))
+	return int64(signed)
+}
+
+// argbColor converts a signed CoT color value back to a color
+func argbColor(value int64) color.Color {
+	cc := util.NewColorConverter()
+	r, g, b, a := cc.IntToRGBA(cc.IntToUint(int32(value)))
+	return color.NRGBA{R: r, G: g, B: b, A: a}
+}
+
+// kmlColor formats a color as the lowercase ARGB hex string ATAK writes in KML styles
+func kmlColor(c color.Color) string {
+	return fmt.Sprintf("%08x", colorToARGB(c))
+}
+
+// newDrawingEvent creates an event with the details common to all drawing shapes
+func newDrawingEvent(eventType, uid, callsign string, point Point, style DrawingStyle, filled bool) *Event {
+	event := NewEvent(eventType, uid)
+	event.SetHow("h-e")
+	event.SetStale(event.Time.Add(DrawingStaleTime).Time())
+	event.SetPoint(point)
+
+	if style.StrokeColor != nil {
+		event.Detail.SetStrokeColor(signedColor(style.StrokeColor))
+	}
+	event.Detail.SetStrokeWeight(style.StrokeWeight)
+	if filled && style.FillColor != nil {
+		event.Detail.SetFillColor(signedColor(style.FillColor))
+	}
+	event.Detail.AddContact(callsign)
+	event.Detail.AddRemarks("")
+	event.Detail.AddArchive()
+	event.Detail.SetLabelsOn(style.LabelsOn)
+	event.Detail.AddPrecisionLocation("???")
+	return event
+}
+
+// kmlStyleLink returns the nested KML style link ATAK attaches to ellipse shapes
+func kmlStyleLink(uid string, style DrawingStyle) *Link {
+	kml := &Style{}
+	if style.StrokeColor != nil {
+		kml.SetLineStyle(&LineStyle{Color: kmlColor(style.StrokeColor), Width: style.StrokeWeight})
+	}
+	if style.FillColor != nil {
+		kml.SetPolyStyle(&PolyStyle{Color: kmlColor(style.FillColor)})
+	}
+	return &Link{
+		UID:      uid + ".Style",
+		Type:     TypeKMLStyle,
+		Relation: "p-c",
+		Style:    kml,
+	}
+}
+
+// NewCircle creates a u-d-c-c circle drawing with the given radius in meters
+func NewCircle(uid, callsign string, center Point, radius float64, style DrawingStyle) *Event {
+	event := newDrawingEvent(TypeDrawingCircle, uid, callsign, center, style, true)
+	shape := event.Detail.AddShape()
+	shape.AddEllipse(radius, radius, 360)
+	shape.SetLink(kmlStyleLink(uid, style))
+	return event
+}
+
+// NewEllipse creates a u-d-c-e ellipse drawing. Major and minor are the semi-axes in
+// meters and angle is the rotation of the major axis in degrees from true north.
+func NewEllipse(uid, callsign string, center Point, major, minor, angle float64, style DrawingStyle) *Event {
+	event := newDrawingEvent(TypeDrawingEllipse, uid, callsign, center, style, true)
+	shape := event.Detail.AddShape()
+	shape.AddEllipse(major, minor, angle)
+	shape.SetLink(kmlStyleLink(uid, style))
+	return event
+}
+
+// NewRectangle creates a u-d-r rectangle drawing from its four corners in drawing order.
+// The event point is placed at the center of the rectangle.
+func NewRectangle(uid, callsign string, corners [4]Point, style DrawingStyle) *Event {
+	center := corners[0].Midpoint(corners[2])
+	event := newDrawingEvent(TypeDrawingRectangle, uid, callsign, center, style, true)
+	for _, corner := range corners {
+		event.Detail.AddVertex(corner)
+	}
+	event.Detail.SetTog(false)
+	return event
+}
+
+// NewRectangleFromBoundingBox creates a u-d-r rectangle drawing covering a bounding box
+func NewRectangleFromBoundingBox(uid, callsign string, bb BoundingBox, style DrawingStyle) *Event {
+	return NewRectangle(uid, callsign, [4]Point{
+		NewPoint(bb.MaxLat, bb.MinLon),
+		NewPoint(bb.MaxLat, bb.MaxLon),
+		NewPoint(bb.MinLat, bb.MaxLon),
+		NewPoint(bb.MinLat, bb.MinLon),
+	}, style)
+}
+
+// NewPolygon creates a closed u-d-f free-form drawing. The ring is closed by repeating
+// the first vertex if the caller has not already done so.
+func NewPolygon(uid, callsign string, vertices []Point, style DrawingStyle) (*Event, error) {
+	if len(vertices) < 3 {
+		return nil, fmt.Errorf("polygon requires at least 3 vertices, got %d", len(vertices))
+	}
+	first, last := vertices[0], vertices[len(vertices)-1]
+	if first.Lat != last.Lat || first.Lon != last.Lon {
+		vertices = append(append([]Point{}, vertices...), first)
+	}
+	return newFreeForm(uid, callsign, vertices, style, true), nil
+}
+
+// NewPolyline creates an open u-d-f free-form drawing
+func NewPolyline(uid, callsign string, vertices []Point, style DrawingStyle) (*Event, error) {
+	if len(vertices) < 2 {
+		return nil, fmt.Errorf("polyline requires at least 2 vertices, got %d", len(vertices))
+	}
+	return newFreeForm(uid, callsign, vertices, style, false), nil
+}
+
+func newFreeForm(uid, callsign string, vertices []Point, style DrawingStyle, closed bool) *Event {
+	center := NewBoundingBox(vertices...).Center()
+	event := newDrawingEvent(TypeDrawingFreeForm, uid, callsign, center, style, closed)
+	for _, v := range vertices {
+		event.Detail.AddVertex(v)
+	}
+	if style.StrokeColor != nil {
+		event.Detail.SetShapeColor(signedColor(style.StrokeColor))
+	}
+	return event
+}
+
+// NewTelestration creates a u-d-f-m freehand drawing. Each stroke becomes a u-d-f line
+// event embedded as XML in the line attribute of a link, as ATAK does.
+func NewTelestration(uid, callsign string, strokes [][]Point, style DrawingStyle) (*Event, error) {
+	if len(strokes) == 0 {
+		return nil, fmt.Errorf("telestration requires at least one stroke")
+	}
+
+	event := NewEvent(TypeDrawingTelestration, uid)
+	event.SetHow("h-e")
+	event.SetStale(event.Time.Add(DrawingStaleTime).Time())
+
+	for i, stroke := range strokes {
+		line, err := NewPolyline(fmt.Sprintf("%s.%d", uid, i), callsign, stroke, style)
+		if err != nil {
+			return nil, fmt.Errorf("stroke %d: %w", i, err)
+		}
+		// Embedded strokes carry only the geometry and style
+		line.Time, line.Start, line.Stale = event.Time, event.Start, event.Stale
+		line.Detail.Remarks = nil
+		line.Detail.PrecisionLocation = nil
+		line.Detail.Color = nil
+
+		data, err := xml.Marshal(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize stroke %d: %w", i, err)
+		}
+		event.Detail.AddLink(&Link{Line: xmlDeclaration + string(data)})
+	}
+
+	if style.StrokeColor != nil {
+		event.Detail.SetStrokeColor(signedColor(style.StrokeColor))
+		event.Detail.SetShapeColor(signedColor(style.StrokeColor))
+	}
+	event.Detail.SetStrokeWeight(style.StrokeWeight)
+	event.Detail.AddContact(callsign)
+	event.Detail.AddRemarks("")
+	event.Detail.AddArchive()
+	event.Detail.SetLabelsOn(style.LabelsOn)
+	return event, nil
+}
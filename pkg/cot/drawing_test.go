@@ -0,0 +1,327 @@
+package cot
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readExample reads an event of the doc/examples directory
+func readExample(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "doc", "examples", name+".cot"))
+	if err != nil {
+		t.Fatalf("Failed to read example: %v", err)
+	}
+	return data
+}
+
+// elementAttrs returns the attributes of the first element with the given name in XML
+// data, or nil if there is none
+func elementAttrs(t *testing.T, data []byte, name string) []xml.Attr {
+	t.Helper()
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil
+		}
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local == name {
+			return start.Attr
+		}
+	}
+}
+
+// assertExampleElements checks that the event marshals the given elements with the same
+// attributes as the example
+func assertExampleElements(t *testing.T, event *Event, example string, names ...string) {
+	t.Helper()
+	data, err := xml.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal event: %v", err)
+	}
+	expected := readExample(t, example)
+	for _, name := range names {
+		got, want := elementAttrs(t, data, name), elementAttrs(t, expected, name)
+		if want == nil {
+			t.Fatalf("Example %s has no %s element", example, name)
+		}
+		if len(got) != len(want) {
+			t.Errorf("Attributes of %s not correct. Got: %v, Expected: %v", name, got, want)
+			continue
+		}
+		for i := range want {
+			if got[i].Name.Local != want[i].Name.Local || got[i].Value != want[i].Value {
+				t.Errorf("Attributes of %s not correct. Got: %v, Expected: %v", name, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestNewCircle(t *testing.T) {
+	// Given
+	center := NewPoint(38.83737606453269, -77.0729993250914)
+	style := DefaultDrawingStyle()
+	style.LabelsOn = true
+
+	// When
+	event := NewCircle("6d09b6f6", "Drawing Circle 1", center, 226.98412686380018, style)
+
+	// Then
+	if event.Type != TypeDrawingCircle || event.How != "h-e" {
+		t.Errorf("Type or how not correct. Got: %s, %s", event.Type, event.How)
+	}
+	if event.Stale.Time().Sub(event.Time.Time()) != DrawingStaleTime {
+		t.Errorf("Stale time not one day after time")
+	}
+
+	data, err := xml.Marshal(event.Detail)
+	if err != nil {
+		t.Fatalf("Failed to marshal Detail to XML: %v", err)
+	}
+	expected := []string{
+		`<ellipse major="226.98412686380018" minor="226.98412686380018" angle="360"></ellipse>`,
+		`<link uid="6d09b6f6.Style" type="b-x-KmlStyle" relation="p-c"><Style><LineStyle><color>ffffffff</color><width>4</width></LineStyle><PolyStyle><color>96ffffff</color></PolyStyle></Style></link>`,
+		`<strokeColor value="-1"></strokeColor>`,
+		`<strokeWeight value="4"></strokeWeight>`,
+		`<fillColor value="-1761607681"></fillColor>`,
+		`<contact callsign="Drawing Circle 1"></contact>`,
+		`<labels_on value="true"></labels_on>`,
+		`<precisionlocation altsrc="???"></precisionlocation>`,
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(string(data), fragment) {
+			t.Errorf("Marshaled XML missing %s\nGot: %s", fragment, string(data))
+		}
+	}
+}
+
+func TestNewEllipse(t *testing.T) {
+	// Given
+	style := DrawingStyle{StrokeColor: color.NRGBA{R: 0xFF, A: 0xFF}, StrokeWeight: 3}
+
+	// When
+	event := NewEllipse("ellipse-1", "Ellipse 1", NewPoint(38.8, -77.0), 300, 150, 45, style)
+
+	// Then
+	if event.Type != TypeDrawingEllipse {
+		t.Errorf("Type not correct. Got: %s", event.Type)
+	}
+	ellipse := event.Detail.Shape.Ellipse
+	if ellipse.Major != 300 || ellipse.Minor != 150 || ellipse.Angle != 45 {
+		t.Errorf("Ellipse not correct. Got: %+v", ellipse)
+	}
+	if event.Detail.StrokeColor.Value != -65536 {
+		t.Errorf("Stroke color not correct. Got: %d", event.Detail.StrokeColor.Value)
+	}
+	if event.Detail.FillColor != nil {
+		t.Errorf("Unfilled ellipse should have no fill color")
+	}
+	if event.Detail.Shape.Link.Style.PolyStyle != nil {
+		t.Errorf("Unfilled ellipse should have no PolyStyle")
+	}
+}
+
+func TestNewRectangle(t *testing.T) {
+	// Given
+	corners := [4]Point{
+		NewPoint(38.83884480020009, -77.06896916307281),
+		NewPoint(38.83878017039543, -77.06737849735573),
+		NewPoint(38.8365895820601, -77.0675250569016),
+		NewPoint(38.83665521881576, -77.06911560643489),
+	}
+	style := DefaultDrawingStyle()
+	style.StrokeWeight = 3
+
+	// When
+	event := NewRectangle("f48ad69d", "Rectangle 1", corners, style)
+
+	// Then
+	if event.Type != TypeDrawingRectangle {
+		t.Errorf("Type not correct. Got: %s", event.Type)
+	}
+	if len(event.Detail.Links) != 4 {
+		t.Fatalf("Expected 4 corner links, got %d", len(event.Detail.Links))
+	}
+	if event.Detail.Links[0].Point != "38.83884480020009,-77.06896916307281" {
+		t.Errorf("Corner not formatted as ATAK does. Got: %s", event.Detail.Links[0].Point)
+	}
+	if d := event.Point.DistanceTo(NewPoint(38.83771744357615, -77.06824708113128)); d > 1 {
+		t.Errorf("Center not correct, off by %fm", d)
+	}
+	if event.Detail.Tog == nil || event.Detail.Tog.Value {
+		t.Errorf("Tog not disabled. Got: %+v", event.Detail.Tog)
+	}
+}
+
+func TestNewRectangleFromBoundingBox(t *testing.T) {
+	// Given
+	bb := BoundingBox{MinLat: 38.8, MinLon: -77.1, MaxLat: 38.9, MaxLon: -77.0}
+
+	// When
+	event := NewRectangleFromBoundingBox("rect-1", "Rect", bb, DefaultDrawingStyle())
+
+	// Then
+	expected := []string{"38.9,-77.1", "38.9,-77", "38.8,-77", "38.8,-77.1"}
+	for i, link := range event.Detail.Links {
+		if link.Point != expected[i] {
+			t.Errorf("Corner %d not correct. Got: %s, Expected: %s", i, link.Point, expected[i])
+		}
+	}
+}
+
+func TestNewPolygon(t *testing.T) {
+	// Given
+	vertices := []Point{
+		NewPoint(38.838231810315555, -77.06616468204862),
+		NewPoint(38.83745360129687, -77.06579790102278),
+		NewPoint(38.83857723982895, -77.06521420648704),
+	}
+
+	// When
+	event, err := NewPolygon("b112202e", "Shape 1", vertices, DefaultDrawingStyle())
+	if err != nil {
+		t.Fatalf("NewPolygon() error = %v", err)
+	}
+
+	// Then
+	if event.Type != TypeDrawingFreeForm {
+		t.Errorf("Type not correct. Got: %s", event.Type)
+	}
+	if len(event.Detail.Links) != 4 {
+		t.Fatalf("Expected the ring to be closed with 4 links, got %d", len(event.Detail.Links))
+	}
+	if event.Detail.Links[3].Point != event.Detail.Links[0].Point {
+		t.Errorf("Ring not closed. Got: %s", event.Detail.Links[3].Point)
+	}
+	if event.Detail.FillColor == nil || event.Detail.Color == nil || event.Detail.Color.Value != -1 {
+		t.Errorf("Fill or color not set. Got: %+v, %+v", event.Detail.FillColor, event.Detail.Color)
+	}
+
+	if _, err := NewPolygon("p", "p", vertices[:2], DefaultDrawingStyle()); err == nil {
+		t.Errorf("Expected an error for a polygon with two vertices")
+	}
+}
+
+func TestNewPolyline(t *testing.T) {
+	// Given
+	vertices := []Point{NewPoint(38.8, -77.0), NewPoint(38.9, -77.1)}
+
+	// When
+	event, err := NewPolyline("line-1", "Line 1", vertices, DefaultDrawingStyle())
+	if err != nil {
+		t.Fatalf("NewPolyline() error = %v", err)
+	}
+
+	// Then
+	if len(event.Detail.Links) != 2 {
+		t.Errorf("Expected 2 links, got %d", len(event.Detail.Links))
+	}
+	if event.Detail.FillColor != nil {
+		t.Errorf("Open polyline should have no fill color")
+	}
+}
+
+func TestNewTelestration(t *testing.T) {
+	// Given
+	strokes := [][]Point{
+		{NewPoint(38.838027634047705, -77.06337210808186), NewPoint(38.8377981306119, -77.06340377023312)},
+		{NewPoint(38.8371446833294, -77.063425933739), NewPoint(38.8371446833294, -77.0632771216281)},
+	}
+
+	// When
+	event, err := NewTelestration("455a0f80", "Freehand 1", strokes, DefaultDrawingStyle())
+	if err != nil {
+		t.Fatalf("NewTelestration() error = %v", err)
+	}
+
+	// Then
+	if event.Type != TypeDrawingTelestration {
+		t.Errorf("Type not correct. Got: %s", event.Type)
+	}
+	if len(event.Detail.Links) != 2 {
+		t.Fatalf("Expected one link per stroke, got %d", len(event.Detail.Links))
+	}
+	line, err := event.Detail.Links[1].LineEvent()
+	if err != nil {
+		t.Fatalf("Failed to parse embedded stroke: %v", err)
+	}
+	if line.Type != TypeDrawingFreeForm || len(line.Detail.Links) != 2 {
+		t.Errorf("Embedded stroke not correct. Got type %s with %d links", line.Type, len(line.Detail.Links))
+	}
+	if line.Detail.Links[0].Point != "38.8371446833294,-77.063425933739" {
+		t.Errorf("Embedded stroke vertex not correct. Got: %s", line.Detail.Links[0].Point)
+	}
+
+	data, err := xml.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal telestration: %v", err)
+	}
+	if !strings.Contains(string(data), `line="&lt;?xml version=&#39;1.0&#39;`) {
+		t.Errorf("Embedded stroke not escaped in the line attribute. Got: %s", string(data))
+	}
+
+	if _, err := NewTelestration("t", "t", nil, DefaultDrawingStyle()); err == nil {
+		t.Errorf("Expected an error for a telestration without strokes")
+	}
+}
+
+func TestTelestrationExampleParses(t *testing.T) {
+	// Given the link of an ATAK telestration, shortened to two vertices
+	xmlData := `<link line='&lt;?xml version=&apos;1.0&apos; encoding=&apos;UTF-8&apos; standalone=&apos;yes&apos;?&gt;&lt;event version=&apos;2.0&apos; uid=&apos;741ad148&apos; type=&apos;u-d-f&apos; time=&apos;2020-12-16T19:59:34.916Z&apos; start=&apos;2020-12-16T19:59:34.916Z&apos; stale=&apos;2020-12-17T19:59:34.916Z&apos; how=&apos;h-e&apos;&gt;&lt;point lat=&apos;38.83747085261451&apos; lon=&apos;-77.06387615576367&apos; hae=&apos;9999999.0&apos; ce=&apos;9999999.0&apos; le=&apos;9999999.0&apos; /&gt;&lt;detail&gt;&lt;link point=&apos;38.838027634047705,-77.06337210808186&apos;/&gt;&lt;link point=&apos;38.8377981306119,-77.06340377023312&apos;/&gt;&lt;/detail&gt;&lt;/event&gt;'/>`
+
+	// When
+	var link Link
+	if err := xml.Unmarshal([]byte(xmlData), &link); err != nil {
+		t.Fatalf("Failed to unmarshal XML to Link: %v", err)
+	}
+	line, err := link.LineEvent()
+
+	// Then
+	if err != nil {
+		t.Fatalf("Failed to parse embedded stroke: %v", err)
+	}
+	if line.UID != "741ad148" || len(line.Detail.Links) != 2 {
+		t.Errorf("Embedded stroke not correct. Got uid %s with %d links", line.UID, len(line.Detail.Links))
+	}
+}
+
+func TestNewPolygonMatchesExample(t *testing.T) {
+	// Given the vertices of the ATAK free form example
+	vertices := []Point{
+		NewPoint(38.838231810315555, -77.06616468204862),
+		NewPoint(38.83745360129687, -77.06579790102278),
+		NewPoint(38.83857723982895, -77.06521420648704),
+		NewPoint(38.83703273315412, -77.06520237484105),
+		NewPoint(38.83676671272426, -77.06643680990649),
+		NewPoint(38.83733845812574, -77.06629483015456),
+	}
+
+	// When
+	event, err := NewPolygon("b112202e", "Shape 1", vertices, DefaultDrawingStyle())
+	if err != nil {
+		t.Fatalf("NewPolygon() error = %v", err)
+	}
+
+	// Then
+	assertExampleElements(t, event, "Drawing Shapes - Free Form", "color", "strokeColor", "fillColor", "contact", "labels_on")
+}
+
+func TestNewTelestrationMatchesExample(t *testing.T) {
+	// Given
+	strokes := [][]Point{{NewPoint(38.838027634047705, -77.06337210808186), NewPoint(38.8377981306119, -77.06340377023312)}}
+
+	// When
+	event, err := NewTelestration("455a0f80", "Freehand 1", strokes, DefaultDrawingStyle())
+	if err != nil {
+		t.Fatalf("NewTelestration() error = %v", err)
+	}
+
+	// Then
+	assertExampleElements(t, event, "Drawing Shapes - Telestration", "color", "strokeColor", "contact", "labels_on")
+}
@@ -0,0 +1,72 @@
+package cot
+
+import (
+	"math"
+)
+
+// ECEF represents earth-centered, earth-fixed cartesian coordinates in meters
+type ECEF struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// ToECEF converts the point to ECEF coordinates using its height above the WGS84
+// ellipsoid. An unknown hae is treated as zero.
+func (p Point) ToECEF() ECEF {
+	a, f := WGS84SemiMajorAxis, WGS84Flattening
+	e2 := f * (2 - f)
+
+	h, _ := knownValue(p.Hae)
+	sinPhi, cosPhi := math.Sincos(toRadians(p.Lat))
+	sinLambda, cosLambda := math.Sincos(toRadians(p.Lon))
+	N := a / math.Sqrt(1-e2*sinPhi*sinPhi)
+
+	return ECEF{
+		X: (N + h) * cosPhi * cosLambda,
+		Y: (N + h) * cosPhi * sinLambda,
+		Z: (N*(1-e2) + h) * sinPhi,
+	}
+}
+
+// ToPoint converts ECEF coordinates to a geodetic point with hae set, using
+// Heikkinen's closed-form solution
+func (c ECEF) ToPoint() Point {
+	a, b, f := WGS84SemiMajorAxis, WGS84SemiMinorAxis, WGS84Flattening
+	e2 := f * (2 - f)
+	ep2 := (a*a - b*b) / (b * b)
+
+	p := math.Hypot(c.X, c.Y)
+	if p < 1e-9 {
+		// On the polar axis the longitude is undefined and the latitude is ±90°
+		lat := 90.0
+		if c.Z < 0 {
+			lat = -90
+		}
+		point := NewPoint(lat, 0)
+		point.SetHae(math.Abs(c.Z) - b)
+		return point
+	}
+
+	z2 := c.Z * c.Z
+	F := 54 * b * b * z2
+	G := p*p + (1-e2)*z2 - e2*(a*a-b*b)
+	C := e2 * e2 * F * p * p / (G * G * G)
+	S := math.Cbrt(1 + C + math.Sqrt(C*C+2*C))
+	k := S + 1 + 1/S
+	P := F / (3 * k * k * G * G)
+	Q := math.Sqrt(1 + 2*e2*e2*P)
+	r0 := -(P*e2*p)/(1+Q) + math.Sqrt(a*a/2*(1+1/Q)-P*(1-e2)*z2/(Q*(1+Q))-P*p*p/2)
+	U := math.Hypot(p-e2*r0, c.Z)
+	V := math.Sqrt((p-e2*r0)*(p-e2*r0) + (1-e2)*z2)
+	z0 := b * b * c.Z / (a * V)
+
+	point := NewPoint(toDegrees(math.Atan((c.Z+ep2*z0)/p)), toDegrees(math.Atan2(c.Y, c.X)))
+	point.SetHae(U * (1 - b*b/(a*V)))
+	return point
+}
+
+// PointFromECEF converts ECEF coordinates in meters to a geodetic point
+func PointFromECEF(x, y, z float64) Point {
+	return ECEF{X: x, Y: y, Z: z}.ToPoint()
+}
@@ -0,0 +1,75 @@
+package cot
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPointToECEF(t *testing.T) {
+	// Given
+	point := NewPoint(0, 0)
+	point.SetHae(0)
+
+	// When
+	ecef := point.ToECEF()
+
+	// Then
+	if ecef.X != WGS84SemiMajorAxis || ecef.Y != 0 || ecef.Z != 0 {
+		t.Errorf("ECEF of the origin not correct. Got: %+v", ecef)
+	}
+}
+
+func TestPointToECEFUnknownHae(t *testing.T) {
+	// Given
+	point := NewPoint(90, 0)
+
+	// When
+	ecef := point.ToECEF()
+
+	// Then
+	if math.Abs(ecef.Z-WGS84SemiMinorAxis) > 1e-6 {
+		t.Errorf("ECEF of the north pole not correct. Got: %+v", ecef)
+	}
+}
+
+func TestECEFRoundTrip(t *testing.T) {
+	tests := []struct {
+		lat, lon, hae float64
+	}{
+		{38.8977, -77.0365, 100},
+		{-33.8568, 151.2153, -25},
+		{89.9, 45, 10000},
+		{0, 180, 0},
+		{-90, 0, 50},
+	}
+
+	for _, tt := range tests {
+		p := NewPoint(tt.lat, tt.lon)
+		p.SetHae(tt.hae)
+
+		back := p.ToECEF().ToPoint()
+
+		if math.Abs(back.Lat-tt.lat) > 1e-9 {
+			t.Errorf("Latitude round trip not exact. Got: %f, Expected: %f", back.Lat, tt.lat)
+		}
+		if tt.lat > -90 && math.Abs(normalizeLon(back.Lon-tt.lon)) > 1e-9 {
+			t.Errorf("Longitude round trip not exact. Got: %f, Expected: %f", back.Lon, tt.lon)
+		}
+		if back.Hae == nil || math.Abs(*back.Hae-tt.hae) > 1e-4 {
+			t.Errorf("Hae round trip not exact. Got: %v, Expected: %f", back.Hae, tt.hae)
+		}
+	}
+}
+
+func TestPointFromECEF(t *testing.T) {
+	// When
+	point := PointFromECEF(1115043.66, -4843860.92, 3983547.56)
+
+	// Then
+	if math.Abs(point.Lat-38.8977) > 1e-6 || math.Abs(point.Lon+77.0365) > 1e-6 {
+		t.Errorf("Point not correct. Got: %f,%f", point.Lat, point.Lon)
+	}
+	if math.Abs(*point.Hae-100) > 0.01 {
+		t.Errorf("Hae not correct. Got: %f", *point.Hae)
+	}
+}
@@ -148,3 +148,47 @@ func NewPingEvent(uid string) *Event {
 		},
 	}
 }
+
+// ellipseOutlineSegments is the number of segments Vertices uses to approximate ellipses
+const ellipseOutlineSegments = 72
+
+// Vertices returns the ordered geometry of the event. Shape polylines, link points of
+// rectangles, free-form drawings and routes, and the strokes of telestrations are
+// returned in drawing order; ellipses and circles are approximated by a closed ring.
+// Events without any of these return their point.
+func (e *Event) Vertices() ([]Point, error) {
+	if shape := e.Detail.Shape; shape != nil && shape.Polyline != nil {
+		return shape.Polyline.Coordinates()
+	}
+
+	var vertices []Point
+	for _, link := range e.Detail.Links {
+		switch {
+		case link.Point != "":
+			p, err := link.LatLon()
+			if err != nil {
+				return nil, err
+			}
+			vertices = append(vertices, p)
+		case link.Line != "":
+			line, err := link.LineEvent()
+			if err != nil {
+				return nil, err
+			}
+			stroke, err := line.Vertices()
+			if err != nil {
+				return nil, err
+			}
+			vertices = append(vertices, stroke...)
+		}
+	}
+	if len(vertices) > 0 {
+		return vertices, nil
+	}
+
+	if shape := e.Detail.Shape; shape != nil && shape.Ellipse != nil {
+		return shape.Ellipse.Outline(e.Point, ellipseOutlineSegments), nil
+	}
+
+	return []Point{e.Point}, nil
+}
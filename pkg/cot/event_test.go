@@ -357,3 +357,74 @@ func TestEventWithOptionalFields(t *testing.T) {
 		t.Errorf("Marshaled XML with optional fields does not match expected.\nGot: %s\nExpected: %s", string(xmlData), expectedXML)
 	}
 }
+
+func TestEventVertices(t *testing.T) {
+	style := DefaultDrawingStyle()
+	a, b, c := NewPoint(38.83, -77.06), NewPoint(38.84, -77.05), NewPoint(38.85, -77.06)
+
+	polygon, err := NewPolygon("poly-1", "Polygon", []Point{a, b, c}, style)
+	if err != nil {
+		t.Fatalf("Failed to create polygon: %v", err)
+	}
+	telestration, err := NewTelestration("tele-1", "Telestration", [][]Point{{a, b}, {c, a}}, style)
+	if err != nil {
+		t.Fatalf("Failed to create telestration: %v", err)
+	}
+	shapeLine := NewEvent("u-d-f", "shape-1")
+	shapeLine.Detail.AddShape().SetPolyline((&Polyline{}).SetCoordinates([]Point{a, b}))
+	marker := NewEvent("a-f-G", "marker-1")
+	marker.SetPoint(c)
+
+	tests := []struct {
+		name     string
+		event    *Event
+		expected []Point
+	}{
+		{name: "polygon links", event: polygon, expected: []Point{a, b, c, a}},
+		{name: "telestration strokes", event: telestration, expected: []Point{a, b, c, a}},
+		{name: "shape polyline", event: shapeLine, expected: []Point{a, b}},
+		{name: "point only", event: marker, expected: []Point{c}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			vertices, err := tt.event.Vertices()
+
+			// Then
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(vertices) != len(tt.expected) {
+				t.Fatalf("Unexpected number of vertices. Got: %d, Expected: %d", len(vertices), len(tt.expected))
+			}
+			for i := range vertices {
+				if vertices[i].Lat != tt.expected[i].Lat || vertices[i].Lon != tt.expected[i].Lon {
+					t.Errorf("Unexpected vertex %d. Got: %v,%v, Expected: %v,%v",
+						i, vertices[i].Lat, vertices[i].Lon, tt.expected[i].Lat, tt.expected[i].Lon)
+				}
+			}
+		})
+	}
+}
+
+func TestEventVerticesEllipse(t *testing.T) {
+	// Given
+	circle := NewCircle("circle-1", "Circle", NewPoint(38.83, -77.06), 250, DefaultDrawingStyle())
+
+	// When
+	vertices, err := circle.Vertices()
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(vertices) != ellipseOutlineSegments+1 {
+		t.Fatalf("Unexpected number of vertices. Got: %d, Expected: %d", len(vertices), ellipseOutlineSegments+1)
+	}
+	for i, v := range vertices {
+		if d := circle.Point.DistanceTo(v); d < 249.99 || d > 250.01 {
+			t.Errorf("Vertex %d is %f m from the center, Expected: 250", i, d)
+		}
+	}
+}
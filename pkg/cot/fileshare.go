@@ -0,0 +1,80 @@
+package cot
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Event types of direct file transfers between clients
+const (
+	// TypeFileTransferRequest announces a file a peer can download
+	TypeFileTransferRequest = "b-f-t-r"
+	// TypeFileTransferAck reports the outcome of a download to the sender
+	TypeFileTransferAck = "b-f-t-a"
+)
+
+// FileTransferStaleTime is how long a file transfer request stays valid
+const FileTransferStaleTime = 10 * time.Minute
+
+// FileShare represents the fileshare element as defined in fileshare.xsd
+type FileShare struct {
+	XMLName xml.Name `xml:"fileshare" json:"-"`
+	// Filename is the name of the file, e.g. package.zip
+	Filename string `xml:"filename,attr" json:"filename"`
+	// Name is the name shown to the user
+	Name           string `xml:"name,attr" json:"name"`
+	SenderCallsign string `xml:"senderCallsign,attr" json:"sender_callsign"`
+	SenderUID      string `xml:"senderUid,attr" json:"sender_uid"`
+	// SenderURL is where the file is downloaded from
+	SenderURL string `xml:"senderUrl,attr" json:"sender_url"`
+	// SHA256 is the hex SHA-256 hash of the file
+	SHA256      string `xml:"sha256,attr" json:"sha256"`
+	SizeInBytes int64  `xml:"sizeInBytes,attr" json:"size_in_bytes"`
+}
+
+// AckRequest asks the receiver of an event to acknowledge it
+type AckRequest struct {
+	XMLName      xml.Name `xml:"ackrequest" json:"-"`
+	UID          string   `xml:"uid,attr" json:"uid"`
+	AckRequested bool     `xml:"ackrequested,attr" json:"ack_requested"`
+	Tag          string   `xml:"tag,attr,omitempty" json:"tag,omitempty"`
+}
+
+// AckResponse acknowledges an event that carried an AckRequest
+type AckResponse struct {
+	XMLName xml.Name `xml:"ackresponse" json:"-"`
+	// UID is the UID of the AckRequest
+	UID     string `xml:"uid,attr" json:"uid"`
+	Success bool   `xml:"success,attr" json:"success"`
+	Reason  string `xml:"reason,attr,omitempty" json:"reason,omitempty"`
+}
+
+// NewFileTransferRequest creates a b-f-t-r event announcing a shared file. The event
+// asks for an acknowledgement with ackUID, which identifies the transfer.
+func NewFileTransferRequest(uid string, share FileShare, ackUID string) *Event {
+	event := NewEvent(TypeFileTransferRequest, uid)
+	event.SetHow("h-e")
+	event.SetStale(event.Time.Add(FileTransferStaleTime).Time())
+	event.Detail.FileShare = &share
+	event.Detail.AckRequest = &AckRequest{UID: ackUID, AckRequested: true, Tag: share.Name}
+	return event
+}
+
+// NewFileTransferAck creates the b-f-t-a event acknowledging a file transfer request.
+// A failed download is reported with its reason.
+func NewFileTransferAck(request *Event, uid, callsign string, success bool, reason string) *Event {
+	event := NewEvent(TypeFileTransferAck, uid)
+	event.SetHow("h-e")
+	event.SetStale(event.Time.Add(FileTransferStaleTime).Time())
+	event.Detail.AddContact(callsign)
+	if share := request.Detail.FileShare; share != nil {
+		ack := *share
+		event.Detail.FileShare = &ack
+	}
+	ackUID := request.UID
+	if request.Detail.AckRequest != nil {
+		ackUID = request.Detail.AckRequest.UID
+	}
+	event.Detail.AckResponse = &AckResponse{UID: ackUID, Success: success, Reason: reason}
+	return event
+}
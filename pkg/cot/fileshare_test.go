@@ -0,0 +1,70 @@
+package cot
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestFileTransferRequestXML(t *testing.T) {
+	// Given
+	share := FileShare{
+		Filename:       "route.zip",
+		Name:           "Route",
+		SenderCallsign: "R1",
+		SenderUID:      "ANDROID-1",
+		SenderURL:      "http://10.0.0.5:8080/getfile?file=abc",
+		SHA256:         "abc",
+		SizeInBytes:    2048,
+	}
+	event := NewFileTransferRequest("transfer-1", share, "ack-1")
+
+	// When
+	data, err := xml.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal file transfer request: %v", err)
+	}
+	var parsed Event
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("Failed to unmarshal file transfer request: %v", err)
+	}
+
+	// Then
+	expected := `<fileshare filename="route.zip" name="Route" senderCallsign="R1" senderUid="ANDROID-1" senderUrl="http://10.0.0.5:8080/getfile?file=abc" sha256="abc" sizeInBytes="2048"></fileshare>`
+	if !strings.Contains(string(data), expected) {
+		t.Errorf("Marshaled XML does not contain the fileshare element.\nGot: %s\nExpected: %s", data, expected)
+	}
+	if parsed.Type != TypeFileTransferRequest {
+		t.Errorf("Unexpected type. Got: %s, Expected: %s", parsed.Type, TypeFileTransferRequest)
+	}
+	share.XMLName = xml.Name{Local: "fileshare"}
+	if parsed.Detail.FileShare == nil || *parsed.Detail.FileShare != share {
+		t.Errorf("Unexpected fileshare. Got: %+v, Expected: %+v", parsed.Detail.FileShare, share)
+	}
+	if ack := parsed.Detail.AckRequest; ack == nil || ack.UID != "ack-1" || !ack.AckRequested || ack.Tag != "Route" {
+		t.Errorf("Unexpected ack request. Got: %+v", ack)
+	}
+}
+
+func TestFileTransferAck(t *testing.T) {
+	// Given
+	request := NewFileTransferRequest("transfer-1", FileShare{Filename: "route.zip", SHA256: "abc"}, "ack-1")
+
+	// When
+	ack := NewFileTransferAck(request, "ack-event-1", "R2", false, "hash mismatch")
+
+	// Then
+	if ack.Type != TypeFileTransferAck {
+		t.Errorf("Unexpected type. Got: %s, Expected: %s", ack.Type, TypeFileTransferAck)
+	}
+	response := ack.Detail.AckResponse
+	if response == nil || response.UID != "ack-1" || response.Success || response.Reason != "hash mismatch" {
+		t.Errorf("Unexpected ack response. Got: %+v", response)
+	}
+	if ack.Detail.FileShare == nil || ack.Detail.FileShare.SHA256 != "abc" {
+		t.Errorf("Unexpected fileshare. Got: %+v", ack.Detail.FileShare)
+	}
+	if ack.Detail.FileShare == request.Detail.FileShare {
+		t.Error("Expected the ack to hold a copy of the fileshare")
+	}
+}
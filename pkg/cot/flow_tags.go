@@ -3,6 +3,7 @@ package cot
 import (
 	"encoding/xml"
 	"fmt"
+	"strings"
 	"sync/atomic"
 	"time"
 )
@@ -62,3 +63,41 @@ func (f *FlowTags) AddHop(clientID string) {
 	}
 	f.Hops = append(f.Hops, clientID)
 }
+
+// flowTagsXML is the XML form of flow tags, with the hops in one space separated attribute
+type flowTagsXML struct {
+	Version   float64 `xml:"version,attr,omitempty"`
+	From      string  `xml:"f,attr"`
+	MessageID uint64  `xml:"m,attr"`
+	Timestamp int64   `xml:"t,attr"`
+	Hops      string  `xml:"h,attr,omitempty"`
+}
+
+// MarshalXML writes the hop list as a single space separated h attribute
+func (f FlowTags) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "_flow-tags_"}
+	return e.EncodeElement(flowTagsXML{
+		Version:   f.Version,
+		From:      f.From,
+		MessageID: f.MessageID,
+		Timestamp: f.Timestamp,
+		Hops:      strings.Join(f.Hops, " "),
+	}, start)
+}
+
+// UnmarshalXML reads flow tags with a space separated h attribute
+func (f *FlowTags) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v flowTagsXML
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	*f = FlowTags{
+		XMLName:   start.Name,
+		Version:   v.Version,
+		From:      v.From,
+		MessageID: v.MessageID,
+		Timestamp: v.Timestamp,
+		Hops:      strings.Fields(v.Hops),
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package cot
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+func TestFlowTagsHopsXML(t *testing.T) {
+	// Given
+	tags := &FlowTags{From: "relay-a", MessageID: 7, Timestamp: 1000}
+	tags.AddHop("relay-a")
+	tags.AddHop("relay-b")
+
+	// When
+	data, err := xml.Marshal(tags)
+	if err != nil {
+		t.Fatalf("Failed to marshal FlowTags: %v", err)
+	}
+
+	// Then
+	expected := `<_flow-tags_ f="relay-a" m="7" t="1000" h="relay-a relay-b"></_flow-tags_>`
+	if string(data) != expected {
+		t.Errorf("Marshaled XML does not match expected.\nGot: %s\nExpected: %s", string(data), expected)
+	}
+
+	// When
+	var parsed FlowTags
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("Failed to unmarshal FlowTags: %v", err)
+	}
+
+	// Then
+	if !reflect.DeepEqual(parsed.Hops, []string{"relay-a", "relay-b"}) {
+		t.Errorf("Unexpected hops. Got: %v, Expected: [relay-a relay-b]", parsed.Hops)
+	}
+	if parsed.From != "relay-a" || parsed.MessageID != 7 || parsed.Timestamp != 1000 {
+		t.Errorf("Unexpected flow tags. Got: %+v", parsed)
+	}
+}
+
+func TestFlowTagsWithoutHops(t *testing.T) {
+	// Given
+	tags := FlowTags{From: "client", MessageID: 1, Timestamp: 2}
+
+	// When
+	data, err := xml.Marshal(tags)
+	if err != nil {
+		t.Fatalf("Failed to marshal FlowTags: %v", err)
+	}
+
+	// Then
+	expected := `<_flow-tags_ f="client" m="1" t="2"></_flow-tags_>`
+	if string(data) != expected {
+		t.Errorf("Marshaled XML does not match expected.\nGot: %s\nExpected: %s", string(data), expected)
+	}
+}
@@ -0,0 +1,342 @@
+package cot
+
+import (
+	"math"
+)
+
+// WGS84 ellipsoid parameters
+const (
+	// WGS84SemiMajorAxis is the equatorial radius of the WGS84 ellipsoid in meters
+	WGS84SemiMajorAxis = 6378137.0
+	// WGS84Flattening is the flattening of the WGS84 ellipsoid
+	WGS84Flattening = 1 / 298.257223563
+	// WGS84SemiMinorAxis is the polar radius of the WGS84 ellipsoid in meters
+	WGS84SemiMinorAxis = WGS84SemiMajorAxis * (1 - WGS84Flattening)
+	// MeanEarthRadius is the mean radius of the earth in meters, used as a spherical fallback
+	MeanEarthRadius = 6371008.8
+)
+
+// vincentyMaxIterations bounds the Vincenty iterations; nearly antipodal points fall back to a great circle
+const vincentyMaxIterations = 200
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func toDegrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// normalizeBearing wraps a bearing in degrees into the range [0, 360)
+func normalizeBearing(deg float64) float64 {
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	return deg
+}
+
+// normalizeLon wraps a longitude in degrees into the range [-180, 180)
+func normalizeLon(deg float64) float64 {
+	return math.Mod(deg+540, 360) - 180
+}
+
+// knownValue returns the value of an optional point attribute and whether it is known.
+// CoT uses DefaultValue to mark unknown hae, ce and le values.
+func knownValue(v *float64) (float64, bool) {
+	if v == nil || *v >= DefaultValue {
+		return 0, false
+	}
+	return *v, true
+}
+
+// Inverse solves the inverse geodesic problem between two points on the WGS84 ellipsoid
+// using Vincenty's formulae. It returns the distance in meters and the initial and final
+// bearings in degrees from true north. Nearly antipodal points, for which Vincenty's
+// iteration does not converge, fall back to a great circle on the mean earth sphere.
+func Inverse(p1, p2 Point) (distance, initialBearing, finalBearing float64) {
+	if p1.Lat == p2.Lat && p1.Lon == p2.Lon {
+		return 0, 0, 0
+	}
+
+	a, b, f := WGS84SemiMajorAxis, WGS84SemiMinorAxis, WGS84Flattening
+
+	L := toRadians(p2.Lon - p1.Lon)
+	U1 := math.Atan((1 - f) * math.Tan(toRadians(p1.Lat)))
+	U2 := math.Atan((1 - f) * math.Tan(toRadians(p2.Lat)))
+	sinU1, cosU1 := math.Sincos(U1)
+	sinU2, cosU2 := math.Sincos(U2)
+
+	lambda := L
+	var sinLambda, cosLambda, sinSigma, cosSigma, sigma, cosSqAlpha, cos2SigmaM float64
+	converged := false
+	for i := 0; i < vincentyMaxIterations; i++ {
+		sinLambda, cosLambda = math.Sincos(lambda)
+		sinSigma = math.Sqrt((cosU2*sinLambda)*(cosU2*sinLambda) +
+			(cosU1*sinU2-sinU1*cosU2*cosLambda)*(cosU1*sinU2-sinU1*cosU2*cosLambda))
+		if sinSigma == 0 {
+			return 0, 0, 0 // Coincident points
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cosSqAlpha = 1 - sinAlpha*sinAlpha
+		if cosSqAlpha != 0 {
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cosSqAlpha
+		} else {
+			cos2SigmaM = 0 // Equatorial line
+		}
+		C := f / 16 * cosSqAlpha * (4 + f*(4-3*cosSqAlpha))
+		lambdaPrev := lambda
+		lambda = L + (1-C)*f*sinAlpha*
+			(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-lambdaPrev) < 1e-12 {
+			converged = true
+			break
+		}
+		if math.Abs(lambda) > math.Pi {
+			break
+		}
+	}
+
+	if !converged {
+		return greatCircleInverse(p1, p2)
+	}
+
+	uSq := cosSqAlpha * (a*a - b*b) / (b * b)
+	A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+	deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+		B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+
+	distance = b * A * (sigma - deltaSigma)
+	alpha1 := math.Atan2(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+	alpha2 := math.Atan2(cosU1*sinLambda, -sinU1*cosU2+cosU1*sinU2*cosLambda)
+
+	return distance, normalizeBearing(toDegrees(alpha1)), normalizeBearing(toDegrees(alpha2))
+}
+
+// greatCircleInverse computes distance and bearings on the mean earth sphere
+func greatCircleInverse(p1, p2 Point) (distance, initialBearing, finalBearing float64) {
+	phi1, phi2 := toRadians(p1.Lat), toRadians(p2.Lat)
+	dPhi := phi2 - phi1
+	dLambda := toRadians(p2.Lon - p1.Lon)
+
+	h := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	distance = 2 * MeanEarthRadius * math.Atan2(math.Sqrt(h), math.Sqrt(1-h))
+
+	bearing := func(phiA, phiB, dL float64) float64 {
+		y := math.Sin(dL) * math.Cos(phiB)
+		x := math.Cos(phiA)*math.Sin(phiB) - math.Sin(phiA)*math.Cos(phiB)*math.Cos(dL)
+		return normalizeBearing(toDegrees(math.Atan2(y, x)))
+	}
+	initialBearing = bearing(phi1, phi2, dLambda)
+	finalBearing = normalizeBearing(bearing(phi2, phi1, -dLambda) + 180)
+	return distance, initialBearing, finalBearing
+}
+
+// DistanceTo returns the geodesic distance in meters between the point and q
+func (p Point) DistanceTo(q Point) float64 {
+	d, _, _ := Inverse(p, q)
+	return d
+}
+
+// BearingTo returns the initial bearing in degrees from true north from the point towards q
+func (p Point) BearingTo(q Point) float64 {
+	_, b, _ := Inverse(p, q)
+	return b
+}
+
+// FinalBearingTo returns the bearing in degrees from true north on arrival at q
+func (p Point) FinalBearingTo(q Point) float64 {
+	_, _, b := Inverse(p, q)
+	return b
+}
+
+// Destination solves the direct geodesic problem using Vincenty's formulae. It returns the
+// point reached by travelling distance meters from p along the given initial bearing in degrees.
+// Hae, Ce and Le are carried over from p.
+func (p Point) Destination(distance, bearing float64) Point {
+	a, b, f := WGS84SemiMajorAxis, WGS84SemiMinorAxis, WGS84Flattening
+
+	alpha1 := toRadians(bearing)
+	sinAlpha1, cosAlpha1 := math.Sincos(alpha1)
+
+	tanU1 := (1 - f) * math.Tan(toRadians(p.Lat))
+	cosU1 := 1 / math.Sqrt(1+tanU1*tanU1)
+	sinU1 := tanU1 * cosU1
+	sigma1 := math.Atan2(tanU1, cosAlpha1)
+	sinAlpha := cosU1 * sinAlpha1
+	cosSqAlpha := 1 - sinAlpha*sinAlpha
+	uSq := cosSqAlpha * (a*a - b*b) / (b * b)
+	A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+
+	sigma := distance / (b * A)
+	var sinSigma, cosSigma, cos2SigmaM float64
+	for i := 0; i < vincentyMaxIterations; i++ {
+		cos2SigmaM = math.Cos(2*sigma1 + sigma)
+		sinSigma, cosSigma = math.Sincos(sigma)
+		deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+			B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+		sigmaPrev := sigma
+		sigma = distance/(b*A) + deltaSigma
+		if math.Abs(sigma-sigmaPrev) < 1e-12 {
+			break
+		}
+	}
+	cos2SigmaM = math.Cos(2*sigma1 + sigma)
+	sinSigma, cosSigma = math.Sincos(sigma)
+
+	x := sinU1*sinSigma - cosU1*cosSigma*cosAlpha1
+	lat := math.Atan2(sinU1*cosSigma+cosU1*sinSigma*cosAlpha1, (1-f)*math.Sqrt(sinAlpha*sinAlpha+x*x))
+	lambda := math.Atan2(sinSigma*sinAlpha1, cosU1*cosSigma-sinU1*sinSigma*cosAlpha1)
+	C := f / 16 * cosSqAlpha * (4 + f*(4-3*cosSqAlpha))
+	L := lambda - (1-C)*f*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+
+	dest := p
+	dest.Lat = toDegrees(lat)
+	dest.Lon = normalizeLon(p.Lon + toDegrees(L))
+	return dest
+}
+
+// Midpoint returns the point halfway along the geodesic between the point and q
+func (p Point) Midpoint(q Point) Point {
+	d, b, _ := Inverse(p, q)
+	mid := p.Destination(d/2, b)
+	mid.Hae, mid.Ce, mid.Le = nil, nil, nil
+	if h1, ok := knownValue(p.Hae); ok {
+		if h2, ok := knownValue(q.Hae); ok {
+			mid.SetHae((h1 + h2) / 2)
+		}
+	}
+	return mid
+}
+
+// WithinError reports whether the point and q may describe the same location given their
+// circular (ce) and linear (le) errors plus an additional tolerance in meters. An unknown
+// ce or le places no constraint on the corresponding axis.
+func (p Point) WithinError(q Point, tolerance float64) bool {
+	ce1, ok1 := knownValue(p.Ce)
+	ce2, ok2 := knownValue(q.Ce)
+	if ok1 && ok2 {
+		if p.DistanceTo(q) > ce1+ce2+tolerance {
+			return false
+		}
+	}
+
+	h1, okH1 := knownValue(p.Hae)
+	h2, okH2 := knownValue(q.Hae)
+	le1, okL1 := knownValue(p.Le)
+	le2, okL2 := knownValue(q.Le)
+	if okH1 && okH2 && okL1 && okL2 {
+		if math.Abs(h1-h2) > le1+le2+tolerance {
+			return false
+		}
+	}
+
+	return true
+}
+
+// BoundingBox is an axis-aligned latitude/longitude rectangle in degrees.
+// Boxes that cross the antimeridian are not supported.
+type BoundingBox struct {
+	MinLat float64 `json:"min_lat"`
+	MinLon float64 `json:"min_lon"`
+	MaxLat float64 `json:"max_lat"`
+	MaxLon float64 `json:"max_lon"`
+}
+
+// NewBoundingBox returns the smallest bounding box containing all the given points
+func NewBoundingBox(points ...Point) BoundingBox {
+	if len(points) == 0 {
+		return BoundingBox{}
+	}
+	bb := BoundingBox{
+		MinLat: points[0].Lat,
+		MinLon: points[0].Lon,
+		MaxLat: points[0].Lat,
+		MaxLon: points[0].Lon,
+	}
+	for _, p := range points[1:] {
+		bb.Extend(p)
+	}
+	return bb
+}
+
+// BoundingBoxAround returns a bounding box enclosing a circle of the given radius in meters.
+// When the circle contains a pole the box spans all longitudes and reaches the pole; when
+// it crosses the antimeridian the longitudes are clamped to ±180.
+func BoundingBoxAround(center Point, radius float64) BoundingBox {
+	north := NewPoint(90, center.Lon)
+	south := NewPoint(-90, center.Lon)
+	bb := BoundingBox{
+		MinLat: center.Destination(radius, 180).Lat,
+		MinLon: -180,
+		MaxLat: center.Destination(radius, 0).Lat,
+		MaxLon: 180,
+	}
+	poles := false
+	if center.DistanceTo(north) <= radius {
+		bb.MaxLat, poles = 90, true
+	}
+	if center.DistanceTo(south) <= radius {
+		bb.MinLat, poles = -90, true
+	}
+	if poles {
+		return bb
+	}
+
+	// The meridian tangent to the circle is at asin(sin(d)/cos(lat)) of longitude; the
+	// angular distance d uses the smallest radius of curvature of the ellipsoid, so the
+	// box is never narrower than the geodesic circle
+	minRadius := WGS84SemiMajorAxis * (1 - WGS84Flattening) * (1 - WGS84Flattening)
+	ratio := math.Sin(math.Min(radius/minRadius, math.Pi/2)) / math.Cos(toRadians(center.Lat))
+	if ratio >= 1 {
+		return bb
+	}
+	halfWidth := toDegrees(math.Asin(ratio))
+	bb.MinLon = math.Max(center.Lon-halfWidth, -180)
+	bb.MaxLon = math.Min(center.Lon+halfWidth, 180)
+	return bb
+}
+
+// Extend grows the bounding box to include p
+func (bb *BoundingBox) Extend(p Point) *BoundingBox {
+	bb.MinLat = math.Min(bb.MinLat, p.Lat)
+	bb.MinLon = math.Min(bb.MinLon, p.Lon)
+	bb.MaxLat = math.Max(bb.MaxLat, p.Lat)
+	bb.MaxLon = math.Max(bb.MaxLon, p.Lon)
+	return bb
+}
+
+// Contains reports whether p lies inside or on the edge of the bounding box
+func (bb BoundingBox) Contains(p Point) bool {
+	return p.Lat >= bb.MinLat && p.Lat <= bb.MaxLat && p.Lon >= bb.MinLon && p.Lon <= bb.MaxLon
+}
+
+// Intersects reports whether the two bounding boxes overlap
+func (bb BoundingBox) Intersects(other BoundingBox) bool {
+	return bb.MinLat <= other.MaxLat && bb.MaxLat >= other.MinLat &&
+		bb.MinLon <= other.MaxLon && bb.MaxLon >= other.MinLon
+}
+
+// Center returns the center of the bounding box
+func (bb BoundingBox) Center() Point {
+	return NewPoint((bb.MinLat+bb.MaxLat)/2, (bb.MinLon+bb.MaxLon)/2)
+}
+
+// PolygonContains reports whether p lies inside the polygon described by ring, using the
+// even-odd rule on latitude and longitude. The ring may be open or closed. Like
+// BoundingBox, polygons that cross the antimeridian are not supported.
+func PolygonContains(ring []Point, p Point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lon < (b.Lon-a.Lon)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
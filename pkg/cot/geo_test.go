@@ -0,0 +1,269 @@
+package cot
+
+import (
+	"math"
+	"testing"
+)
+
+// Flinders Peak and Buninyong are the reference points from Vincenty's original paper
+var (
+	flindersPeak = NewPoint(-37.95103341666667, 144.42486788888889)
+	buninyong    = NewPoint(-37.65282113888889, 143.92649552777777)
+)
+
+func TestInverse(t *testing.T) {
+	// When
+	distance, initial, final := Inverse(flindersPeak, buninyong)
+
+	// Then
+	if math.Abs(distance-54972.271) > 0.001 {
+		t.Errorf("Distance not correct. Got: %f, Expected: %f", distance, 54972.271)
+	}
+	if math.Abs(initial-306.86815920) > 1e-6 {
+		t.Errorf("Initial bearing not correct. Got: %f, Expected: %f", initial, 306.86815920)
+	}
+	if math.Abs(final-307.17363) > 1e-5 {
+		t.Errorf("Final bearing not correct. Got: %f, Expected: %f", final, 307.17363)
+	}
+}
+
+func TestInverseCoincidentPoints(t *testing.T) {
+	// Given
+	p := NewPoint(38.8977, -77.0365)
+
+	// When
+	distance := p.DistanceTo(p)
+
+	// Then
+	if distance != 0 {
+		t.Errorf("Distance between coincident points should be zero. Got: %f", distance)
+	}
+}
+
+func TestInverseAntipodalFallsBack(t *testing.T) {
+	// Given
+	p1 := NewPoint(0, 0)
+	p2 := NewPoint(0.5, 179.7)
+
+	// When
+	distance := p1.DistanceTo(p2)
+
+	// Then
+	if math.IsNaN(distance) || distance < 19900000 || distance > 20050000 {
+		t.Errorf("Nearly antipodal distance not plausible. Got: %f", distance)
+	}
+}
+
+func TestDestination(t *testing.T) {
+	// When
+	dest := flindersPeak.Destination(54972.271, 306.86815920)
+
+	// Then
+	if math.Abs(dest.Lat-buninyong.Lat) > 1e-8 {
+		t.Errorf("Destination latitude not correct. Got: %.10f, Expected: %.10f", dest.Lat, buninyong.Lat)
+	}
+	if math.Abs(dest.Lon-buninyong.Lon) > 1e-8 {
+		t.Errorf("Destination longitude not correct. Got: %.10f, Expected: %.10f", dest.Lon, buninyong.Lon)
+	}
+}
+
+func TestDestinationAcrossAntimeridian(t *testing.T) {
+	// Given
+	p := NewPoint(0, 179.999)
+
+	// When
+	dest := p.Destination(1000, 90)
+
+	// Then
+	if dest.Lon > -179 || dest.Lon < -180 {
+		t.Errorf("Longitude not wrapped across the antimeridian. Got: %f", dest.Lon)
+	}
+}
+
+func TestMidpoint(t *testing.T) {
+	// Given
+	p1 := NewPoint(38.8977, -77.0365)
+	p1.SetHae(100)
+	p2 := NewPoint(38.9977, -77.1365)
+	p2.SetHae(200)
+
+	// When
+	mid := p1.Midpoint(p2)
+
+	// Then
+	d1 := p1.DistanceTo(mid)
+	d2 := mid.DistanceTo(p2)
+	if math.Abs(d1-d2) > 0.01 {
+		t.Errorf("Midpoint not equidistant. Got: %f and %f", d1, d2)
+	}
+	if mid.Hae == nil || *mid.Hae != 150 {
+		t.Errorf("Midpoint hae not averaged. Got: %v", mid.Hae)
+	}
+}
+
+func TestWithinError(t *testing.T) {
+	base := NewPoint(38.8977, -77.0365)
+	base.SetCe(10)
+	near := base.Destination(15, 45)
+	near.SetCe(10)
+	far := base.Destination(50, 45)
+	far.SetCe(10)
+	unknown := base.Destination(5000, 45)
+	unknown.Ce = nil
+
+	tests := []struct {
+		name      string
+		other     Point
+		tolerance float64
+		expected  bool
+	}{
+		{name: "Inside combined circular error", other: near, expected: true},
+		{name: "Outside combined circular error", other: far, expected: false},
+		{name: "Tolerance widens the check", other: far, tolerance: 40, expected: true},
+		{name: "Unknown circular error", other: unknown, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := base.WithinError(tt.other, tt.tolerance); got != tt.expected {
+				t.Errorf("WithinError() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWithinErrorVertical(t *testing.T) {
+	// Given
+	p1 := NewPoint(38.8977, -77.0365)
+	p1.SetCe(10).SetLe(5).SetHae(100)
+	p2 := NewPoint(38.8977, -77.0365)
+	p2.SetCe(10).SetLe(5).SetHae(120)
+
+	// When
+	within := p1.WithinError(p2, 0)
+
+	// Then
+	if within {
+		t.Errorf("Points 20m apart vertically should not be within 10m of linear error")
+	}
+}
+
+func TestBoundingBox(t *testing.T) {
+	// Given
+	bb := NewBoundingBox(NewPoint(38.8, -77.1), NewPoint(38.9, -77.0), NewPoint(38.85, -77.2))
+
+	// Then
+	expected := BoundingBox{MinLat: 38.8, MinLon: -77.2, MaxLat: 38.9, MaxLon: -77.0}
+	if bb != expected {
+		t.Errorf("Bounding box not correct. Got: %+v, Expected: %+v", bb, expected)
+	}
+	if !bb.Contains(NewPoint(38.85, -77.1)) {
+		t.Errorf("Bounding box should contain its interior point")
+	}
+	if bb.Contains(NewPoint(39.0, -77.1)) {
+		t.Errorf("Bounding box should not contain an exterior point")
+	}
+	if !bb.Intersects(BoundingBox{MinLat: 38.88, MinLon: -77.05, MaxLat: 39.0, MaxLon: -76.9}) {
+		t.Errorf("Overlapping bounding boxes should intersect")
+	}
+	if bb.Intersects(BoundingBox{MinLat: 39.0, MinLon: -77.05, MaxLat: 39.1, MaxLon: -76.9}) {
+		t.Errorf("Disjoint bounding boxes should not intersect")
+	}
+}
+
+func TestBoundingBoxAround(t *testing.T) {
+	// Given
+	center := NewPoint(38.8977, -77.0365)
+
+	// When
+	bb := BoundingBoxAround(center, 1000)
+
+	// Then
+	corner := NewPoint(bb.MaxLat, center.Lon)
+	if d := center.DistanceTo(corner); math.Abs(d-1000) > 0.01 {
+		t.Errorf("Bounding box north edge not at radius. Got: %f", d)
+	}
+	if !bb.Contains(center) {
+		t.Errorf("Bounding box should contain its center")
+	}
+}
+
+func TestBoundingBoxAroundHighLatitude(t *testing.T) {
+	// Given a circle far north, where meridians converge
+	center := NewPoint(70, 20)
+
+	// When
+	bb := BoundingBoxAround(center, 500000)
+
+	// Then every point on the circle is inside
+	for bearing := 0.0; bearing < 360; bearing += 5 {
+		p := center.Destination(499000, bearing)
+		if !bb.Contains(p) {
+			t.Errorf("Point at bearing %.0f not in bounding box. Got: %v, Expected: inside %v", bearing, p, bb)
+		}
+	}
+	if p := center.Destination(499000, 75); !bb.Contains(p) {
+		t.Errorf("Point at bearing 75 not in bounding box. Got: %v", p)
+	}
+	if bb.MinLat <= -90 || bb.MaxLat >= 90 || bb.MinLon <= -180 || bb.MaxLon >= 180 {
+		t.Errorf("Bounding box should not reach the poles or the antimeridian. Got: %v", bb)
+	}
+}
+
+func TestBoundingBoxAroundPole(t *testing.T) {
+	// Given circles containing the north and the south pole
+	tests := []struct {
+		center   Point
+		radius   float64
+		expected BoundingBox
+	}{
+		{NewPoint(89, 10), 200000, BoundingBox{MinLat: NewPoint(89, 10).Destination(200000, 180).Lat, MinLon: -180, MaxLat: 90, MaxLon: 180}},
+		{NewPoint(-89.5, -40), 100000, BoundingBox{MinLat: -90, MinLon: -180, MaxLat: NewPoint(-89.5, -40).Destination(100000, 0).Lat, MaxLon: 180}},
+	}
+
+	for _, tt := range tests {
+		// When
+		bb := BoundingBoxAround(tt.center, tt.radius)
+
+		// Then the box covers the pole and all longitudes
+		if bb != tt.expected {
+			t.Errorf("Bounding box around %v mismatch. Got: %v, Expected: %v", tt.center, bb, tt.expected)
+		}
+		for bearing := 0.0; bearing < 360; bearing += 15 {
+			if p := tt.center.Destination(tt.radius*0.99, bearing); !bb.Contains(p) {
+				t.Errorf("Point at bearing %.0f not in bounding box. Got: %v, Expected: inside %v", bearing, p, bb)
+			}
+		}
+	}
+}
+
+func TestPolygonContains(t *testing.T) {
+	// Given a square and an L-shaped ring
+	square := []Point{NewPoint(0, 0), NewPoint(0, 1), NewPoint(1, 1), NewPoint(1, 0), NewPoint(0, 0)}
+	lShape := []Point{NewPoint(0, 0), NewPoint(0, 2), NewPoint(1, 2), NewPoint(1, 1), NewPoint(2, 1), NewPoint(2, 0)}
+
+	tests := []struct {
+		name     string
+		ring     []Point
+		point    Point
+		expected bool
+	}{
+		{"inside square", square, NewPoint(0.5, 0.5), true},
+		{"outside square", square, NewPoint(1.5, 0.5), false},
+		{"inside open L", lShape, NewPoint(0.5, 1.5), true},
+		{"in the notch of L", lShape, NewPoint(1.5, 1.5), false},
+		{"empty ring", nil, NewPoint(0, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			result := PolygonContains(tt.ring, tt.point)
+
+			// Then
+			if result != tt.expected {
+				t.Errorf("Got: %v, Expected: %v", result, tt.expected)
+			}
+		})
+	}
+}
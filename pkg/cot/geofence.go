@@ -0,0 +1,88 @@
+package cot
+
+import "encoding/xml"
+
+// GeoFenceTrigger selects which crossings of a geofence raise an alert
+type GeoFenceTrigger string
+
+const (
+	GeoFenceTriggerEntry GeoFenceTrigger = "Entry"
+	GeoFenceTriggerExit  GeoFenceTrigger = "Exit"
+	GeoFenceTriggerBoth  GeoFenceTrigger = "Both"
+)
+
+// GeoFenceMonitor selects which items a geofence watches
+type GeoFenceMonitor string
+
+const (
+	GeoFenceMonitorTAKUsers GeoFenceMonitor = "TAKUsers"
+	GeoFenceMonitorFriendly GeoFenceMonitor = "Friendly"
+	GeoFenceMonitorHostile  GeoFenceMonitor = "Hostile"
+	GeoFenceMonitorAll      GeoFenceMonitor = "All"
+)
+
+// GeoFence represents the __geofence element as defined in __geofence.xsd. It turns the
+// drawing shape it is attached to into a geofence.
+type GeoFence struct {
+	XMLName xml.Name `xml:"__geofence" json:"-"`
+
+	ElevationMonitored bool            `xml:"elevationMonitored,attr" json:"elevationMonitored"`
+	MinElevation       float64         `xml:"minElevation,attr" json:"minElevation"`
+	Monitor            GeoFenceMonitor `xml:"monitor,attr" json:"monitor"`
+	Trigger            GeoFenceTrigger `xml:"trigger,attr" json:"trigger"`
+	Tracking           bool            `xml:"tracking,attr" json:"tracking"`
+	MaxElevation       float64         `xml:"maxElevation,attr" json:"maxElevation"`
+	// BoundingSphere is the radius in meters around the fence within which items are monitored
+	BoundingSphere float64 `xml:"boundingSphere,attr" json:"boundingSphere"`
+}
+
+// NewGeoFence creates a tracking geofence with ATAK's default 75 km bounding sphere
+func NewGeoFence(trigger GeoFenceTrigger, monitor GeoFenceMonitor) *GeoFence {
+	return &GeoFence{
+		Monitor:        monitor,
+		Trigger:        trigger,
+		Tracking:       true,
+		BoundingSphere: 75000,
+	}
+}
+
+// SetElevation limits the fence to heights between min and max meters
+func (g *GeoFence) SetElevation(min, max float64) *GeoFence {
+	g.ElevationMonitored = true
+	g.MinElevation = min
+	g.MaxElevation = max
+	return g
+}
+
+// SetTracking sets whether the fence is active
+func (g *GeoFence) SetTracking(tracking bool) *GeoFence {
+	g.Tracking = tracking
+	return g
+}
+
+// SetBoundingSphere sets the radius in meters within which items are monitored
+func (g *GeoFence) SetBoundingSphere(radius float64) *GeoFence {
+	g.BoundingSphere = radius
+	return g
+}
+
+// AddGeoFence attaches a geofence to the detail
+func (d *Detail) AddGeoFence(trigger GeoFenceTrigger, monitor GeoFenceMonitor) *GeoFence {
+	d.GeoFence = NewGeoFence(trigger, monitor)
+	return d.GeoFence
+}
+
+// TypeGeoFenceBreach is the type of the alert ATAK raises when a geofence is breached
+const TypeGeoFenceBreach = "b-a-g"
+
+// EmergencyTypeGeoFenceBreach is the emergency type of a geofence breach alert
+const EmergencyTypeGeoFenceBreach = "Geo-fence Breached"
+
+// Emergency represents the emergency element carried by alert events
+type Emergency struct {
+	XMLName xml.Name `xml:"emergency" json:"-"`
+
+	Type   string `xml:"type,attr,omitempty" json:"type,omitempty"`
+	Cancel bool   `xml:"cancel,attr,omitempty" json:"cancel,omitempty"`
+	Text   string `xml:",chardata" json:"text,omitempty"`
+}
@@ -0,0 +1,50 @@
+package cot
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestGeoFenceUnmarshalXML(t *testing.T) {
+	// Given
+	xmlData := `<detail><__geofence elevationMonitored='true' minElevation='-33.30720360300985' monitor='All' trigger='Entry' tracking='true' maxElevation='271.4927963969902' boundingSphere='75000.0'/></detail>`
+
+	// When
+	var detail Detail
+	if err := xml.Unmarshal([]byte(xmlData), &detail); err != nil {
+		t.Fatalf("Failed to unmarshal detail: %v", err)
+	}
+
+	// Then
+	fence := detail.GeoFence
+	if fence == nil {
+		t.Fatal("Expected geofence")
+	}
+	if !fence.ElevationMonitored || fence.MinElevation != -33.30720360300985 || fence.MaxElevation != 271.4927963969902 {
+		t.Errorf("Unexpected elevation bounds. Got: %+v", fence)
+	}
+	if fence.Monitor != GeoFenceMonitorAll || fence.Trigger != GeoFenceTriggerEntry {
+		t.Errorf("Unexpected monitor or trigger. Got: %s, %s", fence.Monitor, fence.Trigger)
+	}
+	if !fence.Tracking || fence.BoundingSphere != 75000 {
+		t.Errorf("Unexpected tracking or bounding sphere. Got: %v, %f", fence.Tracking, fence.BoundingSphere)
+	}
+}
+
+func TestGeoFenceMarshalXML(t *testing.T) {
+	// Given
+	detail := Detail{}
+	detail.AddGeoFence(GeoFenceTriggerBoth, GeoFenceMonitorHostile).SetElevation(0, 500)
+
+	// When
+	data, err := xml.Marshal(detail.GeoFence)
+	if err != nil {
+		t.Fatalf("Failed to marshal geofence: %v", err)
+	}
+
+	// Then
+	expected := `<__geofence elevationMonitored="true" minElevation="0" monitor="Hostile" trigger="Both" tracking="true" maxElevation="500" boundingSphere="75000"></__geofence>`
+	if string(data) != expected {
+		t.Errorf("Unexpected XML.\nGot: %s\nExpected: %s", string(data), expected)
+	}
+}
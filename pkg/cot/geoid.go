@@ -0,0 +1,198 @@
+package cot
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// ErrNoGeoid is returned by MSL conversions when no geoid model is available
+var ErrNoGeoid = errors.New("no geoid model loaded")
+
+// Geoid provides the geoid undulation, the height of the geoid (mean sea level) above the
+// WGS84 ellipsoid, at a position
+type Geoid interface {
+	// Undulation returns the geoid height in meters at the given latitude and longitude
+	Undulation(lat, lon float64) float64
+}
+
+// GeoidGrid is a regular latitude/longitude grid of geoid undulations, such as the NGA
+// EGM96 WW15MGH.GRD grid, interpolated bilinearly
+type GeoidGrid struct {
+	south, north, west, east float64
+	dLat, dLon               float64
+	rows, cols               int
+	values                   []float64 // Row-major, north to south, west to east
+}
+
+// NewGeoidGrid creates a geoid grid from undulations in meters. Values are row-major,
+// starting at the north-west corner. The grid must cover all longitudes, i.e. east - west
+// must be 360 degrees, with the last column repeating the first.
+func NewGeoidGrid(south, north, west, east, dLat, dLon float64, values []float64) (*GeoidGrid, error) {
+	if dLat <= 0 || dLon <= 0 || north <= south || east <= west {
+		return nil, fmt.Errorf("invalid geoid grid bounds")
+	}
+	if east-west != 360 {
+		return nil, fmt.Errorf("geoid grid must span 360 degrees of longitude, got %f", east-west)
+	}
+
+	rows := int(math.Round((north-south)/dLat)) + 1
+	cols := int(math.Round((east-west)/dLon)) + 1
+	if len(values) != rows*cols {
+		return nil, fmt.Errorf("geoid grid has %d values, expected %d (%d rows x %d columns)", len(values), rows*cols, rows, cols)
+	}
+
+	return &GeoidGrid{
+		south: south, north: north, west: west, east: east,
+		dLat: dLat, dLon: dLon,
+		rows: rows, cols: cols,
+		values: values,
+	}, nil
+}
+
+// ParseGeoidGrid reads a geoid grid in the NGA .GRD text format used by WW15MGH.GRD.
+// The header holds the south, north, west and east bounds followed by the latitude and
+// longitude spacing, all in degrees. It is followed by the undulations in meters, row by
+// row from north to south.
+func ParseGeoidGrid(r io.Reader) (*GeoidGrid, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+
+	var header [6]float64
+	for i := range header {
+		if !scanner.Scan() {
+			return nil, fmt.Errorf("geoid grid header truncated")
+		}
+		v, err := strconv.ParseFloat(scanner.Text(), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid geoid grid header: %w", err)
+		}
+		header[i] = v
+	}
+
+	var values []float64
+	for scanner.Scan() {
+		v, err := strconv.ParseFloat(scanner.Text(), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid geoid grid value: %w", err)
+		}
+		values = append(values, v)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read geoid grid: %w", err)
+	}
+
+	return NewGeoidGrid(header[0], header[1], header[2], header[3], header[4], header[5], values)
+}
+
+// LoadGeoidGrid reads a geoid grid in the NGA .GRD text format from a file
+func LoadGeoidGrid(path string) (*GeoidGrid, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geoid grid: %w", err)
+	}
+	defer f.Close()
+	return ParseGeoidGrid(f)
+}
+
+// value returns the grid value at the given row and column, wrapping columns around the globe
+func (g *GeoidGrid) value(row, col int) float64 {
+	if row < 0 {
+		row = 0
+	}
+	if row > g.rows-1 {
+		row = g.rows - 1
+	}
+	col = ((col % (g.cols - 1)) + (g.cols - 1)) % (g.cols - 1)
+	return g.values[row*g.cols+col]
+}
+
+// Undulation returns the bilinearly interpolated geoid height in meters
+func (g *GeoidGrid) Undulation(lat, lon float64) float64 {
+	lat = math.Max(g.south, math.Min(g.north, lat))
+	lon = math.Mod(lon-g.west, 360)
+	if lon < 0 {
+		lon += 360
+	}
+
+	y := (g.north - lat) / g.dLat
+	x := lon / g.dLon
+	row, col := int(math.Floor(y)), int(math.Floor(x))
+	fy, fx := y-float64(row), x-float64(col)
+
+	v00 := g.value(row, col)
+	v01 := g.value(row, col+1)
+	v10 := g.value(row+1, col)
+	v11 := g.value(row+1, col+1)
+
+	return v00*(1-fx)*(1-fy) + v01*fx*(1-fy) + v10*(1-fx)*fy + v11*fx*fy
+}
+
+var (
+	defaultGeoid    Geoid
+	defaultGeoidSet bool
+	defaultGeoidMu  sync.RWMutex
+)
+
+// SetGeoid sets the geoid model used for HAE to MSL conversions, replacing the embedded
+// EGM96 grid. Setting nil disables the conversions.
+func SetGeoid(g Geoid) {
+	defaultGeoidMu.Lock()
+	defer defaultGeoidMu.Unlock()
+	defaultGeoid = g
+	defaultGeoidSet = true
+}
+
+// GetGeoid returns the geoid model used for HAE to MSL conversions. Unless SetGeoid was
+// called, this is the embedded EGM96 grid. It returns nil if no geoid is available.
+func GetGeoid() Geoid {
+	defaultGeoidMu.RLock()
+	g, set := defaultGeoid, defaultGeoidSet
+	defaultGeoidMu.RUnlock()
+	if set {
+		return g
+	}
+	grid, err := EGM96()
+	if err != nil {
+		return nil
+	}
+	return grid
+}
+
+// GeoidUndulation returns the height of the geoid above the WGS84 ellipsoid in meters
+// at the given position, using the model returned by GetGeoid
+func GeoidUndulation(lat, lon float64) (float64, error) {
+	g := GetGeoid()
+	if g == nil {
+		return 0, ErrNoGeoid
+	}
+	return g.Undulation(lat, lon), nil
+}
+
+// MSL returns the height of the point above mean sea level in meters
+func (p Point) MSL() (float64, error) {
+	hae, ok := knownValue(p.Hae)
+	if !ok {
+		return 0, errors.New("point has no height above ellipsoid")
+	}
+	n, err := GeoidUndulation(p.Lat, p.Lon)
+	if err != nil {
+		return 0, err
+	}
+	return hae - n, nil
+}
+
+// SetMSL sets the height above ellipsoid of the point from a height above mean sea level in meters
+func (p *Point) SetMSL(msl float64) error {
+	n, err := GeoidUndulation(p.Lat, p.Lon)
+	if err != nil {
+		return err
+	}
+	p.SetHae(msl + n)
+	return nil
+}
@@ -0,0 +1,52 @@
+package cot
+
+import (
+	"compress/gzip"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+)
+
+//go:generate go run geoid_gen.go -in WW15MGH.GRD -step 1 -out geoiddata/egm96-1deg.grd.gz
+
+// egm96File is the embedded EGM96 grid, a 1 degree subsample of the NGA WW15MGH.GRD
+// grid in the same format, compressed with gzip
+const egm96File = "geoiddata/egm96-1deg.grd.gz"
+
+//go:embed geoiddata
+var geoidData embed.FS
+
+var (
+	egm96Once sync.Once
+	egm96Grid *GeoidGrid
+	egm96Err  error
+)
+
+// EGM96 returns the embedded EGM96 geoid grid, which is loaded on first use. It returns
+// ErrNoGeoid when the package was built without the grid.
+func EGM96() (*GeoidGrid, error) {
+	egm96Once.Do(func() {
+		egm96Grid, egm96Err = loadEGM96()
+	})
+	return egm96Grid, egm96Err
+}
+
+// loadEGM96 reads the embedded EGM96 grid
+func loadEGM96() (*GeoidGrid, error) {
+	f, err := geoidData.Open(egm96File)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNoGeoid
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedded geoid grid: %w", err)
+	}
+	defer zr.Close()
+	return ParseGeoidGrid(zr)
+}
@@ -0,0 +1,96 @@
+//go:build ignore
+
+// geoid_gen subsamples an NGA geoid grid such as the 15 minute EGM96 WW15MGH.GRD into
+// the coarse grid embedded by the cot package:
+//
+//	go run geoid_gen.go -in WW15MGH.GRD -step 1 -out geoiddata/egm96-1deg.grd.gz
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+)
+
+func main() {
+	in := flag.String("in", "WW15MGH.GRD", "NGA .GRD geoid grid to subsample")
+	step := flag.Float64("step", 1, "Spacing of the output grid in degrees")
+	out := flag.String("out", "geoiddata/egm96-1deg.grd.gz", "Output file, compressed with gzip")
+	flag.Parse()
+
+	f, err := os.Open(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Split(bufio.ScanWords)
+	var values []float64
+	for scanner.Scan() {
+		v, err := strconv.ParseFloat(scanner.Text(), 64)
+		if err != nil {
+			log.Fatalf("Invalid value %q: %v", scanner.Text(), err)
+		}
+		values = append(values, v)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	if len(values) < 6 {
+		log.Fatal("Grid header truncated")
+	}
+
+	south, north, west, east, dLat, dLon := values[0], values[1], values[2], values[3], values[4], values[5]
+	values = values[6:]
+	rows := int(math.Round((north-south)/dLat)) + 1
+	cols := int(math.Round((east-west)/dLon)) + 1
+	if len(values) != rows*cols {
+		log.Fatalf("Grid has %d values, expected %d", len(values), rows*cols)
+	}
+	rowStep, colStep := int(math.Round(*step/dLat)), int(math.Round(*step/dLon))
+	if rowStep < 1 || colStep < 1 || (rows-1)%rowStep != 0 || (cols-1)%colStep != 0 {
+		log.Fatalf("Step %g does not divide the grid", *step)
+	}
+
+	o, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	zw, err := gzip.NewWriterLevel(o, gzip.BestCompression)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := bufio.NewWriter(zw)
+	fmt.Fprintf(w, "%.1f %.1f %.1f %.1f %g %g\n", south, north, west, east, *step, *step)
+	for row := 0; row < rows; row += rowStep {
+		n := 0
+		for col := 0; col < cols; col += colStep {
+			if n > 0 {
+				w.WriteByte(' ')
+			}
+			w.WriteString(strconv.FormatFloat(values[row*cols+col], 'f', 2, 64))
+			if n++; n == 10 {
+				w.WriteByte('\n')
+				n = 0
+			}
+		}
+		if n > 0 {
+			w.WriteByte('\n')
+		}
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := o.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,166 @@
+package cot
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// constantGeoid is a geoid with the same undulation everywhere
+type constantGeoid float64
+
+func (g constantGeoid) Undulation(lat, lon float64) float64 {
+	return float64(g)
+}
+
+// testGrid is a 90 degree grid in the NGA .GRD layout, rows from north to south
+const testGrid = `-90.0 90.0 0.0 360.0 90.0 90.0
+ 10  10  10  10  10
+ 20  40  60  80  20
+-10 -20 -30 -40 -10
+`
+
+func TestParseGeoidGrid(t *testing.T) {
+	// When
+	grid, err := ParseGeoidGrid(strings.NewReader(testGrid))
+	if err != nil {
+		t.Fatalf("Failed to parse geoid grid: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		expected float64
+	}{
+		{name: "Grid node", lat: 0, lon: 90, expected: 40},
+		{name: "North pole", lat: 90, lon: 123, expected: 10},
+		{name: "Between columns", lat: 0, lon: 45, expected: 30},
+		{name: "Between rows", lat: 45, lon: 0, expected: 15},
+		{name: "Negative longitude wraps", lat: 0, lon: -90, expected: 80},
+		{name: "Last column wraps to the first", lat: 0, lon: 315, expected: 50},
+		{name: "Cell center", lat: -45, lon: 45, expected: 7.5},
+	}
+
+	// Then
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := grid.Undulation(tt.lat, tt.lon); math.Abs(got-tt.expected) > 1e-9 {
+				t.Errorf("Undulation() = %f, want %f", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseGeoidGridInvalid(t *testing.T) {
+	inputs := []string{
+		"",
+		"-90.0 90.0 0.0 360.0",
+		"-90.0 90.0 0.0 360.0 90.0 90.0\n1 2 3",
+		"-90.0 90.0 0.0 180.0 90.0 90.0\n1 2 3 4 5 6 7 8 9",
+		"-90.0 90.0 0.0 360.0 90.0 x",
+	}
+
+	for _, input := range inputs {
+		if _, err := ParseGeoidGrid(strings.NewReader(input)); err == nil {
+			t.Errorf("ParseGeoidGrid(%q) expected an error", input)
+		}
+	}
+}
+
+func TestPointMSL(t *testing.T) {
+	// Given
+	SetGeoid(constantGeoid(-33))
+	defer SetGeoid(nil)
+	point := NewPoint(38.8977, -77.0365)
+	point.SetHae(67)
+
+	// When
+	msl, err := point.MSL()
+	if err != nil {
+		t.Fatalf("MSL() error = %v", err)
+	}
+
+	// Then
+	if msl != 100 {
+		t.Errorf("MSL not correct. Got: %f, Expected: %f", msl, 100.0)
+	}
+
+	// And back again
+	if err := point.SetMSL(200); err != nil {
+		t.Fatalf("SetMSL() error = %v", err)
+	}
+	if *point.Hae != 167 {
+		t.Errorf("Hae not correct. Got: %f, Expected: %f", *point.Hae, 167.0)
+	}
+}
+
+func TestPointMSLErrors(t *testing.T) {
+	// Given
+	SetGeoid(nil)
+	point := NewPoint(38.8977, -77.0365)
+	point.SetHae(67)
+
+	// Then
+	if _, err := point.MSL(); err != ErrNoGeoid {
+		t.Errorf("Expected ErrNoGeoid, got: %v", err)
+	}
+
+	SetGeoid(constantGeoid(-33))
+	defer SetGeoid(nil)
+	if _, err := NewPoint(0, 0).MSL(); err == nil {
+		t.Errorf("Expected an error for a point without hae")
+	}
+}
+
+func TestEGM96(t *testing.T) {
+	// Given
+	grid, err := EGM96()
+	if err == ErrNoGeoid {
+		t.Skip("Built without the EGM96 grid, see geoiddata/README.md")
+	} else if err != nil {
+		t.Fatalf("Failed to load the EGM96 grid: %v", err)
+	}
+
+	// Test points of the NGA EGM96 interpolation program; the 1 degree grid is
+	// within a meter or two of the full model away from steep gradients
+	tests := []struct {
+		lat, lon float64
+		expected float64
+	}{
+		{lat: 38.6281550, lon: 269.7791550, expected: -31.628},
+		{lat: -14.6212170, lon: 305.0211140, expected: -2.969},
+		{lat: 46.8743190, lon: 102.4487290, expected: -43.575},
+		{lat: -23.6174460, lon: 133.8747120, expected: 15.871},
+		{lat: 38.6254730, lon: 359.9995000, expected: 50.066},
+		{lat: -0.4667440, lon: 0.0023000, expected: 17.329},
+	}
+
+	// Then
+	for _, tt := range tests {
+		if got := grid.Undulation(tt.lat, tt.lon); math.Abs(got-tt.expected) > 2 {
+			t.Errorf("Undulation(%f, %f) not correct. Got: %f, Expected: %f", tt.lat, tt.lon, got, tt.expected)
+		}
+	}
+}
+
+func TestEGM96IsDefaultGeoid(t *testing.T) {
+	// Given
+	grid, err := EGM96()
+	if err == ErrNoGeoid {
+		t.Skip("Built without the EGM96 grid, see geoiddata/README.md")
+	}
+	defaultGeoidMu.Lock()
+	defaultGeoid, defaultGeoidSet = nil, false
+	defaultGeoidMu.Unlock()
+
+	// When
+	n, err := GeoidUndulation(-23.6174460, 133.8747120)
+
+	// Then
+	if err != nil {
+		t.Fatalf("GeoidUndulation() error = %v", err)
+	}
+	if expected := grid.Undulation(-23.6174460, 133.8747120); n != expected {
+		t.Errorf("Default geoid is not EGM96. Got: %f, Expected: %f", n, expected)
+	}
+}
@@ -0,0 +1,178 @@
+package cot
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// HeightUnit identifies a unit of length using the codes ATAK writes to height_unit
+type HeightUnit int
+
+// Height unit codes as used by ATAK
+const (
+	HeightUnitKilometers    HeightUnit = 0
+	HeightUnitMeters        HeightUnit = 1
+	HeightUnitMiles         HeightUnit = 2
+	HeightUnitYards         HeightUnit = 3
+	HeightUnitFeet          HeightUnit = 4
+	HeightUnitNauticalMiles HeightUnit = 5
+)
+
+// Length conversion factors to meters
+const (
+	MetersPerFoot         = 0.3048
+	MetersPerYard         = 0.9144
+	MetersPerMile         = 1609.344
+	MetersPerNauticalMile = 1852.0
+)
+
+// metersPer returns the number of meters in one of the given unit
+func (u HeightUnit) metersPer() (float64, error) {
+	switch u {
+	case HeightUnitKilometers:
+		return 1000, nil
+	case HeightUnitMeters:
+		return 1, nil
+	case HeightUnitMiles:
+		return MetersPerMile, nil
+	case HeightUnitYards:
+		return MetersPerYard, nil
+	case HeightUnitFeet:
+		return MetersPerFoot, nil
+	case HeightUnitNauticalMiles:
+		return MetersPerNauticalMile, nil
+	default:
+		return 0, fmt.Errorf("unknown height unit: %d", u)
+	}
+}
+
+// String returns the name of the unit
+func (u HeightUnit) String() string {
+	switch u {
+	case HeightUnitKilometers:
+		return "kilometers"
+	case HeightUnitMeters:
+		return "meters"
+	case HeightUnitMiles:
+		return "miles"
+	case HeightUnitYards:
+		return "yards"
+	case HeightUnitFeet:
+		return "feet"
+	case HeightUnitNauticalMiles:
+		return "nautical miles"
+	default:
+		return fmt.Sprintf("HeightUnit(%d)", int(u))
+	}
+}
+
+// ToMeters converts a length in the unit to meters
+func (u HeightUnit) ToMeters(value float64) (float64, error) {
+	m, err := u.metersPer()
+	if err != nil {
+		return 0, err
+	}
+	return value * m, nil
+}
+
+// FromMeters converts a length in meters to the unit
+func (u HeightUnit) FromMeters(meters float64) (float64, error) {
+	m, err := u.metersPer()
+	if err != nil {
+		return 0, err
+	}
+	return meters / m, nil
+}
+
+// ConvertHeight converts a length between units
+func ConvertHeight(value float64, from, to HeightUnit) (float64, error) {
+	meters, err := from.ToMeters(value)
+	if err != nil {
+		return 0, err
+	}
+	return to.FromMeters(meters)
+}
+
+// HeightReference identifies the datum a height is measured from
+type HeightReference string
+
+const (
+	// HeightReferenceHAE is height above the WGS84 ellipsoid
+	HeightReferenceHAE HeightReference = "HAE"
+	// HeightReferenceMSL is height above mean sea level (the geoid)
+	HeightReferenceMSL HeightReference = "MSL"
+	// HeightReferenceAGL is height above ground level
+	HeightReferenceAGL HeightReference = "AGL"
+)
+
+// Height represents the height element. ATAK writes the value in meters as the element
+// text; unit and reference optionally record how the height was originally reported.
+type Height struct {
+	XMLName xml.Name `xml:"height" json:"-"`
+
+	Value     float64         `xml:",chardata" json:"value"`
+	Unit      string          `xml:"unit,attr,omitempty" json:"unit,omitempty"`
+	Reference HeightReference `xml:"reference,attr,omitempty" json:"reference,omitempty"`
+}
+
+// SetValue sets the height in meters
+func (h *Height) SetValue(meters float64) *Height {
+	h.Value = meters
+	return h
+}
+
+// SetUnit sets the unit attribute of the height
+func (h *Height) SetUnit(unit string) *Height {
+	h.Unit = unit
+	return h
+}
+
+// SetReference sets the reference attribute of the height
+func (h *Height) SetReference(ref HeightReference) *Height {
+	h.Reference = ref
+	return h
+}
+
+// In returns the height converted to the given unit
+func (h Height) In(unit HeightUnit) (float64, error) {
+	return unit.FromMeters(h.Value)
+}
+
+// HeightUnitDetail represents the height_unit element, the unit ATAK uses to display the height
+type HeightUnitDetail struct {
+	XMLName xml.Name   `xml:"height_unit" json:"-"`
+	Value   HeightUnit `xml:",chardata" json:"value"`
+}
+
+// SetHeight sets the point's height above ellipsoid from a height in the given unit and
+// reference, and records the height in meters and the display unit in the height and
+// height_unit details. MSL heights are converted using the geoid set with SetGeoid.
+// AGL heights cannot be converted without terrain data, so they are recorded in the
+// details and leave hae unchanged.
+func (e *Event) SetHeight(value float64, unit HeightUnit, ref HeightReference) error {
+	meters, err := unit.ToMeters(value)
+	if err != nil {
+		return err
+	}
+
+	switch ref {
+	case HeightReferenceHAE, "":
+		ref = HeightReferenceHAE
+		e.Point.SetHae(meters)
+	case HeightReferenceMSL:
+		if err := e.Point.SetMSL(meters); err != nil {
+			return err
+		}
+	case HeightReferenceAGL:
+	default:
+		return fmt.Errorf("unknown height reference: %q", ref)
+	}
+
+	e.Detail.Height = &Height{
+		Value:     meters,
+		Unit:      unit.String(),
+		Reference: ref,
+	}
+	e.Detail.HeightUnit = &HeightUnitDetail{Value: unit}
+	return nil
+}
@@ -0,0 +1,139 @@
+package cot
+
+import (
+	"encoding/xml"
+	"math"
+	"testing"
+)
+
+func TestHeightMarshalXML(t *testing.T) {
+	// Given
+	height := Height{Value: 30.48, Unit: "feet", Reference: HeightReferenceMSL}
+
+	// When
+	data, err := xml.Marshal(height)
+	if err != nil {
+		t.Fatalf("Failed to marshal Height to XML: %v", err)
+	}
+
+	// Then
+	expected := `<height unit="feet" reference="MSL">30.48</height>`
+	if string(data) != expected {
+		t.Errorf("Marshaled XML does not match expected.\nGot: %s\nExpected: %s", string(data), expected)
+	}
+}
+
+func TestHeightUnmarshalXML(t *testing.T) {
+	// Given
+	xmlData := `<detail><height>30.48</height><height_unit>4</height_unit></detail>`
+
+	// When
+	var detail Detail
+	err := xml.Unmarshal([]byte(xmlData), &detail)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal XML to Detail: %v", err)
+	}
+
+	// Then
+	if detail.Height == nil || detail.Height.Value != 30.48 {
+		t.Fatalf("Height not correctly unmarshaled. Got: %+v", detail.Height)
+	}
+	if detail.HeightUnit == nil || detail.HeightUnit.Value != HeightUnitFeet {
+		t.Fatalf("HeightUnit not correctly unmarshaled. Got: %+v", detail.HeightUnit)
+	}
+	feet, err := detail.Height.In(detail.HeightUnit.Value)
+	if err != nil || math.Abs(feet-100) > 1e-9 {
+		t.Errorf("Height in feet not correct. Got: %f, %v", feet, err)
+	}
+}
+
+func TestConvertHeight(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    float64
+		from, to HeightUnit
+		expected float64
+	}{
+		{name: "Feet to meters", value: 1000, from: HeightUnitFeet, to: HeightUnitMeters, expected: 304.8},
+		{name: "Meters to feet", value: 304.8, from: HeightUnitMeters, to: HeightUnitFeet, expected: 1000},
+		{name: "Kilometers to miles", value: 1.609344, from: HeightUnitKilometers, to: HeightUnitMiles, expected: 1},
+		{name: "Nautical miles to yards", value: 1, from: HeightUnitNauticalMiles, to: HeightUnitYards, expected: 1852 / 0.9144},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertHeight(tt.value, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("ConvertHeight() error = %v", err)
+			}
+			if math.Abs(got-tt.expected) > 1e-9 {
+				t.Errorf("ConvertHeight() = %f, want %f", got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := ConvertHeight(1, HeightUnit(42), HeightUnitMeters); err == nil {
+		t.Errorf("Expected an error for an unknown unit")
+	}
+}
+
+func TestEventSetHeight(t *testing.T) {
+	// Given
+	SetGeoid(constantGeoid(-33))
+	defer SetGeoid(nil)
+	event := NewEvent("a-f-A", "height-test")
+	event.Point = NewPoint(38.8977, -77.0365)
+
+	// When
+	err := event.SetHeight(1000, HeightUnitFeet, HeightReferenceMSL)
+	if err != nil {
+		t.Fatalf("SetHeight() error = %v", err)
+	}
+
+	// Then
+	if event.Point.Hae == nil || math.Abs(*event.Point.Hae-(304.8-33)) > 1e-9 {
+		t.Errorf("Hae not correct. Got: %v", event.Point.Hae)
+	}
+	if event.Detail.Height == nil || math.Abs(event.Detail.Height.Value-304.8) > 1e-9 {
+		t.Errorf("Height detail not correct. Got: %+v", event.Detail.Height)
+	}
+	if event.Detail.Height.Reference != HeightReferenceMSL || event.Detail.Height.Unit != "feet" {
+		t.Errorf("Height reference or unit not correct. Got: %+v", event.Detail.Height)
+	}
+	if event.Detail.HeightUnit == nil || event.Detail.HeightUnit.Value != HeightUnitFeet {
+		t.Errorf("HeightUnit detail not correct. Got: %+v", event.Detail.HeightUnit)
+	}
+}
+
+func TestEventSetHeightAGL(t *testing.T) {
+	// Given
+	event := NewEvent("a-f-A", "height-test")
+
+	// When
+	err := event.SetHeight(50, HeightUnitMeters, HeightReferenceAGL)
+
+	// Then
+	if err != nil {
+		t.Fatalf("SetHeight() error = %v", err)
+	}
+	if event.Point.Hae != nil {
+		t.Errorf("AGL height should not change hae. Got: %f", *event.Point.Hae)
+	}
+	if event.Detail.Height == nil || event.Detail.Height.Reference != HeightReferenceAGL {
+		t.Errorf("Height detail not correct. Got: %+v", event.Detail.Height)
+	}
+}
+
+func TestEventSetHeightMSLWithoutGeoid(t *testing.T) {
+	// Given
+	SetGeoid(nil)
+	event := NewEvent("a-f-A", "height-test")
+
+	// When
+	err := event.SetHeight(50, HeightUnitMeters, HeightReferenceMSL)
+
+	// Then
+	if err != ErrNoGeoid {
+		t.Errorf("Expected ErrNoGeoid, got: %v", err)
+	}
+}
@@ -2,23 +2,30 @@ package cot
 
 import (
 	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // Link represents a link element as defined in link.xsd
 type Link struct {
-	XMLName    xml.Name `xml:"link" json:"-"`
-	UID        string   `xml:"uid,attr,omitempty" json:"uid,omitempty"`
-	Type       string   `xml:"type,attr,omitempty" json:"type,omitempty"`
-	Relation   string   `xml:"relation,attr,omitempty" json:"relation,omitempty"`
-	Point      string   `xml:"point,attr,omitempty" json:"point,omitempty"`
-	URL        string   `xml:"url,attr,omitempty" json:"url,omitempty"`
-	Remarks    string   `xml:"remarks,attr,omitempty" json:"remarks,omitempty"`
-	Production *CotTime `xml:"production_time,attr,omitempty" json:"production_time,omitempty"`
-	Version    string   `xml:"version,attr,omitempty" json:"version,omitempty"`
-	Parent     string   `xml:"parent,attr,omitempty" json:"parent,omitempty"`
-	Medium     string   `xml:"medium,attr,omitempty" json:"medium,omitempty"`
-	Style      *Style   `xml:"Style,omitempty" json:"style,omitempty"`
+	XMLName        xml.Name `xml:"link" json:"-"`
+	UID            string   `xml:"uid,attr,omitempty" json:"uid,omitempty"`
+	Callsign       string   `xml:"callsign,attr,omitempty" json:"callsign,omitempty"`
+	Type           string   `xml:"type,attr,omitempty" json:"type,omitempty"`
+	Relation       string   `xml:"relation,attr,omitempty" json:"relation,omitempty"`
+	Point          string   `xml:"point,attr,omitempty" json:"point,omitempty"`
+	URL            string   `xml:"url,attr,omitempty" json:"url,omitempty"`
+	Remarks        string   `xml:"remarks,attr,omitempty" json:"remarks,omitempty"`
+	Production     *CotTime `xml:"production_time,attr,omitempty" json:"production_time,omitempty"`
+	Version        string   `xml:"version,attr,omitempty" json:"version,omitempty"`
+	Parent         string   `xml:"parent,attr,omitempty" json:"parent,omitempty"`
+	ParentCallsign string   `xml:"parent_callsign,attr,omitempty" json:"parent_callsign,omitempty"`
+	Medium         string   `xml:"medium,attr,omitempty" json:"medium,omitempty"`
+	Line           string   `xml:"line,attr,omitempty" json:"line,omitempty"` // Embedded stroke event of a telestration
+	Style          *Style   `xml:"Style,omitempty" json:"style,omitempty"`
 }
 
 // SetUID sets the uid attribute of the link
@@ -27,6 +34,12 @@ func (l *Link) SetUID(uid string) *Link {
 	return l
 }
 
+// SetCallsign sets the callsign attribute of the link
+func (l *Link) SetCallsign(callsign string) *Link {
+	l.Callsign = callsign
+	return l
+}
+
 // SetType sets the type attribute of the link
 func (l *Link) SetType(linkType string) *Link {
 	l.Type = linkType
@@ -45,6 +58,61 @@ func (l *Link) SetPoint(point string) *Link {
 	return l
 }
 
+// LatLon parses the point attribute of the link. The attribute holds "lat,lon" or
+// "lat,lon,hae"; a hae is set on the returned point only when present.
+func (l *Link) LatLon() (Point, error) {
+	if l.Point == "" {
+		return Point{}, errors.New("link has no point")
+	}
+	return parseLatLon(l.Point, ",")
+}
+
+// SetLatLon sets the point attribute of the link from a typed point, in the "lat,lon"
+// format ATAK writes. The hae is appended as a third value when it is known.
+func (l *Link) SetLatLon(p Point) *Link {
+	l.Point = formatVertex(p)
+	return l
+}
+
+// formatLatLon formats a position as "lat,lon"
+func formatLatLon(p Point) string {
+	return strconv.FormatFloat(p.Lat, 'f', -1, 64) + "," + strconv.FormatFloat(p.Lon, 'f', -1, 64)
+}
+
+// formatVertex formats a position as "lat,lon", or "lat,lon,hae" when the hae is known
+func formatVertex(p Point) string {
+	if hae, ok := knownValue(p.Hae); ok {
+		return formatLatLon(p) + "," + strconv.FormatFloat(hae, 'f', -1, 64)
+	}
+	return formatLatLon(p)
+}
+
+// parseLatLon parses "lat<sep>lon" with an optional "<sep>hae"
+func parseLatLon(s, sep string) (Point, error) {
+	parts := strings.Split(s, sep)
+	if len(parts) != 2 && len(parts) != 3 {
+		return Point{}, fmt.Errorf("invalid point %q: expected lat%slon[%shae]", s, sep, sep)
+	}
+
+	values := make([]float64, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return Point{}, fmt.Errorf("invalid point %q: %w", s, err)
+		}
+		values[i] = v
+	}
+	if values[0] < -90 || values[0] > 90 || values[1] < -180 || values[1] > 180 {
+		return Point{}, fmt.Errorf("invalid point %q: coordinates out of range", s)
+	}
+
+	p := NewPoint(values[0], values[1])
+	if len(values) == 3 {
+		p.SetHae(values[2])
+	}
+	return p, nil
+}
+
 // SetURL sets the url attribute of the link
 func (l *Link) SetURL(url string) *Link {
 	l.URL = url
@@ -76,6 +144,12 @@ func (l *Link) SetParent(parent string) *Link {
 	return l
 }
 
+// SetParentCallsign sets the parent_callsign attribute of the link
+func (l *Link) SetParentCallsign(callsign string) *Link {
+	l.ParentCallsign = callsign
+	return l
+}
+
 // SetMedium sets the medium attribute of the link
 func (l *Link) SetMedium(medium string) *Link {
 	l.Medium = medium
@@ -87,3 +161,21 @@ func (l *Link) SetStyle(style *Style) *Link {
 	l.Style = style
 	return l
 }
+
+// SetLine sets the line attribute of the link
+func (l *Link) SetLine(line string) *Link {
+	l.Line = line
+	return l
+}
+
+// LineEvent parses the stroke event embedded in the line attribute of a telestration link
+func (l *Link) LineEvent() (*Event, error) {
+	if l.Line == "" {
+		return nil, errors.New("link has no line")
+	}
+	var event Event
+	if err := xml.Unmarshal([]byte(l.Line), &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
@@ -210,3 +210,101 @@ func TestLinkSetters(t *testing.T) {
 		t.Errorf("Setter methods did not return the link instance for chaining")
 	}
 }
+
+func TestLinkLatLon(t *testing.T) {
+	tests := []struct {
+		name    string
+		point   string
+		lat     float64
+		lon     float64
+		hae     float64
+		hasHae  bool
+		wantErr bool
+	}{
+		{name: "lat lon", point: "38.83,-77.06", lat: 38.83, lon: -77.06},
+		{name: "with hae", point: "38.83,-77.06,120.5", lat: 38.83, lon: -77.06, hae: 120.5, hasHae: true},
+		{name: "spaces", point: "38.83, -77.06", lat: 38.83, lon: -77.06},
+		{name: "empty", point: "", wantErr: true},
+		{name: "single value", point: "38.83", wantErr: true},
+		{name: "not a number", point: "abc,-77.06", wantErr: true},
+		{name: "out of range", point: "91,0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			link := Link{Point: tt.point}
+
+			// When
+			p, err := link.LatLon()
+
+			// Then
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q", tt.point)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if p.Lat != tt.lat || p.Lon != tt.lon {
+				t.Errorf("Unexpected position. Got: %v,%v, Expected: %v,%v", p.Lat, p.Lon, tt.lat, tt.lon)
+			}
+			if !tt.hasHae && p.Hae != nil {
+				t.Errorf("Expected no hae. Got: %v", *p.Hae)
+			}
+			if tt.hasHae && (p.Hae == nil || *p.Hae != tt.hae) {
+				t.Errorf("Unexpected hae. Got: %v, Expected: %v", p.Hae, tt.hae)
+			}
+		})
+	}
+}
+
+func TestLinkSetLatLon(t *testing.T) {
+	// Given
+	withHae := NewPoint(38.83, -77.06)
+	withHae.SetHae(120.5)
+	unknownHae := NewPoint(38.83, -77.06)
+	unknownHae.SetHae(DefaultValue)
+
+	// When
+	plain := (&Link{}).SetLatLon(NewPoint(38.83, -77.06))
+	elevated := (&Link{}).SetLatLon(withHae)
+	unknown := (&Link{}).SetLatLon(unknownHae)
+
+	// Then
+	if plain.Point != "38.83,-77.06" {
+		t.Errorf("Unexpected point. Got: %s, Expected: %s", plain.Point, "38.83,-77.06")
+	}
+	if elevated.Point != "38.83,-77.06,120.5" {
+		t.Errorf("Unexpected point. Got: %s, Expected: %s", elevated.Point, "38.83,-77.06,120.5")
+	}
+	if unknown.Point != "38.83,-77.06" {
+		t.Errorf("Unknown hae should be omitted. Got: %s", unknown.Point)
+	}
+}
+
+func TestLinkLatLonRoundTrip(t *testing.T) {
+	// Given
+	original := `<link point="38.8546301,-77.0573912"></link>`
+	var link Link
+	if err := xml.Unmarshal([]byte(original), &link); err != nil {
+		t.Fatalf("Failed to unmarshal link: %v", err)
+	}
+
+	// When
+	p, err := link.LatLon()
+	if err != nil {
+		t.Fatalf("Failed to parse point: %v", err)
+	}
+	data, err := xml.Marshal((&Link{}).SetLatLon(p))
+	if err != nil {
+		t.Fatalf("Failed to marshal link: %v", err)
+	}
+
+	// Then
+	if string(data) != original {
+		t.Errorf("Round trip changed the link.\nGot: %s\nExpected: %s", string(data), original)
+	}
+}
@@ -0,0 +1,120 @@
+package cot
+
+import (
+	"encoding/xml"
+	"image/color"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TypeSpotMap is the type of spot map markers
+const TypeSpotMap = "b-m-p-s-m"
+
+// MarkerStaleTime is how long markers placed by hand stay valid
+const MarkerStaleTime = 14 * 24 * time.Hour
+
+// Icon set paths of the icon sets built into ATAK
+const (
+	IconSetSpotMap = "COT_MAPPING_SPOTMAP"
+	IconSet2525B   = "COT_MAPPING_2525B"
+)
+
+// UserIcon represents the usericon element as defined in usericon.xsd
+type UserIcon struct {
+	XMLName     xml.Name `xml:"usericon" json:"-"`
+	IconSetPath string   `xml:"iconsetpath,attr" json:"iconsetpath"`
+}
+
+// SetIconSetPath sets the iconsetpath attribute of the user icon
+func (u *UserIcon) SetIconSetPath(path string) *UserIcon {
+	u.IconSetPath = path
+	return u
+}
+
+// SetUserIcon sets the usericon element of the detail
+func (d *Detail) SetUserIcon(path string) *UserIcon {
+	d.UserIcon = &UserIcon{IconSetPath: path}
+	return d.UserIcon
+}
+
+// IconSetPath returns the path of an icon in a user icon set, e.g.
+// f7f71666-8b28-4b57-9fbb-e38e61d33b79/Google/hiker.png
+func IconSetPath(iconSetUID, group, filename string) string {
+	return iconSetUID + "/" + group + "/" + filename
+}
+
+// SpotMapIconPath returns the icon path of a spot map marker of the given signed ARGB color
+func SpotMapIconPath(argb int64) string {
+	return IconSetSpotMap + "/" + TypeSpotMap + "/" + strconv.FormatInt(argb, 10)
+}
+
+// MIL2525IconPath returns the icon path of the 2525B symbol for a CoT type, e.g.
+// COT_MAPPING_2525B/a-u/a-u-G
+func MIL2525IconPath(eventType string) string {
+	parts := strings.SplitN(eventType, "-", 3)
+	affiliation := eventType
+	if len(parts) >= 2 {
+		affiliation = parts[0] + "-" + parts[1]
+	}
+	return IconSet2525B + "/" + affiliation + "/" + eventType
+}
+
+// newMarkerEvent creates a marker with the details ATAK writes for markers placed by hand
+func newMarkerEvent(eventType, uid, callsign string, point Point, argb int64, iconPath string) *Event {
+	event := NewEvent(eventType, uid)
+	event.SetHow("h-g-i-g-o")
+	event.SetStale(event.Time.Add(MarkerStaleTime).Time())
+	event.SetPoint(point)
+
+	event.Detail.AddStatus().SetReadiness(true)
+	event.Detail.AddArchive()
+	event.Detail.AddContact(callsign)
+	event.Detail.AddRemarks("")
+	event.Detail.SetColor(argb)
+	event.Detail.AddPrecisionLocation("???")
+	event.Detail.SetUserIcon(iconPath)
+	return event
+}
+
+// NewSpotMarker creates a b-m-p-s-m spot map marker of the given color
+func NewSpotMarker(uid, callsign string, point Point, c color.Color) *Event {
+	argb := signedColor(c)
+	return newMarkerEvent(TypeSpotMap, uid, callsign, point, argb, SpotMapIconPath(argb))
+}
+
+// NewIconSetMarker creates a marker of the given CoT type shown with an icon from a user
+// icon set, identified by the icon set UID, the group within the set and the file name
+func NewIconSetMarker(uid, callsign, eventType string, point Point, iconSetUID, group, filename string) *Event {
+	white := signedColor(color.White)
+	return newMarkerEvent(eventType, uid, callsign, point, white, IconSetPath(iconSetUID, group, filename))
+}
+
+// NewMIL2525Marker creates a marker shown with the 2525B symbol of its CoT type
+func NewMIL2525Marker(uid, callsign, eventType string, point Point) *Event {
+	white := signedColor(color.White)
+	return newMarkerEvent(eventType, uid, callsign, point, white, MIL2525IconPath(eventType))
+}
+
+// SetMarkerColor sets the color of a marker. Spot map icons encode the color, so their
+// icon path is updated to match.
+func (e *Event) SetMarkerColor(c color.Color) *Event {
+	argb := signedColor(c)
+	e.Detail.SetColor(argb)
+	if e.Type == TypeSpotMap {
+		e.Detail.SetUserIcon(SpotMapIconPath(argb))
+	}
+	return e
+}
+
+// AddParentLink adds the p-p link ATAK writes to markers to record the user that created them
+func (d *Detail) AddParentLink(uid, eventType, callsign string, produced time.Time) *Link {
+	production := CotTime(produced)
+	return d.AddLink(&Link{
+		UID:            uid,
+		Production:     &production,
+		Type:           eventType,
+		ParentCallsign: callsign,
+		Relation:       "p-p",
+	})
+}
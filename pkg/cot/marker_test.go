@@ -0,0 +1,139 @@
+package cot
+
+import (
+	"encoding/xml"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSpotMarker(t *testing.T) {
+	// Given
+	point := NewPoint(38.85606343062312, -77.0563755018233)
+	red := color.NRGBA{R: 0xFF, A: 0xFF}
+
+	// When
+	event := NewSpotMarker("spot-1", "R 1", point, red)
+
+	// Then
+	if event.Type != TypeSpotMap || event.How != "h-g-i-g-o" {
+		t.Errorf("Unexpected type or how. Got: %s %s", event.Type, event.How)
+	}
+	if got := event.Stale.Time().Sub(event.Time.Time()); got != MarkerStaleTime {
+		t.Errorf("Unexpected stale period. Got: %v, Expected: %v", got, MarkerStaleTime)
+	}
+	data, err := xml.Marshal(event.Detail)
+	if err != nil {
+		t.Fatalf("Failed to marshal detail: %v", err)
+	}
+	expected := []string{
+		`<status readiness="true">`,
+		`<archive></archive>`,
+		`<contact callsign="R 1">`,
+		`<color argb="-65536">`,
+		`<precisionlocation altsrc="???">`,
+		`<usericon iconsetpath="COT_MAPPING_SPOTMAP/b-m-p-s-m/-65536">`,
+	}
+	for _, s := range expected {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("Expected detail to contain %s\nGot: %s", s, string(data))
+		}
+	}
+}
+
+func TestNewIconSetMarker(t *testing.T) {
+	// Given
+	point := NewPoint(38.85513174538468, -77.05143976872927)
+
+	// When
+	event := NewIconSetMarker("marker-1", "hiker 1", "a-u-G", point, "f7f71666-8b28-4b57-9fbb-e38e61d33b79", "Google", "hiker.png")
+
+	// Then
+	if event.Type != "a-u-G" {
+		t.Errorf("Unexpected type. Got: %s, Expected: %s", event.Type, "a-u-G")
+	}
+	if event.Detail.UserIcon.IconSetPath != "f7f71666-8b28-4b57-9fbb-e38e61d33b79/Google/hiker.png" {
+		t.Errorf("Unexpected icon path. Got: %s", event.Detail.UserIcon.IconSetPath)
+	}
+	if event.Detail.Color.Value != -1 {
+		t.Errorf("Unexpected color. Got: %d, Expected: %d", event.Detail.Color.Value, -1)
+	}
+}
+
+func TestNewMIL2525Marker(t *testing.T) {
+	tests := []struct {
+		eventType string
+		expected  string
+	}{
+		{"a-u-G", "COT_MAPPING_2525B/a-u/a-u-G"},
+		{"a-h-G-U-C-I", "COT_MAPPING_2525B/a-h/a-h-G-U-C-I"},
+		{"a-f", "COT_MAPPING_2525B/a-f/a-f"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.eventType, func(t *testing.T) {
+			// When
+			event := NewMIL2525Marker("marker-1", "U.16.135057", tt.eventType, NewPoint(38.85, -77.06))
+
+			// Then
+			if event.Detail.UserIcon.IconSetPath != tt.expected {
+				t.Errorf("Unexpected icon path. Got: %s, Expected: %s", event.Detail.UserIcon.IconSetPath, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSetMarkerColor(t *testing.T) {
+	// Given
+	spot := NewSpotMarker("spot-1", "R 1", NewPoint(38.85, -77.06), color.White)
+	marker := NewMIL2525Marker("marker-1", "U 1", "a-u-G", NewPoint(38.85, -77.06))
+	blue := color.NRGBA{B: 0xFF, A: 0xFF}
+
+	// When
+	spot.SetMarkerColor(blue)
+	marker.SetMarkerColor(blue)
+
+	// Then
+	if spot.Detail.Color.Value != -16776961 || spot.Detail.UserIcon.IconSetPath != "COT_MAPPING_SPOTMAP/b-m-p-s-m/-16776961" {
+		t.Errorf("Unexpected spot color or icon. Got: %d, %s", spot.Detail.Color.Value, spot.Detail.UserIcon.IconSetPath)
+	}
+	if marker.Detail.Color.Value != -16776961 || marker.Detail.UserIcon.IconSetPath != "COT_MAPPING_2525B/a-u/a-u-G" {
+		t.Errorf("Unexpected marker color or icon. Got: %d, %s", marker.Detail.Color.Value, marker.Detail.UserIcon.IconSetPath)
+	}
+}
+
+func TestAddParentLink(t *testing.T) {
+	// Given
+	detail := Detail{}
+	produced := time.Date(2020, 12, 16, 19, 51, 9, 603000000, time.UTC)
+
+	// When
+	detail.AddParentLink("ANDROID-589520ccfcd20f01", "a-f-G-U-C", "HOPE", produced)
+	data, err := xml.Marshal(detail.Links[0])
+	if err != nil {
+		t.Fatalf("Failed to marshal link: %v", err)
+	}
+
+	// Then
+	expected := `<link uid="ANDROID-589520ccfcd20f01" type="a-f-G-U-C" relation="p-p" production_time="2020-12-16T19:51:09.603Z" parent_callsign="HOPE"></link>`
+	if string(data) != expected {
+		t.Errorf("Unexpected link.\nGot: %s\nExpected: %s", string(data), expected)
+	}
+}
+
+func TestUserIconUnmarshalXML(t *testing.T) {
+	// Given
+	xmlData := `<detail><usericon iconsetpath='COT_MAPPING_2525B/a-u/a-u-G'/></detail>`
+
+	// When
+	var detail Detail
+	if err := xml.Unmarshal([]byte(xmlData), &detail); err != nil {
+		t.Fatalf("Failed to unmarshal detail: %v", err)
+	}
+
+	// Then
+	if detail.UserIcon == nil || detail.UserIcon.IconSetPath != "COT_MAPPING_2525B/a-u/a-u-G" {
+		t.Errorf("Unexpected user icon. Got: %+v", detail.UserIcon)
+	}
+}
@@ -0,0 +1,158 @@
+package cot
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// mgrsLatBands are the 8° latitude bands from 80°S to 84°N (band X is 12° tall)
+	mgrsLatBands = "CDEFGHJKLMNPQRSTUVWX"
+	// mgrsRowLetters are the 100km square row letters, which repeat every 2000km
+	mgrsRowLetters = "ABCDEFGHJKLMNPQRSTUV"
+	// mgrsEpsilon in meters is added before truncating UTM coordinates, so that the
+	// floating-point error of a conversion does not move a point on a square edge,
+	// such as one returned by PointFromMGRS, into the square below
+	mgrsEpsilon = 1e-3
+)
+
+// mgrsColumnLetters are the 100km square column letters, one set per zone modulo 3
+var mgrsColumnLetters = [3]string{"ABCDEFGH", "JKLMNPQR", "STUVWXYZ"}
+
+// mgrsLatBand returns the MGRS latitude band letter for a latitude in degrees
+func mgrsLatBand(lat float64) byte {
+	idx := int(math.Floor((lat + 80) / 8))
+	if idx > len(mgrsLatBands)-1 {
+		idx = len(mgrsLatBands) - 1 // Band X extends to 84°N
+	}
+	if idx < 0 {
+		idx = 0
+	}
+	return mgrsLatBands[idx]
+}
+
+// ToMGRS converts the point to an MGRS grid reference such as "18SUJ2337106519".
+// Precision is the number of digits for each of easting and northing, from 0 (100km)
+// to 5 (1m).
+func (p Point) ToMGRS(precision int) (string, error) {
+	if precision < 0 || precision > 5 {
+		return "", fmt.Errorf("invalid MGRS precision: %d (must be 0-5)", precision)
+	}
+
+	u, err := p.ToUTM()
+	if err != nil {
+		return "", err
+	}
+
+	e, n := u.Easting+mgrsEpsilon, u.Northing+mgrsEpsilon
+	col := int(math.Floor(e / 100000))
+	row := int(math.Floor(n/100000)) % 20
+	if col < 1 || col > 8 {
+		return "", fmt.Errorf("easting outside MGRS grid: %f", u.Easting)
+	}
+
+	colLetter := mgrsColumnLetters[(u.Zone-1)%3][col-1]
+	rowOffset := 0
+	if u.Zone%2 == 0 {
+		rowOffset = 5 // Even zones start the row letters at F
+	}
+	rowLetter := mgrsRowLetters[(row+rowOffset)%20]
+
+	// Truncate rather than round, so the reference names the square containing the point
+	divisor := math.Pow(10, float64(5-precision))
+	easting := int(math.Floor(math.Mod(e, 100000) / divisor))
+	northing := int(math.Floor(math.Mod(n, 100000) / divisor))
+
+	ref := fmt.Sprintf("%02d%c%c%c", u.Zone, mgrsLatBand(p.Lat), colLetter, rowLetter)
+	if precision > 0 {
+		ref += fmt.Sprintf("%0*d%0*d", precision, easting, precision, northing)
+	}
+	return ref, nil
+}
+
+// PointFromMGRS converts an MGRS grid reference to the point at the south-west corner of
+// the referenced grid square. Spaces are ignored, so both "18SUJ2337106519" and
+// "18S UJ 23371 06519" are accepted.
+func PointFromMGRS(ref string) (Point, error) {
+	s := strings.ToUpper(strings.Join(strings.Fields(ref), ""))
+
+	// Zone is one or two digits
+	i := 0
+	for i < len(s) && i < 2 && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i == 0 {
+		return Point{}, fmt.Errorf("invalid MGRS reference %q: missing zone", ref)
+	}
+	zone, _ := strconv.Atoi(s[:i])
+	if zone < 1 || zone > 60 {
+		return Point{}, fmt.Errorf("invalid MGRS reference %q: zone %d out of range", ref, zone)
+	}
+
+	rest := s[i:]
+	if len(rest) < 3 {
+		return Point{}, fmt.Errorf("invalid MGRS reference %q: missing band or square", ref)
+	}
+
+	band := rest[0]
+	bandIdx := strings.IndexByte(mgrsLatBands, band)
+	if bandIdx < 0 {
+		return Point{}, fmt.Errorf("invalid MGRS reference %q: latitude band %c", ref, band)
+	}
+
+	col := strings.IndexByte(mgrsColumnLetters[(zone-1)%3], rest[1])
+	if col < 0 {
+		return Point{}, fmt.Errorf("invalid MGRS reference %q: column letter %c not valid for zone %d", ref, rest[1], zone)
+	}
+	row := strings.IndexByte(mgrsRowLetters, rest[2])
+	if row < 0 {
+		return Point{}, fmt.Errorf("invalid MGRS reference %q: row letter %c", ref, rest[2])
+	}
+	if zone%2 == 0 {
+		row = (row - 5 + 20) % 20
+	}
+
+	digits := rest[3:]
+	if len(digits)%2 != 0 || len(digits) > 10 {
+		return Point{}, fmt.Errorf("invalid MGRS reference %q: easting and northing must have the same number of digits", ref)
+	}
+	precision := len(digits) / 2
+	var e, n float64
+	if precision > 0 {
+		ei, err := strconv.Atoi(digits[:precision])
+		if err != nil {
+			return Point{}, fmt.Errorf("invalid MGRS reference %q: easting: %w", ref, err)
+		}
+		ni, err := strconv.Atoi(digits[precision:])
+		if err != nil {
+			return Point{}, fmt.Errorf("invalid MGRS reference %q: northing: %w", ref, err)
+		}
+		scale := math.Pow(10, float64(5-precision))
+		e, n = float64(ei)*scale, float64(ni)*scale
+	}
+
+	easting := float64(col+1)*100000 + e
+	northing := float64(row)*100000 + n
+
+	// Row letters repeat every 2000km; add 2000km blocks until the northing reaches the
+	// bottom of the latitude band. The band bottom is taken on the central meridian,
+	// less one 100km row since squares straddle the band edge.
+	bandLat := float64(bandIdx)*8 - 80
+	_, bandNorthing := transverseMercator(bandLat, 0, 0)
+	if bandLat < 0 {
+		bandNorthing += utmFalseNorthing
+	}
+	bandNorthing = math.Floor(bandNorthing/100000)*100000 - 100000
+	for northing < bandNorthing {
+		northing += 2000000
+	}
+
+	hemisphere := byte('N')
+	if band < 'N' {
+		hemisphere = 'S'
+	}
+
+	return UTM{Zone: zone, Hemisphere: hemisphere, Easting: easting, Northing: northing}.ToPoint()
+}
@@ -0,0 +1,138 @@
+package cot
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPointToMGRS(t *testing.T) {
+	point := NewPoint(48.8582, 2.2945)
+
+	tests := []struct {
+		precision int
+		expected  string
+	}{
+		{precision: 5, expected: "31UDQ4825111932"},
+		{precision: 3, expected: "31UDQ482119"},
+		{precision: 1, expected: "31UDQ41"},
+		{precision: 0, expected: "31UDQ"},
+	}
+
+	for _, tt := range tests {
+		got, err := point.ToMGRS(tt.precision)
+		if err != nil {
+			t.Fatalf("ToMGRS(%d) error = %v", tt.precision, err)
+		}
+		if got != tt.expected {
+			t.Errorf("ToMGRS(%d) = %s, want %s", tt.precision, got, tt.expected)
+		}
+	}
+}
+
+func TestPointToMGRSInvalidPrecision(t *testing.T) {
+	// When
+	_, err := NewPoint(48.8582, 2.2945).ToMGRS(6)
+
+	// Then
+	if err == nil {
+		t.Errorf("Expected an error for precision 6")
+	}
+}
+
+func TestPointFromMGRS(t *testing.T) {
+	tests := []struct {
+		name string
+		ref  string
+		lat  float64
+		lon  float64
+	}{
+		{name: "Washington Monument", ref: "18SUJ2337106519", lat: 38.889800, lon: -77.036542},
+		{name: "Spaced reference", ref: "31U DQ 48251 11932", lat: 48.858198, lon: 2.294497},
+		{name: "Lowercase reference", ref: "31udq4825111932", lat: 48.858198, lon: 2.294497},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			point, err := PointFromMGRS(tt.ref)
+			if err != nil {
+				t.Fatalf("PointFromMGRS() error = %v", err)
+			}
+			if math.Abs(point.Lat-tt.lat) > 1e-5 || math.Abs(point.Lon-tt.lon) > 1e-5 {
+				t.Errorf("PointFromMGRS() = %f,%f, want %f,%f", point.Lat, point.Lon, tt.lat, tt.lon)
+			}
+		})
+	}
+}
+
+func TestPointFromMGRSInvalid(t *testing.T) {
+	refs := []string{
+		"",
+		"18",
+		"61SUJ2337106519",
+		"18IUJ2337106519",
+		"18SAJ2337106519",
+		"18SUJ233710651",
+		"18SUJ23371065AB",
+	}
+
+	for _, ref := range refs {
+		if _, err := PointFromMGRS(ref); err == nil {
+			t.Errorf("PointFromMGRS(%q) expected an error", ref)
+		}
+	}
+}
+
+func TestMGRSRoundTrip(t *testing.T) {
+	points := []Point{
+		NewPoint(38.8977, -77.0365),
+		NewPoint(-33.8568, 151.2153),
+		NewPoint(60, 5),
+		NewPoint(78, 15),
+		NewPoint(-79.9, -179.9),
+		NewPoint(83.9, 179.9),
+	}
+
+	for _, p := range points {
+		ref, err := p.ToMGRS(5)
+		if err != nil {
+			t.Fatalf("Failed to convert %v to MGRS: %v", p, err)
+		}
+		back, err := PointFromMGRS(ref)
+		if err != nil {
+			t.Fatalf("Failed to convert %s back to a point: %v", ref, err)
+		}
+		if d := p.DistanceTo(back); d > 1.5 {
+			t.Errorf("Round trip of %s off by %fm", ref, d)
+		}
+	}
+}
+
+func TestMGRSGridRoundTrip(t *testing.T) {
+	refs := []string{
+		"18SUJ2337106519",
+		"31UDQ4825111932",
+		"56HLH3430761245",
+		"33XWG0000000000",
+		"18SUJ23370651",
+		"18SUJ",
+	}
+
+	for _, ref := range refs {
+		// Given
+		point, err := PointFromMGRS(ref)
+		if err != nil {
+			t.Fatalf("PointFromMGRS(%q) error = %v", ref, err)
+		}
+
+		// When
+		got, err := point.ToMGRS((len(ref) - 5) / 2)
+
+		// Then
+		if err != nil {
+			t.Fatalf("ToMGRS() error = %v", err)
+		}
+		if got != ref {
+			t.Errorf("Round trip of grid reference. Got: %s, Expected: %s", got, ref)
+		}
+	}
+}
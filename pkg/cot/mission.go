@@ -0,0 +1,78 @@
+package cot
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+)
+
+// Event types of the mission notifications TAK Server sends to mission subscribers
+const (
+	MissionChangeType = "t-x-m-c"
+	MissionCreateType = "t-x-m-n"
+	MissionDeleteType = "t-x-m-d"
+)
+
+// Mission represents a mission element as defined in mission.xsd. It carries the
+// changes of a TAK Server data sync mission in t-x-m-c notifications.
+type Mission struct {
+	XMLName xml.Name `xml:"mission" json:"-"`
+	// Type is the notification kind, e.g. "CHANGE", "CREATE" or "DELETE"
+	Type      string          `xml:"type,attr" json:"type"`
+	Tool      string          `xml:"tool,attr" json:"tool"`
+	Name      string          `xml:"name,attr" json:"name"`
+	AuthorUID string          `xml:"authorUid,attr,omitempty" json:"author_uid,omitempty"`
+	Changes   []MissionChange `xml:"MissionChanges>MissionChange,omitempty" json:"changes,omitempty"`
+}
+
+// MissionChange is a single change of a mission
+type MissionChange struct {
+	// ContentResource is the file added or removed by content changes
+	ContentResource *ContentResource `xml:"contentResource,omitempty" json:"content_resource,omitempty"`
+	// ContentUID is the UID added or removed by UID changes
+	ContentUID   string               `xml:"contentUid,omitempty" json:"content_uid,omitempty"`
+	CreatorUID   string               `xml:"creatorUid" json:"creator_uid"`
+	ExternalData *MissionExternalData `xml:"externalData,omitempty" json:"external_data,omitempty"`
+	MissionName  string               `xml:"missionName" json:"mission_name"`
+	Timestamp    time.Time            `xml:"timestamp" json:"timestamp"`
+	// Type is the change kind, e.g. "ADD_CONTENT" or "REMOVE_CONTENT"
+	Type    string                `xml:"type" json:"type"`
+	Details *MissionChangeDetails `xml:"details,omitempty" json:"details,omitempty"`
+}
+
+// ContentResource describes a file stored on TAK Server
+type ContentResource struct {
+	CreatorUID     string    `xml:"creatorUid,omitempty" json:"creator_uid,omitempty"`
+	Filename       string    `xml:"filename,omitempty" json:"filename,omitempty"`
+	Hash           string    `xml:"hash" json:"hash"`
+	Keywords       []string  `xml:"keywords,omitempty" json:"keywords,omitempty"`
+	MIMEType       string    `xml:"mimeType" json:"mime_type"`
+	Name           string    `xml:"name" json:"name"`
+	Size           int64     `xml:"size" json:"size"`
+	SubmissionTime time.Time `xml:"submissionTime" json:"submission_time"`
+	Submitter      string    `xml:"submitter" json:"submitter"`
+	Tool           string    `xml:"tool,omitempty" json:"tool,omitempty"`
+	UID            string    `xml:"uid" json:"uid"`
+}
+
+// MissionExternalData links a mission to data held by another system
+type MissionExternalData struct {
+	UID     string `xml:"uid" json:"uid"`
+	Name    string `xml:"name" json:"name"`
+	Tool    string `xml:"tool" json:"tool"`
+	URLData string `xml:"urlData" json:"url_data"`
+	URLView string `xml:"urlView" json:"url_view"`
+}
+
+// MissionChangeDetails describes the map item behind a UID change
+type MissionChangeDetails struct {
+	Type        string `xml:"type,attr" json:"type"`
+	Callsign    string `xml:"callsign,attr,omitempty" json:"callsign,omitempty"`
+	Color       string `xml:"color,attr,omitempty" json:"color,omitempty"`
+	IconsetPath string `xml:"iconsetPath,attr,omitempty" json:"iconset_path,omitempty"`
+}
+
+// IsMissionNotification reports whether the event is a mission notification
+func (e *Event) IsMissionNotification() bool {
+	return strings.HasPrefix(e.Type, "t-x-m-") && e.Detail.Mission != nil
+}
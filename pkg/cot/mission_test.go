@@ -0,0 +1,118 @@
+package cot
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+const missionChangeXML = `<event version="2.0" uid="e5f7c1d2" type="t-x-m-c" how="h-g-i-g-o" time="2024-05-01T12:00:00.000Z" start="2024-05-01T12:00:00.000Z" stale="2024-05-01T12:00:20.000Z">
+<point lat="0.0" lon="0.0" hae="0.0" ce="9999999" le="9999999"/>
+<detail>
+<mission type="CHANGE" tool="public" name="op-alpha" authorUid="ANDROID-1">
+<MissionChanges>
+<MissionChange>
+<contentResource>
+<filename>route.kml</filename>
+<hash>9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08</hash>
+<keywords>route</keywords>
+<keywords>night</keywords>
+<mimeType>application/vnd.google-earth.kml+xml</mimeType>
+<name>route.kml</name>
+<size>2048</size>
+<submissionTime>2024-05-01T11:59:58.120Z</submissionTime>
+<submitter>bot-1</submitter>
+<uid>4c0b5d8a</uid>
+</contentResource>
+<creatorUid>ANDROID-1</creatorUid>
+<missionName>op-alpha</missionName>
+<timestamp>2024-05-01T11:59:59.000Z</timestamp>
+<type>ADD_CONTENT</type>
+</MissionChange>
+</MissionChanges>
+</mission>
+</detail>
+</event>`
+
+func TestMissionChangeUnmarshal(t *testing.T) {
+	// Given
+	var event Event
+
+	// When
+	err := xml.Unmarshal([]byte(missionChangeXML), &event)
+
+	// Then
+	if err != nil {
+		t.Fatalf("Failed to unmarshal mission change: %v", err)
+	}
+	if !event.IsMissionNotification() {
+		t.Fatalf("Expected a mission notification")
+	}
+	mission := event.Detail.Mission
+	if mission.Type != "CHANGE" || mission.Name != "op-alpha" || mission.Tool != "public" || mission.AuthorUID != "ANDROID-1" {
+		t.Errorf("Unexpected mission. Got: %+v", mission)
+	}
+	if len(mission.Changes) != 1 {
+		t.Fatalf("Unexpected number of changes. Got: %d, Expected: 1", len(mission.Changes))
+	}
+	change := mission.Changes[0]
+	if change.Type != "ADD_CONTENT" || change.CreatorUID != "ANDROID-1" || change.MissionName != "op-alpha" {
+		t.Errorf("Unexpected change. Got: %+v", change)
+	}
+	if expected := time.Date(2024, 5, 1, 11, 59, 59, 0, time.UTC); !change.Timestamp.Equal(expected) {
+		t.Errorf("Unexpected timestamp. Got: %v, Expected: %v", change.Timestamp, expected)
+	}
+	resource := change.ContentResource
+	if resource == nil {
+		t.Fatalf("Expected a content resource")
+	}
+	if resource.Name != "route.kml" || resource.Size != 2048 || resource.Submitter != "bot-1" || resource.UID != "4c0b5d8a" {
+		t.Errorf("Unexpected content resource. Got: %+v", resource)
+	}
+	if strings.Join(resource.Keywords, ",") != "route,night" {
+		t.Errorf("Unexpected keywords. Got: %v, Expected: [route night]", resource.Keywords)
+	}
+}
+
+func TestMissionChangeRoundTrip(t *testing.T) {
+	// Given
+	event := NewEvent(MissionChangeType, "change-1")
+	event.Detail.Mission = &Mission{
+		Type: "CHANGE",
+		Tool: "public",
+		Name: "op-alpha",
+		Changes: []MissionChange{{
+			ContentUID:  "ANDROID-2",
+			CreatorUID:  "ANDROID-1",
+			MissionName: "op-alpha",
+			Timestamp:   time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			Type:        "ADD_CONTENT",
+			Details:     &MissionChangeDetails{Type: "a-f-G-U-C", Callsign: "R2"},
+		}},
+	}
+
+	// When
+	data, err := xml.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal mission change: %v", err)
+	}
+	var parsed Event
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("Failed to unmarshal mission change: %v", err)
+	}
+
+	// Then
+	if !strings.Contains(string(data), `<details type="a-f-G-U-C" callsign="R2"></details>`) {
+		t.Errorf("Unexpected details XML. Got: %s", data)
+	}
+	if parsed.Detail.Mission == nil || len(parsed.Detail.Mission.Changes) != 1 {
+		t.Fatalf("Mission change was not round-tripped. Got: %s", data)
+	}
+	if change := parsed.Detail.Mission.Changes[0]; change.ContentUID != "ANDROID-2" || change.Details.Callsign != "R2" {
+		t.Errorf("Unexpected change. Got: %+v", change)
+	}
+	if strings.Count(string(data), "<mission ") != 1 {
+		t.Errorf("Mission element was duplicated. Got: %s", data)
+	}
+}
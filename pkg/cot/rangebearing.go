@@ -0,0 +1,314 @@
+package cot
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Range & Bearing event types as used by ATAK
+const (
+	TypeRangeBearingLine   = "u-rb-a"
+	TypeRangeBearingCircle = "u-r-b-c-c"
+	TypeBullseye           = "u-r-b-bullseye"
+)
+
+// BullseyeStaleTime is how long bullseyes stay valid; ATAK keeps them for weeks
+const BullseyeStaleTime = 14 * 24 * time.Hour
+
+// MilsPerCircle is the number of NATO mils in a full circle
+const MilsPerCircle = 6400.0
+
+// DegreesToMils converts an angle in degrees to NATO mils
+func DegreesToMils(deg float64) float64 {
+	return deg * MilsPerCircle / 360
+}
+
+// MilsToDegrees converts an angle in NATO mils to degrees
+func MilsToDegrees(mils float64) float64 {
+	return mils * 360 / MilsPerCircle
+}
+
+// NorthReference identifies the north a bearing is measured from, using the codes ATAK
+// writes to northRef
+type NorthReference int
+
+const (
+	NorthReferenceTrue     NorthReference = 0
+	NorthReferenceMagnetic NorthReference = 1
+	NorthReferenceGrid     NorthReference = 2
+)
+
+// Code returns the single letter ATAK uses for the reference in bullseye details
+func (r NorthReference) Code() string {
+	switch r {
+	case NorthReferenceMagnetic:
+		return "M"
+	case NorthReferenceGrid:
+		return "G"
+	default:
+		return "T"
+	}
+}
+
+// ParseNorthReference parses the single letter code of a north reference
+func ParseNorthReference(code string) (NorthReference, error) {
+	switch code {
+	case "T":
+		return NorthReferenceTrue, nil
+	case "M":
+		return NorthReferenceMagnetic, nil
+	case "G":
+		return NorthReferenceGrid, nil
+	default:
+		return 0, fmt.Errorf("unknown north reference: %q", code)
+	}
+}
+
+// Offset returns the angle in degrees, clockwise from true north, of the reference north
+// at p. Grid north follows the UTM grid convergence. No magnetic model is bundled, so the
+// magnetic declination in degrees (east positive) must be supplied by the caller; it is
+// ignored for the other references.
+func (r NorthReference) Offset(p Point, declination float64) (float64, error) {
+	switch r {
+	case NorthReferenceTrue:
+		return 0, nil
+	case NorthReferenceMagnetic:
+		return declination, nil
+	case NorthReferenceGrid:
+		return GridConvergence(p), nil
+	default:
+		return 0, fmt.Errorf("unknown north reference: %d", r)
+	}
+}
+
+// FromTrue converts a bearing from true north to the reference north
+func (r NorthReference) FromTrue(bearing float64, p Point, declination float64) (float64, error) {
+	offset, err := r.Offset(p, declination)
+	if err != nil {
+		return 0, err
+	}
+	return normalizeBearing(bearing - offset), nil
+}
+
+// ToTrue converts a bearing from the reference north to true north
+func (r NorthReference) ToTrue(bearing float64, p Point, declination float64) (float64, error) {
+	offset, err := r.Offset(p, declination)
+	if err != nil {
+		return 0, err
+	}
+	return normalizeBearing(bearing + offset), nil
+}
+
+// GridConvergence returns the angle in degrees from true north to UTM grid north at p,
+// positive when grid north lies east of true north
+func GridConvergence(p Point) float64 {
+	lon0 := centralMeridian(utmZone(p.Lat, p.Lon))
+	dLon := toRadians(normalizeLon(p.Lon - lon0))
+	return toDegrees(math.Atan(math.Tan(dLon) * math.Sin(toRadians(p.Lat))))
+}
+
+// RangeUnit identifies the units ATAK displays a range in, using the codes it writes to rangeUnits
+type RangeUnit int
+
+const (
+	RangeUnitEnglish      RangeUnit = 0
+	RangeUnitMetric       RangeUnit = 1
+	RangeUnitNauticalMile RangeUnit = 2
+)
+
+// AngleUnit identifies the units ATAK displays a bearing in, using the codes it writes to bearingUnits
+type AngleUnit int
+
+const (
+	AngleUnitDegrees AngleUnit = 0
+	AngleUnitMils    AngleUnit = 1
+)
+
+// Range represents the range element; the value is in meters
+type Range struct {
+	XMLName xml.Name `xml:"range" json:"-"`
+	Value   float64  `xml:"value,attr" json:"value"`
+}
+
+// Bearing represents the bearing element; the value is in degrees from true north
+type Bearing struct {
+	XMLName xml.Name `xml:"bearing" json:"-"`
+	Value   float64  `xml:"value,attr" json:"value"`
+}
+
+// Inclination represents the inclination element; the value is in degrees above the horizon
+type Inclination struct {
+	XMLName xml.Name `xml:"inclination" json:"-"`
+	Value   float64  `xml:"value,attr" json:"value"`
+}
+
+// RangeUnits represents the rangeUnits element
+type RangeUnits struct {
+	XMLName xml.Name  `xml:"rangeUnits" json:"-"`
+	Value   RangeUnit `xml:"value,attr" json:"value"`
+}
+
+// BearingUnits represents the bearingUnits element
+type BearingUnits struct {
+	XMLName xml.Name  `xml:"bearingUnits" json:"-"`
+	Value   AngleUnit `xml:"value,attr" json:"value"`
+}
+
+// NorthRef represents the northRef element
+type NorthRef struct {
+	XMLName xml.Name       `xml:"northRef" json:"-"`
+	Value   NorthReference `xml:"value,attr" json:"value"`
+}
+
+// Bullseye represents the bullseye element
+type Bullseye struct {
+	XMLName xml.Name `xml:"bullseye" json:"-"`
+
+	Mils     bool    `xml:"mils,attr" json:"mils"`
+	Distance float64 `xml:"distance,attr" json:"distance"`
+	// BearingRef is the code of the north reference: T, M or G
+	BearingRef string `xml:"bearingRef,attr" json:"bearingRef"`
+	// BullseyeUID is the UID of the marker the bullseye is centered on, if any
+	BullseyeUID      string `xml:"bullseyeUID,attr,omitempty" json:"bullseyeUID,omitempty"`
+	DistanceUnits    string `xml:"distanceUnits,attr" json:"distanceUnits"`
+	EdgeToCenter     bool   `xml:"edgeToCenter,attr" json:"edgeToCenter"`
+	RangeRingVisible bool   `xml:"rangeRingVisible,attr" json:"rangeRingVisible"`
+	Title            string `xml:"title,attr" json:"title"`
+	HasRangeRings    bool   `xml:"hasRangeRings,attr" json:"hasRangeRings"`
+}
+
+// SetMils sets whether bearings from the bullseye are shown in mils
+func (b *Bullseye) SetMils(mils bool) *Bullseye {
+	b.Mils = mils
+	return b
+}
+
+// SetNorthReference sets the north reference of bearings from the bullseye
+func (b *Bullseye) SetNorthReference(ref NorthReference) *Bullseye {
+	b.BearingRef = ref.Code()
+	return b
+}
+
+// NorthReference returns the north reference of bearings from the bullseye
+func (b *Bullseye) NorthReference() (NorthReference, error) {
+	return ParseNorthReference(b.BearingRef)
+}
+
+// SetRangeRings sets whether the bullseye has range rings and whether they are shown
+func (b *Bullseye) SetRangeRings(hasRings, visible bool) *Bullseye {
+	b.HasRangeRings = hasRings
+	b.RangeRingVisible = visible
+	return b
+}
+
+// SetBullseyeUID sets the UID of the marker the bullseye is centered on
+func (b *Bullseye) SetBullseyeUID(uid string) *Bullseye {
+	b.BullseyeUID = uid
+	return b
+}
+
+// inclination returns the angle in degrees from p up to q, or 0 if either height is unknown
+func inclination(p, q Point, distance float64) float64 {
+	h1, ok1 := knownValue(p.Hae)
+	h2, ok2 := knownValue(q.Hae)
+	if !ok1 || !ok2 || distance == 0 {
+		return 0
+	}
+	return toDegrees(math.Atan2(h2-h1, distance))
+}
+
+// NewRangeBearingLine creates a u-rb-a range and bearing line from one point to another.
+// The range is written in meters and the bearing in degrees from true north; rangeUnits,
+// bearingUnits and northRef only select how ATAK displays them.
+func NewRangeBearingLine(uid, callsign string, from, to Point, style DrawingStyle) *Event {
+	event := newDrawingEvent(TypeRangeBearingLine, uid, callsign, from, style, false)
+	event.Detail.PrecisionLocation = nil
+
+	distance := from.DistanceTo(to)
+	event.Detail.Range = &Range{Value: distance}
+	event.Detail.Bearing = &Bearing{Value: from.BearingTo(to)}
+	event.Detail.Inclination = &Inclination{Value: inclination(from, to, distance)}
+	event.Detail.RangeUnits = &RangeUnits{Value: RangeUnitMetric}
+	event.Detail.BearingUnits = &BearingUnits{Value: AngleUnitDegrees}
+	event.Detail.NorthRef = &NorthRef{Value: NorthReferenceTrue}
+	if style.StrokeColor != nil {
+		event.Detail.SetShapeColor(signedColor(style.StrokeColor))
+	}
+	return event
+}
+
+// SetRangeBearingDisplay sets the units and north reference ATAK uses to display a range
+// and bearing line
+func (e *Event) SetRangeBearingDisplay(rangeUnit RangeUnit, angleUnit AngleUnit, ref NorthReference) *Event {
+	e.Detail.RangeUnits = &RangeUnits{Value: rangeUnit}
+	e.Detail.BearingUnits = &BearingUnits{Value: angleUnit}
+	e.Detail.NorthRef = &NorthRef{Value: ref}
+	return e
+}
+
+// RangeBearingEnd returns the far end of a range and bearing line, computed from the event
+// point, range and bearing
+func (e *Event) RangeBearingEnd() (Point, error) {
+	if e.Detail.Range == nil || e.Detail.Bearing == nil {
+		return Point{}, fmt.Errorf("event has no range and bearing")
+	}
+	end := e.Point.Destination(e.Detail.Range.Value, e.Detail.Bearing.Value)
+	end.Hae, end.Ce, end.Le = nil, nil, nil
+	if hae, ok := knownValue(e.Point.Hae); ok && e.Detail.Inclination != nil {
+		end.SetHae(hae + e.Detail.Range.Value*math.Tan(toRadians(e.Detail.Inclination.Value)))
+	}
+	return end, nil
+}
+
+// NewRangeBearingCircle creates a u-r-b-c-c range circle centered on center and passing
+// through edge
+func NewRangeBearingCircle(uid, callsign string, center, edge Point, style DrawingStyle) *Event {
+	event := NewCircle(uid, callsign, center, center.DistanceTo(edge), style)
+	event.SetType(TypeRangeBearingCircle)
+	if style.StrokeColor != nil {
+		event.Detail.SetColor(signedColor(style.StrokeColor))
+	}
+	return event
+}
+
+// NewBullseye creates a u-r-b-bullseye centered on center. Distance is the range ring
+// spacing in meters. Bearings are shown in degrees from true north until changed with
+// the setters of the returned event's Detail.Bullseye.
+func NewBullseye(uid, title string, center Point, distance float64) *Event {
+	event := NewEvent(TypeBullseye, uid)
+	event.SetHow("h-g-i-g-o")
+	event.SetStale(event.Time.Add(BullseyeStaleTime).Time())
+	event.SetPoint(center)
+
+	event.Detail.Bullseye = &Bullseye{
+		Distance:      distance,
+		BearingRef:    NorthReferenceTrue.Code(),
+		DistanceUnits: "m",
+		Title:         title,
+	}
+	event.Detail.AddContact(title)
+	event.Detail.AddRemarks("")
+	event.Detail.AddArchive()
+	event.Detail.AddPrecisionLocation("???")
+	return event
+}
+
+// BearingFrom returns the range in meters and bearing of p from the bullseye, with the
+// bearing in degrees or mils from the bullseye's north reference. The magnetic declination
+// at the bullseye must be supplied when the reference is magnetic.
+func (b *Bullseye) BearingFrom(center, p Point, declination float64) (distance, bearing float64, err error) {
+	ref, err := b.NorthReference()
+	if err != nil {
+		return 0, 0, err
+	}
+	distance, bearing, _ = Inverse(center, p)
+	if bearing, err = ref.FromTrue(bearing, center, declination); err != nil {
+		return 0, 0, err
+	}
+	if b.Mils {
+		bearing = DegreesToMils(bearing)
+	}
+	return distance, bearing, nil
+}
@@ -0,0 +1,280 @@
+package cot
+
+import (
+	"encoding/xml"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestRangeBearingUnmarshalXML(t *testing.T) {
+	// Given
+	xmlData := `<detail>
+		<range value='886.144457943895'/>
+		<bearing value='45.59655671674022'/>
+		<inclination value='0.0'/>
+		<rangeUnits value='1'/>
+		<bearingUnits value='0'/>
+		<northRef value='1'/>
+		<bullseye mils='false' distance='328.51363744476487' bearingRef='M' bullseyeUID='a64d29da' distanceUnits='m' edgeToCenter='false' rangeRingVisible='false' title='Bullseye 1' hasRangeRings='false'/>
+	</detail>`
+
+	// When
+	var detail Detail
+	if err := xml.Unmarshal([]byte(xmlData), &detail); err != nil {
+		t.Fatalf("Failed to unmarshal detail: %v", err)
+	}
+
+	// Then
+	if detail.Range == nil || detail.Range.Value != 886.144457943895 {
+		t.Errorf("Unexpected range. Got: %v", detail.Range)
+	}
+	if detail.Bearing == nil || detail.Bearing.Value != 45.59655671674022 {
+		t.Errorf("Unexpected bearing. Got: %v", detail.Bearing)
+	}
+	if detail.RangeUnits == nil || detail.RangeUnits.Value != RangeUnitMetric {
+		t.Errorf("Unexpected range units. Got: %v", detail.RangeUnits)
+	}
+	if detail.BearingUnits == nil || detail.BearingUnits.Value != AngleUnitDegrees {
+		t.Errorf("Unexpected bearing units. Got: %v", detail.BearingUnits)
+	}
+	if detail.NorthRef == nil || detail.NorthRef.Value != NorthReferenceMagnetic {
+		t.Errorf("Unexpected north reference. Got: %v", detail.NorthRef)
+	}
+	if detail.Bullseye == nil {
+		t.Fatal("Expected bullseye")
+	}
+	if detail.Bullseye.Title != "Bullseye 1" || detail.Bullseye.Distance != 328.51363744476487 || detail.Bullseye.BullseyeUID != "a64d29da" {
+		t.Errorf("Unexpected bullseye. Got: %+v", detail.Bullseye)
+	}
+	if ref, err := detail.Bullseye.NorthReference(); err != nil || ref != NorthReferenceMagnetic {
+		t.Errorf("Unexpected bullseye north reference. Got: %v, %v", ref, err)
+	}
+}
+
+func TestNewRangeBearingLine(t *testing.T) {
+	// Given
+	from := NewPoint(38.82080657998482, -77.05494586670942)
+	to := from.Destination(886.144457943895, 45.59655671674022)
+	style := DefaultDrawingStyle()
+
+	// When
+	event := NewRangeBearingLine("rb-1", "R&B 1", from, to, style)
+
+	// Then
+	if event.Type != TypeRangeBearingLine {
+		t.Errorf("Unexpected type. Got: %s, Expected: %s", event.Type, TypeRangeBearingLine)
+	}
+	if math.Abs(event.Detail.Range.Value-886.144457943895) > 1e-6 {
+		t.Errorf("Unexpected range. Got: %f, Expected: %f", event.Detail.Range.Value, 886.144457943895)
+	}
+	if math.Abs(event.Detail.Bearing.Value-45.59655671674022) > 1e-6 {
+		t.Errorf("Unexpected bearing. Got: %f, Expected: %f", event.Detail.Bearing.Value, 45.59655671674022)
+	}
+	if event.Detail.Inclination.Value != 0 {
+		t.Errorf("Expected zero inclination without heights. Got: %f", event.Detail.Inclination.Value)
+	}
+	end, err := event.RangeBearingEnd()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if d := end.DistanceTo(to); d > 0.001 {
+		t.Errorf("End point is %f m from the target", d)
+	}
+
+	data, err := xml.Marshal(event.Detail)
+	if err != nil {
+		t.Fatalf("Failed to marshal detail: %v", err)
+	}
+	for _, s := range []string{`<rangeUnits value="1">`, `<bearingUnits value="0">`, `<northRef value="0">`} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("Expected detail to contain %s\nGot: %s", s, string(data))
+		}
+	}
+}
+
+func TestNewRangeBearingLineMatchesExample(t *testing.T) {
+	// Given the line of the ATAK example, drawn in red
+	from := NewPoint(38.82080657998482, -77.05494586670942)
+	to := from.Destination(886.144457943895, 45.59655671674022)
+	style := DrawingStyle{StrokeColor: color.NRGBA{R: 0xFF, A: 0xFF}, StrokeWeight: 3}
+
+	// When
+	event := NewRangeBearingLine("58df2fcd", "R&B 1", from, to, style)
+
+	// Then
+	assertExampleElements(t, event, "Range & Bearing - Line", "color", "strokeColor", "rangeUnits", "bearingUnits", "contact", "labels_on")
+}
+
+func TestRangeBearingInclination(t *testing.T) {
+	// Given
+	from := NewPoint(38.82, -77.05)
+	from.SetHae(100)
+	to := from.Destination(1000, 90)
+	to.SetHae(1100)
+
+	// When
+	event := NewRangeBearingLine("rb-1", "R&B 1", from, to, DefaultDrawingStyle())
+	end, err := event.RangeBearingEnd()
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if math.Abs(event.Detail.Inclination.Value-45) > 0.01 {
+		t.Errorf("Unexpected inclination. Got: %f, Expected: %f", event.Detail.Inclination.Value, 45.0)
+	}
+	if end.Hae == nil || math.Abs(*end.Hae-1100) > 0.5 {
+		t.Errorf("Unexpected end hae. Got: %v, Expected: %f", end.Hae, 1100.0)
+	}
+}
+
+func TestSetRangeBearingDisplay(t *testing.T) {
+	// Given
+	event := NewRangeBearingLine("rb-1", "R&B 1", NewPoint(0, 0), NewPoint(0, 1), DefaultDrawingStyle())
+
+	// When
+	event.SetRangeBearingDisplay(RangeUnitNauticalMile, AngleUnitMils, NorthReferenceGrid)
+
+	// Then
+	if event.Detail.RangeUnits.Value != RangeUnitNauticalMile || event.Detail.BearingUnits.Value != AngleUnitMils || event.Detail.NorthRef.Value != NorthReferenceGrid {
+		t.Errorf("Unexpected display settings. Got: %v, %v, %v", event.Detail.RangeUnits.Value, event.Detail.BearingUnits.Value, event.Detail.NorthRef.Value)
+	}
+	if math.Abs(event.Detail.Bearing.Value-90) > 1e-9 {
+		t.Errorf("Bearing should remain true. Got: %f", event.Detail.Bearing.Value)
+	}
+}
+
+func TestMils(t *testing.T) {
+	tests := []struct {
+		degrees float64
+		mils    float64
+	}{
+		{0, 0},
+		{90, 1600},
+		{180, 3200},
+		{45, 800},
+	}
+
+	for _, tt := range tests {
+		// When
+		mils := DegreesToMils(tt.degrees)
+		degrees := MilsToDegrees(tt.mils)
+
+		// Then
+		if mils != tt.mils {
+			t.Errorf("DegreesToMils(%f) = %f, Expected: %f", tt.degrees, mils, tt.mils)
+		}
+		if degrees != tt.degrees {
+			t.Errorf("MilsToDegrees(%f) = %f, Expected: %f", tt.mils, degrees, tt.degrees)
+		}
+	}
+}
+
+func TestNorthReferenceConversions(t *testing.T) {
+	// Given
+	onMeridian := NewPoint(45, 3) // Central meridian of zone 31
+	eastOfMeridian := NewPoint(45, 5)
+
+	// When
+	trueBearing, _ := NorthReferenceTrue.FromTrue(100, eastOfMeridian, 10)
+	magnetic, _ := NorthReferenceMagnetic.FromTrue(100, eastOfMeridian, 10)
+	gridOnMeridian, _ := NorthReferenceGrid.FromTrue(100, onMeridian, 0)
+	grid, _ := NorthReferenceGrid.FromTrue(100, eastOfMeridian, 0)
+	back, _ := NorthReferenceGrid.ToTrue(grid, eastOfMeridian, 0)
+
+	// Then
+	if trueBearing != 100 {
+		t.Errorf("True bearing changed. Got: %f", trueBearing)
+	}
+	if magnetic != 90 {
+		t.Errorf("Unexpected magnetic bearing. Got: %f, Expected: %f", magnetic, 90.0)
+	}
+	if math.Abs(gridOnMeridian-100) > 1e-9 {
+		t.Errorf("Grid and true north should agree on the central meridian. Got: %f", gridOnMeridian)
+	}
+	// East of the central meridian in the north, grid north lies east of true north
+	expected := 100 - toDegrees(math.Atan(math.Tan(toRadians(2))*math.Sin(toRadians(45))))
+	if math.Abs(grid-expected) > 1e-9 {
+		t.Errorf("Unexpected grid bearing. Got: %f, Expected: %f", grid, expected)
+	}
+	if math.Abs(back-100) > 1e-9 {
+		t.Errorf("Unexpected round trip. Got: %f, Expected: %f", back, 100.0)
+	}
+}
+
+func TestParseNorthReference(t *testing.T) {
+	for _, ref := range []NorthReference{NorthReferenceTrue, NorthReferenceMagnetic, NorthReferenceGrid} {
+		parsed, err := ParseNorthReference(ref.Code())
+		if err != nil || parsed != ref {
+			t.Errorf("Round trip of %s failed. Got: %v, %v", ref.Code(), parsed, err)
+		}
+	}
+	if _, err := ParseNorthReference("X"); err == nil {
+		t.Error("Expected error for unknown reference")
+	}
+}
+
+func TestNewRangeBearingCircle(t *testing.T) {
+	// Given
+	center := NewPoint(38.817590020847064, -77.04678401125244)
+	edge := center.Destination(468.29991497750774, 30)
+
+	// When
+	event := NewRangeBearingCircle("rbc-1", "R&B Circle 1", center, edge, DefaultDrawingStyle())
+
+	// Then
+	if event.Type != TypeRangeBearingCircle {
+		t.Errorf("Unexpected type. Got: %s, Expected: %s", event.Type, TypeRangeBearingCircle)
+	}
+	if math.Abs(event.Detail.Shape.Ellipse.Major-468.29991497750774) > 1e-6 {
+		t.Errorf("Unexpected radius. Got: %f", event.Detail.Shape.Ellipse.Major)
+	}
+	if event.Detail.Color == nil {
+		t.Error("Expected color detail")
+	}
+}
+
+func TestNewBullseye(t *testing.T) {
+	// Given
+	center := NewPoint(38.81531363752994, -77.0562908588726)
+
+	// When
+	event := NewBullseye("be-1", "Bullseye 1", center, 500)
+	event.Detail.Bullseye.SetMils(true).SetNorthReference(NorthReferenceMagnetic).SetRangeRings(true, true)
+
+	// Then
+	if event.Type != TypeBullseye || event.How != "h-g-i-g-o" {
+		t.Errorf("Unexpected type or how. Got: %s %s", event.Type, event.How)
+	}
+	data, err := xml.Marshal(event.Detail.Bullseye)
+	if err != nil {
+		t.Fatalf("Failed to marshal bullseye: %v", err)
+	}
+	expected := `<bullseye mils="true" distance="500" bearingRef="M" distanceUnits="m" edgeToCenter="false" rangeRingVisible="true" title="Bullseye 1" hasRangeRings="true"></bullseye>`
+	if string(data) != expected {
+		t.Errorf("Unexpected bullseye.\nGot: %s\nExpected: %s", string(data), expected)
+	}
+}
+
+func TestBullseyeBearingFrom(t *testing.T) {
+	// Given
+	center := NewPoint(38.8, -77.0)
+	target := center.Destination(1000, 90)
+	bullseye := &Bullseye{BearingRef: "M", Mils: true}
+
+	// When
+	distance, bearing, err := bullseye.BearingFrom(center, target, -10)
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if math.Abs(distance-1000) > 0.001 {
+		t.Errorf("Unexpected distance. Got: %f, Expected: %f", distance, 1000.0)
+	}
+	if math.Abs(bearing-DegreesToMils(100)) > 0.01 {
+		t.Errorf("Unexpected bearing. Got: %f, Expected: %f", bearing, DegreesToMils(100))
+	}
+}
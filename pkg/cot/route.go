@@ -0,0 +1,254 @@
+package cot
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+)
+
+// Route event and route point types as used by ATAK
+const (
+	TypeRoute           = "b-m-r"
+	TypeRouteWaypoint   = "b-m-p-w"
+	TypeRouteCheckpoint = "b-m-p-c"
+)
+
+// RouteMethod is the means of travel along a route
+type RouteMethod string
+
+const (
+	RouteMethodDriving    RouteMethod = "Driving"
+	RouteMethodWalking    RouteMethod = "Walking"
+	RouteMethodFlying     RouteMethod = "Flying"
+	RouteMethodSwimming   RouteMethod = "Swimming"
+	RouteMethodWatercraft RouteMethod = "Watercraft"
+)
+
+// RouteDirection is whether a route leads into or out of an area
+type RouteDirection string
+
+const (
+	RouteDirectionInfil RouteDirection = "Infil"
+	RouteDirectionExfil RouteDirection = "Exfil"
+)
+
+// RouteOrder is the order in which ATAK numbers the checkpoints of a route
+type RouteOrder string
+
+const (
+	RouteOrderAscending  RouteOrder = "Ascending Check Points"
+	RouteOrderDescending RouteOrder = "Descending Check Points"
+)
+
+// LinkAttr represents the link_attr element holding the navigation metadata of a route
+type LinkAttr struct {
+	XMLName xml.Name `xml:"link_attr" json:"-"`
+
+	PlanningMethod RouteDirection `xml:"planningmethod,attr,omitempty" json:"planningmethod,omitempty"`
+	Color          int64          `xml:"color,attr" json:"color"`
+	Method         RouteMethod    `xml:"method,attr,omitempty" json:"method,omitempty"`
+	Prefix         string         `xml:"prefix,attr,omitempty" json:"prefix,omitempty"`
+	Type           string         `xml:"type,attr,omitempty" json:"type,omitempty"`
+	Stroke         int            `xml:"stroke,attr,omitempty" json:"stroke,omitempty"`
+	Direction      RouteDirection `xml:"direction,attr,omitempty" json:"direction,omitempty"`
+	RouteType      string         `xml:"routetype,attr,omitempty" json:"routetype,omitempty"`
+	Order          RouteOrder     `xml:"order,attr,omitempty" json:"order,omitempty"`
+}
+
+// RouteInfo represents the __routeinfo element ATAK attaches to routes
+type RouteInfo struct {
+	XMLName xml.Name `xml:"__routeinfo" json:"-"`
+	NavCues *NavCues `xml:"__navcues" json:"navcues,omitempty"`
+}
+
+// NavCues represents the __navcues element holding turn-by-turn cues of a route
+type NavCues struct {
+	XMLName xml.Name `xml:"__navcues" json:"-"`
+	Cues    []byte   `xml:",innerxml" json:"-"`
+}
+
+// Waypoint is a point along a route. Named waypoints (b-m-p-w) are shown with their
+// callsign; checkpoints (b-m-p-c) are unnamed intermediate points.
+type Waypoint struct {
+	UID        string
+	Name       string
+	Point      Point
+	Checkpoint bool
+}
+
+// Route is an ordered list of waypoints with the navigation metadata ATAK stores in a
+// b-m-r event
+type Route struct {
+	UID       string
+	Name      string
+	Waypoints []Waypoint
+
+	Method    RouteMethod
+	Direction RouteDirection
+	Order     RouteOrder
+	// Prefix is prepended to generated checkpoint names, e.g. CP
+	Prefix string
+	// Type is the route type shown by ATAK, e.g. Vehicle or On Foot
+	Type string
+	// RouteType is Primary or Secondary
+	RouteType string
+
+	Style DrawingStyle
+}
+
+// NewRoute creates a primary infil driving route with ATAK's default style
+func NewRoute(uid, name string) *Route {
+	return &Route{
+		UID:       uid,
+		Name:      name,
+		Method:    RouteMethodDriving,
+		Direction: RouteDirectionInfil,
+		Order:     RouteOrderAscending,
+		Prefix:    "CP",
+		Type:      "Vehicle",
+		RouteType: "Primary",
+		Style: DrawingStyle{
+			StrokeColor:  DefaultDrawingStyle().StrokeColor,
+			StrokeWeight: 3.0,
+		},
+	}
+}
+
+// AddWaypoint appends a named waypoint to the route
+func (r *Route) AddWaypoint(name string, p Point) *Route {
+	r.Waypoints = append(r.Waypoints, Waypoint{Name: name, Point: p})
+	return r
+}
+
+// AddCheckpoint appends an unnamed checkpoint to the route
+func (r *Route) AddCheckpoint(p Point) *Route {
+	r.Waypoints = append(r.Waypoints, Waypoint{Point: p, Checkpoint: true})
+	return r
+}
+
+// LegDistances returns the distance in meters of each leg between consecutive waypoints
+func (r *Route) LegDistances() []float64 {
+	if len(r.Waypoints) < 2 {
+		return nil
+	}
+	legs := make([]float64, len(r.Waypoints)-1)
+	for i := range legs {
+		legs[i] = r.Waypoints[i].Point.DistanceTo(r.Waypoints[i+1].Point)
+	}
+	return legs
+}
+
+// TotalDistance returns the length of the route in meters
+func (r *Route) TotalDistance() float64 {
+	var total float64
+	for _, leg := range r.LegDistances() {
+		total += leg
+	}
+	return total
+}
+
+// ToEvent creates the b-m-r event for the route. Waypoints without a UID are given one
+// derived from the route UID.
+func (r *Route) ToEvent() (*Event, error) {
+	if len(r.Waypoints) < 2 {
+		return nil, fmt.Errorf("route requires at least 2 waypoints, got %d", len(r.Waypoints))
+	}
+
+	event := NewEvent(TypeRoute, r.UID)
+	event.SetHow("h-e")
+	event.SetStale(event.Time.Add(DrawingStaleTime).Time())
+
+	for i, wp := range r.Waypoints {
+		uid := wp.UID
+		if uid == "" {
+			uid = fmt.Sprintf("%s.%d", r.UID, i)
+		}
+		linkType := TypeRouteWaypoint
+		if wp.Checkpoint {
+			linkType = TypeRouteCheckpoint
+		}
+		event.Detail.AddVertex(wp.Point).
+			SetUID(uid).
+			SetCallsign(wp.Name).
+			SetType(linkType).
+			SetRelation("c")
+	}
+
+	var argb int64 = -1
+	if r.Style.StrokeColor != nil {
+		argb = signedColor(r.Style.StrokeColor)
+	}
+	event.Detail.LinkAttr = &LinkAttr{
+		PlanningMethod: r.Direction,
+		Color:          argb,
+		Method:         r.Method,
+		Prefix:         r.Prefix,
+		Type:           r.Type,
+		Stroke:         int(math.Round(r.Style.StrokeWeight)),
+		Direction:      r.Direction,
+		RouteType:      r.RouteType,
+		Order:          r.Order,
+	}
+	event.Detail.SetStrokeColor(argb)
+	event.Detail.SetStrokeWeight(r.Style.StrokeWeight)
+	event.Detail.RouteInfo = &RouteInfo{NavCues: &NavCues{}}
+	event.Detail.AddContact(r.Name)
+	event.Detail.AddRemarks("")
+	event.Detail.AddArchive()
+	event.Detail.SetLabelsOn(r.Style.LabelsOn)
+	event.Detail.SetShapeColor(argb)
+	return event, nil
+}
+
+// RouteFromEvent reads a route from a b-m-r event
+func RouteFromEvent(e *Event) (*Route, error) {
+	if e.Type != TypeRoute {
+		return nil, fmt.Errorf("event type %q is not a route", e.Type)
+	}
+
+	route := &Route{UID: e.UID}
+	if e.Detail.Contact != nil {
+		route.Name = e.Detail.Contact.Callsign
+	}
+
+	for _, link := range e.Detail.Links {
+		if link.Type != TypeRouteWaypoint && link.Type != TypeRouteCheckpoint {
+			continue
+		}
+		p, err := link.LatLon()
+		if err != nil {
+			return nil, fmt.Errorf("route point %s: %w", link.UID, err)
+		}
+		route.Waypoints = append(route.Waypoints, Waypoint{
+			UID:        link.UID,
+			Name:       link.Callsign,
+			Point:      p,
+			Checkpoint: link.Type == TypeRouteCheckpoint,
+		})
+	}
+
+	if attr := e.Detail.LinkAttr; attr != nil {
+		route.Method = attr.Method
+		route.Direction = attr.Direction
+		if route.Direction == "" {
+			route.Direction = attr.PlanningMethod
+		}
+		route.Order = attr.Order
+		route.Prefix = attr.Prefix
+		route.Type = attr.Type
+		route.RouteType = attr.RouteType
+		route.Style.StrokeColor = argbColor(attr.Color)
+		route.Style.StrokeWeight = float64(attr.Stroke)
+	}
+	if e.Detail.StrokeColor != nil {
+		route.Style.StrokeColor = argbColor(e.Detail.StrokeColor.Value)
+	}
+	if e.Detail.StrokeWeight != nil {
+		route.Style.StrokeWeight = e.Detail.StrokeWeight.Value
+	}
+	if e.Detail.LabelsOn != nil {
+		route.Style.LabelsOn = e.Detail.LabelsOn.Value
+	}
+
+	return route, nil
+}
@@ -0,0 +1,224 @@
+package cot
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+const routeXML = `<event version='2.0' uid='9017073e-5658-42e7-baa8-b98f3c9c1622' type='b-m-r' time='2020-12-16T19:59:34.914Z' start='2020-12-16T19:59:34.914Z' stale='2020-12-17T19:59:34.914Z' how='h-e'>
+	<point lat='0.0' lon='0.0' hae='9999999.0' ce='9999999.0' le='9999999.0' />
+	<detail>
+		<link uid='f3acf150-d75c-407d-be43-e401ab40fe74' callsign='Route 1 SP' type='b-m-p-w' point='38.84335305982451,-77.05440032542333' remarks='' relation='c'/>
+		<link uid='820ebf04-1300-4c3a-a368-d6f1e21a5ddb' callsign='' type='b-m-p-c' point='38.843641314210366,-77.04564214131744' remarks='' relation='c'/>
+		<link uid='091b1c0b-fbde-4072-8a98-404ca29663a3' callsign='VDO' type='b-m-p-w' point='38.84984726157651,-77.0410272520001' remarks='' relation='c'/>
+		<link_attr planningmethod='Infil' color='-1' method='Driving' prefix='CP' type='Vehicle' stroke='3' direction='Infil' routetype='Primary' order='Ascending Check Points'/>
+		<strokeColor value='-1'/>
+		<strokeWeight value='3.0'/>
+		<__routeinfo>
+			<__navcues/>
+		</__routeinfo>
+		<contact callsign='Route 1'/>
+		<remarks></remarks>
+		<archive/>
+		<labels_on value='false'/>
+		<color value='-1'/>
+	</detail>
+</event>`
+
+func TestRouteFromEvent(t *testing.T) {
+	// Given
+	var event Event
+	if err := xml.Unmarshal([]byte(routeXML), &event); err != nil {
+		t.Fatalf("Failed to unmarshal route: %v", err)
+	}
+
+	// When
+	route, err := RouteFromEvent(&event)
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if route.Name != "Route 1" {
+		t.Errorf("Unexpected name. Got: %s, Expected: %s", route.Name, "Route 1")
+	}
+	if len(route.Waypoints) != 3 {
+		t.Fatalf("Unexpected number of waypoints. Got: %d, Expected: %d", len(route.Waypoints), 3)
+	}
+	if route.Waypoints[0].Name != "Route 1 SP" || route.Waypoints[0].Checkpoint {
+		t.Errorf("Unexpected first waypoint. Got: %+v", route.Waypoints[0])
+	}
+	if route.Waypoints[1].Name != "" || !route.Waypoints[1].Checkpoint {
+		t.Errorf("Unexpected checkpoint. Got: %+v", route.Waypoints[1])
+	}
+	if route.Waypoints[2].Name != "VDO" || route.Waypoints[2].Point.Lat != 38.84984726157651 {
+		t.Errorf("Unexpected last waypoint. Got: %+v", route.Waypoints[2])
+	}
+	if route.Method != RouteMethodDriving || route.Direction != RouteDirectionInfil || route.Order != RouteOrderAscending {
+		t.Errorf("Unexpected navigation metadata. Got: %s, %s, %s", route.Method, route.Direction, route.Order)
+	}
+	if route.Prefix != "CP" || route.Type != "Vehicle" || route.RouteType != "Primary" {
+		t.Errorf("Unexpected route attributes. Got: %s, %s, %s", route.Prefix, route.Type, route.RouteType)
+	}
+	if route.Style.StrokeWeight != 3.0 {
+		t.Errorf("Unexpected stroke weight. Got: %f, Expected: %f", route.Style.StrokeWeight, 3.0)
+	}
+	if signedColor(route.Style.StrokeColor) != -1 {
+		t.Errorf("Unexpected stroke color. Got: %d, Expected: %d", signedColor(route.Style.StrokeColor), -1)
+	}
+}
+
+func TestRouteFromEventWrongType(t *testing.T) {
+	// Given
+	event := NewEvent("a-f-G", "not-a-route")
+
+	// When
+	_, err := RouteFromEvent(event)
+
+	// Then
+	if err == nil {
+		t.Error("Expected error for non-route event")
+	}
+}
+
+func TestRouteToEvent(t *testing.T) {
+	// Given
+	route := NewRoute("route-1", "Convoy").
+		AddWaypoint("SP", NewPoint(38.8433, -77.0544)).
+		AddCheckpoint(NewPoint(38.8436, -77.0456)).
+		AddWaypoint("RP", NewPoint(38.8498, -77.0410))
+	route.Method = RouteMethodWalking
+	route.Direction = RouteDirectionExfil
+
+	// When
+	event, err := route.ToEvent()
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if event.Type != TypeRoute || event.How != "h-e" {
+		t.Errorf("Unexpected type or how. Got: %s %s", event.Type, event.How)
+	}
+	data, err := xml.Marshal(event.Detail)
+	if err != nil {
+		t.Fatalf("Failed to marshal detail: %v", err)
+	}
+	xmlStr := string(data)
+	expected := []string{
+		`<link uid="route-1.0" callsign="SP" type="b-m-p-w" relation="c" point="38.8433,-77.0544"></link>`,
+		`<link uid="route-1.1" type="b-m-p-c" relation="c" point="38.8436,-77.0456"></link>`,
+		`<link_attr planningmethod="Exfil" color="-1" method="Walking" prefix="CP" type="Vehicle" stroke="3" direction="Exfil" routetype="Primary" order="Ascending Check Points"></link_attr>`,
+		`<__routeinfo><__navcues></__navcues></__routeinfo>`,
+		`<contact callsign="Convoy"`,
+		`<strokeWeight value="3"></strokeWeight>`,
+	}
+	for _, s := range expected {
+		if !strings.Contains(xmlStr, s) {
+			t.Errorf("Expected detail to contain %s\nGot: %s", s, xmlStr)
+		}
+	}
+}
+
+func TestRouteRoundTrip(t *testing.T) {
+	// Given
+	route := NewRoute("route-1", "Convoy").
+		AddWaypoint("SP", NewPoint(38.8433, -77.0544)).
+		AddCheckpoint(NewPoint(38.8436, -77.0456)).
+		AddWaypoint("RP", NewPoint(38.8498, -77.0410))
+	event, err := route.ToEvent()
+	if err != nil {
+		t.Fatalf("Failed to create route event: %v", err)
+	}
+	data, err := xml.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal route event: %v", err)
+	}
+
+	// When
+	var parsed Event
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("Failed to unmarshal route event: %v", err)
+	}
+	result, err := RouteFromEvent(&parsed)
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Waypoints) != len(route.Waypoints) {
+		t.Fatalf("Unexpected number of waypoints. Got: %d, Expected: %d", len(result.Waypoints), len(route.Waypoints))
+	}
+	for i, wp := range result.Waypoints {
+		if wp.Name != route.Waypoints[i].Name || wp.Checkpoint != route.Waypoints[i].Checkpoint {
+			t.Errorf("Unexpected waypoint %d. Got: %+v, Expected: %+v", i, wp, route.Waypoints[i])
+		}
+		if wp.UID != fmt.Sprintf("route-1.%d", i) {
+			t.Errorf("Unexpected waypoint UID. Got: %s", wp.UID)
+		}
+	}
+	if result.Method != route.Method || result.Direction != route.Direction || result.Order != route.Order {
+		t.Errorf("Navigation metadata not preserved. Got: %+v", result)
+	}
+}
+
+func TestRouteToEventRequiresTwoWaypoints(t *testing.T) {
+	// Given
+	route := NewRoute("route-1", "Convoy").AddWaypoint("SP", NewPoint(38.8433, -77.0544))
+
+	// When
+	_, err := route.ToEvent()
+
+	// Then
+	if err == nil {
+		t.Error("Expected error for route with a single waypoint")
+	}
+}
+
+func TestRouteDistances(t *testing.T) {
+	// Given
+	a, b, c := NewPoint(0, 0), NewPoint(0, 1), NewPoint(1, 1)
+	route := NewRoute("route-1", "Convoy").AddWaypoint("A", a).AddCheckpoint(b).AddWaypoint("C", c)
+
+	// When
+	legs := route.LegDistances()
+	total := route.TotalDistance()
+
+	// Then
+	if len(legs) != 2 {
+		t.Fatalf("Unexpected number of legs. Got: %d, Expected: %d", len(legs), 2)
+	}
+	if math.Abs(legs[0]-a.DistanceTo(b)) > 1e-9 || math.Abs(legs[1]-b.DistanceTo(c)) > 1e-9 {
+		t.Errorf("Unexpected leg distances. Got: %v", legs)
+	}
+	if math.Abs(total-(legs[0]+legs[1])) > 1e-9 {
+		t.Errorf("Unexpected total distance. Got: %f, Expected: %f", total, legs[0]+legs[1])
+	}
+	if math.Abs(legs[0]-111319.49) > 1 {
+		t.Errorf("Unexpected distance along the equator. Got: %f, Expected: ~111319.49", legs[0])
+	}
+}
+
+func TestRouteToEventMatchesExample(t *testing.T) {
+	// Given the route of the ATAK example
+	var example Event
+	if err := xml.Unmarshal(readExample(t, "Route"), &example); err != nil {
+		t.Fatalf("Failed to unmarshal example: %v", err)
+	}
+	route, err := RouteFromEvent(&example)
+	if err != nil {
+		t.Fatalf("RouteFromEvent() error = %v", err)
+	}
+
+	// When
+	event, err := route.ToEvent()
+	if err != nil {
+		t.Fatalf("ToEvent() error = %v", err)
+	}
+
+	// Then
+	assertExampleElements(t, event, "Route", "color", "strokeColor", "link_attr", "contact", "labels_on")
+}
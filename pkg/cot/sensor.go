@@ -0,0 +1,182 @@
+package cot
+
+import (
+	"encoding/xml"
+	"errors"
+	"image/color"
+	"math"
+)
+
+// TypeSensorPoint is the type of the sensor point ATAK places for a camera or other sensor
+const TypeSensorPoint = "b-m-p-s-p-loc"
+
+// DefaultSensorFOVAlpha is the opacity ATAK gives the FOV cone of a new sensor
+const DefaultSensorFOVAlpha = 0.3
+
+// ErrNoFootprint is returned when part of the sensor field of view never reaches the
+// ground and the sensor has no range to clip it at
+var ErrNoFootprint = errors.New("sensor field of view does not intersect the ground")
+
+// Sensor represents the sensor element ATAK uses to draw the field of view of a
+// sensor. Angles are in degrees, ranges in meters.
+type Sensor struct {
+	XMLName xml.Name `xml:"sensor" json:"-"`
+
+	// Azimuth is the direction the sensor looks in, clockwise from true north
+	Azimuth float64 `xml:"azimuth,attr" json:"azimuth"`
+	// Elevation is the angle above the horizon; negative values look down
+	Elevation float64 `xml:"elevation,attr" json:"elevation"`
+	Roll      float64 `xml:"roll,attr" json:"roll"`
+	// HFOV is the horizontal field of view, which ATAK calls fov
+	HFOV  float64 `xml:"fov,attr" json:"fov"`
+	VFOV  float64 `xml:"vfov,attr,omitempty" json:"vfov,omitempty"`
+	Range float64 `xml:"range,attr" json:"range"`
+
+	Model string `xml:"model,attr,omitempty" json:"model,omitempty"`
+	Type  string `xml:"type,attr,omitempty" json:"type,omitempty"`
+
+	// FOV cone fill as color components between 0 and 1
+	FOVAlpha float64 `xml:"fovAlpha,attr" json:"fov_alpha"`
+	FOVRed   float64 `xml:"fovRed,attr" json:"fov_red"`
+	FOVGreen float64 `xml:"fovGreen,attr" json:"fov_green"`
+	FOVBlue  float64 `xml:"fovBlue,attr" json:"fov_blue"`
+
+	StrokeColor              int64   `xml:"strokeColor,attr,omitempty" json:"stroke_color,omitempty"`
+	StrokeWeight             float64 `xml:"strokeWeight,attr,omitempty" json:"stroke_weight,omitempty"`
+	DisplayMagneticReference int     `xml:"displayMagneticReference,attr" json:"display_magnetic_reference"`
+	HideFOV                  bool    `xml:"hideFov,attr" json:"hide_fov"`
+	// RangeLines is the spacing of the range arcs drawn in the cone; 0 draws none
+	RangeLines float64 `xml:"rangeLines,attr,omitempty" json:"range_lines,omitempty"`
+}
+
+// NewSensor creates a sensor looking towards azimuth with the given horizontal field
+// of view and range, drawn with ATAK's default white cone
+func NewSensor(azimuth, hfov, rangeMeters float64) *Sensor {
+	return &Sensor{
+		Azimuth:  azimuth,
+		HFOV:     hfov,
+		Range:    rangeMeters,
+		FOVAlpha: DefaultSensorFOVAlpha,
+		FOVRed:   1,
+		FOVGreen: 1,
+		FOVBlue:  1,
+	}
+}
+
+// SetFOVColor sets the fill of the FOV cone, including its opacity
+func (s *Sensor) SetFOVColor(c color.Color) *Sensor {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	s.FOVRed = float64(n.R) / 0xFF
+	s.FOVGreen = float64(n.G) / 0xFF
+	s.FOVBlue = float64(n.B) / 0xFF
+	s.FOVAlpha = float64(n.A) / 0xFF
+	return s
+}
+
+// SetStrokeColor sets the outline color of the FOV cone
+func (s *Sensor) SetStrokeColor(c color.Color) *Sensor {
+	s.StrokeColor = signedColor(c)
+	return s
+}
+
+// FOVCone returns the outline of the horizontal FOV cone of a sensor at origin: the
+// origin followed by an arc of segments+1 points at the sensor range
+func (s *Sensor) FOVCone(origin Point, segments int) []Point {
+	if segments < 1 {
+		segments = 1
+	}
+	start := s.Azimuth - s.HFOV/2
+	points := make([]Point, 0, segments+2)
+	points = append(points, origin)
+	for i := 0; i <= segments; i++ {
+		bearing := start + s.HFOV*float64(i)/float64(segments)
+		points = append(points, origin.Destination(s.Range, normalizeBearing(bearing)))
+	}
+	return points
+}
+
+// GroundFootprint returns the corners of the area on flat ground seen by a sensor at
+// position, height meters above the ground. The sensor elevation, roll and both fields
+// of view are taken into account; a VFOV of 0 is treated as equal to HFOV. Corner rays
+// that point above the horizon or reach beyond the sensor range are cut off at the
+// range, so looking at the horizon yields a trapezoid ending at the range.
+func (s *Sensor) GroundFootprint(position Point, height float64) ([]Point, error) {
+	if height <= 0 {
+		return nil, errors.New("sensor height above ground must be positive")
+	}
+	vfov := s.VFOV
+	if vfov == 0 {
+		vfov = s.HFOV
+	}
+	az := s.Azimuth * math.Pi / 180
+	el := s.Elevation * math.Pi / 180
+	roll := s.Roll * math.Pi / 180
+
+	// Camera axes in east, north, up coordinates
+	forward := vec3{math.Sin(az) * math.Cos(el), math.Cos(az) * math.Cos(el), math.Sin(el)}
+	right := vec3{math.Cos(az), -math.Sin(az), 0}
+	up := right.cross(forward)
+	right, up = right.scale(math.Cos(roll)).add(up.scale(math.Sin(roll))),
+		up.scale(math.Cos(roll)).sub(right.scale(math.Sin(roll)))
+
+	tanH := math.Tan(s.HFOV * math.Pi / 360)
+	tanV := math.Tan(vfov * math.Pi / 360)
+	corners := [][2]float64{{-1, 1}, {1, 1}, {1, -1}, {-1, -1}}
+
+	points := make([]Point, 0, len(corners))
+	for _, c := range corners {
+		ray := forward.add(right.scale(c[0] * tanH)).add(up.scale(c[1] * tanV))
+		ray = ray.scale(1 / ray.norm())
+
+		var distance float64
+		if ray[2] < 0 {
+			distance = -height / ray[2]
+		}
+		if ray[2] >= 0 || (s.Range > 0 && distance > s.Range) {
+			if s.Range <= 0 {
+				return nil, ErrNoFootprint
+			}
+			distance = s.Range
+		}
+		east, north := ray[0]*distance, ray[1]*distance
+		bearing := math.Atan2(east, north) * 180 / math.Pi
+		points = append(points, position.Destination(math.Hypot(east, north), normalizeBearing(bearing)))
+	}
+	return points, nil
+}
+
+// SetSensor sets the sensor element of the detail
+func (d *Detail) SetSensor(sensor *Sensor) *Sensor {
+	d.Sensor = sensor
+	return d.Sensor
+}
+
+// NewSensorEvent creates a sensor point at position whose FOV cone ATAK draws on the
+// map. A non-empty feedURL attaches a video feed to the sensor.
+func NewSensorEvent(uid, callsign string, position Point, sensor *Sensor, feedURL string) *Event {
+	event := NewEvent(TypeSensorPoint, uid)
+	event.SetHow("h-g-i-g-o")
+	event.SetStale(event.Time.Add(DrawingStaleTime).Time())
+	event.SetPoint(position)
+	event.Detail.AddContact(callsign)
+	event.Detail.SetSensor(sensor)
+	if feedURL != "" {
+		event.Detail.SetVideo(feedURL)
+	}
+	return event
+}
+
+// vec3 is a vector in local east, north, up coordinates
+type vec3 [3]float64
+
+func (a vec3) add(b vec3) vec3 { return vec3{a[0] + b[0], a[1] + b[1], a[2] + b[2]} }
+
+func (a vec3) sub(b vec3) vec3 { return vec3{a[0] - b[0], a[1] - b[1], a[2] - b[2]} }
+
+func (a vec3) scale(k float64) vec3 { return vec3{a[0] * k, a[1] * k, a[2] * k} }
+
+func (a vec3) norm() float64 { return math.Sqrt(a[0]*a[0] + a[1]*a[1] + a[2]*a[2]) }
+
+func (a vec3) cross(b vec3) vec3 {
+	return vec3{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
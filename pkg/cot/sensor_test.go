@@ -0,0 +1,128 @@
+package cot
+
+import (
+	"encoding/xml"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSensorEventXML(t *testing.T) {
+	// Given
+	sensor := NewSensor(270, 45, 500)
+	sensor.SetFOVColor(color.NRGBA{R: 0xFF, A: 0x80})
+	event := NewSensorEvent("sensor-1", "Cam 1", NewPoint(59.3293, 18.0686), sensor, "rtsp://10.0.0.5/live")
+
+	// When
+	data, err := xml.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal sensor event: %v", err)
+	}
+	var parsed Event
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("Failed to unmarshal sensor event: %v", err)
+	}
+
+	// Then
+	if !strings.Contains(string(data), `<sensor azimuth="270" elevation="0" roll="0" fov="45" range="500"`) {
+		t.Errorf("Marshaled XML does not contain the sensor element. Got: %s", data)
+	}
+	if !strings.Contains(string(data), `<__video url="rtsp://10.0.0.5/live"></__video>`) {
+		t.Errorf("Marshaled XML does not contain the video element. Got: %s", data)
+	}
+	got := parsed.Detail.Sensor
+	if got == nil || got.Azimuth != 270 || got.HFOV != 45 || got.Range != 500 || got.FOVRed != 1 || got.FOVGreen != 0 {
+		t.Errorf("Unexpected sensor. Got: %+v", got)
+	}
+	if math.Abs(got.FOVAlpha-0x80/255.0) > 1e-9 {
+		t.Errorf("Unexpected FOV alpha. Got: %v, Expected: %v", got.FOVAlpha, 0x80/255.0)
+	}
+}
+
+func TestFOVCone(t *testing.T) {
+	// Given
+	origin := NewPoint(59.3293, 18.0686)
+	sensor := NewSensor(0, 90, 1000)
+
+	// When
+	cone := sensor.FOVCone(origin, 4)
+
+	// Then
+	if len(cone) != 6 {
+		t.Fatalf("Unexpected number of points. Got: %d, Expected: %d", len(cone), 6)
+	}
+	if cone[0] != origin {
+		t.Errorf("Expected the cone to start at the origin. Got: %v", cone[0])
+	}
+	for i, bearing := range []float64{315, 337.5, 0, 22.5, 45} {
+		p := cone[i+1]
+		if d := origin.DistanceTo(p); math.Abs(d-1000) > 0.01 {
+			t.Errorf("Unexpected distance of point %d. Got: %v, Expected: %v", i, d, 1000)
+		}
+		if b := origin.BearingTo(p); math.Abs(math.Remainder(b-bearing, 360)) > 0.01 {
+			t.Errorf("Unexpected bearing of point %d. Got: %v, Expected: %v", i, b, bearing)
+		}
+	}
+}
+
+func TestGroundFootprint(t *testing.T) {
+	origin := NewPoint(59.3293, 18.0686)
+
+	testCases := []struct {
+		name      string
+		sensor    Sensor
+		distances []float64
+		bearings  []float64
+	}{
+		{
+			name:      "looking straight down",
+			sensor:    Sensor{Elevation: -90, HFOV: 90, VFOV: 90},
+			distances: []float64{100 * math.Sqrt2, 100 * math.Sqrt2, 100 * math.Sqrt2, 100 * math.Sqrt2},
+			bearings:  []float64{315, 45, 135, 225},
+		},
+		{
+			// The top edge looks at the horizon and is cut off at the range
+			name:      "looking east at the horizon",
+			sensor:    Sensor{Azimuth: 90, Elevation: -30, HFOV: 0.001, VFOV: 60, Range: 1000},
+			distances: []float64{1000, 1000, 100 / math.Tan(math.Pi/3), 100 / math.Tan(math.Pi/3)},
+			bearings:  []float64{90, 90, 90, 90},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// When
+			footprint, err := tc.sensor.GroundFootprint(origin, 100)
+
+			// Then
+			if err != nil {
+				t.Fatalf("Failed to compute footprint: %v", err)
+			}
+			if len(footprint) != 4 {
+				t.Fatalf("Unexpected number of corners. Got: %d, Expected: %d", len(footprint), 4)
+			}
+			for i, p := range footprint {
+				if d := origin.DistanceTo(p); math.Abs(d-tc.distances[i]) > 0.1 {
+					t.Errorf("Unexpected distance of corner %d. Got: %v, Expected: %v", i, d, tc.distances[i])
+				}
+				if b := origin.BearingTo(p); math.Abs(math.Remainder(b-tc.bearings[i], 360)) > 0.1 {
+					t.Errorf("Unexpected bearing of corner %d. Got: %v, Expected: %v", i, b, tc.bearings[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGroundFootprintWithoutRange(t *testing.T) {
+	// Given
+	sensor := Sensor{Elevation: 0, HFOV: 60}
+
+	// When
+	_, err := sensor.GroundFootprint(NewPoint(59.3293, 18.0686), 100)
+
+	// Then
+	if err != ErrNoFootprint {
+		t.Errorf("Unexpected error. Got: %v, Expected: %v", err, ErrNoFootprint)
+	}
+}
@@ -1,6 +1,10 @@
 package cot
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"math"
+	"strings"
+)
 
 // Shape represents the shape element as defined in shape.xsd
 type Shape struct {
@@ -72,6 +76,29 @@ func (e *Ellipse) SetAngle(angle float64) *Ellipse {
 	return e
 }
 
+// Outline approximates the ellipse centered on center with a closed ring of the given
+// number of segments. Major and minor are taken as semi-axes in meters, with the major
+// axis rotated angle degrees clockwise from true north.
+func (e *Ellipse) Outline(center Point, segments int) []Point {
+	if segments < 3 {
+		segments = 3
+	}
+
+	ring := make([]Point, 0, segments+1)
+	for i := 0; i < segments; i++ {
+		theta := 2 * math.Pi * float64(i) / float64(segments)
+		// Offset along the major and minor axes, then rotate into a bearing
+		along := e.Major * math.Cos(theta)
+		across := e.Minor * math.Sin(theta)
+		r := math.Hypot(along, across)
+		bearing := e.Angle + toDegrees(math.Atan2(across, along))
+		p := center.Destination(r, bearing)
+		p.Hae, p.Ce, p.Le = nil, nil, nil
+		ring = append(ring, p)
+	}
+	return append(ring, ring[0])
+}
+
 // Polyline represents the polyline element within a shape
 type Polyline struct {
 	XMLName xml.Name `xml:"polyline" json:"-"`
@@ -83,3 +110,28 @@ func (p *Polyline) SetPoints(points string) *Polyline {
 	p.Points = points
 	return p
 }
+
+// Coordinates parses the points attribute of the polyline, a space-separated list of
+// "lat,lon" or "lat,lon,hae" tuples
+func (p *Polyline) Coordinates() ([]Point, error) {
+	fields := strings.Fields(p.Points)
+	points := make([]Point, 0, len(fields))
+	for _, field := range fields {
+		point, err := parseLatLon(field, ",")
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// SetCoordinates sets the points attribute of the polyline from typed points
+func (p *Polyline) SetCoordinates(points []Point) *Polyline {
+	tuples := make([]string, len(points))
+	for i, point := range points {
+		tuples[i] = formatVertex(point)
+	}
+	p.Points = strings.Join(tuples, " ")
+	return p
+}
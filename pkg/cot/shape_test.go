@@ -2,6 +2,7 @@ package cot
 
 import (
 	"encoding/xml"
+	"math"
 	"testing"
 )
 
@@ -234,3 +235,90 @@ func TestPolylineSetters(t *testing.T) {
 		t.Errorf("SetPoints did not return the polyline instance for chaining")
 	}
 }
+
+func TestPolylineCoordinates(t *testing.T) {
+	// Given
+	polyline := Polyline{Points: "38.83,-77.06 38.84,-77.05,12.5 38.85,-77.04"}
+
+	// When
+	points, err := polyline.Coordinates()
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(points) != 3 {
+		t.Fatalf("Unexpected number of points. Got: %d, Expected: %d", len(points), 3)
+	}
+	if points[0].Lat != 38.83 || points[0].Lon != -77.06 {
+		t.Errorf("Unexpected first point. Got: %v,%v", points[0].Lat, points[0].Lon)
+	}
+	if points[1].Hae == nil || *points[1].Hae != 12.5 {
+		t.Errorf("Expected hae 12.5 on second point. Got: %v", points[1].Hae)
+	}
+
+	// Given an invalid tuple
+	polyline.Points = "38.83,-77.06 bogus"
+
+	// When
+	_, err = polyline.Coordinates()
+
+	// Then
+	if err == nil {
+		t.Error("Expected error for invalid tuple")
+	}
+}
+
+func TestPolylineSetCoordinatesRoundTrip(t *testing.T) {
+	// Given
+	original := `<polyline points="38.83,-77.06 38.84,-77.05,12.5 38.85,-77.04"></polyline>`
+	var polyline Polyline
+	if err := xml.Unmarshal([]byte(original), &polyline); err != nil {
+		t.Fatalf("Failed to unmarshal polyline: %v", err)
+	}
+	points, err := polyline.Coordinates()
+	if err != nil {
+		t.Fatalf("Failed to parse coordinates: %v", err)
+	}
+
+	// When
+	data, err := xml.Marshal((&Polyline{}).SetCoordinates(points))
+	if err != nil {
+		t.Fatalf("Failed to marshal polyline: %v", err)
+	}
+
+	// Then
+	if string(data) != original {
+		t.Errorf("Round trip changed the polyline.\nGot: %s\nExpected: %s", string(data), original)
+	}
+}
+
+func TestEllipseOutline(t *testing.T) {
+	// Given
+	center := NewPoint(38.83, -77.06)
+	ellipse := Ellipse{Major: 1000, Minor: 500, Angle: 90}
+
+	// When
+	ring := ellipse.Outline(center, 4)
+
+	// Then
+	if len(ring) != 5 {
+		t.Fatalf("Unexpected ring length. Got: %d, Expected: %d", len(ring), 5)
+	}
+	if ring[0] != ring[4] {
+		t.Error("Ring is not closed")
+	}
+	// The major axis points east and the minor axis south
+	if d := center.DistanceTo(ring[0]); math.Abs(d-1000) > 0.01 {
+		t.Errorf("Unexpected major axis length. Got: %f, Expected: %f", d, 1000.0)
+	}
+	if b := center.BearingTo(ring[0]); math.Abs(b-90) > 0.01 {
+		t.Errorf("Unexpected major axis bearing. Got: %f, Expected: %f", b, 90.0)
+	}
+	if d := center.DistanceTo(ring[1]); math.Abs(d-500) > 0.01 {
+		t.Errorf("Unexpected minor axis length. Got: %f, Expected: %f", d, 500.0)
+	}
+	if b := center.BearingTo(ring[1]); math.Abs(b-180) > 0.01 {
+		t.Errorf("Unexpected minor axis bearing. Got: %f, Expected: %f", b, 180.0)
+	}
+}
@@ -52,10 +52,52 @@ func (ps *PolyStyle) SetColor(color string) *PolyStyle {
 	return ps
 }
 
+// Attributes that hold the value of a color element. Markers use argb; drawings, routes
+// and range & bearing lines use value.
+const (
+	ColorAttrARGB  = "argb"
+	ColorAttrValue = "value"
+)
+
 // Color represents a color element
 type Color struct {
 	XMLName xml.Name `xml:"color" json:"-"`
-	Value   int64    `xml:"argb,attr" json:"value"`
+	Value   int64    `json:"value"`
+	// Attr is the attribute holding the value, ColorAttrARGB when empty
+	Attr string `json:"attr,omitempty"`
+}
+
+// colorXML is the XML form of a color, with the value in one of two attributes
+type colorXML struct {
+	ARGB  *int64 `xml:"argb,attr,omitempty"`
+	Value *int64 `xml:"value,attr,omitempty"`
+}
+
+// MarshalXML writes the value in the attribute named by Attr
+func (c Color) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "color"}
+	value := c.Value
+	v := colorXML{ARGB: &value}
+	if c.Attr == ColorAttrValue {
+		v = colorXML{Value: &value}
+	}
+	return e.EncodeElement(v, start)
+}
+
+// UnmarshalXML reads a color from either attribute, remembering which one held it
+func (c *Color) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v colorXML
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	*c = Color{XMLName: start.Name}
+	switch {
+	case v.ARGB != nil:
+		c.Value = *v.ARGB
+	case v.Value != nil:
+		c.Value, c.Attr = *v.Value, ColorAttrValue
+	}
+	return nil
 }
 
 // SetValue sets the argb value of the color
@@ -168,6 +168,52 @@ func TestColorMarshalXML(t *testing.T) {
 	}
 }
 
+func TestColorValueAttribute(t *testing.T) {
+	// Given the color of an ATAK free form drawing
+	xmlData := `<color value="-1"></color>`
+
+	// When
+	var color Color
+	if err := xml.Unmarshal([]byte(xmlData), &color); err != nil {
+		t.Fatalf("Failed to unmarshal XML to Color: %v", err)
+	}
+	data, err := xml.Marshal(color)
+	if err != nil {
+		t.Fatalf("Failed to marshal Color to XML: %v", err)
+	}
+
+	// Then
+	if color.Value != -1 || color.Attr != ColorAttrValue {
+		t.Errorf("Color not correct. Got: %+v", color)
+	}
+	if string(data) != xmlData {
+		t.Errorf("Marshaled XML does not match expected.\nGot: %s\nExpected: %s", string(data), xmlData)
+	}
+}
+
+func TestColorArgbAttribute(t *testing.T) {
+	// Given the color of an ATAK marker
+	xmlData := `<color argb="-65536"></color>`
+
+	// When
+	var color Color
+	if err := xml.Unmarshal([]byte(xmlData), &color); err != nil {
+		t.Fatalf("Failed to unmarshal XML to Color: %v", err)
+	}
+	data, err := xml.Marshal(color)
+	if err != nil {
+		t.Fatalf("Failed to marshal Color to XML: %v", err)
+	}
+
+	// Then
+	if color.Value != -65536 || color.Attr != "" {
+		t.Errorf("Color not correct. Got: %+v", color)
+	}
+	if string(data) != xmlData {
+		t.Errorf("Marshaled XML does not match expected.\nGot: %s\nExpected: %s", string(data), xmlData)
+	}
+}
+
 func TestColorSetters(t *testing.T) {
 	// Given
 	color := Color{}
@@ -0,0 +1,254 @@
+package cot
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// UTM projection constants
+const (
+	// utmScaleFactor is the scale factor on the central meridian of a UTM zone
+	utmScaleFactor = 0.9996
+	// utmFalseEasting is added to eastings so they are always positive
+	utmFalseEasting = 500000.0
+	// utmFalseNorthing is added to southern hemisphere northings so they are always positive
+	utmFalseNorthing = 10000000.0
+)
+
+// ErrOutsideUTM is returned for latitudes outside the UTM/MGRS coverage (80°S to 84°N)
+var ErrOutsideUTM = errors.New("latitude outside UTM coverage (80S to 84N)")
+
+// UTM represents a position in the Universal Transverse Mercator grid
+type UTM struct {
+	Zone       int     `json:"zone"`
+	Hemisphere byte    `json:"hemisphere"` // 'N' or 'S'
+	Easting    float64 `json:"easting"`
+	Northing   float64 `json:"northing"`
+}
+
+// krugerSeries holds the Krüger series coefficients for the WGS84 ellipsoid, see
+// Karney, "Transverse Mercator with an accuracy of a few nanometers" (2011)
+var krugerSeries = func() (s struct {
+	e, A        float64
+	alpha, beta [7]float64
+}) {
+	f := WGS84Flattening
+	s.e = math.Sqrt(f * (2 - f))
+	n := f / (2 - f)
+	n2, n3, n4, n5, n6 := n*n, n*n*n, n*n*n*n, n*n*n*n*n, n*n*n*n*n*n
+	s.A = WGS84SemiMajorAxis / (1 + n) * (1 + n2/4 + n4/64 + n6/256)
+	s.alpha = [7]float64{0,
+		n/2 - 2*n2/3 + 5*n3/16 + 41*n4/180 - 127*n5/288 + 7891*n6/37800,
+		13*n2/48 - 3*n3/5 + 557*n4/1440 + 281*n5/630 - 1983433*n6/1935360,
+		61*n3/240 - 103*n4/140 + 15061*n5/26880 + 167603*n6/181440,
+		49561*n4/161280 - 179*n5/168 + 6601661*n6/7257600,
+		34729*n5/80640 - 3418889*n6/1995840,
+		212378941 * n6 / 319334400,
+	}
+	s.beta = [7]float64{0,
+		n/2 - 2*n2/3 + 37*n3/96 - n4/360 - 81*n5/512 + 96199*n6/604800,
+		n2/48 + n3/15 - 437*n4/1440 + 46*n5/105 - 1118711*n6/3870720,
+		17*n3/480 - 37*n4/840 - 209*n5/4480 + 5569*n6/90720,
+		4397*n4/161280 - 11*n5/504 - 830251*n6/7257600,
+		4583*n5/161280 - 108847*n6/3991680,
+		20648693 * n6 / 638668800,
+	}
+	return s
+}()
+
+// utmZone returns the UTM zone for a position, including the Norway and Svalbard exceptions
+func utmZone(lat, lon float64) int {
+	lon = normalizeLon(lon)
+	zone := int(math.Floor((lon+180)/6)) + 1
+	if zone > 60 {
+		zone = 60
+	}
+
+	// Southwest Norway
+	if lat >= 56 && lat < 64 && lon >= 3 && lon < 12 {
+		zone = 32
+	}
+
+	// Svalbard
+	if lat >= 72 && lat <= 84 && lon >= 0 && lon < 42 {
+		switch {
+		case lon < 9:
+			zone = 31
+		case lon < 21:
+			zone = 33
+		case lon < 33:
+			zone = 35
+		default:
+			zone = 37
+		}
+	}
+
+	return zone
+}
+
+// centralMeridian returns the longitude of the central meridian of a UTM zone in degrees
+func centralMeridian(zone int) float64 {
+	return float64(zone-1)*6 - 180 + 3
+}
+
+// transverseMercator projects a position onto a transverse mercator grid with the given
+// central meridian, returning unscaled-origin easting and northing (no false offsets)
+func transverseMercator(lat, lon, lon0 float64) (x, y float64) {
+	s := krugerSeries
+	phi := toRadians(lat)
+	lambda := toRadians(lon - lon0)
+	sinLambda, cosLambda := math.Sincos(lambda)
+
+	tau := math.Tan(phi)
+	sigma := math.Sinh(s.e * math.Atanh(s.e*tau/math.Sqrt(1+tau*tau)))
+	tauP := tau*math.Sqrt(1+sigma*sigma) - sigma*math.Sqrt(1+tau*tau)
+
+	xiP := math.Atan2(tauP, cosLambda)
+	etaP := math.Asinh(sinLambda / math.Sqrt(tauP*tauP+cosLambda*cosLambda))
+
+	xi, eta := xiP, etaP
+	for j := 1; j <= 6; j++ {
+		fj := float64(2 * j)
+		xi += s.alpha[j] * math.Sin(fj*xiP) * math.Cosh(fj*etaP)
+		eta += s.alpha[j] * math.Cos(fj*xiP) * math.Sinh(fj*etaP)
+	}
+
+	return utmScaleFactor * s.A * eta, utmScaleFactor * s.A * xi
+}
+
+// inverseTransverseMercator is the inverse of transverseMercator
+func inverseTransverseMercator(x, y, lon0 float64) (lat, lon float64) {
+	s := krugerSeries
+	eta := x / (utmScaleFactor * s.A)
+	xi := y / (utmScaleFactor * s.A)
+
+	xiP, etaP := xi, eta
+	for j := 1; j <= 6; j++ {
+		fj := float64(2 * j)
+		xiP -= s.beta[j] * math.Sin(fj*xi) * math.Cosh(fj*eta)
+		etaP -= s.beta[j] * math.Cos(fj*xi) * math.Sinh(fj*eta)
+	}
+
+	sinhEtaP := math.Sinh(etaP)
+	sinXiP, cosXiP := math.Sincos(xiP)
+	tauP := sinXiP / math.Sqrt(sinhEtaP*sinhEtaP+cosXiP*cosXiP)
+
+	e2 := s.e * s.e
+	tau := tauP
+	for i := 0; i < 20; i++ {
+		sigma := math.Sinh(s.e * math.Atanh(s.e*tau/math.Sqrt(1+tau*tau)))
+		tauI := tau*math.Sqrt(1+sigma*sigma) - sigma*math.Sqrt(1+tau*tau)
+		delta := (tauP - tauI) / math.Sqrt(1+tauI*tauI) *
+			(1 + (1-e2)*tau*tau) / ((1 - e2) * math.Sqrt(1+tau*tau))
+		tau += delta
+		if math.Abs(delta) < 1e-12 {
+			break
+		}
+	}
+
+	lat = toDegrees(math.Atan(tau))
+	lon = normalizeLon(lon0 + toDegrees(math.Atan2(sinhEtaP, cosXiP)))
+	return lat, lon
+}
+
+// ToUTM converts the point to UTM coordinates in its standard zone
+func (p Point) ToUTM() (UTM, error) {
+	if p.Lat < -80 || p.Lat > 84 {
+		return UTM{}, ErrOutsideUTM
+	}
+	return p.toUTMZone(utmZone(p.Lat, p.Lon)), nil
+}
+
+// toUTMZone converts the point to UTM coordinates in the given zone
+func (p Point) toUTMZone(zone int) UTM {
+	x, y := transverseMercator(p.Lat, p.Lon, centralMeridian(zone))
+	u := UTM{
+		Zone:       zone,
+		Hemisphere: 'N',
+		Easting:    x + utmFalseEasting,
+		Northing:   y,
+	}
+	if p.Lat < 0 {
+		u.Hemisphere = 'S'
+		u.Northing += utmFalseNorthing
+	}
+	return u
+}
+
+// ToPoint converts UTM coordinates back to a geodetic point
+func (u UTM) ToPoint() (Point, error) {
+	if u.Zone < 1 || u.Zone > 60 {
+		return Point{}, fmt.Errorf("invalid UTM zone: %d", u.Zone)
+	}
+
+	y := u.Northing
+	switch u.Hemisphere {
+	case 'N', 'n':
+	case 'S', 's':
+		y -= utmFalseNorthing
+	default:
+		return Point{}, fmt.Errorf("invalid UTM hemisphere: %q", u.Hemisphere)
+	}
+
+	lat, lon := inverseTransverseMercator(u.Easting-utmFalseEasting, y, centralMeridian(u.Zone))
+	return NewPoint(lat, lon), nil
+}
+
+// Format returns the UTM coordinates as "18N 323394 4307395" with easting and northing
+// rounded to the given number of decimal places
+func (u UTM) Format(precision int) string {
+	if precision < 0 {
+		precision = 0
+	}
+	return fmt.Sprintf("%d%c %.*f %.*f", u.Zone, u.Hemisphere, precision, u.Easting, precision, u.Northing)
+}
+
+// String returns the UTM coordinates rounded to the meter
+func (u UTM) String() string {
+	return u.Format(0)
+}
+
+// ParseUTM parses UTM coordinates in the form "18N 323394 4307395" or "18 N 323394 4307395"
+func ParseUTM(s string) (UTM, error) {
+	fields := strings.Fields(strings.ToUpper(s))
+	if len(fields) == 4 {
+		fields = []string{fields[0] + fields[1], fields[2], fields[3]}
+	}
+	if len(fields) != 3 || len(fields[0]) < 2 {
+		return UTM{}, fmt.Errorf("invalid UTM coordinates: %q", s)
+	}
+
+	zoneStr := fields[0][:len(fields[0])-1]
+	zone, err := strconv.Atoi(zoneStr)
+	if err != nil || zone < 1 || zone > 60 {
+		return UTM{}, fmt.Errorf("invalid UTM zone: %q", zoneStr)
+	}
+
+	hemisphere := fields[0][len(fields[0])-1]
+	if hemisphere != 'N' && hemisphere != 'S' {
+		return UTM{}, fmt.Errorf("invalid UTM hemisphere: %q", hemisphere)
+	}
+
+	easting, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return UTM{}, fmt.Errorf("invalid UTM easting: %w", err)
+	}
+	northing, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return UTM{}, fmt.Errorf("invalid UTM northing: %w", err)
+	}
+
+	return UTM{Zone: zone, Hemisphere: hemisphere, Easting: easting, Northing: northing}, nil
+}
+
+// PointFromUTM parses UTM coordinates and converts them to a point
+func PointFromUTM(s string) (Point, error) {
+	u, err := ParseUTM(s)
+	if err != nil {
+		return Point{}, err
+	}
+	return u.ToPoint()
+}
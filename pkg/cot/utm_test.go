@@ -0,0 +1,125 @@
+package cot
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPointToUTM(t *testing.T) {
+	// Given
+	point := NewPoint(48.8582, 2.2945)
+
+	// When
+	u, err := point.ToUTM()
+	if err != nil {
+		t.Fatalf("Failed to convert point to UTM: %v", err)
+	}
+
+	// Then
+	expected := "31N 448252 5411933"
+	if u.String() != expected {
+		t.Errorf("UTM not correct. Got: %s, Expected: %s", u.String(), expected)
+	}
+	if u.Format(2) != "31N 448251.80 5411932.68" {
+		t.Errorf("UTM with precision not correct. Got: %s", u.Format(2))
+	}
+}
+
+func TestPointToUTMZoneExceptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		point    Point
+		expected int
+	}{
+		{name: "Southwest Norway", point: NewPoint(60, 5), expected: 32},
+		{name: "Svalbard zone 33", point: NewPoint(78, 15), expected: 33},
+		{name: "Svalbard zone 37", point: NewPoint(78, 40), expected: 37},
+		{name: "Antimeridian", point: NewPoint(0, 180), expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := tt.point.ToUTM()
+			if err != nil {
+				t.Fatalf("ToUTM() error = %v", err)
+			}
+			if u.Zone != tt.expected {
+				t.Errorf("ToUTM() zone = %d, want %d", u.Zone, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPointToUTMOutsideCoverage(t *testing.T) {
+	// When
+	_, err := NewPoint(85, 0).ToUTM()
+
+	// Then
+	if err != ErrOutsideUTM {
+		t.Errorf("Expected ErrOutsideUTM, got: %v", err)
+	}
+}
+
+func TestUTMRoundTrip(t *testing.T) {
+	points := []Point{
+		NewPoint(38.8977, -77.0365),
+		NewPoint(-33.8568, 151.2153),
+		NewPoint(-79.9, -179.9),
+		NewPoint(83.9, 179.9),
+		NewPoint(0, 0),
+	}
+
+	for _, p := range points {
+		u, err := p.ToUTM()
+		if err != nil {
+			t.Fatalf("Failed to convert %v to UTM: %v", p, err)
+		}
+		back, err := u.ToPoint()
+		if err != nil {
+			t.Fatalf("Failed to convert %v back to a point: %v", u, err)
+		}
+		if math.Abs(back.Lat-p.Lat) > 1e-9 || math.Abs(back.Lon-p.Lon) > 1e-9 {
+			t.Errorf("Round trip not exact. Got: %f,%f, Expected: %f,%f", back.Lat, back.Lon, p.Lat, p.Lon)
+		}
+	}
+}
+
+func TestParseUTM(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		expected  UTM
+		expectErr bool
+	}{
+		{name: "Compact", input: "31N 448252 5411933", expected: UTM{Zone: 31, Hemisphere: 'N', Easting: 448252, Northing: 5411933}},
+		{name: "Separate hemisphere", input: "56 s 334900.5 6252288", expected: UTM{Zone: 56, Hemisphere: 'S', Easting: 334900.5, Northing: 6252288}},
+		{name: "Invalid zone", input: "61N 448252 5411933", expectErr: true},
+		{name: "Invalid hemisphere", input: "31X 448252 5411933", expectErr: true},
+		{name: "Missing northing", input: "31N 448252", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseUTM(tt.input)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("ParseUTM() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if !tt.expectErr && got != tt.expected {
+				t.Errorf("ParseUTM() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPointFromUTM(t *testing.T) {
+	// When
+	point, err := PointFromUTM("31N 448251.80 5411932.68")
+	if err != nil {
+		t.Fatalf("Failed to parse UTM: %v", err)
+	}
+
+	// Then
+	if math.Abs(point.Lat-48.8582) > 1e-7 || math.Abs(point.Lon-2.2945) > 1e-7 {
+		t.Errorf("Point not correct. Got: %f,%f", point.Lat, point.Lon)
+	}
+}
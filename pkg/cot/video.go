@@ -0,0 +1,136 @@
+package cot
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TypeVideoAlias is the type of events announcing a video feed to ATAK's video player
+const TypeVideoAlias = "b-i-v"
+
+// VideoStaleTime is how long a video alias stays valid
+const VideoStaleTime = 24 * time.Hour
+
+// Defaults ATAK writes for video connection entries
+const (
+	DefaultVideoNetworkTimeout = 12 * time.Second
+	DefaultVideoBufferTime     = -1
+)
+
+// videoDefaultPorts are the ports assumed for feed URLs without one
+var videoDefaultPorts = map[string]int{
+	"rtsp":  554,
+	"rtsps": 322,
+	"rtmp":  1935,
+	"rtmps": 443,
+	"http":  80,
+	"https": 443,
+	"srt":   9000,
+}
+
+// Video represents the __video element as defined in __video.xsd. ATAK also nests the
+// full connection settings of the feed in a ConnectionEntry.
+type Video struct {
+	XMLName         xml.Name         `xml:"__video" json:"-"`
+	UID             string           `xml:"uid,attr,omitempty" json:"uid,omitempty"`
+	URL             string           `xml:"url,attr" json:"url"`
+	ConnectionEntry *ConnectionEntry `xml:"ConnectionEntry,omitempty" json:"connection_entry,omitempty"`
+}
+
+// ConnectionEntry describes a video feed as stored in ATAK's video library
+type ConnectionEntry struct {
+	XMLName xml.Name `xml:"ConnectionEntry" json:"-"`
+	UID     string   `xml:"uid,attr" json:"uid"`
+	// Alias is the name of the feed shown to the user
+	Alias    string `xml:"alias,attr" json:"alias"`
+	Protocol string `xml:"protocol,attr" json:"protocol"`
+	Address  string `xml:"address,attr" json:"address"`
+	Port     int    `xml:"port,attr" json:"port"`
+	// Path is the path and query of the feed URL, starting with a slash
+	Path      string `xml:"path,attr" json:"path"`
+	RoverPort int    `xml:"roverPort,attr" json:"rover_port"`
+	// RTSPReliable is 1 to receive RTSP over TCP
+	RTSPReliable      int  `xml:"rtspReliable,attr" json:"rtsp_reliable"`
+	IgnoreEmbeddedKLV bool `xml:"ignoreEmbeddedKLV,attr" json:"ignore_embedded_klv"`
+	// NetworkTimeout and BufferTime are in milliseconds; -1 is the player default
+	NetworkTimeout int `xml:"networkTimeout,attr" json:"network_timeout"`
+	BufferTime     int `xml:"bufferTime,attr" json:"buffer_time"`
+}
+
+// NewConnectionEntry creates the connection entry of a feed URL, e.g.
+// rtsp://10.0.0.5:8554/live
+func NewConnectionEntry(uid, alias, feedURL string) (*ConnectionEntry, error) {
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid video URL: %w", err)
+	}
+	protocol := strings.ToLower(u.Scheme)
+	if protocol == "" || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid video URL %q: protocol and address are required", feedURL)
+	}
+
+	port := videoDefaultPorts[protocol]
+	if p := u.Port(); p != "" {
+		if port, err = strconv.Atoi(p); err != nil {
+			return nil, fmt.Errorf("invalid video URL port %q", p)
+		}
+	}
+	path := u.EscapedPath()
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	return &ConnectionEntry{
+		UID:            uid,
+		Alias:          alias,
+		Protocol:       protocol,
+		Address:        u.Hostname(),
+		Port:           port,
+		Path:           path,
+		RoverPort:      -1,
+		NetworkTimeout: int(DefaultVideoNetworkTimeout / time.Millisecond),
+		BufferTime:     DefaultVideoBufferTime,
+	}, nil
+}
+
+// URL returns the feed URL of the connection entry
+func (c *ConnectionEntry) URL() string {
+	host := c.Address
+	if c.Port > 0 && c.Port != videoDefaultPorts[c.Protocol] {
+		host = net.JoinHostPort(c.Address, strconv.Itoa(c.Port))
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	path := c.Path
+	if path != "" && !strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "?") {
+		path = "/" + path
+	}
+	return c.Protocol + "://" + host + path
+}
+
+// SetVideo sets the __video element of the detail to a feed URL, as sensor and
+// aircraft events carry it
+func (d *Detail) SetVideo(feedURL string) *Video {
+	d.Video = &Video{URL: feedURL}
+	return d.Video
+}
+
+// NewVideoAlias creates a b-i-v event that adds a feed to the video library of ATAK
+// under the given alias
+func NewVideoAlias(uid, alias, feedURL string) (*Event, error) {
+	entry, err := NewConnectionEntry(uid, alias, feedURL)
+	if err != nil {
+		return nil, err
+	}
+	event := NewEvent(TypeVideoAlias, uid)
+	event.SetStale(event.Time.Add(VideoStaleTime).Time())
+	event.Point.SetHae(DefaultValue).SetCe(DefaultValue).SetLe(DefaultValue)
+	event.Detail.AddContact(alias)
+	event.Detail.Video = &Video{UID: uid, URL: entry.URL(), ConnectionEntry: entry}
+	return event, nil
+}
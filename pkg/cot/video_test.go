@@ -0,0 +1,76 @@
+package cot
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestNewConnectionEntry(t *testing.T) {
+	testCases := []struct {
+		url      string
+		protocol string
+		address  string
+		port     int
+		path     string
+	}{
+		{"rtsp://10.0.0.5:8554/live/cam1", "rtsp", "10.0.0.5", 8554, "/live/cam1"},
+		{"rtsp://10.0.0.5/live", "rtsp", "10.0.0.5", 554, "/live"},
+		{"srt://[fd00::5]:9001?streamid=cam", "srt", "fd00::5", 9001, "?streamid=cam"},
+		{"HTTP://camera.local/stream.m3u8", "http", "camera.local", 80, "/stream.m3u8"},
+	}
+
+	for _, tc := range testCases {
+		// When
+		entry, err := NewConnectionEntry("video-1", "Cam", tc.url)
+
+		// Then
+		if err != nil {
+			t.Fatalf("Failed to create connection entry for %s: %v", tc.url, err)
+		}
+		if entry.Protocol != tc.protocol || entry.Address != tc.address || entry.Port != tc.port || entry.Path != tc.path {
+			t.Errorf("Unexpected connection entry for %s. Got: %+v", tc.url, entry)
+		}
+		expected := strings.Replace(tc.url, "HTTP", "http", 1)
+		if got := entry.URL(); got != expected {
+			t.Errorf("Unexpected URL. Got: %s, Expected: %s", got, expected)
+		}
+	}
+
+	if _, err := NewConnectionEntry("video-1", "Cam", "10.0.0.5/live"); err == nil {
+		t.Error("Expected an error for a URL without protocol")
+	}
+}
+
+func TestVideoAliasXML(t *testing.T) {
+	// Given
+	event, err := NewVideoAlias("video-1", "Gate camera", "rtsp://10.0.0.5:8554/live")
+	if err != nil {
+		t.Fatalf("Failed to create video alias: %v", err)
+	}
+
+	// When
+	data, err := xml.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal video alias: %v", err)
+	}
+	var parsed Event
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("Failed to unmarshal video alias: %v", err)
+	}
+
+	// Then
+	expected := `<__video uid="video-1" url="rtsp://10.0.0.5:8554/live"><ConnectionEntry uid="video-1" alias="Gate camera" protocol="rtsp" address="10.0.0.5" port="8554" path="/live" roverPort="-1" rtspReliable="0" ignoreEmbeddedKLV="false" networkTimeout="12000" bufferTime="-1"></ConnectionEntry></__video>`
+	if !strings.Contains(string(data), expected) {
+		t.Errorf("Marshaled XML does not contain the video element.\nGot: %s\nExpected: %s", data, expected)
+	}
+	if parsed.Type != TypeVideoAlias {
+		t.Errorf("Unexpected type. Got: %s, Expected: %s", parsed.Type, TypeVideoAlias)
+	}
+	entry := *event.Detail.Video.ConnectionEntry
+	entry.XMLName = xml.Name{Local: "ConnectionEntry"}
+	video := parsed.Detail.Video
+	if video == nil || video.ConnectionEntry == nil || *video.ConnectionEntry != entry {
+		t.Errorf("Unexpected connection entry. Got: %+v, Expected: %+v", video, entry)
+	}
+}
@@ -0,0 +1,234 @@
+// Package datapackage imports TAK connection data packages: zip files holding a
+// config.pref connection profile, a client certificate and a truststore, as handed out
+// by TAK Server.
+package datapackage
+
+import (
+	"archive/zip"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/angry-kivi/gotak/pkg/enrollment"
+	"github.com/angry-kivi/gotak/pkg/tak"
+	"github.com/angry-kivi/gotak/pkg/util"
+)
+
+// MaxFileSize is the largest file read from a data package
+const MaxFileSize = 16 << 20
+
+// ErrEnrollmentRequired is returned when a connection has no client certificate and
+// expects one to be obtained through certificate enrollment
+var ErrEnrollmentRequired = errors.New("connection requires certificate enrollment")
+
+// Package is an unpacked data package
+type Package struct {
+	// Files holds the package content by path within the zip file
+	Files map[string][]byte
+	// Preferences is the connection profile of the package
+	Preferences Preferences
+	// Connections are the server connections of the profile
+	Connections []Connection
+}
+
+// Open reads a data package from a zip file
+func Open(name string) (*Package, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Read(f, info.Size())
+}
+
+// Read reads a data package from zip data
+func Read(r io.ReaderAt, size int64) (*Package, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open data package: %w", err)
+	}
+
+	p := &Package{Files: map[string][]byte{}}
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		if file.UncompressedSize64 > MaxFileSize {
+			return nil, fmt.Errorf("data package file %s is too large", file.Name)
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+		}
+		data, err := io.ReadAll(io.LimitReader(rc, MaxFileSize+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+		}
+		p.Files[file.Name] = data
+	}
+
+	prefFile := p.preferencesFile()
+	if prefFile == "" {
+		return nil, errors.New("data package has no .pref connection profile")
+	}
+	if p.Preferences, err = ParsePreferences(p.Files[prefFile]); err != nil {
+		return nil, err
+	}
+	if p.Connections, err = p.Preferences.Connections(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// preferencesFile returns the connection profile, preferring config.pref
+func (p *Package) preferencesFile() string {
+	var names []string
+	for name := range p.Files {
+		if strings.HasSuffix(strings.ToLower(name), ".pref") {
+			if path.Base(name) == "config.pref" {
+				return name
+			}
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return names[0]
+}
+
+// File returns a file of the package. Preferences refer to files by their location on
+// the device, such as cert/truststore-root.p12 or /sdcard/atak/cert/bot-1.p12, so a file
+// is found by its path or else by its base name.
+func (p *Package) File(location string) ([]byte, error) {
+	if data, ok := p.Files[location]; ok {
+		return data, nil
+	}
+	base := path.Base(strings.ReplaceAll(location, "\\", "/"))
+	for name, data := range p.Files {
+		if path.Base(name) == base {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("data package has no file %s", location)
+}
+
+// Connection returns the first enabled connection of the package
+func (p *Package) Connection() (Connection, error) {
+	for _, c := range p.Connections {
+		if c.Enabled {
+			return c, nil
+		}
+	}
+	return Connection{}, errors.New("data package has no enabled connection")
+}
+
+// TrustStore returns the CA certificates of the connection's truststore
+func (p *Package) TrustStore(c Connection) (*x509.CertPool, error) {
+	if c.CALocation == "" {
+		return nil, errors.New("connection has no truststore")
+	}
+	data, err := p.File(c.CALocation)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := util.ParseTrustStoreP12(data, c.CAPassword)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return pool, nil
+}
+
+// TLSConfig returns the TLS configuration of a connection, presenting the client
+// certificate and trusting the truststore. It returns ErrEnrollmentRequired when the
+// package has no client certificate and expects enrollment.
+func (p *Package) TLSConfig(c Connection) (*tls.Config, error) {
+	if c.CertificateLocation == "" {
+		if c.EnrollForCertificate {
+			return nil, ErrEnrollmentRequired
+		}
+		return nil, errors.New("connection has no client certificate")
+	}
+	trustStore, err := p.TrustStore(c)
+	if err != nil {
+		return nil, err
+	}
+	data, err := p.File(c.CertificateLocation)
+	if err != nil {
+		return nil, err
+	}
+	return util.TLSConfigFromP12(data, c.ClientPassword, trustStore, false)
+}
+
+// ConnectionConfig returns a client configuration for a connection
+func (p *Package) ConnectionConfig(c Connection) (tak.ClientConfig, error) {
+	config := tak.ClientConfig{Address: c.Address, Port: c.Port}
+	switch c.Protocol {
+	case "ssl":
+		config.ConnectionType = tak.ConnectionTypeTLS
+		tlsConfig, err := p.TLSConfig(c)
+		if err != nil {
+			return tak.ClientConfig{}, err
+		}
+		config.TLSConfig = tlsConfig
+	case "tcp":
+		config.ConnectionType = tak.ConnectionTypeTCP
+	case "udp":
+		config.ConnectionType = tak.ConnectionTypeUDP
+	default:
+		return tak.ClientConfig{}, fmt.Errorf("unsupported connection protocol %q", c.Protocol)
+	}
+	return config, nil
+}
+
+// ClientConfig returns a client configuration for the first enabled connection
+func (p *Package) ClientConfig() (tak.ClientConfig, error) {
+	c, err := p.Connection()
+	if err != nil {
+		return tak.ClientConfig{}, err
+	}
+	return p.ConnectionConfig(c)
+}
+
+// EnrollmentConfig returns the enrollment configuration for a connection that requires
+// enrollment, trusting the package truststore
+func (p *Package) EnrollmentConfig(c Connection, username, password string) (enrollment.Config, error) {
+	trustStore, err := p.TrustStore(c)
+	if err != nil {
+		return enrollment.Config{}, err
+	}
+	return enrollment.Config{
+		Address:   c.Address,
+		Port:      enrollment.DefaultPort,
+		Username:  username,
+		Password:  password,
+		TLSConfig: &tls.Config{RootCAs: trustStore},
+	}, nil
+}
+
+// LoadClientConfig reads a data package and returns a client configuration for its
+// first enabled connection
+func LoadClientConfig(name string) (tak.ClientConfig, error) {
+	p, err := Open(name)
+	if err != nil {
+		return tak.ClientConfig{}, err
+	}
+	return p.ClientConfig()
+}
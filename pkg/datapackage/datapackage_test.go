@@ -0,0 +1,302 @@
+package datapackage
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/enrollment"
+	"github.com/angry-kivi/gotak/pkg/tak"
+	"github.com/angry-kivi/gotak/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const configPref = `<?xml version='1.0' standalone='yes'?>
+<preferences>
+  <preference version="1" name="cot_streams">
+    <entry key="count" class="class java.lang.Integer">1</entry>
+    <entry key="description0" class="class java.lang.String">TAK Server</entry>
+    <entry key="enabled0" class="class java.lang.Boolean">true</entry>
+    <entry key="connectString0" class="class java.lang.String">%s</entry>
+  </preference>
+  <preference version="1" name="com.atakmap.app_preferences">
+    <entry key="displayServerConnectionWidget" class="class java.lang.Boolean">true</entry>
+    <entry key="caLocation" class="class java.lang.String">/storage/emulated/0/atak/cert/truststore-root.p12</entry>
+    <entry key="caPassword" class="class java.lang.String">trust-pass</entry>
+    <entry key="clientPassword" class="class java.lang.String">client-pass</entry>
+    <entry key="certificateLocation" class="class java.lang.String">/storage/emulated/0/atak/cert/bot-1.p12</entry>
+  </preference>
+</preferences>`
+
+const enrollmentPref = `<?xml version='1.0' standalone='yes'?>
+<preferences>
+  <preference version="1" name="cot_streams">
+    <entry key="count" class="class java.lang.Integer">2</entry>
+    <entry key="description0" class="class java.lang.String">Old</entry>
+    <entry key="enabled0" class="class java.lang.Boolean">false</entry>
+    <entry key="connectString0" class="class java.lang.String">old.example.com:8087:tcp</entry>
+    <entry key="description1" class="class java.lang.String">TAK Server</entry>
+    <entry key="enabled1" class="class java.lang.Boolean">true</entry>
+    <entry key="connectString1" class="class java.lang.String">takserver.example.com:8089:ssl</entry>
+    <entry key="caLocation1" class="class java.lang.String">cert/truststore-root.p12</entry>
+    <entry key="caPassword1" class="class java.lang.String">trust-pass</entry>
+    <entry key="enrollForCertificateWithTrust1" class="class java.lang.Boolean">true</entry>
+    <entry key="useAuth1" class="class java.lang.Boolean">true</entry>
+  </preference>
+</preferences>`
+
+// testPKI is a CA with a server and a client certificate
+type testPKI struct {
+	ca         *x509.Certificate
+	serverCert tls.Certificate
+	clientKey  *ecdsa.PrivateKey
+	client     *x509.Certificate
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	issue := func(template, parent *x509.Certificate, pub any, key *ecdsa.PrivateKey) *x509.Certificate {
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(time.Hour)
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, key)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+		return cert
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "TAK CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	ca := issue(caTemplate, caTemplate, &caKey.PublicKey, caKey)
+
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	server := issue(&x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "takserver"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, &serverKey.PublicKey, caKey)
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	client := issue(&x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "bot-1"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, &clientKey.PublicKey, caKey)
+
+	return &testPKI{
+		ca:         ca,
+		serverCert: tls.Certificate{Certificate: [][]byte{server.Raw}, PrivateKey: serverKey},
+		clientKey:  clientKey,
+		client:     client,
+	}
+}
+
+// zipPackage writes files to a data package zip
+func zipPackage(t *testing.T, files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+// connectionPackage returns a data package with a client certificate for connectString
+func (pki *testPKI) connectionPackage(t *testing.T, connectString string) []byte {
+	clientP12, err := util.EncodeP12(pki.clientKey, pki.client, []*x509.Certificate{pki.ca}, "client-pass")
+	require.NoError(t, err)
+	trustStore, err := util.EncodeTrustStoreP12([]*x509.Certificate{pki.ca}, "trust-pass")
+	require.NoError(t, err)
+
+	return zipPackage(t, map[string][]byte{
+		"MANIFEST/manifest.xml":     []byte(`<MissionPackageManifest version="2"/>`),
+		"certs/config.pref":         []byte(fmt.Sprintf(configPref, connectString)),
+		"certs/truststore-root.p12": trustStore,
+		"certs/bot-1.p12":           clientP12,
+		"certs/ignored/readme.txt":  []byte("not a preference file"),
+	})
+}
+
+func TestParseConnectString(t *testing.T) {
+	address, port, protocol, err := ParseConnectString("takserver.example.com:8089:ssl")
+	require.NoError(t, err)
+	assert.Equal(t, "takserver.example.com", address)
+	assert.Equal(t, 8089, port)
+	assert.Equal(t, "ssl", protocol)
+
+	address, port, protocol, err = ParseConnectString("[fd00::1]:8087:TCP")
+	require.NoError(t, err)
+	assert.Equal(t, "fd00::1", address)
+	assert.Equal(t, 8087, port)
+	assert.Equal(t, "tcp", protocol)
+
+	for _, s := range []string{"", "takserver", "takserver:ssl", ":8089:ssl", "takserver:port:ssl", "takserver:70000:ssl"} {
+		_, _, _, err := ParseConnectString(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestPreferencesConnections(t *testing.T) {
+	prefs, err := ParsePreferences([]byte(fmt.Sprintf(configPref, "takserver.example.com:8089:ssl")))
+	require.NoError(t, err)
+	assert.True(t, prefs.Bool(AppPreference, "displayServerConnectionWidget"))
+
+	connections, err := prefs.Connections()
+	require.NoError(t, err)
+	require.Len(t, connections, 1)
+	assert.Equal(t, Connection{
+		Description:         "TAK Server",
+		Address:             "takserver.example.com",
+		Port:                8089,
+		Protocol:            "ssl",
+		Enabled:             true,
+		CALocation:          "/storage/emulated/0/atak/cert/truststore-root.p12",
+		CAPassword:          "trust-pass",
+		CertificateLocation: "/storage/emulated/0/atak/cert/bot-1.p12",
+		ClientPassword:      "client-pass",
+	}, connections[0])
+
+	_, err = ParsePreferences([]byte("<preferences>"))
+	assert.Error(t, err)
+}
+
+func TestClientConfigConnects(t *testing.T) {
+	pki := newTestPKI(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(pki.ca)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{pki.serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 64)
+		n, _ := conn.Read(buf)
+		received <- string(buf[:n])
+	}()
+
+	name := filepath.Join(t.TempDir(), "bot-1.zip")
+	require.NoError(t, os.WriteFile(name, pki.connectionPackage(t, listener.Addr().String()+":ssl"), 0600))
+
+	config, err := LoadClientConfig(name)
+	require.NoError(t, err)
+	assert.Equal(t, tak.ConnectionTypeTLS, config.ConnectionType)
+	assert.Equal(t, "127.0.0.1", config.Address)
+	assert.Equal(t, "bot-1", config.TLSConfig.Certificates[0].Leaf.Subject.CommonName)
+
+	config.DialTimeout = 5 * time.Second
+	client, err := tak.NewClient(config)
+	require.NoError(t, err)
+	require.NoError(t, client.Connect(context.Background()))
+	defer client.Disconnect()
+	require.NoError(t, client.Send([]byte("ping")))
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "ping", msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not receive data")
+	}
+}
+
+func TestClientConfigRejectsUntrustedServer(t *testing.T) {
+	pki := newTestPKI(t)
+	other := newTestPKI(t)
+	data := pki.connectionPackage(t, "127.0.0.1:8089:ssl")
+
+	p, err := Read(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	config, err := p.ClientConfig()
+	require.NoError(t, err)
+
+	// The truststore verifies servers of its CA only, not the client's own certificate
+	opts := x509.VerifyOptions{Roots: config.TLSConfig.RootCAs, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}
+	server, err := x509.ParseCertificate(pki.serverCert.Certificate[0])
+	require.NoError(t, err)
+	_, err = server.Verify(opts)
+	assert.NoError(t, err)
+	otherServer, err := x509.ParseCertificate(other.serverCert.Certificate[0])
+	require.NoError(t, err)
+	_, err = otherServer.Verify(opts)
+	assert.Error(t, err)
+}
+
+func TestEnrollmentPackage(t *testing.T) {
+	pki := newTestPKI(t)
+	trustStore, err := util.EncodeTrustStoreP12([]*x509.Certificate{pki.ca}, "trust-pass")
+	require.NoError(t, err)
+	data := zipPackage(t, map[string][]byte{
+		"enroll.pref":              []byte(enrollmentPref),
+		"cert/truststore-root.p12": trustStore,
+	})
+
+	p, err := Read(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Len(t, p.Connections, 2)
+
+	c, err := p.Connection()
+	require.NoError(t, err)
+	assert.Equal(t, "takserver.example.com", c.Address)
+	assert.True(t, c.EnrollForCertificate)
+	assert.True(t, c.UseAuth)
+
+	_, err = p.ClientConfig()
+	assert.ErrorIs(t, err, ErrEnrollmentRequired)
+
+	config, err := p.EnrollmentConfig(c, "bot-1", "secret")
+	require.NoError(t, err)
+	assert.Equal(t, enrollment.DefaultPort, config.Port)
+	assert.Equal(t, "bot-1", config.Username)
+	assert.NotNil(t, config.TLSConfig.RootCAs)
+}
+
+func TestReadErrors(t *testing.T) {
+	data := zipPackage(t, map[string][]byte{"readme.txt": []byte("no profile")})
+	_, err := Read(bytes.NewReader(data), int64(len(data)))
+	assert.Error(t, err)
+
+	_, err = Read(bytes.NewReader([]byte("not a zip")), 9)
+	assert.Error(t, err)
+
+	data = zipPackage(t, map[string][]byte{"config.pref": []byte(fmt.Sprintf(configPref, "takserver:8089:ssl"))})
+	p, err := Read(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	_, err = p.ClientConfig()
+	assert.Error(t, err, "the certificate files are missing")
+}
@@ -0,0 +1,154 @@
+package datapackage
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Preference names used in connection profiles
+const (
+	StreamsPreference = "cot_streams"
+	AppPreference     = "com.atakmap.app_preferences"
+)
+
+// Preferences is an ATAK preference file such as config.pref, keyed by preference name
+// and entry key
+type Preferences map[string]map[string]string
+
+type preferencesXML struct {
+	XMLName     xml.Name `xml:"preferences"`
+	Preferences []struct {
+		Name    string `xml:"name,attr"`
+		Entries []struct {
+			Key   string `xml:"key,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"entry"`
+	} `xml:"preference"`
+}
+
+// ParsePreferences parses an ATAK preference file
+func ParsePreferences(data []byte) (Preferences, error) {
+	var doc preferencesXML
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse preferences: %w", err)
+	}
+	prefs := Preferences{}
+	for _, p := range doc.Preferences {
+		entries := prefs[p.Name]
+		if entries == nil {
+			entries = map[string]string{}
+			prefs[p.Name] = entries
+		}
+		for _, e := range p.Entries {
+			entries[e.Key] = strings.TrimSpace(e.Value)
+		}
+	}
+	return prefs, nil
+}
+
+// Get returns an entry of a preference
+func (p Preferences) Get(name, key string) string {
+	return p[name][key]
+}
+
+// Bool returns a boolean entry of a preference, false when missing
+func (p Preferences) Bool(name, key string) bool {
+	value, _ := strconv.ParseBool(p.Get(name, key))
+	return value
+}
+
+// Connection is a server connection of the cot_streams preference
+type Connection struct {
+	Description string
+	Address     string
+	Port        int
+	// Protocol is ssl, tcp, udp or quic
+	Protocol string
+	Enabled  bool
+
+	// CALocation and CertificateLocation name the truststore and client .p12 files
+	CALocation          string
+	CAPassword          string
+	CertificateLocation string
+	ClientPassword      string
+
+	// EnrollForCertificate is set when the client certificate has to be obtained through
+	// certificate enrollment with the server
+	EnrollForCertificate bool
+	UseAuth              bool
+}
+
+// ParseConnectString parses a connect string of the form host:port:protocol
+func ParseConnectString(s string) (address string, port int, protocol string, err error) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return "", 0, "", fmt.Errorf("invalid connect string %q", s)
+	}
+	protocol = strings.ToLower(s[i+1:])
+	hostPort := s[:i]
+	j := strings.LastIndex(hostPort, ":")
+	if j < 0 {
+		return "", 0, "", fmt.Errorf("invalid connect string %q", s)
+	}
+	address = strings.Trim(hostPort[:j], "[]")
+	if port, err = strconv.Atoi(hostPort[j+1:]); err != nil || port <= 0 || port > 65535 {
+		return "", 0, "", fmt.Errorf("invalid port in connect string %q", s)
+	}
+	if address == "" {
+		return "", 0, "", fmt.Errorf("missing address in connect string %q", s)
+	}
+	return address, port, protocol, nil
+}
+
+// Connections returns the server connections of the cot_streams preference. Certificate
+// locations and passwords missing from a connection fall back to the app preferences.
+func (p Preferences) Connections() ([]Connection, error) {
+	streams := p[StreamsPreference]
+	count, err := strconv.Atoi(streams["count"])
+	if err != nil {
+		// Some generators leave out the count
+		for count = 0; streams[fmt.Sprintf("connectString%d", count)] != ""; count++ {
+		}
+	}
+
+	connections := make([]Connection, 0, count)
+	for i := 0; i < count; i++ {
+		entry := func(key string) string {
+			return streams[fmt.Sprintf("%s%d", key, i)]
+		}
+		fallback := func(key, appKey string) string {
+			if value := entry(key); value != "" {
+				return value
+			}
+			return p.Get(AppPreference, appKey)
+		}
+
+		address, port, protocol, err := ParseConnectString(entry("connectString"))
+		if err != nil {
+			return nil, err
+		}
+		enabled := true
+		if value := entry("enabled"); value != "" {
+			enabled, _ = strconv.ParseBool(value)
+		}
+		enroll, _ := strconv.ParseBool(entry("enrollForCertificateWithTrust"))
+		useAuth, _ := strconv.ParseBool(entry("useAuth"))
+
+		connections = append(connections, Connection{
+			Description:          entry("description"),
+			Address:              address,
+			Port:                 port,
+			Protocol:             protocol,
+			Enabled:              enabled,
+			CALocation:           fallback("caLocation", "caLocation"),
+			CAPassword:           fallback("caPassword", "caPassword"),
+			CertificateLocation:  fallback("certificateLocation", "certificateLocation"),
+			ClientPassword:       fallback("clientPassword", "clientPassword"),
+			EnrollForCertificate: enroll,
+			UseAuth:              useAuth,
+		})
+	}
+	return connections, nil
+}
@@ -0,0 +1,377 @@
+// Package enrollment obtains a client certificate from a TAK Server with a username and
+// password, as ATAK does when a server connection is set up with enrollment enabled.
+//
+// The flow is: read the certificate configuration from /Marti/api/tls/config, generate a
+// key pair and a certificate signing request with the configured subject, and post the
+// request to /Marti/api/tls/signClient/v2, which returns the signed certificate and the
+// CA chain to trust.
+package enrollment
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/util"
+)
+
+// Defaults for enrollment
+const (
+	DefaultPort    = 8446
+	DefaultKeyBits = 2048
+	DefaultTimeout = 30 * time.Second
+	// DefaultP12Password is the password ATAK and TAK Server use for generated keystores
+	DefaultP12Password = "atakatak"
+)
+
+// Enrollment API paths
+const (
+	ConfigPath     = "/Marti/api/tls/config"
+	SignClientPath = "/Marti/api/tls/signClient/v2"
+)
+
+// ErrUnauthorized is returned when the server rejects the username or password
+var ErrUnauthorized = errors.New("enrollment: invalid username or password")
+
+// Config holds the settings for enrolling with a TAK Server
+type Config struct {
+	// Address is the host name of the TAK Server
+	Address string
+	// Port is the enrollment port, DefaultPort when zero
+	Port int
+
+	Username string
+	Password string
+	// ClientUID identifies the device; it defaults to the username
+	ClientUID string
+	// Version is reported to the server as the client version
+	Version string
+
+	// TLSConfig secures the enrollment connection. The server certificate is usually
+	// signed by the TAK CA, which the client does not trust before enrolling, so either
+	// its RootCAs or InsecureSkipVerify must be set.
+	TLSConfig *tls.Config
+	// HTTPClient overrides the HTTP client; TLSConfig and Timeout are then ignored
+	HTTPClient *http.Client
+	Timeout    time.Duration
+
+	// KeyBits is the size of the generated RSA key, DefaultKeyBits when zero
+	KeyBits int
+}
+
+// CertificateConfig is the subject configuration returned by the server
+type CertificateConfig struct {
+	XMLName     xml.Name    `xml:"certificateConfig"`
+	NameEntries []NameEntry `xml:"nameEntries>nameEntry"`
+	// ValidityDays is the lifetime of signed certificates, when the server reports it
+	ValidityDays int `xml:"validityDays,attr,omitempty"`
+}
+
+// NameEntry is a relative distinguished name to include in the certificate subject
+type NameEntry struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// Subject returns the certificate subject for a common name with the configured entries
+func (c *CertificateConfig) Subject(commonName string) pkix.Name {
+	subject := pkix.Name{CommonName: commonName}
+	for _, entry := range c.NameEntries {
+		switch strings.ToUpper(entry.Name) {
+		case "C":
+			subject.Country = append(subject.Country, entry.Value)
+		case "ST":
+			subject.Province = append(subject.Province, entry.Value)
+		case "L":
+			subject.Locality = append(subject.Locality, entry.Value)
+		case "O":
+			subject.Organization = append(subject.Organization, entry.Value)
+		case "OU":
+			subject.OrganizationalUnit = append(subject.OrganizationalUnit, entry.Value)
+		}
+	}
+	return subject
+}
+
+// Result is the outcome of an enrollment
+type Result struct {
+	Key         *rsa.PrivateKey
+	Certificate *x509.Certificate
+	// CACertificates is the chain to trust, starting with the issuer of Certificate
+	CACertificates []*x509.Certificate
+}
+
+// Client talks to the enrollment API of a TAK Server
+type Client struct {
+	config  Config
+	baseURL string
+	http    *http.Client
+}
+
+// NewClient creates an enrollment client
+func NewClient(config Config) (*Client, error) {
+	if config.Address == "" {
+		return nil, errors.New("enrollment: server address is required")
+	}
+	if config.Username == "" || config.Password == "" {
+		return nil, errors.New("enrollment: username and password are required")
+	}
+	if config.Port == 0 {
+		config.Port = DefaultPort
+	}
+	if config.ClientUID == "" {
+		config.ClientUID = config.Username
+	}
+	if config.KeyBits == 0 {
+		config.KeyBits = DefaultKeyBits
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{
+			Timeout:   config.Timeout,
+			Transport: &http.Transport{TLSClientConfig: config.TLSConfig},
+		}
+	}
+
+	return &Client{
+		config:  config,
+		baseURL: "https://" + net.JoinHostPort(config.Address, strconv.Itoa(config.Port)),
+		http:    httpClient,
+	}, nil
+}
+
+// CertificateConfig fetches the certificate subject configuration
+func (c *Client) CertificateConfig(ctx context.Context) (*CertificateConfig, error) {
+	body, err := c.do(ctx, http.MethodGet, ConfigPath, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var config CertificateConfig
+	if err := xml.Unmarshal(body, &config); err != nil {
+		return nil, fmt.Errorf("enrollment: invalid certificate configuration: %w", err)
+	}
+	return &config, nil
+}
+
+// Enroll generates a key pair and has the server sign a certificate for it
+func (c *Client) Enroll(ctx context.Context) (*Result, error) {
+	certConfig, err := c.CertificateConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, c.config.KeyBits)
+	if err != nil {
+		return nil, fmt.Errorf("enrollment: failed to generate key: %w", err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:            certConfig.Subject(c.config.Username),
+		SignatureAlgorithm: x509.SHA256WithRSA,
+	}, key)
+	if err != nil {
+		return nil, fmt.Errorf("enrollment: failed to create certificate request: %w", err)
+	}
+
+	query := url.Values{"clientUid": {c.config.ClientUID}}
+	if c.config.Version != "" {
+		query.Set("version", c.config.Version)
+	}
+	// The server expects the base64 body of the PEM request without its header lines
+	body, err := c.do(ctx, http.MethodPost, SignClientPath+"?"+query.Encode(),
+		strings.NewReader(base64.StdEncoding.EncodeToString(csr)),
+		http.Header{"Content-Type": {"text/plain"}, "Accept": {"application/json"}})
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := parseSignResponse(body)
+	if err != nil {
+		return nil, err
+	}
+	if result.Certificate.PublicKey.(*rsa.PublicKey).N.Cmp(key.N) != 0 {
+		return nil, errors.New("enrollment: signed certificate does not match the generated key")
+	}
+	result.Key = key
+	return result, nil
+}
+
+// parseSignResponse reads the JSON response of signClient/v2, which holds the base64
+// DER certificate in signedCert and the CA chain in ca0, ca1, ...
+func parseSignResponse(body []byte) (*Result, error) {
+	var fields map[string]string
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("enrollment: invalid signing response: %w", err)
+	}
+
+	signed, ok := fields["signedCert"]
+	if !ok {
+		return nil, errors.New("enrollment: signing response has no signedCert")
+	}
+	cert, err := parseCertificate(signed)
+	if err != nil {
+		return nil, fmt.Errorf("enrollment: invalid signed certificate: %w", err)
+	}
+	if _, ok := cert.PublicKey.(*rsa.PublicKey); !ok {
+		return nil, errors.New("enrollment: signed certificate does not hold an RSA key")
+	}
+
+	var caKeys []string
+	for name := range fields {
+		if strings.HasPrefix(name, "ca") {
+			if _, err := strconv.Atoi(name[2:]); err == nil {
+				caKeys = append(caKeys, name)
+			}
+		}
+	}
+	sort.Slice(caKeys, func(i, j int) bool {
+		a, _ := strconv.Atoi(caKeys[i][2:])
+		b, _ := strconv.Atoi(caKeys[j][2:])
+		return a < b
+	})
+
+	result := &Result{Certificate: cert}
+	for _, name := range caKeys {
+		ca, err := parseCertificate(fields[name])
+		if err != nil {
+			return nil, fmt.Errorf("enrollment: invalid CA certificate %s: %w", name, err)
+		}
+		result.CACertificates = append(result.CACertificates, ca)
+	}
+	return result, nil
+}
+
+// parseCertificate parses a certificate in base64 DER or PEM
+func parseCertificate(s string) (*x509.Certificate, error) {
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		return x509.ParseCertificate(block.Bytes)
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// do sends an authenticated request and returns the response body
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, header http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.SetBasicAuth(c.config.Username, c.config.Password)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("enrollment: %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, ErrUnauthorized
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("enrollment: %s %s: %s", method, path, resp.Status)
+	}
+	return data, nil
+}
+
+// Enroll runs the enrollment flow with a new client
+func Enroll(ctx context.Context, config Config) (*Result, error) {
+	client, err := NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return client.Enroll(ctx)
+}
+
+// TLSConfig returns a TLS configuration that presents the enrolled certificate and
+// trusts the returned CA chain
+func (r *Result) TLSConfig() *tls.Config {
+	roots := x509.NewCertPool()
+	for _, ca := range r.CACertificates {
+		roots.AddCert(ca)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{r.tlsCertificate()},
+		RootCAs:      roots,
+	}
+}
+
+// tlsCertificate returns the enrolled certificate and chain with its key
+func (r *Result) tlsCertificate() tls.Certificate {
+	chain := [][]byte{r.Certificate.Raw}
+	for _, ca := range r.CACertificates {
+		chain = append(chain, ca.Raw)
+	}
+	return tls.Certificate{Certificate: chain, PrivateKey: r.Key, Leaf: r.Certificate}
+}
+
+// P12 returns the key, certificate and CA chain as a PKCS#12 file
+func (r *Result) P12(password string) ([]byte, error) {
+	return util.EncodeP12(r.Key, r.Certificate, r.CACertificates, password)
+}
+
+// WriteP12 stores the key, certificate and CA chain in a PKCS#12 file, readable by
+// LoadTLSConfigFromP12
+func (r *Result) WriteP12(path, password string) error {
+	data, err := r.P12(password)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// WritePEM stores the certificate, the key and the CA chain in PEM files, readable by
+// LoadTLSConfig. An empty caFile skips the CA chain.
+func (r *Result) WritePEM(certFile, keyFile, caFile string) error {
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: r.Certificate.Raw})
+	if err := os.WriteFile(certFile, cert, 0644); err != nil {
+		return err
+	}
+
+	key, err := x509.MarshalPKCS8PrivateKey(r.Key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600); err != nil {
+		return err
+	}
+
+	if caFile == "" {
+		return nil
+	}
+	var cas []byte
+	for _, ca := range r.CACertificates {
+		cas = append(cas, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})...)
+	}
+	return os.WriteFile(caFile, cas, 0644)
+}
@@ -0,0 +1,236 @@
+package enrollment
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const certConfigXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<ns2:certificateConfig xmlns="http://bbn.com/marti/xml/config" xmlns:ns2="com.bbn.marti.config" validityDays="30">
+  <nameEntries>
+    <nameEntry name="O" value="TAK"/>
+    <nameEntry name="OU" value="Bots"/>
+  </nameEntries>
+</ns2:certificateConfig>`
+
+// fakeServer is a stand-in for the TAK Server enrollment API backed by a test CA
+type fakeServer struct {
+	*httptest.Server
+	caKey      *ecdsa.PrivateKey
+	ca         *x509.Certificate
+	clientUIDs []string
+	subjects   []pkix.Name
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "TAK Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	s := &fakeServer{caKey: caKey, ca: ca}
+	mux := http.NewServeMux()
+	mux.HandleFunc(ConfigPath, s.authenticated(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		io.WriteString(w, certConfigXML)
+	}))
+	mux.HandleFunc(SignClientPath, s.authenticated(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.clientUIDs = append(s.clientUIDs, r.URL.Query().Get("clientUid"))
+		body, _ := io.ReadAll(r.Body)
+		der, err := base64.StdEncoding.DecodeString(string(body))
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil || csr.CheckSignature() != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		s.subjects = append(s.subjects, csr.Subject)
+
+		cert, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      csr.Subject,
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(30 * 24 * time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}, s.ca, csr.PublicKey, s.caKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"signedCert": base64.StdEncoding.EncodeToString(cert),
+			"ca0":        base64.StdEncoding.EncodeToString(s.ca.Raw),
+		})
+	}))
+	s.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeServer) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "bot-1" || password != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *fakeServer) config(t *testing.T) Config {
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err)
+	host, port, err := net.SplitHostPort(u.Host)
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(s.Certificate())
+	return Config{
+		Address:   host,
+		Port:      portNumber,
+		Username:  "bot-1",
+		Password:  "secret",
+		ClientUID: "ANDROID-bot-1",
+		TLSConfig: &tls.Config{RootCAs: roots},
+		KeyBits:   1024,
+	}
+}
+
+func TestCertificateConfig(t *testing.T) {
+	server := newFakeServer(t)
+	client, err := NewClient(server.config(t))
+	require.NoError(t, err)
+
+	config, err := client.CertificateConfig(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 30, config.ValidityDays)
+	subject := config.Subject("bot-1")
+	assert.Equal(t, "bot-1", subject.CommonName)
+	assert.Equal(t, []string{"TAK"}, subject.Organization)
+	assert.Equal(t, []string{"Bots"}, subject.OrganizationalUnit)
+}
+
+func TestEnroll(t *testing.T) {
+	server := newFakeServer(t)
+
+	result, err := Enroll(context.Background(), server.config(t))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"ANDROID-bot-1"}, server.clientUIDs)
+	require.Len(t, server.subjects, 1)
+	assert.Equal(t, []string{"Bots"}, server.subjects[0].OrganizationalUnit)
+
+	assert.Equal(t, "bot-1", result.Certificate.Subject.CommonName)
+	require.Len(t, result.CACertificates, 1)
+	assert.Equal(t, "TAK Test CA", result.CACertificates[0].Subject.CommonName)
+
+	tlsConfig := result.TLSConfig()
+	require.Len(t, tlsConfig.Certificates, 1)
+	_, err = result.Certificate.Verify(x509.VerifyOptions{
+		Roots:     tlsConfig.RootCAs,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	assert.NoError(t, err)
+}
+
+func TestEnrollStoresCertificates(t *testing.T) {
+	server := newFakeServer(t)
+	result, err := Enroll(context.Background(), server.config(t))
+	require.NoError(t, err)
+	dir := t.TempDir()
+
+	t.Run("P12", func(t *testing.T) {
+		path := filepath.Join(dir, "bot-1.p12")
+		require.NoError(t, result.WriteP12(path, DefaultP12Password))
+
+		config, err := util.LoadTLSConfigFromP12(path, DefaultP12Password, "", false)
+		require.NoError(t, err)
+		assert.Equal(t, result.Certificate.Raw, config.Certificates[0].Leaf.Raw)
+	})
+
+	t.Run("PEM", func(t *testing.T) {
+		certFile := filepath.Join(dir, "bot-1.pem")
+		keyFile := filepath.Join(dir, "bot-1.key")
+		caFile := filepath.Join(dir, "ca.pem")
+		require.NoError(t, result.WritePEM(certFile, keyFile, caFile))
+
+		config, err := util.LoadTLSConfig(certFile, keyFile, caFile, false)
+		require.NoError(t, err)
+		require.Len(t, config.Certificates, 1)
+		_, err = result.Certificate.Verify(x509.VerifyOptions{
+			Roots:     config.RootCAs,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		assert.NoError(t, err)
+	})
+}
+
+func TestEnrollErrors(t *testing.T) {
+	server := newFakeServer(t)
+
+	config := server.config(t)
+	config.Password = "wrong"
+	_, err := Enroll(context.Background(), config)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	config = server.config(t)
+	config.TLSConfig = nil
+	_, err = Enroll(context.Background(), config)
+	assert.Error(t, err, "the server certificate is not trusted")
+
+	_, err = NewClient(Config{Address: "takserver"})
+	assert.Error(t, err)
+	_, err = NewClient(Config{Username: "bot", Password: "secret"})
+	assert.Error(t, err)
+}
+
+func TestParseSignResponse(t *testing.T) {
+	_, err := parseSignResponse([]byte(`{"ca0": "AAAA"}`))
+	assert.Error(t, err)
+	_, err = parseSignResponse([]byte(`not json`))
+	assert.Error(t, err)
+	_, err = parseSignResponse([]byte(`{"signedCert": "not base64!"}`))
+	assert.Error(t, err)
+}
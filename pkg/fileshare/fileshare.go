@@ -0,0 +1,446 @@
+// Package fileshare transfers files directly between clients, as ATAK peers do on a
+// mesh network without a TAK Server.
+//
+// The sender serves the file over an embedded HTTP endpoint and announces it with a
+// b-f-t-r event holding the download URL, the size and the SHA-256 hash. A receiver that
+// accepts the request downloads and verifies the file and answers with a b-f-t-a
+// acknowledgement.
+package fileshare
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/angry-kivi/gotak/pkg/parser"
+	"github.com/angry-kivi/gotak/pkg/tak"
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults for file sharing
+const (
+	// DefaultPort is the port ATAK serves shared files on
+	DefaultPort = 8080
+	// DefaultMaxSize limits the size of received files
+	DefaultMaxSize = 256 << 20
+	DefaultTimeout = 5 * time.Minute
+)
+
+// FilePath is the path shared files are served under, with the hash in the file parameter
+const FilePath = "/getfile"
+
+var (
+	// ErrHashMismatch is returned when a downloaded file does not match its SHA-256 hash
+	ErrHashMismatch = errors.New("fileshare: file does not match its hash")
+	// ErrTooLarge is returned when a file exceeds the size limit or its announced size
+	ErrTooLarge = errors.New("fileshare: file is too large")
+)
+
+// Config holds the settings for sharing and receiving files
+type Config struct {
+	// UID and Callsign identify the local client in requests and acknowledgements
+	UID      string
+	Callsign string
+
+	// ListenAddr is the address of the HTTP server, ":8080" when empty
+	ListenAddr string
+	// URL is the base URL peers download from. By default it is built from the first
+	// non-loopback IPv4 address and the listening port.
+	URL string
+
+	// Dir is the directory received files are stored in. Existing files are never
+	// replaced; a received file whose name is taken is stored as "name (1).ext" and so on.
+	Dir string
+	// MaxSize limits the size of received files, DefaultMaxSize when zero
+	MaxSize int64
+	// HTTPClient downloads received files; a client with DefaultTimeout when nil
+	HTTPClient *http.Client
+
+	// Accept decides whether Handle downloads the file announced by a transfer request.
+	// Requests are ignored when it is nil, so receiving files is opt-in.
+	Accept func(share *cot.FileShare, event *cot.Event) bool
+	// OnAck is called with the acknowledgements of peers for shared files
+	OnAck func(ack *cot.AckResponse, event *cot.Event)
+
+	Logger logrus.FieldLogger
+}
+
+// sharedFile is a file served to peers
+type sharedFile struct {
+	path string
+	name string
+	size int64
+}
+
+// Sharer serves shared files and receives the files shared by peers
+type Sharer struct {
+	config Config
+	parser *parser.XMLParser
+
+	mu       sync.Mutex
+	files    map[string]sharedFile
+	server   *http.Server
+	listener net.Listener
+}
+
+// New creates a sharer. Call Listen to serve shared files.
+func New(config Config) (*Sharer, error) {
+	if config.UID == "" {
+		return nil, errors.New("fileshare: UID is required")
+	}
+	if config.Callsign == "" {
+		config.Callsign = config.UID
+	}
+	if config.ListenAddr == "" {
+		config.ListenAddr = ":" + strconv.Itoa(DefaultPort)
+	}
+	if config.Dir == "" {
+		config.Dir = "."
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = DefaultMaxSize
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	if config.Logger == nil {
+		config.Logger = logrus.StandardLogger()
+	}
+	return &Sharer{
+		config: config,
+		parser: parser.NewXMLParser(),
+		files:  make(map[string]sharedFile),
+	}, nil
+}
+
+// Listen starts the HTTP server on the configured address
+func (s *Sharer) Listen() error {
+	listener, err := net.Listen("tcp", s.config.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("fileshare: %w", err)
+	}
+	return s.Serve(listener)
+}
+
+// Serve serves shared files on a listener in the background
+func (s *Sharer) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.server != nil {
+		listener.Close()
+		return errors.New("fileshare: already serving")
+	}
+	s.listener = listener
+	server := &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	s.server = server
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.config.Logger.WithError(err).Error("fileshare: server failed")
+		}
+	}()
+	return nil
+}
+
+// Close stops the HTTP server
+func (s *Sharer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.server == nil {
+		return nil
+	}
+	err := s.server.Close()
+	s.server, s.listener = nil, nil
+	return err
+}
+
+// ServeHTTP serves a shared file by its hash
+func (s *Sharer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != FilePath || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Lock()
+	file, ok := s.files[r.URL.Query().Get("file")]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(file.path)
+	if err != nil {
+		s.config.Logger.WithError(err).Warnf("fileshare: failed to open %s", file.path)
+		http.Error(w, "file unavailable", http.StatusGone)
+		return
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil || stat.Size() != file.size {
+		http.Error(w, "file changed", http.StatusGone)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(file.path)))
+	http.ServeContent(w, r, filepath.Base(file.path), stat.ModTime(), f)
+	s.config.Logger.WithField("file", file.name).Debugf("fileshare: served to %s", r.RemoteAddr)
+}
+
+// Share serves a local file or data package and returns the b-f-t-r event announcing
+// it. The name is shown to the receiving user; it defaults to the file name.
+func (s *Sharer) Share(path, name string) (*cot.Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("fileshare: %w", err)
+	}
+	defer f.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return nil, fmt.Errorf("fileshare: failed to read %s: %w", path, err)
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	baseURL, err := s.baseURL()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = filepath.Base(path)
+	}
+
+	s.mu.Lock()
+	s.files[sum] = sharedFile{path: path, name: name, size: size}
+	s.mu.Unlock()
+
+	share := cot.FileShare{
+		Filename:       filepath.Base(path),
+		Name:           name,
+		SenderCallsign: s.config.Callsign,
+		SenderUID:      s.config.UID,
+		SenderURL:      baseURL + FilePath + "?" + url.Values{"file": {sum}, "sender": {s.config.Callsign}}.Encode(),
+		SHA256:         sum,
+		SizeInBytes:    size,
+	}
+	return cot.NewFileTransferRequest(newUID(), share, newUID()), nil
+}
+
+// Unshare stops serving the file with the SHA-256 hash
+func (s *Sharer) Unshare(hash string) {
+	s.mu.Lock()
+	delete(s.files, hash)
+	s.mu.Unlock()
+}
+
+// Send shares a file and sends the announcement through a client
+func (s *Sharer) Send(client tak.Client, path, name string) (*cot.Event, error) {
+	event, err := s.Share(path, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.send(client, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// Receive downloads and verifies the file announced by a b-f-t-r event and returns
+// its path and the acknowledgement for the sender. A failed download is reported in
+// the acknowledgement as well as in the error.
+func (s *Sharer) Receive(ctx context.Context, request *cot.Event) (string, *cot.Event, error) {
+	if request.Type != cot.TypeFileTransferRequest || request.Detail.FileShare == nil {
+		return "", nil, fmt.Errorf("fileshare: event of type %s is not a file transfer request", request.Type)
+	}
+	path, err := Download(ctx, s.config.HTTPClient, *request.Detail.FileShare, s.config.Dir, s.config.MaxSize)
+	reason := ""
+	if err != nil {
+		reason = err.Error()
+	}
+	ack := cot.NewFileTransferAck(request, newUID(), s.config.Callsign, err == nil, reason)
+	return path, ack, err
+}
+
+// Handle processes a received event. File transfer requests that Accept approves are
+// downloaded and acknowledged through the client, and acknowledgements of shared files
+// are passed to OnAck. It returns the path of a received file, or an empty path for
+// other events and declined requests.
+func (s *Sharer) Handle(ctx context.Context, client tak.Client, event *cot.Event) (string, error) {
+	switch event.Type {
+	case cot.TypeFileTransferRequest:
+		share := event.Detail.FileShare
+		if share == nil || share.SenderUID == s.config.UID {
+			return "", nil
+		}
+		if s.config.Accept == nil || !s.config.Accept(share, event) {
+			s.config.Logger.WithField("file", share.Filename).Debugf("fileshare: declined request from %s", share.SenderCallsign)
+			return "", nil
+		}
+		path, ack, err := s.Receive(ctx, event)
+		if ack != nil {
+			if sendErr := s.send(client, ack); sendErr != nil && err == nil {
+				err = sendErr
+			}
+		}
+		return path, err
+	case cot.TypeFileTransferAck:
+		if s.config.OnAck != nil && event.Detail.AckResponse != nil {
+			s.config.OnAck(event.Detail.AckResponse, event)
+		}
+	}
+	return "", nil
+}
+
+// send serializes and sends an event
+func (s *Sharer) send(client tak.Client, event *cot.Event) error {
+	data, err := s.parser.SerializeCoT(event)
+	if err != nil {
+		return err
+	}
+	return client.Send(data)
+}
+
+// baseURL returns the URL peers reach the HTTP server on
+func (s *Sharer) baseURL() (string, error) {
+	if s.config.URL != "" {
+		return s.config.URL, nil
+	}
+	s.mu.Lock()
+	listener := s.listener
+	s.mu.Unlock()
+	if listener == nil {
+		return "", errors.New("fileshare: not serving; call Listen or set URL")
+	}
+
+	addr := listener.Addr().(*net.TCPAddr)
+	host := addr.IP
+	if host.IsUnspecified() {
+		var err error
+		if host, err = localAddress(); err != nil {
+			return "", err
+		}
+	}
+	return "http://" + net.JoinHostPort(host.String(), strconv.Itoa(addr.Port)), nil
+}
+
+// localAddress returns the first non-loopback IPv4 address of the host
+func localAddress() (net.IP, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, fmt.Errorf("fileshare: %w", err)
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+	}
+	return nil, errors.New("fileshare: no network address to advertise; set URL")
+}
+
+// Download fetches the file announced by share into dir, verifies its size and hash,
+// and returns its path. Files exceeding maxSize and hidden file names are rejected. An
+// existing file is never replaced; the name gets a number instead.
+func Download(ctx context.Context, client *http.Client, share cot.FileShare, dir string, maxSize int64) (string, error) {
+	name := filepath.Base(share.Filename)
+	if strings.HasPrefix(name, ".") || name == string(filepath.Separator) {
+		return "", fmt.Errorf("fileshare: invalid file name %q", share.Filename)
+	}
+	if share.SHA256 == "" {
+		return "", errors.New("fileshare: request has no hash")
+	}
+	if share.SizeInBytes > maxSize {
+		return "", fmt.Errorf("%w: %d bytes", ErrTooLarge, share.SizeInBytes)
+	}
+	limit := maxSize
+	if share.SizeInBytes > 0 {
+		limit = share.SizeInBytes
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, share.SenderURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("fileshare: invalid sender URL: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fileshare: download failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fileshare: download failed: %s", resp.Status)
+	}
+
+	// Write to a temporary file that is only linked to its name once verified
+	tmp, err := os.CreateTemp(dir, "."+name+".*")
+	if err != nil {
+		return "", fmt.Errorf("fileshare: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return "", fmt.Errorf("fileshare: download failed: %w", err)
+	}
+	switch {
+	case n > limit:
+		return "", fmt.Errorf("%w: more than %d bytes", ErrTooLarge, limit)
+	case share.SizeInBytes > 0 && n != share.SizeInBytes:
+		return "", fmt.Errorf("fileshare: received %d of %d bytes", n, share.SizeInBytes)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(sum, share.SHA256) {
+		return "", fmt.Errorf("%w: expected %s, got %s", ErrHashMismatch, share.SHA256, sum)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("fileshare: %w", err)
+	}
+
+	return linkUnique(tmp.Name(), dir, name)
+}
+
+// maxNameAttempts limits the numbered names tried for a received file
+const maxNameAttempts = 1000
+
+// linkUnique links the file at src into dir under name, or under "name (n).ext" with
+// the lowest free n, and returns the new path. Linking fails rather than replace an
+// existing file, even one created concurrently.
+func linkUnique(src, dir, name string) (string, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 0; i < maxNameAttempts; i++ {
+		candidate := name
+		if i > 0 {
+			candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+		}
+		path := filepath.Join(dir, candidate)
+		err := os.Link(src, path)
+		if err == nil {
+			return path, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", fmt.Errorf("fileshare: %w", err)
+		}
+	}
+	return "", fmt.Errorf("fileshare: no free name for %s in %s", name, dir)
+}
+
+// newUID returns a random UUID for transfer events
+func newUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
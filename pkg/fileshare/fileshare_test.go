@@ -0,0 +1,262 @@
+package fileshare
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/angry-kivi/gotak/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClient records sent events
+type fakeClient struct {
+	mu   sync.Mutex
+	sent []*cot.Event
+}
+
+func (c *fakeClient) Connect(ctx context.Context) error { return nil }
+func (c *fakeClient) Disconnect() error                 { return nil }
+func (c *fakeClient) IsConnected() bool                 { return true }
+func (c *fakeClient) Receive() ([]byte, error)          { select {} }
+
+func (c *fakeClient) Send(data []byte) error {
+	event, err := parser.NewXMLParser().ParseCoT(data)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, event)
+	return nil
+}
+
+func (c *fakeClient) last() *cot.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.sent) == 0 {
+		return nil
+	}
+	return c.sent[len(c.sent)-1]
+}
+
+// newSender serves shared files on a loopback port
+func newSender(t *testing.T) *Sharer {
+	sender, err := New(Config{UID: "ANDROID-1", Callsign: "R1", Dir: t.TempDir()})
+	require.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	require.NoError(t, sender.Serve(listener))
+	t.Cleanup(func() { sender.Close() })
+	return sender
+}
+
+func acceptAll(*cot.FileShare, *cot.Event) bool { return true }
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestShareAndReceive(t *testing.T) {
+	var acks []*cot.AckResponse
+	sender := newSender(t)
+	sender.config.OnAck = func(ack *cot.AckResponse, event *cot.Event) { acks = append(acks, ack) }
+	receiverDir := t.TempDir()
+	receiver, err := New(Config{UID: "ANDROID-2", Callsign: "R2", Dir: receiverDir, Accept: acceptAll})
+	require.NoError(t, err)
+	senderClient, receiverClient := &fakeClient{}, &fakeClient{}
+
+	// The sender announces the file
+	path := writeFile(t, "route.zip", "data package contents")
+	_, err = sender.Send(senderClient, path, "Route")
+	require.NoError(t, err)
+	request := senderClient.last()
+	require.NotNil(t, request)
+	assert.Equal(t, cot.TypeFileTransferRequest, request.Type)
+	share := request.Detail.FileShare
+	require.NotNil(t, share)
+	assert.Equal(t, "route.zip", share.Filename)
+	assert.Equal(t, "Route", share.Name)
+	assert.Equal(t, "R1", share.SenderCallsign)
+	assert.Equal(t, int64(len("data package contents")), share.SizeInBytes)
+	assert.True(t, strings.HasPrefix(share.SenderURL, "http://127.0.0.1:"))
+
+	// The receiver downloads it and acknowledges
+	received, err := receiver.Handle(context.Background(), receiverClient, request)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(receiverDir, "route.zip"), received)
+	data, err := os.ReadFile(received)
+	require.NoError(t, err)
+	assert.Equal(t, "data package contents", string(data))
+
+	ack := receiverClient.last()
+	require.NotNil(t, ack)
+	assert.Equal(t, cot.TypeFileTransferAck, ack.Type)
+	require.NotNil(t, ack.Detail.AckResponse)
+	assert.True(t, ack.Detail.AckResponse.Success)
+	assert.Equal(t, request.Detail.AckRequest.UID, ack.Detail.AckResponse.UID)
+
+	// The sender sees the acknowledgement
+	_, err = sender.Handle(context.Background(), senderClient, ack)
+	require.NoError(t, err)
+	require.Len(t, acks, 1)
+	assert.True(t, acks[0].Success)
+
+	// Own requests are ignored
+	path, err = sender.Handle(context.Background(), senderClient, request)
+	assert.NoError(t, err)
+	assert.Empty(t, path)
+}
+
+func TestReceiveRejectsModifiedFile(t *testing.T) {
+	sender := newSender(t)
+	receiverDir := t.TempDir()
+	receiver, err := New(Config{UID: "ANDROID-2", Dir: receiverDir, Accept: acceptAll})
+	require.NoError(t, err)
+
+	path := writeFile(t, "route.zip", "original")
+	request, err := sender.Share(path, "")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte("modified"), 0644))
+
+	client := &fakeClient{}
+	_, err = receiver.Handle(context.Background(), client, request)
+	assert.ErrorIs(t, err, ErrHashMismatch)
+
+	ack := client.last()
+	require.NotNil(t, ack)
+	assert.False(t, ack.Detail.AckResponse.Success)
+	assert.Contains(t, ack.Detail.AckResponse.Reason, "does not match")
+	entries, err := os.ReadDir(receiverDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "a rejected download must not leave files behind")
+}
+
+func TestReceiveLimits(t *testing.T) {
+	sender := newSender(t)
+	path := writeFile(t, "large.bin", strings.Repeat("x", 1024))
+	request, err := sender.Share(path, "")
+	require.NoError(t, err)
+
+	receiver, err := New(Config{UID: "ANDROID-2", Dir: t.TempDir(), MaxSize: 512})
+	require.NoError(t, err)
+	_, _, err = receiver.Receive(context.Background(), request)
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	// A file larger than announced is cut off at the announced size
+	share := *request.Detail.FileShare
+	share.SizeInBytes = 100
+	_, err = Download(context.Background(), http.DefaultClient, share, t.TempDir(), DefaultMaxSize)
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	share = *request.Detail.FileShare
+	share.Filename = ".."
+	_, err = Download(context.Background(), http.DefaultClient, share, t.TempDir(), DefaultMaxSize)
+	assert.Error(t, err)
+}
+
+func TestHandleRequiresAccept(t *testing.T) {
+	sender := newSender(t)
+	path := writeFile(t, "route.zip", "data package contents")
+	request, err := sender.Share(path, "")
+	require.NoError(t, err)
+
+	var offered []string
+	decline := func(share *cot.FileShare, event *cot.Event) bool {
+		offered = append(offered, share.Filename)
+		return false
+	}
+	for _, accept := range []func(*cot.FileShare, *cot.Event) bool{nil, decline} {
+		dir := t.TempDir()
+		receiver, err := New(Config{UID: "ANDROID-2", Dir: dir, Accept: accept})
+		require.NoError(t, err)
+		client := &fakeClient{}
+
+		received, err := receiver.Handle(context.Background(), client, request)
+		assert.NoError(t, err)
+		assert.Empty(t, received)
+		assert.Nil(t, client.last(), "a declined request must not be acknowledged")
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "a declined request must not be downloaded")
+	}
+	assert.Equal(t, []string{"route.zip"}, offered)
+}
+
+func TestDownloadKeepsExistingFiles(t *testing.T) {
+	sender := newSender(t)
+	path := writeFile(t, "route.zip", "data package contents")
+	request, err := sender.Share(path, "")
+	require.NoError(t, err)
+	share := *request.Detail.FileShare
+
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "route.zip")
+	require.NoError(t, os.WriteFile(existing, []byte("existing"), 0644))
+
+	// Received files get numbered names instead of replacing the existing one
+	first, err := Download(context.Background(), http.DefaultClient, share, dir, DefaultMaxSize)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "route (1).zip"), first)
+	second, err := Download(context.Background(), http.DefaultClient, share, dir, DefaultMaxSize)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "route (2).zip"), second)
+
+	data, err := os.ReadFile(existing)
+	require.NoError(t, err)
+	assert.Equal(t, "existing", string(data))
+	data, err = os.ReadFile(second)
+	require.NoError(t, err)
+	assert.Equal(t, "data package contents", string(data))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3, "no temporary files may be left behind")
+
+	// Hidden files such as shell profiles are never written
+	for _, name := range []string{".bashrc", ".", "..", "../.profile"} {
+		share.Filename = name
+		_, err = Download(context.Background(), http.DefaultClient, share, dir, DefaultMaxSize)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	sender := newSender(t)
+	path := writeFile(t, "route.zip", "contents")
+	request, err := sender.Share(path, "")
+	require.NoError(t, err)
+
+	resp, err := http.Get(request.Detail.FileShare.SenderURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), "route.zip")
+
+	sender.Unshare(request.Detail.FileShare.SHA256)
+	resp, err = http.Get(request.Detail.FileShare.SenderURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestShareRequiresURL(t *testing.T) {
+	sharer, err := New(Config{UID: "ANDROID-1"})
+	require.NoError(t, err)
+	_, err = sharer.Share(writeFile(t, "a.txt", "a"), "")
+	assert.Error(t, err)
+
+	sharer, err = New(Config{UID: "ANDROID-1", URL: "http://10.0.0.5:8080"})
+	require.NoError(t, err)
+	request, err := sharer.Share(writeFile(t, "a.txt", "a"), "")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(request.Detail.FileShare.SenderURL, "http://10.0.0.5:8080/getfile?file="))
+}
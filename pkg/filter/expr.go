@@ -0,0 +1,391 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+)
+
+// Parse compiles a filter expression. Expressions combine terms with &&, || and !, and
+// group them with parentheses:
+//
+//	type ~ "a-h-*" && within(38.8, -77.1, 38.9, -77.0)
+//	(group == "Cyan" || callsign =~ "^ALPHA") && age < 30s
+//
+// String fields are type, uid, how, callsign, group and affiliation. They are compared
+// with == and !=, matched against a glob where * matches any run of characters with ~
+// and !~, or against a regular expression with =~. The age of an event is compared
+// with <, <=, > and >= against a duration such as 30s or 5m. Functions are
+// within(minLat, minLon, maxLat, maxLon), near(lat, lon, meters),
+// polygon(lat, lon, lat, lon, lat, lon, ...) and stale().
+func Parse(expr string) (Filter, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	return f, nil
+}
+
+// MustParse is like Parse but panics if the expression is invalid
+func MustParse(expr string) Filter {
+	f, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	pos   int
+	value string // unquoted string literal
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// operators in the order they are tried, longest first
+var operators = []string{"&&", "||", "==", "!=", "=~", "!~", "<=", ">=", "~", "!", "<", ">", "(", ")", ","}
+
+// lex splits an expression into tokens
+func lex(expr string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(expr) && expr[end] != '"' {
+				if expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("filter: unterminated string at position %d", i)
+			}
+			value, err := strconv.Unquote(expr[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("filter: invalid string at position %d: %w", i, err)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: expr[i : end+1], pos: i, value: value})
+			i = end + 1
+		case unicode.IsDigit(c) || c == '-' || c == '.':
+			// Numbers may carry a unit, as in the duration 1h30m
+			end := i + 1
+			for end < len(expr) && (isWordChar(rune(expr[end])) || expr[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: expr[i:end], pos: i})
+			i = end
+		case isWordChar(c):
+			end := i + 1
+			for end < len(expr) && isWordChar(rune(expr[end])) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: expr[i:end], pos: i})
+			i = end
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(expr[i:], op) {
+					tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("filter: unexpected character %q at position %d", c, i)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(expr)}), nil
+}
+
+func isWordChar(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the given operator
+func (p *exprParser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokenOperator && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return p.errorf(t, "expected %q, got %s", op, t)
+	}
+	return nil
+}
+
+func (p *exprParser) errorf(t token, format string, args ...any) error {
+	return fmt.Errorf("filter: position %d: %s", t.pos, fmt.Sprintf(format, args...))
+}
+
+// parseOr parses a sequence of terms joined by ||
+func (p *exprParser) parseOr() (Filter, error) {
+	f, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	filters := []Filter{f}
+	for p.accept("||") {
+		f, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return Or(filters...), nil
+}
+
+// parseAnd parses a sequence of terms joined by &&
+func (p *exprParser) parseAnd() (Filter, error) {
+	f, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	filters := []Filter{f}
+	for p.accept("&&") {
+		f, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return And(filters...), nil
+}
+
+// parseUnary parses a negation, a parenthesized expression or a term
+func (p *exprParser) parseUnary() (Filter, error) {
+	if p.accept("!") {
+		f, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not(f), nil
+	}
+	if p.accept("(") {
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+
+	t := p.next()
+	if t.kind != tokenIdent {
+		return nil, p.errorf(t, "expected a field or function, got %s", t)
+	}
+	switch t.text {
+	case "true":
+		return All(), nil
+	case "false":
+		return None(), nil
+	}
+	if p.accept("(") {
+		return p.parseCall(t)
+	}
+	if t.text == "age" {
+		return p.parseAge()
+	}
+	field, ok := stringFields[t.text]
+	if !ok {
+		return nil, p.errorf(t, "unknown field %s", t)
+	}
+	return p.parseComparison(field)
+}
+
+// stringFields are the string fields of an event that can be compared
+var stringFields = map[string]func(e *cot.Event) string{
+	"type": func(e *cot.Event) string { return e.Type },
+	"uid":  func(e *cot.Event) string { return e.UID },
+	"how":  func(e *cot.Event) string { return e.How },
+	"callsign": func(e *cot.Event) string {
+		if e.Detail.Contact == nil {
+			return ""
+		}
+		return e.Detail.Contact.Callsign
+	},
+	"group": func(e *cot.Event) string {
+		if e.Detail.Group == nil {
+			return ""
+		}
+		return e.Detail.Group.Name
+	},
+	"affiliation": func(e *cot.Event) string {
+		parts := strings.SplitN(e.Type, "-", 3)
+		if len(parts) < 2 || parts[0] != "a" {
+			return ""
+		}
+		return parts[1]
+	},
+}
+
+// parseComparison parses the operator and string literal that follow a string field
+func (p *exprParser) parseComparison(field func(*cot.Event) string) (Filter, error) {
+	op := p.next()
+	if op.kind != tokenOperator {
+		return nil, p.errorf(op, "expected a comparison operator, got %s", op)
+	}
+	value := p.next()
+	if value.kind != tokenString {
+		return nil, p.errorf(value, "expected a string, got %s", value)
+	}
+	v := value.value
+
+	switch op.text {
+	case "==":
+		return func(e *cot.Event) bool { return field(e) == v }, nil
+	case "!=":
+		return func(e *cot.Event) bool { return field(e) != v }, nil
+	case "~":
+		return func(e *cot.Event) bool { return Glob(v, field(e)) }, nil
+	case "!~":
+		return func(e *cot.Event) bool { return !Glob(v, field(e)) }, nil
+	case "=~":
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return nil, p.errorf(value, "invalid regular expression: %v", err)
+		}
+		return func(e *cot.Event) bool { return re.MatchString(field(e)) }, nil
+	default:
+		return nil, p.errorf(op, "operator %s does not apply to strings", op)
+	}
+}
+
+// parseAge parses the comparison of the event age with a duration
+func (p *exprParser) parseAge() (Filter, error) {
+	op := p.next()
+	value := p.next()
+	d, err := time.ParseDuration(value.text)
+	if value.kind != tokenNumber || err != nil {
+		return nil, p.errorf(value, "expected a duration, got %s", value)
+	}
+
+	age := func(e *cot.Event) time.Duration { return time.Since(e.Time.Time()) }
+	switch op.text {
+	case "<":
+		return func(e *cot.Event) bool { return age(e) < d }, nil
+	case "<=":
+		return func(e *cot.Event) bool { return age(e) <= d }, nil
+	case ">":
+		return func(e *cot.Event) bool { return age(e) > d }, nil
+	case ">=":
+		return func(e *cot.Event) bool { return age(e) >= d }, nil
+	default:
+		return nil, p.errorf(op, "expected <, <=, > or >= after age, got %s", op)
+	}
+}
+
+// parseCall parses the arguments of a function call and returns its filter
+func (p *exprParser) parseCall(name token) (Filter, error) {
+	var args []float64
+	if !p.accept(")") {
+		for {
+			t := p.next()
+			v, err := strconv.ParseFloat(t.text, 64)
+			if t.kind != tokenNumber || err != nil {
+				return nil, p.errorf(t, "expected a number, got %s", t)
+			}
+			args = append(args, v)
+			if p.accept(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	switch name.text {
+	case "within":
+		if len(args) != 4 {
+			return nil, p.errorf(name, "within takes minLat, minLon, maxLat, maxLon")
+		}
+		return Within(cot.BoundingBox{MinLat: args[0], MinLon: args[1], MaxLat: args[2], MaxLon: args[3]}), nil
+	case "near":
+		if len(args) != 3 {
+			return nil, p.errorf(name, "near takes lat, lon, meters")
+		}
+		return Near(cot.NewPoint(args[0], args[1]), args[2]), nil
+	case "polygon":
+		if len(args) < 6 || len(args)%2 != 0 {
+			return nil, p.errorf(name, "polygon takes at least three lat, lon pairs")
+		}
+		ring := make([]cot.Point, 0, len(args)/2)
+		for i := 0; i < len(args); i += 2 {
+			ring = append(ring, cot.NewPoint(args[i], args[i+1]))
+		}
+		return InPolygon(ring), nil
+	case "stale":
+		if len(args) != 0 {
+			return nil, p.errorf(name, "stale takes no arguments")
+		}
+		return Not(NotStale()), nil
+	default:
+		return nil, p.errorf(name, "unknown function %s", name)
+	}
+}
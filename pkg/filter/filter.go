@@ -0,0 +1,209 @@
+// Package filter selects CoT events with composable predicates or a small textual
+// expression language, for relays that forward only part of the traffic.
+package filter
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/angry-kivi/gotak/pkg/parser"
+	"github.com/angry-kivi/gotak/pkg/tak"
+)
+
+// Filter reports whether an event passes
+type Filter func(e *cot.Event) bool
+
+// All passes every event
+func All() Filter {
+	return func(*cot.Event) bool { return true }
+}
+
+// None passes no event
+func None() Filter {
+	return func(*cot.Event) bool { return false }
+}
+
+// And passes events that pass all filters
+func And(filters ...Filter) Filter {
+	return func(e *cot.Event) bool {
+		for _, f := range filters {
+			if !f(e) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or passes events that pass any filter
+func Or(filters ...Filter) Filter {
+	return func(e *cot.Event) bool {
+		for _, f := range filters {
+			if f(e) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not passes events that do not pass the filter
+func Not(f Filter) Filter {
+	return func(e *cot.Event) bool { return !f(e) }
+}
+
+// TypePrefix passes events whose type starts with any of the prefixes, e.g. a-h- for
+// hostile atoms
+func TypePrefix(prefixes ...string) Filter {
+	return func(e *cot.Event) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(e.Type, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// TypeMatches passes events whose type matches a glob pattern where * matches any
+// run of characters, e.g. a-*-G-*
+func TypeMatches(pattern string) Filter {
+	return func(e *cot.Event) bool { return Glob(pattern, e.Type) }
+}
+
+// Glob reports whether s matches a pattern where * matches any run of characters
+func Glob(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
+
+// Affiliation passes atoms with any of the affiliation letters, e.g. "h" for hostile
+// or "f" for friendly
+func Affiliation(letters ...string) Filter {
+	return func(e *cot.Event) bool {
+		parts := strings.SplitN(e.Type, "-", 3)
+		if len(parts) < 2 || parts[0] != "a" {
+			return false
+		}
+		for _, letter := range letters {
+			if parts[1] == letter {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Within passes events whose point lies inside the bounding box
+func Within(bb cot.BoundingBox) Filter {
+	return func(e *cot.Event) bool { return bb.Contains(e.Point) }
+}
+
+// Near passes events whose point lies within radius meters of center
+func Near(center cot.Point, radius float64) Filter {
+	bb := cot.BoundingBoxAround(center, radius)
+	return func(e *cot.Event) bool {
+		return bb.Contains(e.Point) && center.DistanceTo(e.Point) <= radius
+	}
+}
+
+// InPolygon passes events whose point lies inside the polygon
+func InPolygon(ring []cot.Point) Filter {
+	return func(e *cot.Event) bool { return cot.PolygonContains(ring, e.Point) }
+}
+
+// Group passes events of TAK users in any of the teams
+func Group(names ...string) Filter {
+	return func(e *cot.Event) bool {
+		if e.Detail.Group == nil {
+			return false
+		}
+		for _, name := range names {
+			if e.Detail.Group.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Callsign passes events whose contact callsign matches the regular expression
+func Callsign(re *regexp.Regexp) Filter {
+	return func(e *cot.Event) bool {
+		return e.Detail.Contact != nil && re.MatchString(e.Detail.Contact.Callsign)
+	}
+}
+
+// UID passes events with any of the UIDs
+func UID(uids ...string) Filter {
+	return func(e *cot.Event) bool {
+		for _, uid := range uids {
+			if e.UID == uid {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// How passes events whose how starts with any of the prefixes, e.g. m-g for GPS
+// derived positions or h- for human entered ones
+func How(prefixes ...string) Filter {
+	return func(e *cot.Event) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(e.How, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MaxAge passes events whose time is at most d before now
+func MaxAge(d time.Duration) Filter {
+	return func(e *cot.Event) bool { return time.Since(e.Time.Time()) <= d }
+}
+
+// NotStale passes events whose stale time has not passed
+func NotStale() Filter {
+	return func(e *cot.Event) bool { return e.Stale.Time().After(time.Now()) }
+}
+
+// Message adapts a filter to the messages of a tak client, for use as its receive or
+// send filter. Messages are parsed as TAK protocol messages when they start with the
+// 0xbf magic byte and as CoT XML otherwise. Messages that cannot be parsed are dropped,
+// so a filter never lets through data it could not check. tak.FilteredClient passes
+// received data to the filter one whole event at a time.
+func Message(f Filter) tak.MessageFilter {
+	xmlParser := parser.NewXMLParser()
+	protoParser := parser.NewProtoParser()
+	return func(data []byte) bool {
+		var e *cot.Event
+		var err error
+		if len(data) > 0 && data[0] == 0xbf {
+			e, err = protoParser.ParseCoT(data)
+		} else {
+			e, err = xmlParser.ParseCoT(data)
+		}
+		if err != nil {
+			return false
+		}
+		return f(e)
+	}
+}
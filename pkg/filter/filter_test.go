@@ -0,0 +1,174 @@
+package filter
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/angry-kivi/gotak/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEvent(eventType, uid, callsign, group string, p cot.Point) *cot.Event {
+	e := cot.NewEvent(eventType, uid)
+	now := time.Now()
+	e.SetTime(now).SetStart(now).SetStale(now.Add(time.Minute))
+	e.SetPoint(p)
+	e.How = "m-g"
+	if callsign != "" {
+		e.Detail.AddContact(callsign)
+	}
+	if group != "" {
+		e.Detail.AddGroup(group, "Team Member")
+	}
+	return e
+}
+
+var (
+	hostile  = testEvent("a-h-G-U-C", "hostile-1", "", "", cot.NewPoint(38.85, -77.05))
+	friendly = testEvent("a-f-G-U-C", "friendly-1", "ALPHA-1", "Cyan", cot.NewPoint(38.95, -77.05))
+	drawing  = testEvent("u-d-r", "rect-1", "Rectangle", "", cot.NewPoint(40, -70))
+)
+
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		expected   bool
+	}{
+		{"a-h-*", "a-h-G-U-C", true},
+		{"a-h-*", "a-f-G", false},
+		{"a-*-G-*", "a-f-G-U-C", true},
+		{"a-*-G-*", "a-f-A-M", false},
+		{"*-C", "a-f-G-U-C", true},
+		{"a-f-G", "a-f-G", true},
+		{"a-f-G", "a-f-G-U", false},
+		{"*", "", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, Glob(tt.pattern, tt.s), "%s / %s", tt.pattern, tt.s)
+	}
+}
+
+func TestPredicates(t *testing.T) {
+	dc := cot.BoundingBox{MinLat: 38.8, MinLon: -77.1, MaxLat: 38.9, MaxLon: -77.0}
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []*cot.Event
+	}{
+		{"type prefix", TypePrefix("a-h-", "u-d-"), []*cot.Event{hostile, drawing}},
+		{"type glob", TypeMatches("a-*-G-U-C"), []*cot.Event{hostile, friendly}},
+		{"affiliation", Affiliation("f", "a"), []*cot.Event{friendly}},
+		{"within", Within(dc), []*cot.Event{hostile}},
+		{"near", Near(cot.NewPoint(38.95, -77.05), 1000), []*cot.Event{friendly}},
+		{"group", Group("Cyan"), []*cot.Event{friendly}},
+		{"callsign", Callsign(regexp.MustCompile("^ALPHA")), []*cot.Event{friendly}},
+		{"uid", UID("rect-1"), []*cot.Event{drawing}},
+		{"how", How("m-"), []*cot.Event{hostile, friendly, drawing}},
+		{"max age", MaxAge(time.Minute), []*cot.Event{hostile, friendly, drawing}},
+		{"and", And(Affiliation("h"), Within(dc)), []*cot.Event{hostile}},
+		{"or", Or(Group("Cyan"), UID("rect-1")), []*cot.Event{friendly, drawing}},
+		{"not", Not(TypePrefix("a-")), []*cot.Event{drawing}},
+		{"none", None(), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, apply(tt.filter))
+		})
+	}
+}
+
+func apply(f Filter) []*cot.Event {
+	var result []*cot.Event
+	for _, e := range []*cot.Event{hostile, friendly, drawing} {
+		if f(e) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected []*cot.Event
+	}{
+		{`type ~ "a-h-*" && within(38.8, -77.1, 38.9, -77.0)`, []*cot.Event{hostile}},
+		{`type ~ "a-*"`, []*cot.Event{hostile, friendly}},
+		{`type !~ "a-*"`, []*cot.Event{drawing}},
+		{`type == "u-d-r" || group == "Cyan"`, []*cot.Event{friendly, drawing}},
+		{`callsign =~ "^ALPHA-[0-9]+$"`, []*cot.Event{friendly}},
+		{`affiliation != "h" && affiliation != ""`, []*cot.Event{friendly}},
+		{`!(uid == "hostile-1") && how == "m-g"`, []*cot.Event{friendly, drawing}},
+		{`near(38.95, -77.05, 500)`, []*cot.Event{friendly}},
+		{`polygon(38, -78, 39, -78, 39, -76, 38, -76)`, []*cot.Event{hostile, friendly}},
+		{`age < 30s && !stale()`, []*cot.Event{hostile, friendly, drawing}},
+		{`age >= 1h30m`, nil},
+		{`true && (false || type ~ "u-*")`, []*cot.Event{drawing}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := Parse(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, apply(f))
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		``,
+		`type`,
+		`type ~`,
+		`type < "a"`,
+		`colour == "red"`,
+		`type == "a" &&`,
+		`(type == "a"`,
+		`type == "a")`,
+		`type == "unterminated`,
+		`callsign =~ "["`,
+		`age < soon`,
+		`age == 5s`,
+		`within(1, 2, 3)`,
+		`near(1, 2)`,
+		`polygon(1, 2, 3, 4)`,
+		`stale(1)`,
+		`unknown()`,
+		`type == "a" # comment`,
+	}
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			_, err := Parse(expr)
+			assert.Error(t, err)
+		})
+	}
+	assert.Panics(t, func() { MustParse(`type ==`) })
+}
+
+func TestMessage(t *testing.T) {
+	data, err := parser.NewXMLParser().SerializeCoT(hostile)
+	require.NoError(t, err)
+
+	keepHostile := Message(Affiliation("h"))
+	assert.True(t, keepHostile(data))
+	assert.False(t, Message(Affiliation("f"))(data))
+	assert.False(t, keepHostile([]byte("not cot")), "unparseable messages are dropped")
+	assert.False(t, keepHostile(data[:len(data)/2]), "partial events are dropped")
+}
+
+func TestMessageProto(t *testing.T) {
+	protoParser := parser.NewProtoParser()
+	mesh, err := protoParser.SerializeCoT(hostile)
+	require.NoError(t, err)
+	mesh = append([]byte{0xbf, 0x01, 0xbf}, mesh...)
+	stream, err := protoParser.SerializeStreamCoT(hostile)
+	require.NoError(t, err)
+
+	for _, data := range [][]byte{mesh, stream} {
+		assert.True(t, Message(Affiliation("h"))(data))
+		assert.False(t, Message(Affiliation("f"))(data))
+	}
+	assert.False(t, Message(All())([]byte{0xbf, 0x01, 0xbf, 0xff}), "invalid protobuf is dropped")
+}
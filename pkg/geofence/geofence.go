@@ -0,0 +1,272 @@
+// Package geofence evaluates situational awareness events against geofences drawn in
+// ATAK and raises breach alerts when monitored items enter or leave them.
+package geofence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+)
+
+// ellipseSegments is the number of segments used to approximate elliptical fences
+const ellipseSegments = 72
+
+// Fence is a drawing shape carrying a __geofence detail
+type Fence struct {
+	UID    string
+	Name   string
+	Config cot.GeoFence
+	Event  *cot.Event
+
+	center cot.Point
+	radius float64     // Set for circular fences
+	ring   []cot.Point // Set for polygonal fences
+}
+
+// NewFence creates a fence from a shape event with a __geofence detail. Circles, ellipses,
+// rectangles and free-form polygons are supported.
+func NewFence(e *cot.Event) (*Fence, error) {
+	if e.Detail.GeoFence == nil {
+		return nil, fmt.Errorf("event %s has no geofence", e.UID)
+	}
+
+	f := &Fence{
+		UID:    e.UID,
+		Config: *e.Detail.GeoFence,
+		Event:  e,
+		center: e.Point,
+	}
+	if e.Detail.Contact != nil {
+		f.Name = e.Detail.Contact.Callsign
+	}
+
+	if shape := e.Detail.Shape; shape != nil && shape.Ellipse != nil && shape.Ellipse.Major == shape.Ellipse.Minor {
+		f.radius = shape.Ellipse.Major
+		if f.radius <= 0 {
+			return nil, fmt.Errorf("fence %s has no radius", e.UID)
+		}
+		return f, nil
+	}
+
+	ring, err := e.Vertices()
+	if err != nil {
+		return nil, fmt.Errorf("fence %s: %w", e.UID, err)
+	}
+	if len(ring) < 3 {
+		return nil, fmt.Errorf("fence %s has %d vertices, at least 3 are required", e.UID, len(ring))
+	}
+	f.ring = ring
+	return f, nil
+}
+
+// Contains reports whether p lies inside the fence, including its elevation bounds when
+// they are monitored. Points without a known height pass the elevation check.
+func (f *Fence) Contains(p cot.Point) bool {
+	if f.Config.ElevationMonitored && p.Hae != nil && *p.Hae < cot.DefaultValue {
+		if *p.Hae < f.Config.MinElevation || *p.Hae > f.Config.MaxElevation {
+			return false
+		}
+	}
+	if f.ring != nil {
+		return cot.PolygonContains(f.ring, p)
+	}
+	return f.center.DistanceTo(p) <= f.radius
+}
+
+// Monitors reports whether the fence watches the item described by e at its position.
+// Items outside the bounding sphere are not watched for entering the fence; an
+// Evaluator still reports items it knows to be inside leaving from beyond it.
+func (f *Fence) Monitors(e *cot.Event) bool {
+	return f.watches(e) && f.inBoundingSphere(e.Point)
+}
+
+// inBoundingSphere reports whether p is within the radius around the fence in which
+// items are monitored
+func (f *Fence) inBoundingSphere(p cot.Point) bool {
+	return f.Config.BoundingSphere <= 0 || f.center.DistanceTo(p) <= f.Config.BoundingSphere
+}
+
+// watches reports whether the fence monitors items of the kind described by e,
+// wherever they are
+func (f *Fence) watches(e *cot.Event) bool {
+	if !f.Config.Tracking || e.UID == f.UID || !strings.HasPrefix(e.Type, "a-") {
+		return false
+	}
+
+	switch f.Config.Monitor {
+	case cot.GeoFenceMonitorAll, "":
+		return true
+	case cot.GeoFenceMonitorFriendly:
+		return strings.HasPrefix(e.Type, "a-f-")
+	case cot.GeoFenceMonitorHostile:
+		return strings.HasPrefix(e.Type, "a-h-")
+	case cot.GeoFenceMonitorTAKUsers:
+		return e.Detail.Takv != nil
+	default:
+		return false
+	}
+}
+
+// reports returns whether a crossing in the given direction raises an alert
+func (f *Fence) reports(crossing cot.GeoFenceTrigger) bool {
+	return f.Config.Trigger == cot.GeoFenceTriggerBoth || f.Config.Trigger == crossing
+}
+
+// Breach is a monitored item crossing a fence boundary
+type Breach struct {
+	Fence *Fence
+	Item  *cot.Event
+	// Crossing is GeoFenceTriggerEntry or GeoFenceTriggerExit
+	Crossing cot.GeoFenceTrigger
+}
+
+// AlertEvent returns the b-a-g alert ATAK raises for the breach. The alert is placed at
+// the item's position and links to both the fence and the item.
+func (b Breach) AlertEvent() *cot.Event {
+	callsign := b.Item.UID
+	if b.Item.Detail.Contact != nil && b.Item.Detail.Contact.Callsign != "" {
+		callsign = b.Item.Detail.Contact.Callsign
+	}
+	fenceName := b.Fence.Name
+	if fenceName == "" {
+		fenceName = b.Fence.UID
+	}
+	verb := "entered"
+	if b.Crossing == cot.GeoFenceTriggerExit {
+		verb = "exited"
+	}
+
+	alert := cot.NewEvent(cot.TypeGeoFenceBreach, b.Fence.UID+"."+b.Item.UID)
+	alert.SetPoint(b.Item.Point)
+	alert.Detail.AddLink(&cot.Link{UID: b.Fence.UID, Type: b.Fence.Event.Type, Relation: "p-p"})
+	alert.Detail.AddLink(&cot.Link{UID: b.Item.UID, Type: b.Item.Type, Relation: "p-p"})
+	alert.Detail.AddContact(callsign)
+	alert.Detail.Emergency = &cot.Emergency{Type: cot.EmergencyTypeGeoFenceBreach, Text: callsign}
+	alert.Detail.AddRemarks(fmt.Sprintf("%s %s %s", callsign, verb, fenceName))
+	return alert
+}
+
+// Evaluator tracks which monitored items are inside which fences. It is safe for
+// concurrent use.
+type Evaluator struct {
+	mu     sync.Mutex
+	fences map[string]*Fence
+	inside map[string]map[string]bool // Fence UID to item UID to inside
+}
+
+// NewEvaluator creates an evaluator without fences
+func NewEvaluator() *Evaluator {
+	return &Evaluator{
+		fences: make(map[string]*Fence),
+		inside: make(map[string]map[string]bool),
+	}
+}
+
+// AddFence adds or replaces a fence. Replacing a fence forgets which items were inside it.
+func (ev *Evaluator) AddFence(e *cot.Event) error {
+	f, err := NewFence(e)
+	if err != nil {
+		return err
+	}
+
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	ev.fences[f.UID] = f
+	ev.inside[f.UID] = make(map[string]bool)
+	return nil
+}
+
+// RemoveFence removes a fence
+func (ev *Evaluator) RemoveFence(uid string) {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	delete(ev.fences, uid)
+	delete(ev.inside, uid)
+}
+
+// RemoveItem forgets the state of an item, e.g. when it goes stale
+func (ev *Evaluator) RemoveItem(uid string) {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	for _, items := range ev.inside {
+		delete(items, uid)
+	}
+}
+
+// Fences returns the fences ordered by UID
+func (ev *Evaluator) Fences() []*Fence {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	fences := make([]*Fence, 0, len(ev.fences))
+	for _, f := range ev.fences {
+		fences = append(fences, f)
+	}
+	sort.Slice(fences, func(i, j int) bool { return fences[i].UID < fences[j].UID })
+	return fences
+}
+
+// Evaluate updates the position of an item and returns the breaches it caused, ordered by
+// fence UID. The first position seen for an item only establishes whether it is inside
+// each fence and never raises a breach. Items inside a fence are followed beyond its
+// bounding sphere, so an item jumping far away still raises the exit.
+func (ev *Evaluator) Evaluate(e *cot.Event) []Breach {
+	var breaches []Breach
+	for _, f := range ev.Fences() {
+		if !f.watches(e) {
+			continue
+		}
+		inside := f.Contains(e.Point)
+
+		ev.mu.Lock()
+		items, ok := ev.inside[f.UID]
+		if !ok {
+			// Fence removed concurrently
+			ev.mu.Unlock()
+			continue
+		}
+		was, seen := items[e.UID]
+		if !was && !f.inBoundingSphere(e.Point) {
+			ev.mu.Unlock()
+			continue
+		}
+		items[e.UID] = inside
+		ev.mu.Unlock()
+
+		if !seen || was == inside {
+			continue
+		}
+		crossing := cot.GeoFenceTriggerEntry
+		if !inside {
+			crossing = cot.GeoFenceTriggerExit
+		}
+		if f.reports(crossing) {
+			breaches = append(breaches, Breach{Fence: f, Item: e, Crossing: crossing})
+		}
+	}
+	return breaches
+}
+
+// ErrNotEvaluated is returned by Process for events that are neither fences nor items
+var ErrNotEvaluated = errors.New("event is neither a geofence nor a monitored item")
+
+// Process handles an incoming event: events with a __geofence detail add or replace a
+// fence, and other events are evaluated against the fences. It returns the alert events
+// for any breaches.
+func (ev *Evaluator) Process(e *cot.Event) ([]*cot.Event, error) {
+	if e.Detail.GeoFence != nil {
+		return nil, ev.AddFence(e)
+	}
+	if !strings.HasPrefix(e.Type, "a-") {
+		return nil, ErrNotEvaluated
+	}
+
+	var alerts []*cot.Event
+	for _, b := range ev.Evaluate(e) {
+		alerts = append(alerts, b.AlertEvent())
+	}
+	return alerts, nil
+}
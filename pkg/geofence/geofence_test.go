@@ -0,0 +1,227 @@
+package geofence
+
+import (
+	"testing"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fenceCenter = cot.NewPoint(38.830898851915514, -77.06586686880725)
+
+func circleFence(trigger cot.GeoFenceTrigger, monitor cot.GeoFenceMonitor) *cot.Event {
+	e := cot.NewCircle("fence-circle", "Geo Fence Circle", fenceCenter, 300, cot.DefaultDrawingStyle())
+	e.Detail.AddGeoFence(trigger, monitor)
+	return e
+}
+
+func rectangleFence(trigger cot.GeoFenceTrigger) *cot.Event {
+	bb := cot.BoundingBoxAround(fenceCenter, 300)
+	e := cot.NewRectangleFromBoundingBox("fence-rect", "Geo Fence Rectangle", bb, cot.DefaultDrawingStyle())
+	e.Detail.AddGeoFence(trigger, cot.GeoFenceMonitorAll)
+	return e
+}
+
+func polygonFence(trigger cot.GeoFenceTrigger) *cot.Event {
+	e, _ := cot.NewPolygon("fence-poly", "Geo Fence Polygon", []cot.Point{
+		fenceCenter.Destination(300, 0),
+		fenceCenter.Destination(300, 120),
+		fenceCenter.Destination(300, 240),
+	}, cot.DefaultDrawingStyle())
+	e.Detail.AddGeoFence(trigger, cot.GeoFenceMonitorAll)
+	return e
+}
+
+func item(uid, eventType string, distance float64) *cot.Event {
+	e := cot.NewEvent(eventType, uid)
+	e.SetPoint(fenceCenter.Destination(distance, 45))
+	e.Detail.AddContact("Callsign " + uid)
+	return e
+}
+
+func TestNewFence(t *testing.T) {
+	tests := []struct {
+		name  string
+		event *cot.Event
+	}{
+		{"circle", circleFence(cot.GeoFenceTriggerEntry, cot.GeoFenceMonitorAll)},
+		{"rectangle", rectangleFence(cot.GeoFenceTriggerEntry)},
+		{"polygon", polygonFence(cot.GeoFenceTriggerEntry)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFence(tt.event)
+			require.NoError(t, err)
+			assert.True(t, f.Contains(fenceCenter))
+			assert.False(t, f.Contains(fenceCenter.Destination(1000, 45)))
+		})
+	}
+}
+
+func TestNewFenceWithoutGeoFence(t *testing.T) {
+	e := cot.NewCircle("circle", "Circle", fenceCenter, 300, cot.DefaultDrawingStyle())
+
+	_, err := NewFence(e)
+
+	assert.Error(t, err)
+}
+
+func TestFenceElevation(t *testing.T) {
+	e := circleFence(cot.GeoFenceTriggerEntry, cot.GeoFenceMonitorAll)
+	e.Detail.GeoFence.SetElevation(0, 100)
+	f, err := NewFence(e)
+	require.NoError(t, err)
+
+	low := fenceCenter
+	low.SetHae(50)
+	high := fenceCenter
+	high.SetHae(150)
+
+	assert.True(t, f.Contains(low))
+	assert.False(t, f.Contains(high))
+	assert.True(t, f.Contains(fenceCenter), "unknown heights pass the elevation check")
+}
+
+func TestFenceMonitors(t *testing.T) {
+	tests := []struct {
+		name     string
+		monitor  cot.GeoFenceMonitor
+		event    *cot.Event
+		expected bool
+	}{
+		{"all friendly", cot.GeoFenceMonitorAll, item("f1", "a-f-G-U-C", 0), true},
+		{"all hostile", cot.GeoFenceMonitorAll, item("h1", "a-h-G", 0), true},
+		{"friendly only", cot.GeoFenceMonitorFriendly, item("h1", "a-h-G", 0), false},
+		{"hostile only", cot.GeoFenceMonitorHostile, item("h1", "a-h-G", 0), true},
+		{"TAK users without takv", cot.GeoFenceMonitorTAKUsers, item("f1", "a-f-G-U-C", 0), false},
+		{"drawing", cot.GeoFenceMonitorAll, item("d1", "u-d-f", 0), false},
+		{"outside bounding sphere", cot.GeoFenceMonitorAll, item("f1", "a-f-G-U-C", 80000), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFence(circleFence(cot.GeoFenceTriggerBoth, tt.monitor))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, f.Monitors(tt.event))
+		})
+	}
+
+	t.Run("TAK users with takv", func(t *testing.T) {
+		f, err := NewFence(circleFence(cot.GeoFenceTriggerBoth, cot.GeoFenceMonitorTAKUsers))
+		require.NoError(t, err)
+		e := item("f1", "a-f-G-U-C", 0)
+		e.Detail.AddTakv("ATAK-CIV", "4.10")
+		assert.True(t, f.Monitors(e))
+	})
+
+	t.Run("not tracking", func(t *testing.T) {
+		e := circleFence(cot.GeoFenceTriggerBoth, cot.GeoFenceMonitorAll)
+		e.Detail.GeoFence.SetTracking(false)
+		f, err := NewFence(e)
+		require.NoError(t, err)
+		assert.False(t, f.Monitors(item("f1", "a-f-G-U-C", 0)))
+	})
+}
+
+func TestEvaluatorEntryAndExit(t *testing.T) {
+	for _, fence := range []*cot.Event{
+		circleFence(cot.GeoFenceTriggerBoth, cot.GeoFenceMonitorAll),
+		rectangleFence(cot.GeoFenceTriggerBoth),
+		polygonFence(cot.GeoFenceTriggerBoth),
+	} {
+		t.Run(fence.UID, func(t *testing.T) {
+			ev := NewEvaluator()
+			require.NoError(t, ev.AddFence(fence))
+
+			// The first position only establishes state
+			assert.Empty(t, ev.Evaluate(item("u1", "a-f-G-U-C", 1000)))
+
+			breaches := ev.Evaluate(item("u1", "a-f-G-U-C", 50))
+			require.Len(t, breaches, 1)
+			assert.Equal(t, cot.GeoFenceTriggerEntry, breaches[0].Crossing)
+			assert.Equal(t, fence.UID, breaches[0].Fence.UID)
+
+			assert.Empty(t, ev.Evaluate(item("u1", "a-f-G-U-C", 60)), "no breach while staying inside")
+
+			breaches = ev.Evaluate(item("u1", "a-f-G-U-C", 1000))
+			require.Len(t, breaches, 1)
+			assert.Equal(t, cot.GeoFenceTriggerExit, breaches[0].Crossing)
+		})
+	}
+}
+
+func TestEvaluatorTrigger(t *testing.T) {
+	ev := NewEvaluator()
+	require.NoError(t, ev.AddFence(circleFence(cot.GeoFenceTriggerExit, cot.GeoFenceMonitorAll)))
+
+	ev.Evaluate(item("u1", "a-f-G-U-C", 1000))
+	assert.Empty(t, ev.Evaluate(item("u1", "a-f-G-U-C", 50)), "entry is not reported by exit fences")
+	assert.Len(t, ev.Evaluate(item("u1", "a-f-G-U-C", 1000)), 1)
+}
+
+func TestEvaluatorBoundingSphere(t *testing.T) {
+	fence := circleFence(cot.GeoFenceTriggerBoth, cot.GeoFenceMonitorAll)
+	fence.Detail.GeoFence.SetBoundingSphere(5000)
+	ev := NewEvaluator()
+	require.NoError(t, ev.AddFence(fence))
+
+	// Items beyond the bounding sphere are not monitored
+	assert.Empty(t, ev.Evaluate(item("u1", "a-f-G-U-C", 10000)))
+	assert.Empty(t, ev.Evaluate(item("u1", "a-f-G-U-C", 50)), "the first position within the sphere only establishes state")
+
+	// An item inside jumping beyond the sphere still exits
+	ev.Evaluate(item("u2", "a-f-G-U-C", 50))
+	breaches := ev.Evaluate(item("u2", "a-f-G-U-C", 10000))
+	require.Len(t, breaches, 1)
+	assert.Equal(t, cot.GeoFenceTriggerExit, breaches[0].Crossing)
+	assert.Empty(t, ev.Evaluate(item("u2", "a-f-G-U-C", 20000)), "no further breaches once outside")
+	breaches = ev.Evaluate(item("u2", "a-f-G-U-C", 50))
+	require.Len(t, breaches, 1)
+	assert.Equal(t, cot.GeoFenceTriggerEntry, breaches[0].Crossing)
+}
+
+func TestEvaluatorRemove(t *testing.T) {
+	ev := NewEvaluator()
+	require.NoError(t, ev.AddFence(circleFence(cot.GeoFenceTriggerBoth, cot.GeoFenceMonitorAll)))
+	ev.Evaluate(item("u1", "a-f-G-U-C", 1000))
+
+	ev.RemoveItem("u1")
+	assert.Empty(t, ev.Evaluate(item("u1", "a-f-G-U-C", 50)), "removed items start over")
+
+	ev.RemoveFence("fence-circle")
+	assert.Empty(t, ev.Fences())
+	assert.Empty(t, ev.Evaluate(item("u1", "a-f-G-U-C", 1000)))
+}
+
+func TestEvaluatorProcess(t *testing.T) {
+	ev := NewEvaluator()
+
+	alerts, err := ev.Process(circleFence(cot.GeoFenceTriggerEntry, cot.GeoFenceMonitorAll))
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+	require.Len(t, ev.Fences(), 1)
+
+	_, err = ev.Process(cot.NewEvent("u-d-f", "drawing"))
+	assert.ErrorIs(t, err, ErrNotEvaluated)
+
+	_, err = ev.Process(item("u1", "a-f-G-U-C", 1000))
+	require.NoError(t, err)
+	entering := item("u1", "a-f-G-U-C", 50)
+	alerts, err = ev.Process(entering)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+
+	alert := alerts[0]
+	assert.Equal(t, cot.TypeGeoFenceBreach, alert.Type)
+	assert.Equal(t, "fence-circle.u1", alert.UID)
+	assert.Equal(t, entering.Point.Lat, alert.Point.Lat)
+	require.NotNil(t, alert.Detail.Emergency)
+	assert.Equal(t, cot.EmergencyTypeGeoFenceBreach, alert.Detail.Emergency.Type)
+	assert.Equal(t, "Callsign u1", alert.Detail.Emergency.Text)
+	assert.Equal(t, "Callsign u1 entered Geo Fence Circle", alert.Detail.Remarks.Text)
+	require.Len(t, alert.Detail.Links, 2)
+	assert.Equal(t, "fence-circle", alert.Detail.Links[0].UID)
+	assert.Equal(t, "u1", alert.Detail.Links[1].UID)
+}
@@ -0,0 +1,309 @@
+// Package marti is a client for the HTTPS API of TAK Server, served under /Marti next
+// to the streaming port. It covers the server version, connected clients, groups, file
+// storage (Enterprise Sync), data sync missions and the CoT history of a UID.
+//
+// The API authenticates clients by their certificate, usually on port 8443. Servers
+// that also accept passwords on that port can be reached with Username and Password.
+//
+// Data sync missions are managed with the Mission methods; mission subscribers receive
+// the changes as t-x-m-c events on the streaming connection, which
+// ParseMissionNotification reads.
+package marti
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/util"
+)
+
+// Defaults for the API client
+const (
+	DefaultPort    = 8443
+	DefaultTimeout = 30 * time.Second
+)
+
+// API paths
+const (
+	VersionPath         = "/Marti/api/version"
+	VersionConfigPath   = "/Marti/api/version/config"
+	ClientEndpointsPath = "/Marti/api/clientEndPoints"
+	GroupsPath          = "/Marti/api/groups/all"
+	UploadPath          = "/Marti/sync/upload"
+	ContentPath         = "/Marti/sync/content"
+	CoTPath             = "/Marti/api/cot/xml/"
+)
+
+var (
+	// ErrUnauthorized is returned when the server rejects the client certificate or password
+	ErrUnauthorized = errors.New("marti: unauthorized")
+	// ErrNotFound is returned when the requested resource does not exist
+	ErrNotFound = errors.New("marti: not found")
+)
+
+// Config holds the settings for the API client
+type Config struct {
+	// Address is the host name of the TAK Server
+	Address string
+	// Port is the API port, DefaultPort when zero
+	Port int
+
+	// TLS describes the client certificate and the trusted CAs, as for the streaming
+	// connection
+	TLS util.TLSOptions
+	// TLSConfig replaces TLS, e.g. with the configuration of a certmanager.Manager
+	TLSConfig *tls.Config
+
+	// Username and Password are sent as basic authentication when set
+	Username string
+	Password string
+
+	// HTTPClient overrides the HTTP client; TLS, TLSConfig and Timeout are then ignored
+	HTTPClient *http.Client
+	Timeout    time.Duration
+}
+
+// Client talks to the HTTPS API of a TAK Server
+type Client struct {
+	config  Config
+	baseURL string
+	http    *http.Client
+
+	// tokens holds the subscription token per mission name
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+// NewClient creates an API client
+func NewClient(config Config) (*Client, error) {
+	if config.Address == "" {
+		return nil, errors.New("marti: server address is required")
+	}
+	if config.Port == 0 {
+		config.Port = DefaultPort
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		tlsConfig := config.TLSConfig
+		if tlsConfig == nil {
+			var err error
+			if tlsConfig, err = util.NewTLSConfig(config.TLS); err != nil {
+				return nil, fmt.Errorf("marti: %w", err)
+			}
+		}
+		httpClient = &http.Client{
+			Timeout:   config.Timeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		}
+	}
+
+	return &Client{
+		config:  config,
+		baseURL: "https://" + net.JoinHostPort(config.Address, strconv.Itoa(config.Port)),
+		http:    httpClient,
+		tokens:  make(map[string]string),
+	}, nil
+}
+
+// URL returns the absolute URL of an API path
+func (c *Client) URL(path string, query url.Values) string {
+	if len(query) == 0 {
+		return c.baseURL + path
+	}
+	return c.baseURL + path + "?" + query.Encode()
+}
+
+// response is the envelope of the JSON API responses
+type response[T any] struct {
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Data    T      `json:"data"`
+	NodeID  string `json:"nodeId"`
+}
+
+// ServerConfig describes the server as reported by /Marti/api/version/config
+type ServerConfig struct {
+	// Version is the server release, e.g. "4.10-RELEASE-12-HEAD"
+	Version string `json:"version"`
+	// API is the API version
+	API      string `json:"api"`
+	Hostname string `json:"hostname"`
+	// NodeID identifies the server instance
+	NodeID string `json:"-"`
+}
+
+// Version returns the server version string, e.g. "TAK Server 4.10-RELEASE-12-HEAD"
+func (c *Client) Version(ctx context.Context) (string, error) {
+	resp, err := c.do(ctx, http.MethodGet, VersionPath, nil, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// ServerConfig returns the server version and API details
+func (c *Client) ServerConfig(ctx context.Context) (*ServerConfig, error) {
+	var result response[ServerConfig]
+	if err := c.getJSON(ctx, VersionConfigPath, nil, &result); err != nil {
+		return nil, err
+	}
+	result.Data.NodeID = result.NodeID
+	return &result.Data, nil
+}
+
+// ClientEndpoint is a client known to the server
+type ClientEndpoint struct {
+	UID      string `json:"uid"`
+	Callsign string `json:"callsign"`
+	// Username is the certificate or login name of the client
+	Username      string    `json:"username"`
+	LastEventTime time.Time `json:"lastEventTime"`
+	// LastStatus is "Connected" or "Disconnected"
+	LastStatus string `json:"lastStatus"`
+}
+
+// Connected reports whether the client is connected
+func (e ClientEndpoint) Connected() bool {
+	return strings.EqualFold(e.LastStatus, "Connected")
+}
+
+// ClientEndpointQuery selects the clients returned by ClientEndpoints
+type ClientEndpointQuery struct {
+	// Since limits the result to clients seen within the duration; zero returns all
+	Since time.Duration
+	// ConnectedOnly drops disconnected clients
+	ConnectedOnly bool
+	// Group limits the result to members of a group
+	Group string
+}
+
+// ClientEndpoints returns the clients known to the server
+func (c *Client) ClientEndpoints(ctx context.Context, q ClientEndpointQuery) ([]ClientEndpoint, error) {
+	query := url.Values{
+		"secAgo":                        {strconv.Itoa(int(q.Since / time.Second))},
+		"showCurrentlyConnectedClients": {strconv.FormatBool(q.ConnectedOnly)},
+	}
+	if q.Group != "" {
+		query.Set("group", q.Group)
+	}
+	var result response[[]ClientEndpoint]
+	if err := c.getJSON(ctx, ClientEndpointsPath, query, &result); err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+// Group is a TAK Server group. A group appears once per direction the client is a
+// member in.
+type Group struct {
+	Name string `json:"name"`
+	// Direction is "IN" for groups the client sends to and "OUT" for groups it receives from
+	Direction   string `json:"direction"`
+	Created     string `json:"created"`
+	Type        string `json:"type"`
+	BitPosition int    `json:"bitpos"`
+	Active      bool   `json:"active"`
+	Description string `json:"description,omitempty"`
+}
+
+// Groups returns the groups of the authenticated client
+func (c *Client) Groups(ctx context.Context) ([]Group, error) {
+	var result response[[]Group]
+	if err := c.getJSON(ctx, GroupsPath, url.Values{"useCache": {"true"}}, &result); err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+// getJSON sends a GET request and decodes the JSON response into v
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, v any) error {
+	return c.doJSON(ctx, http.MethodGet, path, query, nil, nil, v)
+}
+
+// doJSON sends a request with in as JSON body, when not nil, and decodes the JSON
+// response into out, when not nil
+func (c *Client) doJSON(ctx context.Context, method, path string, query url.Values, header http.Header, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+		header = header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.do(ctx, method, path, query, body, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("marti: invalid response from %s: %w", path, err)
+	}
+	return nil
+}
+
+// do sends a request and returns the response when it succeeded. The caller closes
+// the response body.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.URL(path, query), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if c.config.Username != "" && req.Header.Get("Authorization") == "" {
+		req.SetBasicAuth(c.config.Username, c.config.Password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		serverName := c.config.Address
+		if c.config.TLS.ServerName != "" {
+			serverName = c.config.TLS.ServerName
+		}
+		return nil, fmt.Errorf("marti: %s %s: %w", method, path, util.DiagnoseTLSError(err, serverName))
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, ErrUnauthorized
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	return nil, fmt.Errorf("marti: %s %s: %s", method, path, resp.Status)
+}
@@ -0,0 +1,279 @@
+package marti
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const eventXML = `<event version="2.0" uid="ANDROID-1" type="a-f-G-U-C" how="m-g" time="%s" start="%s" stale="2024-05-01T12:10:00Z">
+<point lat="38.85" lon="-77.05" hae="10" ce="5" le="9999999"/>
+<detail><contact callsign="R1"/></detail>
+</event>`
+
+// fakeServer is a stand-in for the TAK Server API with a file store
+type fakeServer struct {
+	*httptest.Server
+	files    map[string][]byte
+	requests []*http.Request
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	s := &fakeServer{files: map[string][]byte{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc(VersionPath, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "TAK Server 4.10-RELEASE-12-HEAD\n")
+	})
+	mux.HandleFunc(VersionConfigPath, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"version":"3","type":"ServerConfig","data":{"version":"4.10-RELEASE-12-HEAD","api":"3","hostname":"takserver"},"nodeId":"node-1"}`)
+	})
+	mux.HandleFunc(ClientEndpointsPath, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"version":"3","type":"com.bbn.marti.remote.ClientEndpoint","data":[
+			{"callsign":"R1","uid":"ANDROID-1","username":"bot-1","lastEventTime":"2024-05-01T12:00:00.123Z","lastStatus":"Connected"},
+			{"callsign":"R2","uid":"ANDROID-2","username":"bot-2","lastEventTime":"2024-04-30T08:00:00.000Z","lastStatus":"Disconnected"}
+		],"nodeId":"node-1"}`)
+	})
+	mux.HandleFunc(GroupsPath, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"version":"3","type":"com.bbn.marti.remote.groups.Group","data":[
+			{"name":"__ANON__","direction":"IN","created":"2024-05-01","type":"SYSTEM","bitpos":2,"active":true},
+			{"name":"__ANON__","direction":"OUT","created":"2024-05-01","type":"SYSTEM","bitpos":2,"active":true}
+		],"nodeId":"node-1"}`)
+	})
+	mux.HandleFunc(UploadPath, func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+		s.files[hash] = data
+		fmt.Fprintf(w, `{"UID":["uid-1"],"Name":[%q],"MIMEType":[%q],"Keywords":["missionpackage"],"Size":%d,`+
+			`"SubmissionDateTime":"2024-05-01T12:00:00Z","SubmissionUser":["bot-1"],"CreatorUid":[%q],"Hash":%q}`,
+			r.URL.Query().Get("name"), r.Header.Get("Content-Type"), len(data), r.URL.Query().Get("creatorUid"), hash)
+	})
+	mux.HandleFunc(ContentPath, func(w http.ResponseWriter, r *http.Request) {
+		data, ok := s.files[r.URL.Query().Get("hash")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	})
+	mux.HandleFunc(CoTPath+"ANDROID-1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, eventXML, "2024-05-01T12:05:00Z", "2024-05-01T12:05:00Z")
+	})
+	mux.HandleFunc(CoTPath+"ANDROID-1/all", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "<events>")
+		fmt.Fprintf(w, eventXML, "2024-05-01T12:00:00Z", "2024-05-01T12:00:00Z")
+		fmt.Fprintf(w, eventXML, "2024-05-01T12:05:00Z", "2024-05-01T12:05:00Z")
+		io.WriteString(w, "</events>")
+	})
+
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests = append(s.requests, r)
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeServer) client(t *testing.T) *Client {
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err)
+	host, port, err := net.SplitHostPort(u.Host)
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(s.Certificate())
+	client, err := NewClient(Config{
+		Address:   host,
+		Port:      portNumber,
+		TLSConfig: &tls.Config{RootCAs: roots},
+	})
+	require.NoError(t, err)
+	return client
+}
+
+func (s *fakeServer) lastQuery() url.Values {
+	return s.requests[len(s.requests)-1].URL.Query()
+}
+
+func TestVersion(t *testing.T) {
+	server := newFakeServer(t)
+	client := server.client(t)
+
+	version, err := client.Version(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "TAK Server 4.10-RELEASE-12-HEAD", version)
+
+	config, err := client.ServerConfig(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &ServerConfig{Version: "4.10-RELEASE-12-HEAD", API: "3", Hostname: "takserver", NodeID: "node-1"}, config)
+}
+
+func TestClientEndpoints(t *testing.T) {
+	server := newFakeServer(t)
+	client := server.client(t)
+
+	endpoints, err := client.ClientEndpoints(context.Background(), ClientEndpointQuery{Since: time.Hour, ConnectedOnly: true, Group: "Blue"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "3600", server.lastQuery().Get("secAgo"))
+	assert.Equal(t, "true", server.lastQuery().Get("showCurrentlyConnectedClients"))
+	assert.Equal(t, "Blue", server.lastQuery().Get("group"))
+	require.Len(t, endpoints, 2)
+	assert.Equal(t, "ANDROID-1", endpoints[0].UID)
+	assert.Equal(t, "R1", endpoints[0].Callsign)
+	assert.Equal(t, time.Date(2024, 5, 1, 12, 0, 0, 123e6, time.UTC), endpoints[0].LastEventTime)
+	assert.True(t, endpoints[0].Connected())
+	assert.False(t, endpoints[1].Connected())
+}
+
+func TestGroups(t *testing.T) {
+	server := newFakeServer(t)
+	client := server.client(t)
+
+	groups, err := client.Groups(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, groups, 2)
+	assert.Equal(t, Group{Name: "__ANON__", Direction: "IN", Created: "2024-05-01", Type: "SYSTEM", BitPosition: 2, Active: true}, groups[0])
+	assert.Equal(t, "OUT", groups[1].Direction)
+}
+
+func TestUploadAndDownload(t *testing.T) {
+	server := newFakeServer(t)
+	client := server.client(t)
+	data := []byte("data package contents")
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	content, err := client.Upload(context.Background(), bytes.NewReader(data), UploadOptions{
+		Name:       "package.zip",
+		MIMEType:   "application/zip",
+		Keywords:   []string{"missionpackage"},
+		CreatorUID: "ANDROID-1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &Content{
+		Hash:           hash,
+		UID:            "uid-1",
+		Name:           "package.zip",
+		MIMEType:       "application/zip",
+		Keywords:       []string{"missionpackage"},
+		Size:           int64(len(data)),
+		SubmissionTime: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		SubmissionUser: "bot-1",
+		CreatorUID:     "ANDROID-1",
+	}, content)
+	assert.Equal(t, "missionpackage", server.lastQuery().Get("keywords"))
+	assert.Equal(t, server.URL+ContentPath+"?hash="+hash, client.ContentURL(hash))
+
+	downloaded, err := client.Content(context.Background(), hash)
+	require.NoError(t, err)
+	assert.Equal(t, data, downloaded)
+
+	_, err = client.Content(context.Background(), "0000")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	server.files[hash] = []byte("tampered")
+	_, err = client.Content(context.Background(), hash)
+	assert.ErrorIs(t, err, ErrHashMismatch)
+}
+
+func TestLatestEvent(t *testing.T) {
+	server := newFakeServer(t)
+	client := server.client(t)
+
+	event, err := client.LatestEvent(context.Background(), "ANDROID-1")
+	require.NoError(t, err)
+	assert.Equal(t, "ANDROID-1", event.UID)
+	assert.Equal(t, "a-f-G-U-C", event.Type)
+	assert.Equal(t, 38.85, event.Point.Lat)
+	assert.Equal(t, "R1", event.Detail.Contact.Callsign)
+
+	_, err = client.LatestEvent(context.Background(), "unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestEventHistory(t *testing.T) {
+	server := newFakeServer(t)
+	client := server.client(t)
+	start := time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)
+
+	events, err := client.EventHistory(context.Background(), "ANDROID-1", start, time.Time{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "2024-05-01T11:00:00Z", server.lastQuery().Get("start"))
+	assert.False(t, server.lastQuery().Has("end"))
+	require.Len(t, events, 2)
+	assert.Equal(t, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), events[0].Time.Time())
+	assert.Equal(t, time.Date(2024, 5, 1, 12, 5, 0, 0, time.UTC), events[1].Time.Time())
+}
+
+func TestClientTLSOptions(t *testing.T) {
+	server := newFakeServer(t)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	_, port, err := net.SplitHostPort(u.Host)
+	require.NoError(t, err)
+	portNumber, _ := strconv.Atoi(port)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	// The test certificate is valid for example.com and the loopback addresses only
+	client, err := NewClient(Config{Address: "localhost", Port: portNumber, TLS: util.TLSOptions{CAFile: caFile}})
+	require.NoError(t, err)
+	_, err = client.Version(context.Background())
+	var verificationErr *util.VerificationError
+	assert.ErrorAs(t, err, &verificationErr)
+
+	client, err = NewClient(Config{Address: "localhost", Port: portNumber, TLS: util.TLSOptions{CAFile: caFile, ServerName: "example.com"}})
+	require.NoError(t, err)
+	_, err = client.Version(context.Background())
+	assert.NoError(t, err)
+
+	client, err = NewClient(Config{Address: "127.0.0.1", Port: portNumber})
+	require.NoError(t, err)
+	_, err = client.Version(context.Background())
+	assert.Error(t, err)
+}
+
+func TestNewClientErrors(t *testing.T) {
+	_, err := NewClient(Config{})
+	assert.Error(t, err)
+	_, err = NewClient(Config{Address: "takserver", TLS: util.TLSOptions{CAFile: "missing.pem"}})
+	assert.Error(t, err)
+}
+
+func TestUnauthorized(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer server.Close()
+	client, err := NewClient(Config{Address: "takserver", HTTPClient: server.Client()})
+	require.NoError(t, err)
+	client.baseURL = server.URL
+
+	_, err = client.Groups(context.Background())
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
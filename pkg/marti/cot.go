@@ -0,0 +1,60 @@
+package marti
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+)
+
+// LatestEvent returns the last event the server received for a UID
+func (c *Client) LatestEvent(ctx context.Context, uid string) (*cot.Event, error) {
+	data, err := c.getXML(ctx, CoTPath+url.PathEscape(uid), nil)
+	if err != nil {
+		return nil, err
+	}
+	var event cot.Event
+	if err := xml.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("marti: invalid event for %s: %w", uid, err)
+	}
+	return &event, nil
+}
+
+// EventHistory returns the events the server stored for a UID between start and end,
+// oldest first. A zero start or end leaves that side of the range open.
+func (c *Client) EventHistory(ctx context.Context, uid string, start, end time.Time) ([]*cot.Event, error) {
+	query := url.Values{}
+	if !start.IsZero() {
+		query.Set("start", start.UTC().Format(time.RFC3339))
+	}
+	if !end.IsZero() {
+		query.Set("end", end.UTC().Format(time.RFC3339))
+	}
+	data, err := c.getXML(ctx, CoTPath+url.PathEscape(uid)+"/all", query)
+	if err != nil {
+		return nil, err
+	}
+
+	var history struct {
+		Events []*cot.Event `xml:"event"`
+	}
+	if err := xml.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("marti: invalid history for %s: %w", uid, err)
+	}
+	return history.Events, nil
+}
+
+// getXML sends a GET request and returns the XML response body
+func (c *Client) getXML(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	resp, err := c.do(ctx, http.MethodGet, path, query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,388 @@
+package marti
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+)
+
+// MissionsPath is the path of the mission API
+const MissionsPath = "/Marti/api/missions"
+
+// Mission change types
+const (
+	ChangeCreateMission = "CREATE_MISSION"
+	ChangeDeleteMission = "DELETE_MISSION"
+	ChangeAddContent    = "ADD_CONTENT"
+	ChangeRemoveContent = "REMOVE_CONTENT"
+)
+
+// Mission is a TAK Server data sync mission, a named collection of map items and files
+// shared with its subscribers
+type Mission struct {
+	Name              string           `json:"name"`
+	GUID              string           `json:"guid,omitempty"`
+	Description       string           `json:"description,omitempty"`
+	ChatRoom          string           `json:"chatRoom,omitempty"`
+	Tool              string           `json:"tool,omitempty"`
+	Keywords          []string         `json:"keywords,omitempty"`
+	CreatorUID        string           `json:"creatorUid,omitempty"`
+	CreateTime        time.Time        `json:"createTime"`
+	Groups            []string         `json:"groups,omitempty"`
+	PasswordProtected bool             `json:"passwordProtected"`
+	InviteOnly        bool             `json:"inviteOnly"`
+	UIDs              []MissionUID     `json:"uids,omitempty"`
+	Contents          []MissionContent `json:"contents,omitempty"`
+}
+
+// MissionUID is a map item of a mission
+type MissionUID struct {
+	UID        string       `json:"data"`
+	Timestamp  time.Time    `json:"timestamp"`
+	CreatorUID string       `json:"creatorUid,omitempty"`
+	Details    *ItemDetails `json:"details,omitempty"`
+}
+
+// MissionContent is a file of a mission
+type MissionContent struct {
+	Resource   Resource  `json:"data"`
+	Timestamp  time.Time `json:"timestamp"`
+	CreatorUID string    `json:"creatorUid,omitempty"`
+}
+
+// Resource describes a file stored on the server
+type Resource struct {
+	UID            string    `json:"uid"`
+	Name           string    `json:"name"`
+	Filename       string    `json:"filename,omitempty"`
+	Hash           string    `json:"hash"`
+	MIMEType       string    `json:"mimeType,omitempty"`
+	Keywords       []string  `json:"keywords,omitempty"`
+	Size           int64     `json:"size"`
+	SubmissionTime time.Time `json:"submissionTime"`
+	Submitter      string    `json:"submitter,omitempty"`
+	CreatorUID     string    `json:"creatorUid,omitempty"`
+	Tool           string    `json:"tool,omitempty"`
+}
+
+// ItemDetails describes the map item behind a mission UID
+type ItemDetails struct {
+	Type     string `json:"type"`
+	Callsign string `json:"callsign,omitempty"`
+	// Color is the ARGB color as a signed integer, as in CoT color elements
+	Color       string     `json:"color,omitempty"`
+	IconsetPath string     `json:"iconsetPath,omitempty"`
+	Location    *cot.Point `json:"location,omitempty"`
+}
+
+// UnmarshalJSON reads item details whose color is sent as a number or a string
+func (d *ItemDetails) UnmarshalJSON(data []byte) error {
+	type itemDetails ItemDetails
+	var v struct {
+		itemDetails
+		Color jsonString `json:"color"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*d = ItemDetails(v.itemDetails)
+	d.Color = string(v.Color)
+	return nil
+}
+
+// MissionChange is an entry of the change log of a mission
+type MissionChange struct {
+	// Type is one of the Change constants
+	Type        string    `json:"type"`
+	MissionName string    `json:"missionName"`
+	CreatorUID  string    `json:"creatorUid,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+	// ContentUID is the map item added or removed by UID changes
+	ContentUID string `json:"contentUid,omitempty"`
+	// ContentResource is the file added or removed by content changes
+	ContentResource *Resource    `json:"contentResource,omitempty"`
+	Details         *ItemDetails `json:"details,omitempty"`
+}
+
+// Subscription is the subscription of a client to a mission
+type Subscription struct {
+	// Token authorizes further requests for the mission; the client sends it for the
+	// missions it subscribed to
+	Token      string    `json:"token"`
+	ClientUID  string    `json:"clientUid"`
+	Username   string    `json:"username,omitempty"`
+	CreateTime time.Time `json:"createTime"`
+}
+
+// MissionOptions describes a new mission
+type MissionOptions struct {
+	// CreatorUID is the UID of the creating device
+	CreatorUID  string
+	Description string
+	// Tool is the mission kind, "public" when empty
+	Tool     string
+	Groups   []string
+	ChatRoom string
+	// Password restricts subscriptions to clients that know it
+	Password string
+}
+
+// MissionChangeQuery selects the change log entries returned by MissionChanges
+type MissionChangeQuery struct {
+	// Since limits the result to changes within the duration
+	Since time.Duration
+	// Start and End limit the result to a time range
+	Start, End time.Time
+	// Squashed drops changes undone by later ones
+	Squashed bool
+}
+
+// Missions returns the missions visible to the client
+func (c *Client) Missions(ctx context.Context) ([]Mission, error) {
+	var result response[[]Mission]
+	if err := c.getJSON(ctx, MissionsPath, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+// Mission returns a mission with its UIDs and contents
+func (c *Client) Mission(ctx context.Context, name string) (*Mission, error) {
+	return c.missionRequest(ctx, http.MethodGet, name, "", nil, nil)
+}
+
+// CreateMission creates a mission
+func (c *Client) CreateMission(ctx context.Context, name string, options MissionOptions) (*Mission, error) {
+	query := url.Values{}
+	setQuery(query, "creatorUid", options.CreatorUID)
+	setQuery(query, "description", options.Description)
+	setQuery(query, "chatRoom", options.ChatRoom)
+	setQuery(query, "password", options.Password)
+	if options.Tool == "" {
+		options.Tool = "public"
+	}
+	query.Set("tool", options.Tool)
+	for _, group := range options.Groups {
+		query.Add("group", group)
+	}
+	return c.missionRequest(ctx, http.MethodPut, name, "", query, nil)
+}
+
+// DeleteMission deletes a mission
+func (c *Client) DeleteMission(ctx context.Context, name, creatorUID string) error {
+	query := url.Values{}
+	setQuery(query, "creatorUid", creatorUID)
+	if _, err := c.missionRequest(ctx, http.MethodDelete, name, "", query, nil); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	delete(c.tokens, name)
+	c.mu.Unlock()
+	return nil
+}
+
+// Subscribe subscribes the client with clientUID to a mission, which makes the server
+// send it the mission changes. The password is required for protected missions. The
+// returned token is used for further requests for the mission.
+func (c *Client) Subscribe(ctx context.Context, name, clientUID, password string) (*Subscription, error) {
+	query := url.Values{"uid": {clientUID}}
+	setQuery(query, "password", password)
+	var result response[Subscription]
+	if err := c.doJSON(ctx, http.MethodPut, missionPath(name, "/subscription"), query, c.missionHeader(name), nil, &result); err != nil {
+		return nil, err
+	}
+	if result.Data.Token != "" {
+		c.mu.Lock()
+		c.tokens[name] = result.Data.Token
+		c.mu.Unlock()
+	}
+	return &result.Data, nil
+}
+
+// Unsubscribe ends the subscription of the client with clientUID to a mission
+func (c *Client) Unsubscribe(ctx context.Context, name, clientUID string) error {
+	err := c.doJSON(ctx, http.MethodDelete, missionPath(name, "/subscription"), url.Values{"uid": {clientUID}}, c.missionHeader(name), nil, nil)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	delete(c.tokens, name)
+	c.mu.Unlock()
+	return nil
+}
+
+// Subscriptions returns the UIDs of the clients subscribed to a mission
+func (c *Client) Subscriptions(ctx context.Context, name string) ([]string, error) {
+	var result response[[]string]
+	if err := c.doJSON(ctx, http.MethodGet, missionPath(name, "/subscriptions"), nil, c.missionHeader(name), nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+// AddMissionContent adds map items by UID and stored files by hash to a mission
+func (c *Client) AddMissionContent(ctx context.Context, name, creatorUID string, uids, hashes []string) (*Mission, error) {
+	if len(uids) == 0 && len(hashes) == 0 {
+		return nil, errors.New("marti: no UIDs or hashes to add")
+	}
+	query := url.Values{}
+	setQuery(query, "creatorUid", creatorUID)
+	body := struct {
+		UIDs   []string `json:"uids,omitempty"`
+		Hashes []string `json:"hashes,omitempty"`
+	}{uids, hashes}
+	return c.missionRequest(ctx, http.MethodPut, name, "/contents", query, body)
+}
+
+// RemoveMissionContent removes map items by UID and files by hash from a mission. It
+// returns the mission after the last removal.
+func (c *Client) RemoveMissionContent(ctx context.Context, name, creatorUID string, uids, hashes []string) (*Mission, error) {
+	if len(uids) == 0 && len(hashes) == 0 {
+		return nil, errors.New("marti: no UIDs or hashes to remove")
+	}
+	var mission *Mission
+	remove := func(param, value string) error {
+		query := url.Values{param: {value}}
+		setQuery(query, "creatorUid", creatorUID)
+		var err error
+		mission, err = c.missionRequest(ctx, http.MethodDelete, name, "/contents", query, nil)
+		return err
+	}
+	for _, uid := range uids {
+		if err := remove("uid", uid); err != nil {
+			return nil, err
+		}
+	}
+	for _, hash := range hashes {
+		if err := remove("hash", hash); err != nil {
+			return nil, err
+		}
+	}
+	return mission, nil
+}
+
+// MissionChanges returns the change log of a mission, oldest first
+func (c *Client) MissionChanges(ctx context.Context, name string, q MissionChangeQuery) ([]MissionChange, error) {
+	query := url.Values{}
+	if q.Since > 0 {
+		query.Set("secago", strconv.Itoa(int(q.Since/time.Second)))
+	}
+	if !q.Start.IsZero() {
+		query.Set("start", q.Start.UTC().Format(time.RFC3339))
+	}
+	if !q.End.IsZero() {
+		query.Set("end", q.End.UTC().Format(time.RFC3339))
+	}
+	if q.Squashed {
+		query.Set("squashed", "true")
+	}
+	var result response[[]MissionChange]
+	if err := c.doJSON(ctx, http.MethodGet, missionPath(name, "/changes"), query, c.missionHeader(name), nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+// missionRequest sends a request for a mission that responds with the mission
+func (c *Client) missionRequest(ctx context.Context, method, name, subPath string, query url.Values, body any) (*Mission, error) {
+	var result response[[]Mission]
+	if err := c.doJSON(ctx, method, missionPath(name, subPath), query, c.missionHeader(name), body, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("%w: mission %s", ErrNotFound, name)
+	}
+	return &result.Data[0], nil
+}
+
+// missionHeader returns the authorization header for a mission the client subscribed to
+func (c *Client) missionHeader(name string) http.Header {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if token, ok := c.tokens[name]; ok {
+		return http.Header{"Authorization": {"Bearer " + token}}
+	}
+	return nil
+}
+
+// missionPath returns the API path of a mission
+func missionPath(name, subPath string) string {
+	return MissionsPath + "/" + url.PathEscape(name) + subPath
+}
+
+// setQuery sets a query parameter when the value is not empty
+func setQuery(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
+
+// MissionNotification is a mission notification received over the streaming connection
+type MissionNotification struct {
+	// Type is the notification kind, e.g. "CHANGE", "CREATE" or "DELETE"
+	Type        string
+	MissionName string
+	Tool        string
+	AuthorUID   string
+	Changes     []MissionChange
+}
+
+// ParseMissionNotification reads the mission changes of a t-x-m-c event, or of the
+// other mission notifications
+func ParseMissionNotification(event *cot.Event) (*MissionNotification, error) {
+	if !event.IsMissionNotification() {
+		return nil, fmt.Errorf("marti: event of type %s is not a mission notification", event.Type)
+	}
+	mission := event.Detail.Mission
+	notification := &MissionNotification{
+		Type:        strings.ToUpper(mission.Type),
+		MissionName: mission.Name,
+		Tool:        mission.Tool,
+		AuthorUID:   mission.AuthorUID,
+	}
+	for _, change := range mission.Changes {
+		c := MissionChange{
+			Type:        change.Type,
+			MissionName: change.MissionName,
+			CreatorUID:  change.CreatorUID,
+			Timestamp:   change.Timestamp,
+			ContentUID:  change.ContentUID,
+		}
+		if c.MissionName == "" {
+			c.MissionName = mission.Name
+		}
+		if r := change.ContentResource; r != nil {
+			c.ContentResource = &Resource{
+				UID:            r.UID,
+				Name:           r.Name,
+				Filename:       r.Filename,
+				Hash:           r.Hash,
+				MIMEType:       r.MIMEType,
+				Keywords:       r.Keywords,
+				Size:           r.Size,
+				SubmissionTime: r.SubmissionTime,
+				Submitter:      r.Submitter,
+				CreatorUID:     r.CreatorUID,
+				Tool:           r.Tool,
+			}
+		}
+		if d := change.Details; d != nil {
+			c.Details = &ItemDetails{
+				Type:        d.Type,
+				Callsign:    d.Callsign,
+				Color:       d.Color,
+				IconsetPath: d.IconsetPath,
+			}
+		}
+		notification.Changes = append(notification.Changes, c)
+	}
+	return notification, nil
+}
@@ -0,0 +1,265 @@
+package marti
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMissionServer is a stand-in for the TAK Server mission API
+type fakeMissionServer struct {
+	*httptest.Server
+	mu            sync.Mutex
+	missions      map[string]*Mission
+	subscriptions map[string][]string
+	authorization []string
+}
+
+var missionTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newFakeMissionServer(t *testing.T) *fakeMissionServer {
+	s := &fakeMissionServer{
+		missions:      map[string]*Mission{},
+		subscriptions: map[string][]string{},
+	}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeMissionServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authorization = append(s.authorization, r.Header.Get("Authorization"))
+
+	if r.URL.Path == MissionsPath {
+		var missions []Mission
+		for _, m := range s.missions {
+			missions = append(missions, *m)
+		}
+		writeData(w, missions)
+		return
+	}
+	name, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, MissionsPath+"/"), "/")
+	query := r.URL.Query()
+	mission, ok := s.missions[name]
+	if !ok && !(r.Method == http.MethodPut && sub == "") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method + " " + sub {
+	case "GET ":
+	case "PUT ":
+		mission = &Mission{Name: name, Tool: query.Get("tool"), Description: query.Get("description"),
+			CreatorUID: query.Get("creatorUid"), Groups: query["group"], CreateTime: missionTime,
+			PasswordProtected: query.Get("password") != ""}
+		s.missions[name] = mission
+	case "DELETE ":
+		delete(s.missions, name)
+	case "PUT subscription":
+		if mission.PasswordProtected && query.Get("password") != "secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		s.subscriptions[name] = append(s.subscriptions[name], query.Get("uid"))
+		writeData(w, Subscription{Token: "token-" + name, ClientUID: query.Get("uid"), CreateTime: missionTime})
+		return
+	case "DELETE subscription":
+		s.subscriptions[name] = nil
+		writeData(w, nil)
+		return
+	case "GET subscriptions":
+		writeData(w, s.subscriptions[name])
+		return
+	case "PUT contents":
+		var body struct{ UIDs, Hashes []string }
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		for _, uid := range body.UIDs {
+			mission.UIDs = append(mission.UIDs, MissionUID{UID: uid, Timestamp: missionTime})
+		}
+		for _, hash := range body.Hashes {
+			mission.Contents = append(mission.Contents, MissionContent{Resource: Resource{Hash: hash}, Timestamp: missionTime})
+		}
+	case "DELETE contents":
+		if uid := query.Get("uid"); uid != "" {
+			for i, item := range mission.UIDs {
+				if item.UID == uid {
+					mission.UIDs = append(mission.UIDs[:i], mission.UIDs[i+1:]...)
+					break
+				}
+			}
+		}
+	case "GET changes":
+		// Color is sent as a number, location as an object
+		w.Write([]byte(`{"version":"3","type":"MissionChange","data":[` +
+			`{"type":"ADD_CONTENT","missionName":"` + name + `","timestamp":"2024-05-01T12:00:00.000Z","contentUid":"ANDROID-2",` +
+			`"details":{"type":"a-f-G-U-C","callsign":"R2","color":-65536,"location":{"lat":38.85,"lon":-77.05}}}]}`))
+		return
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeData(w, []Mission{*mission})
+}
+
+func writeData(w http.ResponseWriter, data any) {
+	json.NewEncoder(w).Encode(map[string]any{"version": "3", "type": "Mission", "data": data})
+}
+
+func (s *fakeMissionServer) client(t *testing.T) *Client {
+	client, err := NewClient(Config{Address: "takserver", HTTPClient: s.Client()})
+	require.NoError(t, err)
+	client.baseURL = s.URL
+	return client
+}
+
+func TestMissionLifecycle(t *testing.T) {
+	server := newFakeMissionServer(t)
+	client := server.client(t)
+	ctx := context.Background()
+
+	mission, err := client.CreateMission(ctx, "op alpha", MissionOptions{
+		CreatorUID:  "ANDROID-1",
+		Description: "Route clearance",
+		Groups:      []string{"Blue", "Red"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "op alpha", mission.Name)
+	assert.Equal(t, "public", mission.Tool)
+	assert.Equal(t, []string{"Blue", "Red"}, mission.Groups)
+	assert.Equal(t, missionTime, mission.CreateTime)
+
+	missions, err := client.Missions(ctx)
+	require.NoError(t, err)
+	require.Len(t, missions, 1)
+
+	mission, err = client.AddMissionContent(ctx, "op alpha", "ANDROID-1", []string{"ANDROID-2", "ANDROID-3"}, []string{"abc123"})
+	require.NoError(t, err)
+	require.Len(t, mission.UIDs, 2)
+	require.Len(t, mission.Contents, 1)
+	assert.Equal(t, "abc123", mission.Contents[0].Resource.Hash)
+
+	mission, err = client.RemoveMissionContent(ctx, "op alpha", "ANDROID-1", []string{"ANDROID-2"}, nil)
+	require.NoError(t, err)
+	require.Len(t, mission.UIDs, 1)
+	assert.Equal(t, "ANDROID-3", mission.UIDs[0].UID)
+
+	mission, err = client.Mission(ctx, "op alpha")
+	require.NoError(t, err)
+	assert.Equal(t, "Route clearance", mission.Description)
+
+	require.NoError(t, client.DeleteMission(ctx, "op alpha", "ANDROID-1"))
+	_, err = client.Mission(ctx, "op alpha")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = client.AddMissionContent(ctx, "op alpha", "ANDROID-1", nil, nil)
+	assert.Error(t, err)
+}
+
+func TestMissionSubscription(t *testing.T) {
+	server := newFakeMissionServer(t)
+	client := server.client(t)
+	ctx := context.Background()
+
+	_, err := client.CreateMission(ctx, "op-bravo", MissionOptions{Password: "secret"})
+	require.NoError(t, err)
+
+	_, err = client.Subscribe(ctx, "op-bravo", "ANDROID-1", "wrong")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	subscription, err := client.Subscribe(ctx, "op-bravo", "ANDROID-1", "secret")
+	require.NoError(t, err)
+	assert.Equal(t, "token-op-bravo", subscription.Token)
+	assert.Equal(t, "ANDROID-1", subscription.ClientUID)
+
+	// Requests for the mission carry the subscription token
+	uids, err := client.Subscriptions(ctx, "op-bravo")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ANDROID-1"}, uids)
+	assert.Equal(t, "Bearer token-op-bravo", server.authorization[len(server.authorization)-1])
+
+	require.NoError(t, client.Unsubscribe(ctx, "op-bravo", "ANDROID-1"))
+	_, err = client.Mission(ctx, "op-bravo")
+	require.NoError(t, err)
+	assert.Empty(t, server.authorization[len(server.authorization)-1])
+}
+
+func TestMissionChanges(t *testing.T) {
+	server := newFakeMissionServer(t)
+	client := server.client(t)
+	ctx := context.Background()
+	_, err := client.CreateMission(ctx, "op-charlie", MissionOptions{})
+	require.NoError(t, err)
+
+	changes, err := client.MissionChanges(ctx, "op-charlie", MissionChangeQuery{Since: time.Hour, Squashed: true})
+	require.NoError(t, err)
+
+	require.Len(t, changes, 1)
+	change := changes[0]
+	assert.Equal(t, ChangeAddContent, change.Type)
+	assert.Equal(t, "op-charlie", change.MissionName)
+	assert.Equal(t, "ANDROID-2", change.ContentUID)
+	assert.Equal(t, missionTime, change.Timestamp)
+	require.NotNil(t, change.Details)
+	assert.Equal(t, "R2", change.Details.Callsign)
+	assert.Equal(t, "-65536", change.Details.Color)
+	assert.Equal(t, 38.85, change.Details.Location.Lat)
+}
+
+func TestParseMissionNotification(t *testing.T) {
+	data := `<event version="2.0" uid="change-1" type="t-x-m-c" how="h-g-i-g-o" time="2024-05-01T12:00:00Z" start="2024-05-01T12:00:00Z" stale="2024-05-01T12:00:20Z">
+<point lat="0" lon="0" hae="0" ce="9999999" le="9999999"/>
+<detail><mission type="CHANGE" tool="public" name="op-alpha" authorUid="ANDROID-1"><MissionChanges>
+<MissionChange><contentUid>ANDROID-2</contentUid><creatorUid>ANDROID-1</creatorUid><missionName>op-alpha</missionName>
+<timestamp>2024-05-01T12:00:00.000Z</timestamp><type>ADD_CONTENT</type><details type="a-f-G-U-C" callsign="R2" color="-65536"/></MissionChange>
+<MissionChange><contentResource><filename>route.kml</filename><hash>abc123</hash><mimeType>application/xml</mimeType><name>route.kml</name>
+<size>2048</size><submissionTime>2024-05-01T11:59:00.000Z</submissionTime><submitter>bot-1</submitter><uid>res-1</uid></contentResource>
+<creatorUid>ANDROID-1</creatorUid><missionName>op-alpha</missionName><timestamp>2024-05-01T12:00:00.000Z</timestamp><type>REMOVE_CONTENT</type></MissionChange>
+</MissionChanges></mission></detail></event>`
+	var event cot.Event
+	require.NoError(t, xml.Unmarshal([]byte(data), &event))
+
+	notification, err := ParseMissionNotification(&event)
+	require.NoError(t, err)
+
+	assert.Equal(t, "CHANGE", notification.Type)
+	assert.Equal(t, "op-alpha", notification.MissionName)
+	assert.Equal(t, "ANDROID-1", notification.AuthorUID)
+	require.Len(t, notification.Changes, 2)
+	assert.Equal(t, MissionChange{
+		Type:        ChangeAddContent,
+		MissionName: "op-alpha",
+		CreatorUID:  "ANDROID-1",
+		Timestamp:   missionTime,
+		ContentUID:  "ANDROID-2",
+		Details:     &ItemDetails{Type: "a-f-G-U-C", Callsign: "R2", Color: "-65536"},
+	}, notification.Changes[0])
+	assert.Equal(t, ChangeRemoveContent, notification.Changes[1].Type)
+	assert.Equal(t, &Resource{
+		UID:            "res-1",
+		Name:           "route.kml",
+		Filename:       "route.kml",
+		Hash:           "abc123",
+		MIMEType:       "application/xml",
+		Size:           2048,
+		SubmissionTime: time.Date(2024, 5, 1, 11, 59, 0, 0, time.UTC),
+		Submitter:      "bot-1",
+	}, notification.Changes[1].ContentResource)
+
+	_, err = ParseMissionNotification(cot.NewEvent("a-f-G-U-C", "unit-1"))
+	assert.Error(t, err)
+}
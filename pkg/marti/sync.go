@@ -0,0 +1,195 @@
+package marti
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrHashMismatch is returned when downloaded content does not match its SHA-256 hash
+var ErrHashMismatch = errors.New("marti: content does not match its hash")
+
+// Content is the metadata of a file stored on the server
+type Content struct {
+	// Hash is the hex SHA-256 of the file, which identifies it on the server
+	Hash           string
+	UID            string
+	Name           string
+	MIMEType       string
+	Keywords       []string
+	Size           int64
+	SubmissionTime time.Time
+	SubmissionUser string
+	CreatorUID     string
+}
+
+// UnmarshalJSON reads the metadata returned by the server. Most fields are sent as
+// single-element arrays.
+func (c *Content) UnmarshalJSON(data []byte) error {
+	var fields struct {
+		Hash           jsonString  `json:"Hash"`
+		SHA256         jsonString  `json:"SHA256"`
+		UID            jsonString  `json:"UID"`
+		Name           jsonString  `json:"Name"`
+		MIMEType       jsonString  `json:"MIMEType"`
+		Keywords       []string    `json:"Keywords"`
+		Size           json.Number `json:"Size"`
+		SubmissionTime jsonString  `json:"SubmissionDateTime"`
+		SubmissionUser jsonString  `json:"SubmissionUser"`
+		CreatorUID     jsonString  `json:"CreatorUid"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	*c = Content{
+		Hash:           string(fields.Hash),
+		UID:            string(fields.UID),
+		Name:           string(fields.Name),
+		MIMEType:       string(fields.MIMEType),
+		Keywords:       fields.Keywords,
+		SubmissionUser: string(fields.SubmissionUser),
+		CreatorUID:     string(fields.CreatorUID),
+	}
+	if c.Hash == "" {
+		c.Hash = string(fields.SHA256)
+	}
+	if fields.Size != "" {
+		size, err := fields.Size.Int64()
+		if err != nil {
+			return fmt.Errorf("invalid size: %w", err)
+		}
+		c.Size = size
+	}
+	if fields.SubmissionTime != "" {
+		t, err := time.Parse(time.RFC3339, string(fields.SubmissionTime))
+		if err != nil {
+			return fmt.Errorf("invalid submission time: %w", err)
+		}
+		c.SubmissionTime = t
+	}
+	return nil
+}
+
+// jsonString is a string sent as a JSON string, an array of one or a number
+type jsonString string
+
+func (s *jsonString) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(data, []byte("[")):
+		var values []string
+		if err := json.Unmarshal(data, &values); err != nil {
+			return err
+		}
+		*s = jsonString(strings.Join(values, ","))
+		return nil
+	case bytes.Equal(data, []byte("null")):
+		*s = ""
+		return nil
+	case !bytes.HasPrefix(data, []byte(`"`)):
+		var number json.Number
+		if err := json.Unmarshal(data, &number); err != nil {
+			return err
+		}
+		*s = jsonString(number)
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*s = jsonString(value)
+	return nil
+}
+
+// UploadOptions describes a file to upload
+type UploadOptions struct {
+	// Name is the file name shown to other clients
+	Name string
+	// MIMEType of the file, application/octet-stream when empty
+	MIMEType string
+	Keywords []string
+	// CreatorUID is the UID of the uploading device
+	CreatorUID string
+}
+
+// Upload stores a file on the server and returns its metadata
+func (c *Client) Upload(ctx context.Context, r io.Reader, options UploadOptions) (*Content, error) {
+	if options.Name == "" {
+		return nil, errors.New("marti: upload name is required")
+	}
+	query := url.Values{"name": {options.Name}}
+	if len(options.Keywords) > 0 {
+		query.Set("keywords", strings.Join(options.Keywords, ","))
+	}
+	if options.CreatorUID != "" {
+		query.Set("creatorUid", options.CreatorUID)
+	}
+	contentType := options.MIMEType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	hash := sha256.New()
+	resp, err := c.do(ctx, http.MethodPost, UploadPath, query, io.TeeReader(r, hash), http.Header{"Content-Type": {contentType}})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var content Content
+	if err := json.NewDecoder(resp.Body).Decode(&content); err != nil {
+		return nil, fmt.Errorf("marti: invalid upload response: %w", err)
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if content.Hash == "" {
+		content.Hash = sum
+	} else if !strings.EqualFold(content.Hash, sum) {
+		return nil, fmt.Errorf("%w: server reports %s, uploaded %s", ErrHashMismatch, content.Hash, sum)
+	}
+	return &content, nil
+}
+
+// ContentURL returns the download URL of a file, as shared with other clients
+func (c *Client) ContentURL(hash string) string {
+	return c.URL(ContentPath, url.Values{"hash": {hash}})
+}
+
+// Download writes the file with the SHA-256 hash to w and verifies its content.
+// It returns ErrHashMismatch when the content does not match, after writing it.
+func (c *Client) Download(ctx context.Context, hash string, w io.Writer) (int64, error) {
+	resp, err := c.do(ctx, http.MethodGet, ContentPath, url.Values{"hash": {hash}}, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	sum := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, sum), resp.Body)
+	if err != nil {
+		return n, fmt.Errorf("marti: failed to download %s: %w", hash, err)
+	}
+	if got := hex.EncodeToString(sum.Sum(nil)); !strings.EqualFold(got, hash) {
+		return n, fmt.Errorf("%w: expected %s, got %s", ErrHashMismatch, hash, got)
+	}
+	return n, nil
+}
+
+// Content returns the file with the SHA-256 hash
+func (c *Client) Content(ctx context.Context, hash string) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := c.Download(ctx, hash, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,298 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+)
+
+// DefaultGeoJSONType is the CoT type given to GeoJSON points without a type property
+const DefaultGeoJSONType = "a-u-G"
+
+// Feature is a GeoJSON feature as defined in RFC 7946
+type Feature struct {
+	Type       string         `json:"type"`
+	ID         string         `json:"id,omitempty"`
+	Geometry   *Geometry      `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// FeatureCollection is a GeoJSON feature collection
+type FeatureCollection struct {
+	Type     string     `json:"type"`
+	Features []*Feature `json:"features"`
+}
+
+// Geometry is a GeoJSON Point, LineString or Polygon. Coordinates hold a position, a
+// list of positions or a list of rings respectively; positions are [lon, lat] or
+// [lon, lat, hae].
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// GeoJSONParser converts CoT events to and from GeoJSON features. Points become Point
+// features; drawings, routes and shapes become LineString or, when closed, Polygon
+// features. The event attributes, callsign and remarks are kept as properties.
+type GeoJSONParser struct{}
+
+// ParseCoT converts a GeoJSON feature to a CoT Event. Line strings and polygons become
+// u-d-f drawings unless a type property says otherwise.
+func (p *GeoJSONParser) ParseCoT(data []byte) (*cot.Event, error) {
+	var feature Feature
+	if err := json.Unmarshal(data, &feature); err != nil {
+		return nil, err
+	}
+	return EventFromFeature(&feature)
+}
+
+// SerializeCoT converts a CoT Event to a GeoJSON feature
+func (p *GeoJSONParser) SerializeCoT(event *cot.Event) ([]byte, error) {
+	feature, err := EventToFeature(event)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(feature)
+}
+
+// ParseFeatureCollection converts the features of a GeoJSON feature collection to CoT
+// events. A single feature is accepted as well.
+func (p *GeoJSONParser) ParseFeatureCollection(data []byte) ([]*cot.Event, error) {
+	var collection struct {
+		Type     string            `json:"type"`
+		Features []json.RawMessage `json:"features"`
+	}
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, err
+	}
+	if collection.Type == "Feature" {
+		event, err := p.ParseCoT(data)
+		if err != nil {
+			return nil, err
+		}
+		return []*cot.Event{event}, nil
+	}
+	if collection.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("unsupported GeoJSON type %q", collection.Type)
+	}
+
+	events := make([]*cot.Event, 0, len(collection.Features))
+	for i, raw := range collection.Features {
+		event, err := p.ParseCoT(raw)
+		if err != nil {
+			return nil, fmt.Errorf("feature %d: %w", i, err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// SerializeFeatureCollection converts CoT events to a GeoJSON feature collection
+func (p *GeoJSONParser) SerializeFeatureCollection(events []*cot.Event) ([]byte, error) {
+	collection := FeatureCollection{Type: "FeatureCollection", Features: make([]*Feature, 0, len(events))}
+	for _, event := range events {
+		feature, err := EventToFeature(event)
+		if err != nil {
+			return nil, fmt.Errorf("event %s: %w", event.UID, err)
+		}
+		collection.Features = append(collection.Features, feature)
+	}
+	return json.Marshal(collection)
+}
+
+// NewGeoJSONParser creates a new GeoJSON parser for CoT events
+func NewGeoJSONParser() *GeoJSONParser {
+	return &GeoJSONParser{}
+}
+
+// EventToFeature converts a CoT Event to a GeoJSON feature
+func EventToFeature(event *cot.Event) (*Feature, error) {
+	vertices, err := event.Vertices()
+	if err != nil {
+		return nil, err
+	}
+
+	var geometry Geometry
+	var coordinates any
+	switch {
+	case len(vertices) == 1:
+		geometry.Type = "Point"
+		coordinates = position(vertices[0])
+	case isClosed(event, vertices):
+		ring := positions(vertices)
+		if first, last := vertices[0], vertices[len(vertices)-1]; first.Lat != last.Lat || first.Lon != last.Lon {
+			ring = append(ring, position(first))
+		}
+		geometry.Type = "Polygon"
+		coordinates = [][][]float64{ring}
+	default:
+		geometry.Type = "LineString"
+		coordinates = positions(vertices)
+	}
+	if geometry.Coordinates, err = json.Marshal(coordinates); err != nil {
+		return nil, err
+	}
+
+	properties := map[string]any{
+		"type":  event.Type,
+		"how":   event.How,
+		"time":  event.Time.Time().UTC().Format(time.RFC3339Nano),
+		"start": event.Start.Time().UTC().Format(time.RFC3339Nano),
+		"stale": event.Stale.Time().UTC().Format(time.RFC3339Nano),
+	}
+	if contact := event.Detail.Contact; contact != nil && contact.Callsign != "" {
+		properties["callsign"] = contact.Callsign
+	}
+	if remarks := event.Detail.Remarks; remarks != nil && remarks.Text != "" {
+		properties["remarks"] = remarks.Text
+	}
+	return &Feature{Type: "Feature", ID: event.UID, Geometry: &geometry, Properties: properties}, nil
+}
+
+// EventFromFeature converts a GeoJSON feature to a CoT Event
+func EventFromFeature(feature *Feature) (*cot.Event, error) {
+	if feature.Type != "Feature" {
+		return nil, fmt.Errorf("unsupported GeoJSON type %q", feature.Type)
+	}
+	if feature.Geometry == nil {
+		return nil, errors.New("feature has no geometry")
+	}
+	uid := feature.ID
+	if uid == "" {
+		uid = stringProperty(feature.Properties, "uid")
+	}
+	if uid == "" {
+		return nil, errors.New("feature has no id or uid property")
+	}
+	callsign := stringProperty(feature.Properties, "callsign")
+	if callsign == "" {
+		callsign = stringProperty(feature.Properties, "name")
+	}
+
+	var event *cot.Event
+	switch feature.Geometry.Type {
+	case "Point":
+		var coordinates []float64
+		if err := json.Unmarshal(feature.Geometry.Coordinates, &coordinates); err != nil {
+			return nil, fmt.Errorf("invalid point coordinates: %w", err)
+		}
+		point, err := fromPosition(coordinates)
+		if err != nil {
+			return nil, err
+		}
+		event = cot.NewEvent(DefaultGeoJSONType, uid)
+		event.SetPoint(point)
+		if callsign != "" {
+			event.Detail.AddContact(callsign)
+		}
+	case "LineString":
+		var coordinates [][]float64
+		if err := json.Unmarshal(feature.Geometry.Coordinates, &coordinates); err != nil {
+			return nil, fmt.Errorf("invalid line string coordinates: %w", err)
+		}
+		vertices, err := fromPositions(coordinates)
+		if err != nil {
+			return nil, err
+		}
+		if event, err = cot.NewPolyline(uid, callsign, vertices, cot.DefaultDrawingStyle()); err != nil {
+			return nil, err
+		}
+	case "Polygon":
+		var rings [][][]float64
+		if err := json.Unmarshal(feature.Geometry.Coordinates, &rings); err != nil {
+			return nil, fmt.Errorf("invalid polygon coordinates: %w", err)
+		}
+		if len(rings) == 0 {
+			return nil, errors.New("polygon has no rings")
+		}
+		// CoT drawings have no holes; only the outer ring is kept
+		vertices, err := fromPositions(rings[0])
+		if err != nil {
+			return nil, err
+		}
+		if event, err = cot.NewPolygon(uid, callsign, vertices, cot.DefaultDrawingStyle()); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported geometry type %q", feature.Geometry.Type)
+	}
+
+	if eventType := stringProperty(feature.Properties, "type"); eventType != "" {
+		event.SetType(eventType)
+	}
+	if how := stringProperty(feature.Properties, "how"); how != "" {
+		event.SetHow(how)
+	}
+	for name, set := range map[string]func(time.Time) *cot.Event{
+		"time":  event.SetTime,
+		"start": event.SetStart,
+		"stale": event.SetStale,
+	} {
+		if value := stringProperty(feature.Properties, name); value != "" {
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s property: %w", name, err)
+			}
+			set(t)
+		}
+	}
+	if remarks := stringProperty(feature.Properties, "remarks"); remarks != "" {
+		event.Detail.Remarks = &cot.Remarks{Text: remarks}
+	}
+	return event, nil
+}
+
+// isClosed reports whether the geometry of an event is an area rather than a line
+func isClosed(event *cot.Event, vertices []cot.Point) bool {
+	if event.Type == cot.TypeDrawingRectangle || event.Detail.Shape != nil && event.Detail.Shape.Ellipse != nil {
+		return len(vertices) >= 3
+	}
+	first, last := vertices[0], vertices[len(vertices)-1]
+	return len(vertices) >= 4 && first.Lat == last.Lat && first.Lon == last.Lon
+}
+
+func position(p cot.Point) []float64 {
+	if p.Hae != nil && *p.Hae < cot.DefaultValue {
+		return []float64{p.Lon, p.Lat, *p.Hae}
+	}
+	return []float64{p.Lon, p.Lat}
+}
+
+func positions(points []cot.Point) [][]float64 {
+	result := make([][]float64, len(points))
+	for i, p := range points {
+		result[i] = position(p)
+	}
+	return result
+}
+
+func fromPosition(coordinates []float64) (cot.Point, error) {
+	if len(coordinates) < 2 {
+		return cot.Point{}, fmt.Errorf("position needs at least 2 coordinates, got %d", len(coordinates))
+	}
+	point := cot.NewPoint(coordinates[1], coordinates[0])
+	if len(coordinates) > 2 {
+		point.SetHae(coordinates[2])
+	}
+	return point, nil
+}
+
+func fromPositions(coordinates [][]float64) ([]cot.Point, error) {
+	points := make([]cot.Point, len(coordinates))
+	for i, c := range coordinates {
+		p, err := fromPosition(c)
+		if err != nil {
+			return nil, err
+		}
+		points[i] = p
+	}
+	return points, nil
+}
+
+func stringProperty(properties map[string]any, name string) string {
+	s, _ := properties[name].(string)
+	return s
+}
@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"encoding/json"
+	"image/color"
+	"testing"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventToFeature(t *testing.T) {
+	marker := cot.NewSpotMarker("spot-1", "R1", cot.NewPoint(59.1, 18.2), color.NRGBA{R: 0xFF, A: 0xFF})
+	marker.Detail.Remarks = &cot.Remarks{Text: "check"}
+	polygon, err := cot.NewPolygon("poly-1", "Area", []cot.Point{
+		cot.NewPoint(59.0, 18.0), cot.NewPoint(59.1, 18.0), cot.NewPoint(59.1, 18.1),
+	}, cot.DefaultDrawingStyle())
+	require.NoError(t, err)
+	line, err := cot.NewPolyline("line-1", "Path", []cot.Point{cot.NewPoint(59.0, 18.0), cot.NewPoint(59.1, 18.1)}, cot.DefaultDrawingStyle())
+	require.NoError(t, err)
+
+	data, err := NewGeoJSONParser().SerializeFeatureCollection([]*cot.Event{marker, polygon, line})
+	require.NoError(t, err)
+
+	var collection FeatureCollection
+	require.NoError(t, json.Unmarshal(data, &collection))
+	require.Len(t, collection.Features, 3)
+
+	point := collection.Features[0]
+	assert.Equal(t, "spot-1", point.ID)
+	assert.Equal(t, "Point", point.Geometry.Type)
+	assert.JSONEq(t, `[18.2, 59.1]`, string(point.Geometry.Coordinates))
+	assert.Equal(t, "R1", point.Properties["callsign"])
+	assert.Equal(t, "check", point.Properties["remarks"])
+	assert.Equal(t, marker.Type, point.Properties["type"])
+
+	area := collection.Features[1]
+	assert.Equal(t, "Polygon", area.Geometry.Type)
+	assert.JSONEq(t, `[[[18, 59], [18, 59.1], [18.1, 59.1], [18, 59]]]`, string(area.Geometry.Coordinates))
+
+	path := collection.Features[2]
+	assert.Equal(t, "LineString", path.Geometry.Type)
+	assert.JSONEq(t, `[[18, 59], [18.1, 59.1]]`, string(path.Geometry.Coordinates))
+}
+
+func TestEventFromFeature(t *testing.T) {
+	data := []byte(`{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "id": "pos-1", "geometry": {"type": "Point", "coordinates": [18.2, 59.1, 25]},
+		 "properties": {"type": "a-h-G", "callsign": "Hostile", "stale": "2026-01-01T10:10:00Z", "remarks": "seen"}},
+		{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": [[[18, 59], [18, 59.1], [18.1, 59.1], [18, 59]]]},
+		 "properties": {"uid": "area-1", "name": "Area"}}
+	]}`)
+
+	events, err := NewGeoJSONParser().ParseFeatureCollection(data)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	pos := events[0]
+	assert.Equal(t, "pos-1", pos.UID)
+	assert.Equal(t, "a-h-G", pos.Type)
+	assert.Equal(t, 59.1, pos.Point.Lat)
+	assert.Equal(t, 18.2, pos.Point.Lon)
+	assert.Equal(t, 25.0, *pos.Point.Hae)
+	assert.Equal(t, "Hostile", pos.Detail.Contact.Callsign)
+	assert.Equal(t, "seen", pos.Detail.Remarks.Text)
+	assert.Equal(t, "2026-01-01T10:10:00Z", pos.Stale.Time().UTC().Format("2006-01-02T15:04:05Z"))
+
+	area := events[1]
+	assert.Equal(t, "area-1", area.UID)
+	assert.Equal(t, cot.TypeDrawingFreeForm, area.Type)
+	vertices, err := area.Vertices()
+	require.NoError(t, err)
+	assert.Len(t, vertices, 4)
+}
+
+func TestEventFromFeatureErrors(t *testing.T) {
+	p := NewGeoJSONParser()
+	for name, data := range map[string]string{
+		"no uid":        `{"type": "Feature", "geometry": {"type": "Point", "coordinates": [18, 59]}, "properties": {}}`,
+		"no geometry":   `{"type": "Feature", "id": "a", "geometry": null, "properties": {}}`,
+		"bad position":  `{"type": "Feature", "id": "a", "geometry": {"type": "Point", "coordinates": [18]}}`,
+		"unsupported":   `{"type": "Feature", "id": "a", "geometry": {"type": "MultiPoint", "coordinates": [[18, 59]]}}`,
+		"bad time":      `{"type": "Feature", "id": "a", "geometry": {"type": "Point", "coordinates": [18, 59]}, "properties": {"time": "yesterday"}}`,
+		"not a feature": `{"type": "Point", "coordinates": [18, 59]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := p.ParseCoT([]byte(data))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	protobufv1 "github.com/angry-kivi/gotak/pkg/cotproto"
+	"google.golang.org/protobuf/proto"
+)
+
+// protoMagic starts the header of TAK protocol version 1 messages
+const protoMagic = 0xbf
+
+// ProtoParser handles parsing and serialization of TAK protocol version 1 (protobuf)
+// messages. Serialized messages are bare TakMessages; parsing also accepts the mesh
+// header (0xbf 0x01 0xbf) and the streaming header (0xbf and a varint length).
+type ProtoParser struct{}
+
+// ParseCoT converts a TAK protocol message to a CoT Event
+func (p *ProtoParser) ParseCoT(data []byte) (*cot.Event, error) {
+	payload, err := stripProtoHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var message protobufv1.TakMessage
+	if err := proto.Unmarshal(payload, &message); err != nil {
+		return nil, fmt.Errorf("invalid TAK protocol message: %w", err)
+	}
+	if message.CotEvent == nil {
+		return nil, errors.New("TAK protocol message has no CoT event")
+	}
+	return EventFromProto(message.CotEvent)
+}
+
+// SerializeCoT converts a CoT Event to a TakMessage without header
+func (p *ProtoParser) SerializeCoT(event *cot.Event) ([]byte, error) {
+	protoEvent, err := EventToProto(event)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(&protobufv1.TakMessage{CotEvent: protoEvent})
+}
+
+// SerializeStreamCoT converts a CoT Event to a TakMessage with the streaming header,
+// as sent over TCP and TLS connections
+func (p *ProtoParser) SerializeStreamCoT(event *cot.Event) ([]byte, error) {
+	payload, err := p.SerializeCoT(event)
+	if err != nil {
+		return nil, err
+	}
+	frame := binary.AppendUvarint([]byte{protoMagic}, uint64(len(payload)))
+	return append(frame, payload...), nil
+}
+
+// SerializeMeshCoT converts a CoT Event to a TakMessage with the mesh header, as sent
+// over UDP and multicast
+func (p *ProtoParser) SerializeMeshCoT(event *cot.Event) ([]byte, error) {
+	payload, err := p.SerializeCoT(event)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{protoMagic, 0x01, protoMagic}, payload...), nil
+}
+
+// SplitProtoStream cuts data holding TakMessages with streaming headers into the single
+// messages, each still with its header
+func SplitProtoStream(data []byte) ([][]byte, error) {
+	messages, rest, err := CutProtoStream(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("truncated TAK protocol stream message")
+	}
+	return messages, nil
+}
+
+// CutProtoStream cuts the complete TakMessages with streaming headers at the start of
+// data, each still with its header, and returns the rest: the start of a message that
+// continues in the next read
+func CutProtoStream(data []byte) (messages [][]byte, rest []byte, err error) {
+	for len(data) > 0 {
+		if data[0] != protoMagic {
+			return messages, data, errors.New("invalid TAK protocol stream header")
+		}
+		length, n := binary.Uvarint(data[1:])
+		if n < 0 {
+			return messages, data, errors.New("invalid TAK protocol stream header")
+		}
+		if n == 0 || uint64(len(data)-1-n) < length {
+			return messages, data, nil
+		}
+		end := 1 + n + int(length)
+		messages = append(messages, data[:end])
+		data = data[end:]
+	}
+	return messages, nil, nil
+}
+
+// isMeshMessage reports whether data starts with the header of a TAK protocol mesh
+// message, which fills a whole datagram
+func isMeshMessage(data []byte) bool {
+	return len(data) >= 3 && data[0] == protoMagic && data[1] == 0x01 && data[2] == protoMagic
+}
+
+// NewProtoParser creates a new parser for TAK protocol version 1 messages
+func NewProtoParser() *ProtoParser {
+	return &ProtoParser{}
+}
+
+// stripProtoHeader removes the mesh or streaming header of a TAK protocol message
+func stripProtoHeader(data []byte) ([]byte, error) {
+	if len(data) == 0 || data[0] != protoMagic {
+		return data, nil
+	}
+	if isMeshMessage(data) {
+		return data[3:], nil
+	}
+	length, n := binary.Uvarint(data[1:])
+	if n <= 0 || uint64(len(data)-1-n) < length {
+		return nil, errors.New("invalid TAK protocol stream header")
+	}
+	return data[1+n : 1+n+int(length)], nil
+}
+
+// EventToProto converts a CoT Event to its protobuf form. Contact, group, precision
+// location, status, takv and track details move to their protobuf fields when the
+// fields can hold them; all other detail elements are kept as XML.
+func EventToProto(event *cot.Event) (*protobufv1.CotEvent, error) {
+	protoEvent := &protobufv1.CotEvent{
+		Type:      event.Type,
+		Access:    event.Access,
+		Qos:       event.Qos,
+		Opex:      event.Opex,
+		Uid:       event.UID,
+		SendTime:  timeToMillis(event.Time.Time()),
+		StartTime: timeToMillis(event.Start.Time()),
+		StaleTime: timeToMillis(event.Stale.Time()),
+		How:       event.How,
+		Lat:       event.Point.Lat,
+		Lon:       event.Point.Lon,
+		Hae:       valueOrDefault(event.Point.Hae),
+		Ce:        valueOrDefault(event.Point.Ce),
+		Le:        valueOrDefault(event.Point.Le),
+	}
+
+	detail := event.Detail
+	protoDetail := &protobufv1.Detail{}
+	if c := detail.Contact; c != nil && c.EmailAddress == "" && c.Phone == "" && c.XmppUsername == "" {
+		protoDetail.Contact = &protobufv1.Contact{Endpoint: c.Endpoint, Callsign: c.Callsign}
+		detail.Contact = nil
+	}
+	if g := detail.Group; g != nil {
+		protoDetail.Group = &protobufv1.Group{Name: g.Name, Role: g.Role}
+		detail.Group = nil
+	}
+	if l := detail.PrecisionLocation; l != nil && l.PreciseImageFile == "" && l.PreciseImageFileX == 0 && l.PreciseImageFileY == 0 {
+		protoDetail.PrecisionLocation = &protobufv1.PrecisionLocation{Geopointsrc: l.GeoPointSrc, Altsrc: l.AltSrc}
+		detail.PrecisionLocation = nil
+	}
+	if s := detail.Status; s != nil && !s.Readiness && s.Battery >= 0 {
+		protoDetail.Status = &protobufv1.Status{Battery: uint32(s.Battery)}
+		detail.Status = nil
+	}
+	if t := detail.Takv; t != nil {
+		protoDetail.Takv = &protobufv1.Takv{Device: t.Device, Platform: t.Platform, Os: t.OS, Version: t.Version}
+		detail.Takv = nil
+	}
+	if t := detail.Track; t != nil && t.Slope == 0 && t.Etype == "" && t.TimeStamp.IsZero() {
+		protoDetail.Track = &protobufv1.Track{Speed: t.Speed, Course: t.Course}
+		detail.Track = nil
+	}
+
+	xmlDetail, err := innerDetailXML(detail)
+	if err != nil {
+		return nil, err
+	}
+	protoDetail.XmlDetail = xmlDetail
+	if !proto.Equal(protoDetail, &protobufv1.Detail{}) {
+		protoEvent.Detail = protoDetail
+	}
+	return protoEvent, nil
+}
+
+// EventFromProto converts a protobuf CoT event to a CoT Event
+func EventFromProto(protoEvent *protobufv1.CotEvent) (*cot.Event, error) {
+	event := &cot.Event{
+		Version: "2.0",
+		UID:     protoEvent.Uid,
+		Type:    protoEvent.Type,
+		Time:    cot.CotTime(millisToTime(protoEvent.SendTime)),
+		Start:   cot.CotTime(millisToTime(protoEvent.StartTime)),
+		Stale:   cot.CotTime(millisToTime(protoEvent.StaleTime)),
+		How:     protoEvent.How,
+		Access:  protoEvent.Access,
+		Qos:     protoEvent.Qos,
+		Opex:    protoEvent.Opex,
+	}
+	event.Point = cot.NewPoint(protoEvent.Lat, protoEvent.Lon)
+	event.Point.SetHae(protoEvent.Hae).SetCe(protoEvent.Ce).SetLe(protoEvent.Le)
+
+	protoDetail := protoEvent.Detail
+	if protoDetail == nil {
+		return event, nil
+	}
+	if protoDetail.XmlDetail != "" {
+		if err := xml.Unmarshal([]byte("<detail>"+protoDetail.XmlDetail+"</detail>"), &event.Detail); err != nil {
+			return nil, fmt.Errorf("invalid detail XML: %w", err)
+		}
+	}
+	if c := protoDetail.Contact; c != nil {
+		event.Detail.Contact = &cot.Contact{Endpoint: c.Endpoint, Callsign: c.Callsign}
+	}
+	if g := protoDetail.Group; g != nil {
+		event.Detail.Group = &cot.Group{Name: g.Name, Role: g.Role}
+	}
+	if l := protoDetail.PrecisionLocation; l != nil {
+		event.Detail.PrecisionLocation = &cot.PrecisionLocation{GeoPointSrc: l.Geopointsrc, AltSrc: l.Altsrc}
+	}
+	if s := protoDetail.Status; s != nil {
+		event.Detail.Status = &cot.Status{Battery: int(s.Battery)}
+	}
+	if t := protoDetail.Takv; t != nil {
+		event.Detail.Takv = &cot.Takv{Device: t.Device, Platform: t.Platform, OS: t.Os, Version: t.Version}
+	}
+	if t := protoDetail.Track; t != nil {
+		event.Detail.Track = &cot.Track{Speed: t.Speed, Course: t.Course}
+	}
+	return event, nil
+}
+
+// innerDetailXML marshals the children of a detail without the detail element
+func innerDetailXML(detail cot.Detail) (string, error) {
+	data, err := xml.Marshal(detail)
+	if err != nil {
+		return "", err
+	}
+	data = bytes.TrimPrefix(data, []byte("<detail>"))
+	data = bytes.TrimSuffix(data, []byte("</detail>"))
+	return string(data), nil
+}
+
+func timeToMillis(t time.Time) uint64 {
+	if t.IsZero() || t.Before(time.Unix(0, 0)) {
+		return 0
+	}
+	return uint64(t.UnixMilli())
+}
+
+func millisToTime(ms uint64) time.Time {
+	return time.UnixMilli(int64(ms)).UTC()
+}
+
+// valueOrDefault returns an optional point value, or the CoT value for unknown
+func valueOrDefault(v *float64) float64 {
+	if v == nil {
+		return cot.DefaultValue
+	}
+	return *v
+}
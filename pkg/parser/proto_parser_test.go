@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	protobufv1 "github.com/angry-kivi/gotak/pkg/cotproto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+const sampleEvent = `<event version="2.0" uid="ANDROID-1" type="a-f-G-U-C" time="2026-01-01T10:00:00.000Z" start="2026-01-01T10:00:00.000Z" stale="2026-01-01T10:10:00.000Z" how="m-g">` +
+	`<point lat="59.1" lon="18.2" hae="10" ce="5" le="9999999"/>` +
+	`<detail><contact callsign="R1" endpoint="*:-1:stcp"/><__group name="Cyan" role="Team Member"/>` +
+	`<status battery="80"/><track speed="1.5" course="90"/><remarks>hello</remarks><custom foo="bar"/></detail></event>`
+
+func TestEventToProto(t *testing.T) {
+	event, err := NewXMLParser().ParseCoT([]byte(sampleEvent))
+	require.NoError(t, err)
+
+	protoEvent, err := EventToProto(event)
+	require.NoError(t, err)
+
+	assert.Equal(t, "a-f-G-U-C", protoEvent.Type)
+	assert.Equal(t, "ANDROID-1", protoEvent.Uid)
+	assert.Equal(t, uint64(time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC).UnixMilli()), protoEvent.SendTime)
+	assert.Equal(t, 10.0, protoEvent.Hae)
+	assert.Equal(t, cot.DefaultValue, protoEvent.Le)
+
+	detail := protoEvent.Detail
+	require.NotNil(t, detail)
+	assert.Equal(t, "R1", detail.Contact.Callsign)
+	assert.Equal(t, "*:-1:stcp", detail.Contact.Endpoint)
+	assert.Equal(t, "Cyan", detail.Group.Name)
+	assert.Equal(t, uint32(80), detail.Status.Battery)
+	assert.Equal(t, 90.0, detail.Track.Course)
+	// Elements without protobuf field stay XML, the others are not repeated there
+	assert.Contains(t, detail.XmlDetail, "<remarks>hello</remarks>")
+	assert.Contains(t, detail.XmlDetail, `<custom foo="bar"/>`)
+	assert.NotContains(t, detail.XmlDetail, "contact")
+	assert.NotContains(t, detail.XmlDetail, "__group")
+}
+
+func TestEventToProtoKeepsUnrepresentableDetails(t *testing.T) {
+	event := cot.NewEvent("a-f-G", "uid-1")
+	event.Detail.Contact = &cot.Contact{Callsign: "R1", Phone: "555-0100"}
+
+	protoEvent, err := EventToProto(event)
+	require.NoError(t, err)
+
+	assert.Nil(t, protoEvent.Detail.Contact)
+	assert.Contains(t, protoEvent.Detail.XmlDetail, `phone="555-0100"`)
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	event, err := NewXMLParser().ParseCoT([]byte(sampleEvent))
+	require.NoError(t, err)
+	p := NewProtoParser()
+
+	for name, serialize := range map[string]func(*cot.Event) ([]byte, error){
+		"bare":   p.SerializeCoT,
+		"stream": p.SerializeStreamCoT,
+		"mesh": func(e *cot.Event) ([]byte, error) {
+			data, err := p.SerializeCoT(e)
+			return append([]byte{0xbf, 0x01, 0xbf}, data...), err
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := serialize(event)
+			require.NoError(t, err)
+
+			parsed, err := p.ParseCoT(data)
+			require.NoError(t, err)
+
+			assert.Equal(t, event.UID, parsed.UID)
+			assert.Equal(t, event.Type, parsed.Type)
+			assert.True(t, event.Time.Time().Equal(parsed.Time.Time()))
+			assert.True(t, event.Stale.Time().Equal(parsed.Stale.Time()))
+			assert.Equal(t, event.Point.Lat, parsed.Point.Lat)
+			assert.Equal(t, 5.0, *parsed.Point.Ce)
+			assert.Equal(t, "R1", parsed.Detail.Contact.Callsign)
+			assert.Equal(t, "Team Member", parsed.Detail.Group.Role)
+			assert.Equal(t, 80, parsed.Detail.Status.Battery)
+			assert.Equal(t, 1.5, parsed.Detail.Track.Speed)
+			require.NotNil(t, parsed.Detail.Remarks)
+			assert.Equal(t, "hello", parsed.Detail.Remarks.Text)
+
+			out, err := xml.Marshal(parsed)
+			require.NoError(t, err)
+			assert.Contains(t, string(out), `<custom foo="bar"/>`)
+		})
+	}
+}
+
+func TestSplitProtoStream(t *testing.T) {
+	p := NewProtoParser()
+	var data []byte
+	for _, uid := range []string{"a", "b"} {
+		message, err := p.SerializeStreamCoT(cot.NewEvent("a-f-G", uid))
+		require.NoError(t, err)
+		data = append(data, message...)
+	}
+
+	messages, err := SplitProtoStream(data)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	event, err := p.ParseCoT(messages[1])
+	require.NoError(t, err)
+	assert.Equal(t, "b", event.UID)
+
+	_, err = SplitProtoStream(data[:len(data)-1])
+	assert.Error(t, err)
+
+	// A partial message is left for the next read
+	messages, rest, err := CutProtoStream(data[:len(data)-1])
+	require.NoError(t, err)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, data[len(messages[0]):len(data)-1], rest)
+}
+
+func TestParseProtoWithoutEvent(t *testing.T) {
+	data, err := proto.Marshal(&protobufv1.TakMessage{TakControl: &protobufv1.TakControl{MinProtoVersion: 1}})
+	require.NoError(t, err)
+
+	_, err = NewProtoParser().ParseCoT(data)
+	assert.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"bytes"
+)
+
+// maxBuffered bounds the data kept while waiting for the end of an event
+const maxBuffered = 1 << 20
+
+var (
+	eventStart = []byte("<event")
+	eventEnd   = []byte("</event>")
+)
+
+// EventSplitter cuts a stream of received data into complete events. Reads from stream
+// connections may hold several events or end in the middle of one. CoT XML events and
+// TAK protocol messages with streaming headers are cut apart, the latter still with
+// their header, so a stream may switch from XML to the TAK protocol. A TAK protocol
+// mesh message fills its datagram and is returned whole.
+type EventSplitter struct {
+	buf []byte
+}
+
+// Feed appends received data and returns the complete events it finishes
+func (s *EventSplitter) Feed(data []byte) [][]byte {
+	if len(s.buf) == 0 && isMeshMessage(data) {
+		return [][]byte{append([]byte(nil), data...)}
+	}
+	s.buf = append(s.buf, data...)
+
+	var events [][]byte
+	for {
+		// TAK protocol messages follow each other directly or after an XML event
+		if proto := bytes.TrimLeft(s.buf, " \t\r\n"); len(proto) > 0 && proto[0] == protoMagic {
+			messages, rest, err := CutProtoStream(proto)
+			for _, message := range messages {
+				events = append(events, append([]byte(nil), message...))
+			}
+			// Drop a corrupt header or a message too large to buffer; other data after
+			// the messages is XML again
+			if err != nil && rest[0] == protoMagic || len(rest) > maxBuffered {
+				rest = nil
+			}
+			s.buf = append(s.buf[:0], rest...)
+			if len(rest) > 0 && rest[0] == protoMagic {
+				break
+			}
+			continue
+		}
+
+		start := bytes.Index(s.buf, eventStart)
+		if start < 0 {
+			// Keep a possible partial start tag
+			if keep := len(eventStart) - 1; len(s.buf) > keep {
+				s.buf = append(s.buf[:0], s.buf[len(s.buf)-keep:]...)
+			}
+			break
+		}
+		end := bytes.Index(s.buf[start:], eventEnd)
+		if end < 0 {
+			s.buf = append(s.buf[:0], s.buf[start:]...)
+			if len(s.buf) > maxBuffered {
+				s.buf = s.buf[:0]
+			}
+			break
+		}
+		end += start + len(eventEnd)
+		events = append(events, append([]byte(nil), s.buf[start:end]...))
+		s.buf = s.buf[end:]
+	}
+	return events
+}
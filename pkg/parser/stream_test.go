@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventSplitter(t *testing.T) {
+	var s EventSplitter
+
+	events := s.Feed([]byte(`<?xml version="1.0"?><event uid="1"></event><event uid="2"><det`))
+	assert.Equal(t, [][]byte{[]byte(`<event uid="1"></event>`)}, events)
+
+	events = s.Feed([]byte(`ail/></event><eve`))
+	assert.Equal(t, [][]byte{[]byte(`<event uid="2"><detail/></event>`)}, events)
+
+	events = s.Feed([]byte(`nt uid="3"></event>`))
+	assert.Equal(t, [][]byte{[]byte(`<event uid="3"></event>`)}, events)
+
+	assert.Empty(t, s.Feed([]byte("garbage without events")))
+	assert.Len(t, s.buf, len(eventStart)-1)
+}
+
+func TestEventSplitterProto(t *testing.T) {
+	p := NewProtoParser()
+	var stream [][]byte
+	for _, uid := range []string{"1", "2", "3"} {
+		message, err := p.SerializeStreamCoT(cot.NewEvent("a-f-G", uid))
+		require.NoError(t, err)
+		stream = append(stream, message)
+	}
+	var s EventSplitter
+
+	// Two messages and the start of a third in one read
+	data := append(append(append([]byte(nil), stream[0]...), stream[1]...), stream[2][:5]...)
+	assert.Equal(t, stream[:2], s.Feed(data))
+
+	// The rest of the third message, then XML after a protocol switch back
+	events := s.Feed(append(append([]byte(nil), stream[2][5:]...), `<event uid="4"></event>`...))
+	assert.Equal(t, [][]byte{stream[2], []byte(`<event uid="4"></event>`)}, events)
+
+	// XML followed by a TAK protocol message in the same read
+	events = s.Feed(append([]byte("<event uid=\"5\"></event>\n"), stream[0]...))
+	assert.Equal(t, [][]byte{[]byte(`<event uid="5"></event>`), stream[0]}, events)
+	assert.Empty(t, s.buf)
+
+	// A mesh message fills its datagram
+	payload, err := p.SerializeCoT(cot.NewEvent("a-f-G", "6"))
+	require.NoError(t, err)
+	mesh := append([]byte{0xbf, 0x01, 0xbf}, payload...)
+	assert.Equal(t, [][]byte{mesh}, s.Feed(mesh))
+}
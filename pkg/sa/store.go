@@ -0,0 +1,296 @@
+// Package sa keeps a live situational awareness picture: the latest CoT event for every
+// UID, expired when the event goes stale.
+package sa
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+)
+
+// TypeDelete is the type of the event ATAK sends to delete the items it links to
+const TypeDelete = "t-x-d-d"
+
+// ChangeType identifies the kind of change made to the store
+type ChangeType int
+
+const (
+	// ChangeAdded is an event for a UID that was not in the store
+	ChangeAdded ChangeType = iota
+	// ChangeUpdated is a newer event for a UID already in the store
+	ChangeUpdated
+	// ChangeRemoved is a UID removed because it went stale or was deleted
+	ChangeRemoved
+)
+
+// String returns the name of the change type
+func (c ChangeType) String() string {
+	switch c {
+	case ChangeAdded:
+		return "added"
+	case ChangeUpdated:
+		return "updated"
+	case ChangeRemoved:
+		return "removed"
+	default:
+		return "unknown"
+	}
+}
+
+// Change is a notification of a change made to the store
+type Change struct {
+	Type ChangeType
+	// Event is the new event, or the removed event for ChangeRemoved
+	Event *cot.Event
+	// Previous is the event that was replaced by an update
+	Previous *cot.Event
+}
+
+// Listener receives change notifications. Listeners are called synchronously, outside
+// the store's lock, in the order the changes were made. They may query the store but
+// must not modify it.
+type Listener func(Change)
+
+// Store holds the latest event for every UID. It is safe for concurrent use.
+type Store struct {
+	mu        sync.RWMutex
+	events    map[string]*cot.Event
+	listeners map[int]Listener
+	nextID    int
+	now       func() time.Time
+
+	// notifyMu serializes notifications so listeners see changes in order
+	notifyMu sync.Mutex
+}
+
+// NewStore creates an empty store
+func NewStore() *Store {
+	return &Store{
+		events:    make(map[string]*cot.Event),
+		listeners: make(map[int]Listener),
+		now:       time.Now,
+	}
+}
+
+// SetClock replaces the clock used to decide whether events are stale
+func (s *Store) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// Subscribe registers a listener for change notifications and returns a function that
+// unregisters it
+func (s *Store) Subscribe(l Listener) (unsubscribe func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextID
+	s.nextID++
+	s.listeners[id] = l
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.listeners, id)
+	}
+}
+
+// notify delivers changes to the current listeners
+func (s *Store) notify(changes []Change) {
+	if len(changes) == 0 {
+		return
+	}
+
+	s.mu.RLock()
+	ids := make([]int, 0, len(s.listeners))
+	for id := range s.listeners {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	listeners := make([]Listener, len(ids))
+	for i, id := range ids {
+		listeners[i] = s.listeners[id]
+	}
+	s.mu.RUnlock()
+
+	for _, c := range changes {
+		for _, l := range listeners {
+			l(c)
+		}
+	}
+}
+
+// Ingest adds an event to the store and reports whether it changed the store. Events
+// older than the one already held for the UID, and events that are already stale, are
+// ignored. A t-x-d-d event removes the items it links to.
+func (s *Store) Ingest(e *cot.Event) bool {
+	s.notifyMu.Lock()
+	defer s.notifyMu.Unlock()
+
+	if e.Type == TypeDelete {
+		changes := s.remove(deletedUIDs(e))
+		s.notify(changes)
+		return len(changes) > 0
+	}
+
+	s.mu.Lock()
+	if !e.Stale.Time().After(s.now()) {
+		s.mu.Unlock()
+		return false
+	}
+	previous, exists := s.events[e.UID]
+	if exists && previous.Time.Time().After(e.Time.Time()) {
+		s.mu.Unlock()
+		return false
+	}
+	s.events[e.UID] = e
+	s.mu.Unlock()
+
+	change := Change{Type: ChangeAdded, Event: e}
+	if exists {
+		change = Change{Type: ChangeUpdated, Event: e, Previous: previous}
+	}
+	s.notify([]Change{change})
+	return true
+}
+
+// deletedUIDs returns the UIDs a t-x-d-d event deletes
+func deletedUIDs(e *cot.Event) []string {
+	var uids []string
+	for _, link := range e.Detail.Links {
+		if link.UID != "" {
+			uids = append(uids, link.UID)
+		}
+	}
+	return uids
+}
+
+// remove deletes UIDs from the store and returns the resulting changes
+func (s *Store) remove(uids []string) []Change {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changes []Change
+	for _, uid := range uids {
+		if e, ok := s.events[uid]; ok {
+			delete(s.events, uid)
+			changes = append(changes, Change{Type: ChangeRemoved, Event: e})
+		}
+	}
+	return changes
+}
+
+// Remove deletes a UID from the store and reports whether it was present
+func (s *Store) Remove(uid string) bool {
+	s.notifyMu.Lock()
+	defer s.notifyMu.Unlock()
+
+	changes := s.remove([]string{uid})
+	s.notify(changes)
+	return len(changes) > 0
+}
+
+// Expire removes all events that are stale at now and returns them
+func (s *Store) Expire(now time.Time) []*cot.Event {
+	s.notifyMu.Lock()
+	defer s.notifyMu.Unlock()
+
+	s.mu.RLock()
+	var stale []string
+	for uid, e := range s.events {
+		if !e.Stale.Time().After(now) {
+			stale = append(stale, uid)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Strings(stale)
+
+	changes := s.remove(stale)
+	s.notify(changes)
+
+	expired := make([]*cot.Event, len(changes))
+	for i, c := range changes {
+		expired[i] = c.Event
+	}
+	return expired
+}
+
+// Run expires stale events every interval until the context is canceled
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.RLock()
+			now := s.now()
+			s.mu.RUnlock()
+			s.Expire(now)
+		}
+	}
+}
+
+// Get returns the latest event for a UID
+func (s *Store) Get(uid string) (*cot.Event, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.events[uid]
+	return e, ok
+}
+
+// Len returns the number of UIDs in the store
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.events)
+}
+
+// Query returns the events matching the predicate, ordered by UID
+func (s *Store) Query(match func(*cot.Event) bool) []*cot.Event {
+	s.mu.RLock()
+	var result []*cot.Event
+	for _, e := range s.events {
+		if match(e) {
+			result = append(result, e)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].UID < result[j].UID })
+	return result
+}
+
+// All returns all events, ordered by UID
+func (s *Store) All() []*cot.Event {
+	return s.Query(func(*cot.Event) bool { return true })
+}
+
+// ByTypePrefix returns the events whose type starts with prefix, e.g. a-f- for friendly units
+func (s *Store) ByTypePrefix(prefix string) []*cot.Event {
+	return s.Query(func(e *cot.Event) bool { return strings.HasPrefix(e.Type, prefix) })
+}
+
+// ByCallsign returns the events with the given contact callsign
+func (s *Store) ByCallsign(callsign string) []*cot.Event {
+	return s.Query(func(e *cot.Event) bool {
+		return e.Detail.Contact != nil && e.Detail.Contact.Callsign == callsign
+	})
+}
+
+// ByGroup returns the events of TAK users in the given team
+func (s *Store) ByGroup(name string) []*cot.Event {
+	return s.Query(func(e *cot.Event) bool {
+		return e.Detail.Group != nil && e.Detail.Group.Name == name
+	})
+}
+
+// InBoundingBox returns the events whose point lies inside the bounding box
+func (s *Store) InBoundingBox(bb cot.BoundingBox) []*cot.Event {
+	return s.Query(func(e *cot.Event) bool { return bb.Contains(e.Point) })
+}
@@ -0,0 +1,193 @@
+package sa
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var baseTime = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func newEvent(uid, eventType string, at time.Time, lat, lon float64) *cot.Event {
+	e := cot.NewEvent(eventType, uid)
+	e.SetTime(at).SetStart(at).SetStale(at.Add(5 * time.Minute))
+	e.SetPoint(cot.NewPoint(lat, lon))
+	return e
+}
+
+func newStore() *Store {
+	s := NewStore()
+	s.SetClock(func() time.Time { return baseTime })
+	return s
+}
+
+func TestIngestKeepsLatest(t *testing.T) {
+	s := newStore()
+	var changes []Change
+	s.Subscribe(func(c Change) { changes = append(changes, c) })
+
+	first := newEvent("u1", "a-f-G-U-C", baseTime, 1, 1)
+	newer := newEvent("u1", "a-f-G-U-C", baseTime.Add(time.Second), 2, 2)
+	older := newEvent("u1", "a-f-G-U-C", baseTime.Add(-time.Second), 3, 3)
+
+	assert.True(t, s.Ingest(first))
+	assert.True(t, s.Ingest(newer))
+	assert.False(t, s.Ingest(older), "older events are ignored")
+
+	got, ok := s.Get("u1")
+	require.True(t, ok)
+	assert.Same(t, newer, got)
+	assert.Equal(t, 1, s.Len())
+
+	require.Len(t, changes, 2)
+	assert.Equal(t, ChangeAdded, changes[0].Type)
+	assert.Same(t, first, changes[0].Event)
+	assert.Equal(t, ChangeUpdated, changes[1].Type)
+	assert.Same(t, newer, changes[1].Event)
+	assert.Same(t, first, changes[1].Previous)
+}
+
+func TestIngestIgnoresStale(t *testing.T) {
+	s := newStore()
+	e := newEvent("u1", "a-f-G-U-C", baseTime.Add(-10*time.Minute), 1, 1)
+
+	assert.False(t, s.Ingest(e))
+	assert.Equal(t, 0, s.Len())
+}
+
+func TestIngestDelete(t *testing.T) {
+	s := newStore()
+	s.Ingest(newEvent("u1", "u-d-f", baseTime, 1, 1))
+	s.Ingest(newEvent("u2", "u-d-f", baseTime, 1, 1))
+	var changes []Change
+	s.Subscribe(func(c Change) { changes = append(changes, c) })
+
+	del := newEvent("del-1", TypeDelete, baseTime, 0, 0)
+	del.Detail.AddLink(&cot.Link{UID: "u1", Type: "u-d-f", Relation: "none"})
+
+	assert.True(t, s.Ingest(del))
+	_, ok := s.Get("u1")
+	assert.False(t, ok)
+	_, ok = s.Get("del-1")
+	assert.False(t, ok, "delete events are not stored")
+	assert.Equal(t, 1, s.Len())
+	require.Len(t, changes, 1)
+	assert.Equal(t, ChangeRemoved, changes[0].Type)
+	assert.Equal(t, "u1", changes[0].Event.UID)
+}
+
+func TestRemoveAndUnsubscribe(t *testing.T) {
+	s := newStore()
+	s.Ingest(newEvent("u1", "a-f-G-U-C", baseTime, 1, 1))
+	var changes []Change
+	unsubscribe := s.Subscribe(func(c Change) { changes = append(changes, c) })
+
+	assert.True(t, s.Remove("u1"))
+	assert.False(t, s.Remove("u1"))
+	unsubscribe()
+	s.Ingest(newEvent("u2", "a-f-G-U-C", baseTime, 1, 1))
+
+	require.Len(t, changes, 1)
+	assert.Equal(t, ChangeRemoved, changes[0].Type)
+}
+
+func TestExpire(t *testing.T) {
+	s := newStore()
+	s.Ingest(newEvent("u1", "a-f-G-U-C", baseTime, 1, 1))
+	later := newEvent("u2", "a-f-G-U-C", baseTime, 1, 1)
+	later.SetStale(baseTime.Add(time.Hour))
+	s.Ingest(later)
+	var changes []Change
+	s.Subscribe(func(c Change) { changes = append(changes, c) })
+
+	expired := s.Expire(baseTime.Add(10 * time.Minute))
+
+	require.Len(t, expired, 1)
+	assert.Equal(t, "u1", expired[0].UID)
+	assert.Equal(t, 1, s.Len())
+	require.Len(t, changes, 1)
+	assert.Equal(t, ChangeRemoved, changes[0].Type)
+}
+
+func TestRun(t *testing.T) {
+	s := newStore()
+	s.Ingest(newEvent("u1", "a-f-G-U-C", baseTime, 1, 1))
+	removed := make(chan string, 1)
+	s.Subscribe(func(c Change) {
+		if c.Type == ChangeRemoved {
+			removed <- c.Event.UID
+		}
+	})
+	s.SetClock(func() time.Time { return baseTime.Add(time.Hour) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx, 10*time.Millisecond)
+
+	select {
+	case uid := <-removed:
+		assert.Equal(t, "u1", uid)
+	case <-time.After(time.Second):
+		t.Fatal("stale event was not expired")
+	}
+}
+
+func TestQueries(t *testing.T) {
+	s := newStore()
+
+	alpha := newEvent("alpha", "a-f-G-U-C", baseTime, 38.85, -77.05)
+	alpha.Detail.AddContact("ALPHA")
+	alpha.Detail.AddGroup("Cyan", "Team Member")
+	bravo := newEvent("bravo", "a-f-G-U-C", baseTime, 40.0, -75.0)
+	bravo.Detail.AddContact("BRAVO")
+	bravo.Detail.AddGroup("Red", "Team Lead")
+	hostile := newEvent("hostile", "a-h-G", baseTime, 38.86, -77.04)
+	drawing := newEvent("drawing", "u-d-f", baseTime, 38.84, -77.06)
+
+	for _, e := range []*cot.Event{drawing, hostile, bravo, alpha} {
+		require.True(t, s.Ingest(e))
+	}
+
+	uids := func(events []*cot.Event) []string {
+		var result []string
+		for _, e := range events {
+			result = append(result, e.UID)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"alpha", "bravo", "drawing", "hostile"}, uids(s.All()))
+	assert.Equal(t, []string{"alpha", "bravo"}, uids(s.ByTypePrefix("a-f-")))
+	assert.Equal(t, []string{"hostile"}, uids(s.ByTypePrefix("a-h")))
+	assert.Equal(t, []string{"bravo"}, uids(s.ByCallsign("BRAVO")))
+	assert.Empty(t, s.ByCallsign("CHARLIE"))
+	assert.Equal(t, []string{"alpha"}, uids(s.ByGroup("Cyan")))
+
+	bb := cot.BoundingBox{MinLat: 38.8, MinLon: -77.1, MaxLat: 38.9, MaxLon: -77.0}
+	assert.Equal(t, []string{"alpha", "drawing", "hostile"}, uids(s.InBoundingBox(bb)))
+}
+
+func TestConcurrentIngest(t *testing.T) {
+	s := newStore()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s.Ingest(newEvent("u1", "a-f-G-U-C", baseTime.Add(time.Duration(j)*time.Second), 1, 1))
+				s.ByTypePrefix("a-")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	got, ok := s.Get("u1")
+	require.True(t, ok)
+	assert.Equal(t, baseTime.Add(99*time.Second), got.Time.Time())
+}
@@ -0,0 +1,208 @@
+package spatial
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/angry-kivi/gotak/pkg/sa"
+)
+
+// ellipseSegments is the number of segments used to approximate elliptical areas
+const ellipseSegments = 72
+
+// Result is an event returned by a distance query
+type Result struct {
+	Event *cot.Event
+	// Distance is the distance in meters from the query point to the event's bounding box
+	Distance float64
+}
+
+// indexed is an event with the geometry used to answer queries about it
+type indexed struct {
+	event *cot.Event
+	// area is the closed outline of drawing shapes and nil for points and open lines
+	area []cot.Point
+	// radius is set for circles, which are tested exactly instead of by outline
+	radius float64
+}
+
+// Index is a spatial index of live events. Points are indexed by location and shapes by
+// the bounding box of their vertices. It is safe for concurrent use.
+type Index struct {
+	mu     sync.RWMutex
+	tree   *RTree
+	events map[string]indexed
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{
+		tree:   NewRTree(),
+		events: make(map[string]indexed),
+	}
+}
+
+// Bounds returns the bounding box of an event: the box around the vertices of shapes
+// and routes, the box around circles and ellipses, or the event point
+func Bounds(e *cot.Event) cot.BoundingBox {
+	if shape := e.Detail.Shape; shape != nil && shape.Ellipse != nil && shape.Polyline == nil {
+		return cot.BoundingBoxAround(e.Point, math.Max(shape.Ellipse.Major, shape.Ellipse.Minor))
+	}
+	if vertices, err := e.Vertices(); err == nil && len(vertices) > 0 {
+		return cot.NewBoundingBox(vertices...)
+	}
+	return cot.NewBoundingBox(e.Point)
+}
+
+// geometry returns the indexed geometry of an event
+func geometry(e *cot.Event) indexed {
+	x := indexed{event: e}
+	if shape := e.Detail.Shape; shape != nil && shape.Ellipse != nil && shape.Polyline == nil {
+		if shape.Ellipse.Major == shape.Ellipse.Minor {
+			x.radius = shape.Ellipse.Major
+		} else {
+			x.area = shape.Ellipse.Outline(e.Point, ellipseSegments)
+		}
+		return x
+	}
+
+	vertices, err := e.Vertices()
+	if err != nil || len(vertices) < 3 {
+		return x
+	}
+	first, last := vertices[0], vertices[len(vertices)-1]
+	if e.Type == cot.TypeDrawingRectangle || (first.Lat == last.Lat && first.Lon == last.Lon) {
+		x.area = vertices
+	}
+	return x
+}
+
+// Add indexes an event, replacing any event with the same UID
+func (x *Index) Add(e *cot.Event) {
+	g := geometry(e)
+	bounds := Bounds(e)
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.events[e.UID] = g
+	x.tree.Insert(e.UID, bounds)
+}
+
+// Remove removes an event from the index and reports whether it was present
+func (x *Index) Remove(uid string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	delete(x.events, uid)
+	return x.tree.Remove(uid)
+}
+
+// Get returns an indexed event
+func (x *Index) Get(uid string) (*cot.Event, bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	g, ok := x.events[uid]
+	return g.event, ok
+}
+
+// Len returns the number of indexed events
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.events)
+}
+
+// sortedEvents returns the events for the IDs ordered by UID; the caller must hold the lock
+func (x *Index) sortedEvents(ids []string, keep func(indexed) bool) []*cot.Event {
+	sort.Strings(ids)
+	var result []*cot.Event
+	for _, id := range ids {
+		if g := x.events[id]; keep == nil || keep(g) {
+			result = append(result, g.event)
+		}
+	}
+	return result
+}
+
+// InBoundingBox returns the events whose bounds intersect bb, ordered by UID
+func (x *Index) InBoundingBox(bb cot.BoundingBox) []*cot.Event {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.sortedEvents(x.tree.Search(bb), nil)
+}
+
+// Nearest returns up to k events nearest to p, nearest first
+func (x *Index) Nearest(p cot.Point, k int) []Result {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.results(x.tree.Nearest(p, k))
+}
+
+// WithinRadius returns the events within radius meters of p, nearest first
+func (x *Index) WithinRadius(p cot.Point, radius float64) []Result {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.results(x.tree.nearest(p, math.MaxInt, radius))
+}
+
+// results converts neighbors to results; the caller must hold the lock
+func (x *Index) results(neighbors []Neighbor) []Result {
+	result := make([]Result, len(neighbors))
+	for i, n := range neighbors {
+		result[i] = Result{Event: x.events[n.ID].event, Distance: n.Distance}
+	}
+	return result
+}
+
+// InPolygon returns the events whose point lies inside the polygon, ordered by UID
+func (x *Index) InPolygon(ring []cot.Point) []*cot.Event {
+	if len(ring) < 3 {
+		return nil
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	candidates := x.tree.Search(cot.NewBoundingBox(ring...))
+	return x.sortedEvents(candidates, func(g indexed) bool {
+		return cot.PolygonContains(ring, g.event.Point)
+	})
+}
+
+// Containing returns the closed shapes (circles, ellipses, rectangles and closed
+// free-form drawings) that contain p, ordered by UID
+func (x *Index) Containing(p cot.Point) []*cot.Event {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	candidates := x.tree.Search(cot.NewBoundingBox(p))
+	return x.sortedEvents(candidates, func(g indexed) bool {
+		switch {
+		case g.radius > 0:
+			return g.event.Point.DistanceTo(p) <= g.radius
+		case g.area != nil:
+			return cot.PolygonContains(g.area, p)
+		default:
+			return false
+		}
+	})
+}
+
+// Attach keeps the index in sync with a store: current events are indexed and added,
+// updated and removed events follow. It returns a function that stops following the store.
+func (x *Index) Attach(store *sa.Store) (detach func()) {
+	detach = store.Subscribe(func(c sa.Change) {
+		switch c.Type {
+		case sa.ChangeAdded, sa.ChangeUpdated:
+			x.Add(c.Event)
+		case sa.ChangeRemoved:
+			x.Remove(c.Event.UID)
+		}
+	})
+	for _, e := range store.All() {
+		// Skip events removed or replaced since they were listed; the listener has them
+		if current, ok := store.Get(e.UID); ok && current == e {
+			x.Add(e)
+		}
+	}
+	return detach
+}
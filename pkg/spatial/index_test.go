@@ -0,0 +1,147 @@
+package spatial
+
+import (
+	"testing"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/angry-kivi/gotak/pkg/sa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var center = cot.NewPoint(38.85, -77.05)
+
+func pointEvent(uid string, p cot.Point) *cot.Event {
+	e := cot.NewEvent("a-f-G-U-C", uid)
+	e.SetPoint(p)
+	return e
+}
+
+func uids(events []*cot.Event) []string {
+	var result []string
+	for _, e := range events {
+		result = append(result, e.UID)
+	}
+	return result
+}
+
+func TestBounds(t *testing.T) {
+	circle := cot.NewCircle("circle", "Circle", center, 1000, cot.DefaultDrawingStyle())
+	bb := Bounds(circle)
+	assert.True(t, bb.Contains(center.Destination(999, 0)))
+	assert.False(t, bb.Contains(center.Destination(1100, 0)))
+
+	rect := cot.NewRectangleFromBoundingBox("rect", "Rect", cot.BoundingBox{MinLat: 38.8, MinLon: -77.1, MaxLat: 38.9, MaxLon: -77.0}, cot.DefaultDrawingStyle())
+	assert.Equal(t, cot.BoundingBox{MinLat: 38.8, MinLon: -77.1, MaxLat: 38.9, MaxLon: -77.0}, Bounds(rect))
+
+	point := pointEvent("p", center)
+	assert.Equal(t, cot.NewBoundingBox(center), Bounds(point))
+}
+
+func TestIndexQueries(t *testing.T) {
+	x := NewIndex()
+	near := pointEvent("near", center.Destination(100, 0))
+	mid := pointEvent("mid", center.Destination(500, 90))
+	far := pointEvent("far", center.Destination(5000, 180))
+	circle := cot.NewCircle("circle", "Circle", center.Destination(2000, 270), 300, cot.DefaultDrawingStyle())
+	for _, e := range []*cot.Event{near, mid, far, circle} {
+		x.Add(e)
+	}
+	require.Equal(t, 4, x.Len())
+
+	t.Run("bounding box", func(t *testing.T) {
+		assert.Equal(t, []string{"mid", "near"}, uids(x.InBoundingBox(cot.BoundingBoxAround(center, 1000))))
+	})
+
+	t.Run("nearest", func(t *testing.T) {
+		results := x.Nearest(center, 3)
+		require.Len(t, results, 3)
+		assert.Equal(t, "near", results[0].Event.UID)
+		assert.Equal(t, "mid", results[1].Event.UID)
+		assert.Equal(t, "circle", results[2].Event.UID)
+		assert.InDelta(t, 100, results[0].Distance, 0.01)
+	})
+
+	t.Run("within radius", func(t *testing.T) {
+		results := x.WithinRadius(center, 600)
+		require.Len(t, results, 2)
+		assert.Equal(t, "near", results[0].Event.UID)
+		assert.Equal(t, "mid", results[1].Event.UID)
+	})
+
+	t.Run("in polygon", func(t *testing.T) {
+		triangle := []cot.Point{
+			center.Destination(1000, 0),
+			center.Destination(1000, 120),
+			center.Destination(1000, 240),
+		}
+		assert.Equal(t, []string{"mid", "near"}, uids(x.InPolygon(triangle)))
+		assert.Empty(t, x.InPolygon(triangle[:2]))
+	})
+
+	t.Run("containing", func(t *testing.T) {
+		inCircle := center.Destination(2000, 270).Destination(100, 0)
+		assert.Equal(t, []string{"circle"}, uids(x.Containing(inCircle)))
+		assert.Empty(t, x.Containing(center), "points do not contain anything")
+	})
+}
+
+func TestIndexContainingShapes(t *testing.T) {
+	x := NewIndex()
+	rect := cot.NewRectangleFromBoundingBox("rect", "Rect", cot.BoundingBoxAround(center, 500), cot.DefaultDrawingStyle())
+	polygon, err := cot.NewPolygon("polygon", "Polygon", []cot.Point{
+		center.Destination(300, 0), center.Destination(300, 120), center.Destination(300, 240),
+	}, cot.DefaultDrawingStyle())
+	require.NoError(t, err)
+	line, err := cot.NewPolyline("line", "Line", []cot.Point{
+		center.Destination(300, 0), center.Destination(300, 120), center.Destination(300, 240),
+	}, cot.DefaultDrawingStyle())
+	require.NoError(t, err)
+	ellipse := cot.NewEllipse("ellipse", "Ellipse", center, 800, 200, 90, cot.DefaultDrawingStyle())
+	for _, e := range []*cot.Event{rect, polygon, line, ellipse} {
+		x.Add(e)
+	}
+
+	assert.Equal(t, []string{"ellipse", "polygon", "rect"}, uids(x.Containing(center)))
+	assert.Equal(t, []string{"ellipse"}, uids(x.Containing(center.Destination(700, 90))))
+	assert.Equal(t, []string{"rect"}, uids(x.Containing(center.Destination(450, 0))))
+}
+
+func TestIndexRemoveAndReplace(t *testing.T) {
+	x := NewIndex()
+	x.Add(pointEvent("a", center))
+	x.Add(pointEvent("a", center.Destination(10000, 0)))
+
+	assert.Equal(t, 1, x.Len())
+	assert.Empty(t, x.InBoundingBox(cot.BoundingBoxAround(center, 100)))
+
+	assert.True(t, x.Remove("a"))
+	assert.False(t, x.Remove("a"))
+	_, ok := x.Get("a")
+	assert.False(t, ok)
+}
+
+func TestIndexAttach(t *testing.T) {
+	store := sa.NewStore()
+	existing := pointEvent("existing", center)
+	existing.SetStale(time.Now().Add(time.Hour))
+	require.True(t, store.Ingest(existing))
+
+	x := NewIndex()
+	detach := x.Attach(store)
+
+	added := pointEvent("added", center.Destination(100, 0))
+	added.SetStale(time.Now().Add(time.Hour))
+	require.True(t, store.Ingest(added))
+	assert.Equal(t, 2, x.Len())
+
+	store.Remove("existing")
+	_, ok := x.Get("existing")
+	assert.False(t, ok)
+
+	detach()
+	store.Remove("added")
+	_, ok = x.Get("added")
+	assert.True(t, ok, "detached index no longer follows the store")
+}
@@ -0,0 +1,410 @@
+// Package spatial indexes CoT events by location for bounding box, radius, polygon and
+// nearest neighbor queries.
+package spatial
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+)
+
+// Node fan-out of the R-tree
+const (
+	maxEntries = 16
+	minEntries = maxEntries * 2 / 5
+)
+
+// entry is a child node or, in a leaf, an indexed item
+type entry struct {
+	bounds cot.BoundingBox
+	child  *node
+	id     string
+}
+
+type node struct {
+	leaf    bool
+	entries []entry
+}
+
+// RTree is an R-tree of items identified by string IDs, each with a bounding box in
+// latitude and longitude. It uses quadratic splits and is not safe for concurrent use.
+type RTree struct {
+	root  *node
+	items map[string]cot.BoundingBox
+}
+
+// NewRTree creates an empty R-tree
+func NewRTree() *RTree {
+	return &RTree{
+		root:  &node{leaf: true},
+		items: make(map[string]cot.BoundingBox),
+	}
+}
+
+// Len returns the number of items in the tree
+func (t *RTree) Len() int {
+	return len(t.items)
+}
+
+// Bounds returns the bounding box of an item
+func (t *RTree) Bounds(id string) (cot.BoundingBox, bool) {
+	bb, ok := t.items[id]
+	return bb, ok
+}
+
+// Insert adds an item, replacing any item with the same ID
+func (t *RTree) Insert(id string, bounds cot.BoundingBox) {
+	if _, ok := t.items[id]; ok {
+		t.Remove(id)
+	}
+	t.items[id] = bounds
+	t.insert(entry{bounds: bounds, id: id}, t.height()-1)
+}
+
+// height returns the number of levels in the tree
+func (t *RTree) height() int {
+	h := 1
+	for n := t.root; !n.leaf; n = n.entries[0].child {
+		h++
+	}
+	return h
+}
+
+// insert adds an entry at the given level, where level 0 is the root
+func (t *RTree) insert(e entry, level int) {
+	path := []*node{t.root}
+	n := t.root
+	for depth := 0; depth < level; depth++ {
+		best := chooseSubtree(n, e.bounds)
+		n.entries[best].bounds = union(n.entries[best].bounds, e.bounds)
+		n = n.entries[best].child
+		path = append(path, n)
+	}
+	n.entries = append(n.entries, e)
+
+	// Split overflowing nodes on the way back up
+	for i := len(path) - 1; i >= 0; i-- {
+		n := path[i]
+		if len(n.entries) <= maxEntries {
+			break
+		}
+		sibling := split(n)
+		if i == 0 {
+			t.root = &node{entries: []entry{
+				{bounds: nodeBounds(n), child: n},
+				{bounds: nodeBounds(sibling), child: sibling},
+			}}
+			break
+		}
+		parent := path[i-1]
+		for j := range parent.entries {
+			if parent.entries[j].child == n {
+				parent.entries[j].bounds = nodeBounds(n)
+			}
+		}
+		parent.entries = append(parent.entries, entry{bounds: nodeBounds(sibling), child: sibling})
+	}
+}
+
+// chooseSubtree returns the entry needing the least enlargement to include bb
+func chooseSubtree(n *node, bb cot.BoundingBox) int {
+	best := 0
+	bestEnlargement, bestArea := math.Inf(1), math.Inf(1)
+	for i, e := range n.entries {
+		a := area(e.bounds)
+		enlargement := area(union(e.bounds, bb)) - a
+		if enlargement < bestEnlargement || (enlargement == bestEnlargement && a < bestArea) {
+			best, bestEnlargement, bestArea = i, enlargement, a
+		}
+	}
+	return best
+}
+
+// split moves about half the entries of an overflowing node to a new sibling using
+// Guttman's quadratic split
+func split(n *node) *node {
+	entries := n.entries
+
+	// Pick the pair of seeds that would waste the most area together
+	seedA, seedB, worst := 0, 1, math.Inf(-1)
+	for i := range entries {
+		for j := i + 1; j < len(entries); j++ {
+			d := area(union(entries[i].bounds, entries[j].bounds)) - area(entries[i].bounds) - area(entries[j].bounds)
+			if d > worst {
+				seedA, seedB, worst = i, j, d
+			}
+		}
+	}
+
+	groupA := []entry{entries[seedA]}
+	groupB := []entry{entries[seedB]}
+	boundsA, boundsB := entries[seedA].bounds, entries[seedB].bounds
+
+	remaining := make([]entry, 0, len(entries)-2)
+	for i, e := range entries {
+		if i != seedA && i != seedB {
+			remaining = append(remaining, e)
+		}
+	}
+
+	for len(remaining) > 0 {
+		// Make sure both groups reach the minimum fill
+		if len(groupA)+len(remaining) == minEntries {
+			groupA = append(groupA, remaining...)
+			break
+		}
+		if len(groupB)+len(remaining) == minEntries {
+			groupB = append(groupB, remaining...)
+			break
+		}
+
+		// Assign the entry with the strongest preference for one group
+		pick, pickDiff := 0, math.Inf(-1)
+		for i, e := range remaining {
+			dA := area(union(boundsA, e.bounds)) - area(boundsA)
+			dB := area(union(boundsB, e.bounds)) - area(boundsB)
+			if diff := math.Abs(dA - dB); diff > pickDiff {
+				pick, pickDiff = i, diff
+			}
+		}
+		e := remaining[pick]
+		remaining = append(remaining[:pick], remaining[pick+1:]...)
+
+		dA := area(union(boundsA, e.bounds)) - area(boundsA)
+		dB := area(union(boundsB, e.bounds)) - area(boundsB)
+		if dA < dB || (dA == dB && len(groupA) <= len(groupB)) {
+			groupA = append(groupA, e)
+			boundsA = union(boundsA, e.bounds)
+		} else {
+			groupB = append(groupB, e)
+			boundsB = union(boundsB, e.bounds)
+		}
+	}
+
+	n.entries = groupA
+	return &node{leaf: n.leaf, entries: groupB}
+}
+
+// Remove deletes an item and reports whether it was present
+func (t *RTree) Remove(id string) bool {
+	bb, ok := t.items[id]
+	if !ok {
+		return false
+	}
+	delete(t.items, id)
+
+	var orphans []orphan
+	t.remove(t.root, id, bb, &orphans)
+
+	// Shorten the tree while the root has a single child
+	for !t.root.leaf && len(t.root.entries) == 1 {
+		t.root = t.root.entries[0].child
+	}
+	if !t.root.leaf && len(t.root.entries) == 0 {
+		t.root = &node{leaf: true}
+	}
+
+	// Reinsert the entries of underfull nodes at their original level
+	for _, o := range orphans {
+		level := t.height() - 1 - o.heightAbove
+		if level >= 0 {
+			t.insert(o.entry, level)
+			continue
+		}
+		// The tree has become shorter than the orphaned subtree; reinsert its items
+		for _, e := range leafEntries(o.entry) {
+			t.insert(e, t.height()-1)
+		}
+	}
+	return true
+}
+
+// leafEntries returns the items in the subtree of an entry
+func leafEntries(e entry) []entry {
+	if e.child == nil {
+		return []entry{e}
+	}
+	var result []entry
+	for _, c := range e.child.entries {
+		result = append(result, leafEntries(c)...)
+	}
+	return result
+}
+
+// orphan is an entry removed from an underfull node, with its distance from the leaves
+type orphan struct {
+	entry       entry
+	heightAbove int
+}
+
+// remove deletes the item from the subtree and returns whether it was found and the
+// height of the subtree
+func (t *RTree) remove(n *node, id string, bb cot.BoundingBox, orphans *[]orphan) (found bool, height int) {
+	if n.leaf {
+		for i, e := range n.entries {
+			if e.id == id {
+				n.entries = append(n.entries[:i], n.entries[i+1:]...)
+				return true, 0
+			}
+		}
+		return false, 0
+	}
+
+	for i := 0; i < len(n.entries); i++ {
+		e := n.entries[i]
+		if !e.bounds.Intersects(bb) {
+			continue
+		}
+		found, h := t.remove(e.child, id, bb, orphans)
+		if !found {
+			continue
+		}
+		if len(e.child.entries) < minEntries {
+			for _, c := range e.child.entries {
+				*orphans = append(*orphans, orphan{entry: c, heightAbove: h})
+			}
+			n.entries = append(n.entries[:i], n.entries[i+1:]...)
+		} else {
+			n.entries[i].bounds = nodeBounds(e.child)
+		}
+		return true, h + 1
+	}
+	return false, 0
+}
+
+// Search returns the IDs of the items whose bounding box intersects bb
+func (t *RTree) Search(bb cot.BoundingBox) []string {
+	var result []string
+	var search func(n *node)
+	search = func(n *node) {
+		for _, e := range n.entries {
+			if !e.bounds.Intersects(bb) {
+				continue
+			}
+			if n.leaf {
+				result = append(result, e.id)
+			} else {
+				search(e.child)
+			}
+		}
+	}
+	search(t.root)
+	return result
+}
+
+// Neighbor is a result of a nearest neighbor query
+type Neighbor struct {
+	ID string
+	// Distance is the distance in meters from the query point to the item's bounding box
+	Distance float64
+}
+
+// Nearest returns up to k items closest to p, nearest first. Distances are measured to
+// the items' bounding boxes, so they are exact for points and a lower bound for shapes.
+func (t *RTree) Nearest(p cot.Point, k int) []Neighbor {
+	return t.nearest(p, k, math.Inf(1))
+}
+
+// nearest returns up to k items within maxDistance meters of p, nearest first
+func (t *RTree) nearest(p cot.Point, k int, maxDistance float64) []Neighbor {
+	if k <= 0 {
+		return nil
+	}
+
+	queue := &distanceQueue{}
+	heap.Push(queue, queued{node: t.root})
+	var result []Neighbor
+	for queue.Len() > 0 && len(result) < k {
+		q := heap.Pop(queue).(queued)
+		if q.distance > maxDistance {
+			break
+		}
+		if q.node == nil {
+			result = append(result, Neighbor{ID: q.id, Distance: q.distance})
+			continue
+		}
+		for _, e := range q.node.entries {
+			d := distanceToBox(p, e.bounds)
+			if q.node.leaf {
+				heap.Push(queue, queued{id: e.id, distance: d})
+			} else {
+				heap.Push(queue, queued{node: e.child, distance: d})
+			}
+		}
+	}
+	return result
+}
+
+// queued is a node or item waiting in the nearest neighbor search
+type queued struct {
+	node     *node
+	id       string
+	distance float64
+}
+
+// distanceQueue is a min-heap of queued nodes and items ordered by distance
+type distanceQueue []queued
+
+func (q distanceQueue) Len() int           { return len(q) }
+func (q distanceQueue) Less(i, j int) bool { return q[i].distance < q[j].distance }
+func (q distanceQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *distanceQueue) Push(x any)        { *q = append(*q, x.(queued)) }
+func (q *distanceQueue) Pop() any {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
+
+// distanceToBox returns a lower bound of the distance in meters from p to bb. It is exact
+// for boxes of a single point and for points within the longitudes of the box. Beside
+// the box the shortest path may run poleward of its nearest corner at high latitudes,
+// so the bound is the larger of the latitude difference and the distance to the great
+// circle of the nearest edge meridian.
+func distanceToBox(p cot.Point, bb cot.BoundingBox) float64 {
+	if bb.MinLat == bb.MaxLat && bb.MinLon == bb.MaxLon {
+		return p.DistanceTo(cot.NewPoint(bb.MinLat, bb.MinLon))
+	}
+	lat := math.Max(bb.MinLat, math.Min(bb.MaxLat, p.Lat))
+	along := 0.0
+	if lat != p.Lat {
+		along = p.DistanceTo(cot.NewPoint(lat, p.Lon))
+	}
+	if p.Lon >= bb.MinLon && p.Lon <= bb.MaxLon {
+		return along
+	}
+
+	dLon := math.Min(math.Abs(p.Lon-bb.MinLon), math.Abs(p.Lon-bb.MaxLon))
+	if dLon >= 90 {
+		return along
+	}
+	// Angles are scaled by the smallest radius of curvature of the ellipsoid
+	minRadius := cot.WGS84SemiMajorAxis * (1 - cot.WGS84Flattening) * (1 - cot.WGS84Flattening)
+	across := minRadius * math.Asin(math.Cos(p.Lat*math.Pi/180)*math.Sin(dLon*math.Pi/180))
+	return math.Max(along, across)
+}
+
+// area returns the area of a bounding box in square degrees
+func area(bb cot.BoundingBox) float64 {
+	return (bb.MaxLat - bb.MinLat) * (bb.MaxLon - bb.MinLon)
+}
+
+// union returns the smallest bounding box containing both a and b
+func union(a, b cot.BoundingBox) cot.BoundingBox {
+	return cot.BoundingBox{
+		MinLat: math.Min(a.MinLat, b.MinLat),
+		MinLon: math.Min(a.MinLon, b.MinLon),
+		MaxLat: math.Max(a.MaxLat, b.MaxLat),
+		MaxLon: math.Max(a.MaxLon, b.MaxLon),
+	}
+}
+
+// nodeBounds returns the bounding box of all entries of a node
+func nodeBounds(n *node) cot.BoundingBox {
+	bb := n.entries[0].bounds
+	for _, e := range n.entries[1:] {
+		bb = union(bb, e.bounds)
+	}
+	return bb
+}
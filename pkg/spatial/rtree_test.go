@@ -0,0 +1,160 @@
+package spatial
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// randomPoints returns n reproducible points around Washington, DC
+func randomPoints(n int) map[string]cot.Point {
+	r := rand.New(rand.NewSource(1))
+	points := make(map[string]cot.Point, n)
+	for i := 0; i < n; i++ {
+		points[fmt.Sprintf("p%04d", i)] = cot.NewPoint(38.5+r.Float64(), -77.5+r.Float64())
+	}
+	return points
+}
+
+func bruteForceSearch(points map[string]cot.Point, bb cot.BoundingBox) []string {
+	var result []string
+	for id, p := range points {
+		if bb.Contains(p) {
+			result = append(result, id)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func bruteForceNearest(points map[string]cot.Point, p cot.Point, k int) []string {
+	ids := make([]string, 0, len(points))
+	for id := range points {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return p.DistanceTo(points[ids[i]]) < p.DistanceTo(points[ids[j]])
+	})
+	if len(ids) > k {
+		ids = ids[:k]
+	}
+	return ids
+}
+
+func TestRTreeSearchMatchesBruteForce(t *testing.T) {
+	points := randomPoints(2000)
+	tree := NewRTree()
+	for id, p := range points {
+		tree.Insert(id, cot.NewBoundingBox(p))
+	}
+	require.Equal(t, len(points), tree.Len())
+
+	boxes := []cot.BoundingBox{
+		{MinLat: 38.6, MinLon: -77.4, MaxLat: 38.7, MaxLon: -77.2},
+		{MinLat: 38.9, MinLon: -77.0, MaxLat: 39.0, MaxLon: -76.9},
+		{MinLat: 40.0, MinLon: -70.0, MaxLat: 41.0, MaxLon: -69.0},
+	}
+	for _, bb := range boxes {
+		got := tree.Search(bb)
+		sort.Strings(got)
+		assert.Equal(t, bruteForceSearch(points, bb), got)
+	}
+}
+
+func TestRTreeRemove(t *testing.T) {
+	points := randomPoints(1000)
+	tree := NewRTree()
+	for id, p := range points {
+		tree.Insert(id, cot.NewBoundingBox(p))
+	}
+
+	// Remove every other point
+	for i := 0; i < 1000; i += 2 {
+		id := fmt.Sprintf("p%04d", i)
+		require.True(t, tree.Remove(id))
+		delete(points, id)
+	}
+	assert.False(t, tree.Remove("p0000"))
+	assert.Equal(t, len(points), tree.Len())
+
+	bb := cot.BoundingBox{MinLat: 38.5, MinLon: -77.5, MaxLat: 39.5, MaxLon: -76.5}
+	got := tree.Search(bb)
+	sort.Strings(got)
+	assert.Equal(t, bruteForceSearch(points, bb), got)
+
+	// Remove the rest
+	for id := range points {
+		require.True(t, tree.Remove(id))
+	}
+	assert.Equal(t, 0, tree.Len())
+	assert.Empty(t, tree.Search(bb))
+}
+
+func TestRTreeInsertReplaces(t *testing.T) {
+	tree := NewRTree()
+	tree.Insert("a", cot.NewBoundingBox(cot.NewPoint(10, 10)))
+	tree.Insert("a", cot.NewBoundingBox(cot.NewPoint(20, 20)))
+
+	assert.Equal(t, 1, tree.Len())
+	assert.Empty(t, tree.Search(cot.NewBoundingBox(cot.NewPoint(10, 10))))
+	assert.Equal(t, []string{"a"}, tree.Search(cot.NewBoundingBox(cot.NewPoint(20, 20))))
+}
+
+func TestRTreeNearestMatchesBruteForce(t *testing.T) {
+	points := randomPoints(1000)
+	tree := NewRTree()
+	for id, p := range points {
+		tree.Insert(id, cot.NewBoundingBox(p))
+	}
+
+	query := cot.NewPoint(38.9, -77.0)
+	neighbors := tree.Nearest(query, 10)
+
+	require.Len(t, neighbors, 10)
+	ids := make([]string, len(neighbors))
+	for i, n := range neighbors {
+		ids[i] = n.ID
+		assert.InDelta(t, query.DistanceTo(points[n.ID]), n.Distance, 1e-6)
+		if i > 0 {
+			assert.GreaterOrEqual(t, n.Distance, neighbors[i-1].Distance)
+		}
+	}
+	assert.Equal(t, bruteForceNearest(points, query, 10), ids)
+}
+
+func TestRTreeNearestHighLatitude(t *testing.T) {
+	// Near the pole the shortest path to a box beside the query point runs poleward of
+	// the box's nearest corner
+	r := rand.New(rand.NewSource(1))
+	points := make(map[string]cot.Point)
+	tree := NewRTree()
+	for i := 0; i < 1000; i++ {
+		id, p := fmt.Sprintf("p%04d", i), cot.NewPoint(70+r.Float64()*19.9, -180+r.Float64()*360)
+		points[id] = p
+		tree.Insert(id, cot.NewBoundingBox(p))
+	}
+
+	for i := 0; i < 100; i++ {
+		query := cot.NewPoint(75+r.Float64()*14, -180+r.Float64()*360)
+		neighbors := tree.Nearest(query, 20)
+		ids := make([]string, len(neighbors))
+		for i, n := range neighbors {
+			ids[i] = n.ID
+		}
+		assert.Equal(t, bruteForceNearest(points, query, 20), ids, "query at %.0f, %.0f", query.Lat, query.Lon)
+	}
+}
+
+func TestRTreeNearestEdgeCases(t *testing.T) {
+	tree := NewRTree()
+	assert.Empty(t, tree.Nearest(cot.NewPoint(0, 0), 5))
+
+	tree.Insert("a", cot.NewBoundingBox(cot.NewPoint(0, 0)))
+	assert.Empty(t, tree.Nearest(cot.NewPoint(0, 0), 0))
+	assert.Len(t, tree.Nearest(cot.NewPoint(0, 0), 5), 1)
+}
@@ -56,6 +56,14 @@ type ClientConfig struct {
 	CAFile        string
 	P12Password   string
 	SkipTLSVerify bool
+	// CAPassword protects a .p12 truststore given as CAFile
+	CAPassword string
+	// ServerName overrides the name the server certificate is verified against and the
+	// SNI name, for servers reached by an address their certificate does not name
+	ServerName string
+	// PinnedKeys are SHA-256 public key hashes, see util.SPKIHash, of which the server
+	// chain must contain one. Combined with SkipTLSVerify they replace CA verification.
+	PinnedKeys []string
 
 	// Timeout configurations
 	DialTimeout  time.Duration
@@ -76,20 +84,47 @@ type ClientConfig struct {
 	MulticastAddr string
 	MulticastPort int
 
+	// Filtering
+	// ReceiveFilter drops received messages it returns false for
+	ReceiveFilter MessageFilter
+	// SendFilter drops outgoing messages it returns false for instead of sending them
+	SendFilter MessageFilter
+
 	// Logging
 	Logger logrus.FieldLogger
 }
 
-// NewClient creates a new TAK client with the given configuration
+// NewClient creates a new TAK client with the given configuration. The client is wrapped
+// in a FilteredClient when a receive or send filter is configured.
 func NewClient(config ClientConfig) (Client, error) {
+	client, err := newClient(config)
+	if err != nil {
+		return nil, err
+	}
+	if config.ReceiveFilter != nil || config.SendFilter != nil {
+		return NewFilteredClient(client, config.ReceiveFilter, config.SendFilter), nil
+	}
+	return client, nil
+}
+
+// newClient creates the client for the configured connection type
+func newClient(config ClientConfig) (Client, error) {
 	switch config.ConnectionType {
 	case ConnectionTypeTCP:
 		return NewTCPClient(config)
 	case ConnectionTypeTLS:
+		// A ready TLS configuration needs no certificate files
+		if config.TLSConfig != nil {
+			return NewTLSClient(config)
+		}
+
 		// Check if any certificate files are provided
 		if config.CertFile == "" {
+			if !config.SkipTLSVerify && config.CAFile == "" && len(config.PinnedKeys) == 0 {
+				return nil, errors.New("TLS connection requires either a certificate file, a CA file, pinned keys or skip-verify option")
+			}
 			if !config.SkipTLSVerify {
-				return nil, errors.New("TLS connection requires either a certificate file or skip-verify option")
+				return NewTLSClient(config)
 			}
 
 			// Log warning if logger is provided
@@ -1,6 +1,7 @@
 package tak
 
 import (
+	"crypto/tls"
 	"testing"
 
 	"github.com/sirupsen/logrus"
@@ -37,6 +38,18 @@ func TestNewClient(t *testing.T) {
 			expectedType: "*tak.TLSClient",
 			expectError:  false,
 		},
+		{
+			name: "TLS Client with TLSConfig",
+			config: ClientConfig{
+				Address:        "127.0.0.1",
+				Port:           8089,
+				ConnectionType: ConnectionTypeTLS,
+				TLSConfig:      &tls.Config{},
+				Logger:         logrus.New(),
+			},
+			expectedType: "*tak.TLSClient",
+			expectError:  false,
+		},
 		{
 			name: "TLS Client without cert or skipverify",
 			config: ClientConfig{
@@ -0,0 +1,65 @@
+package tak
+
+import "github.com/angry-kivi/gotak/pkg/parser"
+
+// MessageFilter decides whether a message is passed on. It returns true to keep the message.
+type MessageFilter func(data []byte) bool
+
+// FilteredClient wraps a client and drops the messages that do not pass its filters.
+// Dropped outgoing messages are not sent and do not cause an error; Receive waits for
+// the next message that passes. With a receive filter, received data is cut into whole
+// CoT XML events and TAK protocol messages first, so the filter sees each event once,
+// including events split across or sharing reads of stream connections.
+type FilteredClient struct {
+	Client
+	receive MessageFilter
+	send    MessageFilter
+
+	splitter parser.EventSplitter
+	pending  [][]byte
+}
+
+// NewFilteredClient wraps a client with filters for received and sent messages.
+// A nil filter passes every message.
+func NewFilteredClient(client Client, receive, send MessageFilter) *FilteredClient {
+	return &FilteredClient{
+		Client:  client,
+		receive: receive,
+		send:    send,
+	}
+}
+
+// Send transmits data if it passes the send filter
+func (c *FilteredClient) Send(data []byte) error {
+	if c.send != nil && !c.send(data) {
+		return nil
+	}
+	return c.Client.Send(data)
+}
+
+// Receive returns the next received event that passes the receive filter. Without a
+// receive filter it returns the data of the wrapped client unchanged.
+func (c *FilteredClient) Receive() ([]byte, error) {
+	if c.receive == nil {
+		return c.Client.Receive()
+	}
+	for {
+		for len(c.pending) > 0 {
+			event := c.pending[0]
+			c.pending = c.pending[1:]
+			if c.receive(event) {
+				return event, nil
+			}
+		}
+		data, err := c.Client.Receive()
+		if err != nil {
+			return nil, err
+		}
+		c.pending = c.splitter.Feed(data)
+	}
+}
+
+// Unwrap returns the wrapped client
+func (c *FilteredClient) Unwrap() Client {
+	return c.Client
+}
@@ -0,0 +1,126 @@
+package tak
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/angry-kivi/gotak/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClient records sent messages and returns queued messages
+type fakeClient struct {
+	sent     [][]byte
+	received [][]byte
+}
+
+func (c *fakeClient) Connect(ctx context.Context) error { return nil }
+func (c *fakeClient) Disconnect() error                 { return nil }
+func (c *fakeClient) IsConnected() bool                 { return true }
+
+func (c *fakeClient) Send(data []byte) error {
+	c.sent = append(c.sent, data)
+	return nil
+}
+
+func (c *fakeClient) Receive() ([]byte, error) {
+	if len(c.received) == 0 {
+		return nil, errors.New("no more data")
+	}
+	data := c.received[0]
+	c.received = c.received[1:]
+	return data, nil
+}
+
+func noDrop(data []byte) bool {
+	return !bytes.Contains(data, []byte("drop"))
+}
+
+func TestFilteredClientSend(t *testing.T) {
+	fake := &fakeClient{}
+	client := NewFilteredClient(fake, nil, noDrop)
+
+	require.NoError(t, client.Send([]byte("keep 1")))
+	require.NoError(t, client.Send([]byte("drop")))
+	require.NoError(t, client.Send([]byte("keep 2")))
+
+	assert.Equal(t, [][]byte{[]byte("keep 1"), []byte("keep 2")}, fake.sent)
+}
+
+func TestFilteredClientReceive(t *testing.T) {
+	fake := &fakeClient{received: [][]byte{
+		[]byte(`<event uid="drop-1"></event>`),
+		[]byte(`<event uid="keep"></event>`),
+		[]byte(`<event uid="drop-2"></event>`),
+	}}
+	client := NewFilteredClient(fake, noDrop, nil)
+
+	data, err := client.Receive()
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`<event uid="keep"></event>`), data)
+
+	_, err = client.Receive()
+	assert.Error(t, err)
+	assert.Same(t, fake, client.Unwrap())
+}
+
+func TestFilteredClientReceiveReassemblesEvents(t *testing.T) {
+	p := parser.NewProtoParser()
+	keep, err := p.SerializeStreamCoT(cot.NewEvent("a-f-G", "keep-3"))
+	require.NoError(t, err)
+	drop, err := p.SerializeStreamCoT(cot.NewEvent("a-f-G", "drop-4"))
+	require.NoError(t, err)
+	stream := append(append([]byte(nil), drop...), keep...)
+
+	fake := &fakeClient{received: [][]byte{
+		// Several events in one read, the first of which is dropped
+		[]byte(`<event uid="drop-1"></event><event uid="keep-1"></event><event uid="ke`),
+		// An event split across reads
+		[]byte(`ep-2"></event>`),
+		// TAK protocol messages sharing and split across reads
+		stream[:len(drop)+3],
+		stream[len(drop)+3:],
+	}}
+	client := NewFilteredClient(fake, noDrop, nil)
+
+	var received [][]byte
+	for {
+		data, err := client.Receive()
+		if err != nil {
+			break
+		}
+		received = append(received, data)
+	}
+	assert.Equal(t, [][]byte{
+		[]byte(`<event uid="keep-1"></event>`),
+		[]byte(`<event uid="keep-2"></event>`),
+		keep,
+	}, received)
+}
+
+func TestFilteredClientReceiveWithoutFilter(t *testing.T) {
+	fake := &fakeClient{received: [][]byte{[]byte("raw data")}}
+	client := NewFilteredClient(fake, nil, nil)
+
+	data, err := client.Receive()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("raw data"), data)
+}
+
+func TestNewClientWithFilter(t *testing.T) {
+	client, err := NewClient(ClientConfig{
+		Address:        "127.0.0.1",
+		Port:           8087,
+		ConnectionType: ConnectionTypeTCP,
+		ReceiveFilter:  noDrop,
+	})
+	require.NoError(t, err)
+
+	filtered, ok := client.(*FilteredClient)
+	require.True(t, ok)
+	assert.Equal(t, "*tak.TCPClient", GetType(filtered.Unwrap()))
+}
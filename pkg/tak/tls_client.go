@@ -25,31 +25,35 @@ func NewTLSClient(config ClientConfig) (*TLSClient, error) {
 		// Check if any certificate files are provided
 		certProvided := config.CertFile != ""
 
-		// If we're not skipping verification and no cert provided, return error
-		if !config.SkipTLSVerify && !certProvided {
-			return nil, errors.New("TLS connection requires either a certificate or skip-verify option")
+		// Without a certificate, the server must be verified against a CA or pinned keys
+		// unless verification is skipped
+		if !config.SkipTLSVerify && !certProvided && config.CAFile == "" && len(config.PinnedKeys) == 0 {
+			return nil, errors.New("TLS connection requires either a certificate, a CA file, pinned keys or skip-verify option")
 		}
 
-		// If certificate file is provided, attempt to load config
-		if certProvided {
-			var err error
-
-			// Use the auto-detection function to handle both PEM and P12 formats
-			config.TLSConfig, err = util.LoadTLSConfigAuto(
-				config.CertFile,
-				config.KeyFile,
-				config.P12Password,
-				config.CAFile,
-				config.SkipTLSVerify,
-			)
-
-			if err != nil {
-				return nil, fmt.Errorf("failed to load TLS configuration: %w", err)
-			}
-		} else if config.SkipTLSVerify {
-			// Create a basic TLS config that skips verification
-			config.TLSConfig = &tls.Config{
-				InsecureSkipVerify: true,
+		var err error
+		config.TLSConfig, err = util.NewTLSConfig(util.TLSOptions{
+			CertFile:   config.CertFile,
+			KeyFile:    config.KeyFile,
+			Password:   config.P12Password,
+			CAFile:     config.CAFile,
+			CAPassword: config.CAPassword,
+			ServerName: config.ServerName,
+			Pins:       config.PinnedKeys,
+			SkipVerify: config.SkipTLSVerify,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS configuration: %w", err)
+		}
+	} else if config.ServerName != "" || len(config.PinnedKeys) > 0 {
+		// Apply the server name and pins to a copy of the provided configuration
+		config.TLSConfig = config.TLSConfig.Clone()
+		if config.ServerName != "" {
+			config.TLSConfig.ServerName = config.ServerName
+		}
+		if len(config.PinnedKeys) > 0 {
+			if err := util.ApplyPins(config.TLSConfig, config.PinnedKeys); err != nil {
+				return nil, err
 			}
 		}
 	}
@@ -78,9 +82,10 @@ func (c *TLSClient) Connect(ctx context.Context) error {
 
 	var err error
 	// Use the provided context for cancellation
-	conn, err := tls.DialWithDialer(dialer, "tcp", address, c.config.TLSConfig.Clone())
+	tlsConfig := c.config.TLSConfig.Clone()
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
 	if err != nil {
-		return err
+		return util.DiagnoseTLSError(err, tlsConfig.ServerName)
 	}
 
 	// Apply TCP_USER_TIMEOUT if configured (Linux only).
@@ -151,7 +156,8 @@ func (c *TLSClient) Receive() ([]byte, error) {
 	buffer := make([]byte, 8192) // Reasonable buffer size for CoT messages
 	n, err := c.conn.Read(buffer)
 	if err != nil {
-		return nil, err
+		// With TLS 1.3 a rejected client certificate is reported on the first read
+		return nil, util.DiagnoseTLSError(err, c.config.TLSConfig.ServerName)
 	}
 
 	return buffer[:n], nil
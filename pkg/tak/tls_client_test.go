@@ -2,11 +2,21 @@ package tak
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/angry-kivi/gotak/pkg/util"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -188,3 +198,117 @@ func TestTLSClient_RootCAConfig(t *testing.T) {
 	// Check that the root CA cert pool is preserved
 	assert.Equal(t, certPool, client.config.TLSConfig.RootCAs)
 }
+
+// startTLSServer serves a certificate for takserver.local, issued by a new CA written to
+// a PEM file, and returns the server port, the CA file and the server certificate
+func startTLSServer(t *testing.T) (int, string, *x509.Certificate) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "TAK CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serverDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "takserver"},
+		DNSNames:     []string{"takserver.local"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, &serverKey.PublicKey, caKey)
+	require.NoError(t, err)
+	server, err := x509.ParseCertificate(serverDER)
+	require.NoError(t, err)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverDER}, PrivateKey: serverKey}},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0600))
+	return listener.Addr().(*net.TCPAddr).Port, caFile, server
+}
+
+func TestTLSClient_ServerVerification(t *testing.T) {
+	port, caFile, server := startTLSServer(t)
+
+	tests := []struct {
+		name   string
+		config ClientConfig
+		errMsg string
+	}{
+		{
+			name:   "Server name mismatch",
+			config: ClientConfig{CAFile: caFile},
+			errMsg: `valid for takserver.local, not "127.0.0.1"`,
+		},
+		{
+			name:   "Server name override",
+			config: ClientConfig{CAFile: caFile, ServerName: "takserver.local"},
+		},
+		{
+			name:   "Unknown CA",
+			config: ClientConfig{PinnedKeys: []string{util.SPKIHash(server)}, ServerName: "takserver.local"},
+			errMsg: `issued by "CN=TAK CA", which is not a trusted CA`,
+		},
+		{
+			name:   "Pinned key replaces CA verification",
+			config: ClientConfig{SkipTLSVerify: true, PinnedKeys: []string{util.SPKIHash(server)}},
+		},
+		{
+			name:   "Pinned key mismatch",
+			config: ClientConfig{SkipTLSVerify: true, PinnedKeys: []string{"sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}},
+			errMsg: "does not match any pinned public key",
+		},
+		{
+			name:   "CA and pin",
+			config: ClientConfig{CAFile: caFile, ServerName: "takserver.local", PinnedKeys: []string{util.SPKIHash(server)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Address = "127.0.0.1"
+			tt.config.Port = port
+			tt.config.ConnectionType = ConnectionTypeTLS
+			tt.config.DialTimeout = 5 * time.Second
+			client, err := NewTLSClient(tt.config)
+			require.NoError(t, err)
+
+			err = client.Connect(context.Background())
+			if tt.errMsg == "" {
+				require.NoError(t, err)
+				client.Disconnect()
+				return
+			}
+			require.Error(t, err)
+			var verificationErr *util.VerificationError
+			assert.ErrorAs(t, err, &verificationErr)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
@@ -0,0 +1,377 @@
+package track
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+)
+
+// HowFused is the how of events fused from several machine reports
+const HowFused = "m-f"
+
+// CorrelatorConfig controls when reports are treated as the same item
+type CorrelatorConfig struct {
+	// MaxDistance is the gate in meters added to the circular errors of two reports
+	MaxDistance float64
+	// MaxTimeGap is the longest time between reports of the same item; older source
+	// reports are dropped from a fused track
+	MaxTimeGap time.Duration
+	// DefaultCE is the circular error in meters assumed for reports without one
+	DefaultCE float64
+	// UIDPrefix is prepended to the UID of the first source to form the fused UID. A
+	// number is appended when another track already has that UID.
+	UIDPrefix string
+	// StaleTime is how long fused events stay valid
+	StaleTime time.Duration
+}
+
+// DefaultCorrelatorConfig returns a configuration suited to ground vehicles
+func DefaultCorrelatorConfig() CorrelatorConfig {
+	return CorrelatorConfig{
+		MaxDistance: 50,
+		MaxTimeGap:  30 * time.Second,
+		DefaultCE:   25,
+		UIDPrefix:   "fused-",
+		StaleTime:   2 * time.Minute,
+	}
+}
+
+// FusedTrack is a cluster of reports from different sources that describe one item
+type FusedTrack struct {
+	UID string
+	// Sources holds the latest report of every source UID in the cluster
+	Sources map[string]*cot.Event
+}
+
+// SourceUIDs returns the UIDs of the reports in the track, sorted
+func (f *FusedTrack) SourceUIDs() []string {
+	uids := make([]string, 0, len(f.Sources))
+	for uid := range f.Sources {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	return uids
+}
+
+// latest returns the most recent source report
+func (f *FusedTrack) latest() *cot.Event {
+	var latest *cot.Event
+	for _, uid := range f.SourceUIDs() {
+		if e := f.Sources[uid]; latest == nil || e.Time.Time().After(latest.Time.Time()) {
+			latest = e
+		}
+	}
+	return latest
+}
+
+// latestTrack returns the track detail of the most recent source report that has one
+func (f *FusedTrack) latestTrack() *cot.Track {
+	var latest *cot.Event
+	for _, uid := range f.SourceUIDs() {
+		e := f.Sources[uid]
+		if e.Detail.Track != nil && (latest == nil || e.Time.Time().After(latest.Time.Time())) {
+			latest = e
+		}
+	}
+	if latest == nil {
+		return nil
+	}
+	return latest.Detail.Track
+}
+
+// Correlator clusters reports of the same item made by different sensors under
+// different UIDs. It is safe for concurrent use.
+type Correlator struct {
+	mu     sync.Mutex
+	config CorrelatorConfig
+	tracks map[string]*FusedTrack
+	// bySource maps a source UID to the UID of its fused track
+	bySource map[string]string
+}
+
+// NewCorrelator creates a correlator
+func NewCorrelator(config CorrelatorConfig) *Correlator {
+	return &Correlator{
+		config:   config,
+		tracks:   make(map[string]*FusedTrack),
+		bySource: make(map[string]string),
+	}
+}
+
+// Correlate assigns a report to the fused track of the item it describes, creating a
+// new track when it matches none, and returns the track's fused event. Reports that
+// match no other source return a fused event with a single source.
+func (c *Correlator) Correlate(e *cot.Event) *cot.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A source that no longer matches its cluster is split off
+	if uid, ok := c.bySource[e.UID]; ok {
+		c.removeSource(uid, e.UID)
+	}
+
+	best, bestScore := (*FusedTrack)(nil), math.Inf(1)
+	for _, uid := range c.trackUIDs() {
+		f := c.tracks[uid]
+		c.prune(f, e.Time.Time())
+		if len(f.Sources) == 0 {
+			delete(c.tracks, uid)
+			continue
+		}
+		if score, ok := c.match(f, e); ok && score < bestScore {
+			best, bestScore = f, score
+		}
+	}
+
+	if best == nil {
+		best = &FusedTrack{UID: c.newTrackUID(e.UID), Sources: make(map[string]*cot.Event)}
+		c.tracks[best.UID] = best
+	}
+	best.Sources[e.UID] = e
+	c.bySource[e.UID] = best.UID
+	return c.fuse(best)
+}
+
+// newTrackUID returns an unused fused UID for a track first reported by source. The
+// plain name can still belong to the track the source was split off from.
+func (c *Correlator) newTrackUID(source string) string {
+	uid := c.config.UIDPrefix + source
+	for i := 2; ; i++ {
+		if _, taken := c.tracks[uid]; !taken {
+			return uid
+		}
+		uid = c.config.UIDPrefix + source + "-" + strconv.Itoa(i)
+	}
+}
+
+// removeSource drops a source from its fused track, and the track once it is empty
+func (c *Correlator) removeSource(uid, source string) {
+	delete(c.bySource, source)
+	f, ok := c.tracks[uid]
+	if !ok {
+		return
+	}
+	delete(f.Sources, source)
+	if len(f.Sources) == 0 {
+		delete(c.tracks, uid)
+	}
+}
+
+// trackUIDs returns the fused track UIDs, sorted so matching is deterministic
+func (c *Correlator) trackUIDs() []string {
+	uids := make([]string, 0, len(c.tracks))
+	for uid := range c.tracks {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	return uids
+}
+
+// prune drops source reports older than the time gap
+func (c *Correlator) prune(f *FusedTrack, now time.Time) {
+	for uid, src := range f.Sources {
+		if now.Sub(src.Time.Time()) > c.config.MaxTimeGap {
+			delete(f.Sources, uid)
+			delete(c.bySource, uid)
+		}
+	}
+}
+
+// match reports whether a report gates with every source of a track and returns its
+// mean normalized distance to them, lower being a better match
+func (c *Correlator) match(f *FusedTrack, e *cot.Event) (float64, bool) {
+	at := e.Time.Time()
+	ce := c.ce(e.Point)
+
+	var score float64
+	for _, src := range f.Sources {
+		if !TypesCompatible(src.Type, e.Type) {
+			return 0, false
+		}
+		gap := at.Sub(src.Time.Time())
+		if gap < 0 {
+			gap = -gap
+		}
+		if gap > c.config.MaxTimeGap {
+			return 0, false
+		}
+
+		// Compare against where the source is expected to be now
+		predicted := DeadReckon(SampleFromEvent(src), at)
+		predicted.Hae, predicted.Le = src.Point.Hae, src.Point.Le
+		gate := ce + c.ce(src.Point) + c.config.MaxDistance
+		if predicted.DistanceTo(e.Point) > gate {
+			return 0, false
+		}
+		// Heights must also agree within their linear errors
+		if !predicted.WithinError(e.Point, c.config.MaxDistance) {
+			return 0, false
+		}
+		score += predicted.DistanceTo(e.Point) / gate
+	}
+	return score / float64(len(f.Sources)), true
+}
+
+// ce returns the circular error of a point, or the configured default
+func (c *Correlator) ce(p cot.Point) float64 {
+	if ce, ok := knownValue(p.Ce); ok {
+		return ce
+	}
+	return c.config.DefaultCE
+}
+
+// fuse builds the event of a fused track. The position is the error weighted mean of
+// the sources dead-reckoned to the latest report, and its error shrinks accordingly.
+func (c *Correlator) fuse(f *FusedTrack) *cot.Event {
+	latest := f.latest()
+	at := latest.Time.Time()
+
+	var east, north, weights float64
+	var hae, haeWeights float64
+	origin := latest.Point
+	eventType := ""
+	for _, uid := range f.SourceUIDs() {
+		src := f.Sources[uid]
+		p := DeadReckon(SampleFromEvent(src), at)
+		ce := math.Max(c.ce(src.Point), 1)
+		w := 1 / (ce * ce)
+		x, y := toPlane(origin, Sample{Point: p})
+		east, north, weights = east+w*x, north+w*y, weights+w
+
+		if h, ok := knownValue(p.Hae); ok {
+			le := 1.0
+			if v, ok := knownValue(src.Point.Le); ok {
+				le = math.Max(v, 1)
+			}
+			hae, haeWeights = hae+h/(le*le), haeWeights+1/(le*le)
+		}
+		eventType = moreSpecificType(eventType, src.Type)
+	}
+
+	point := fromPlane(origin, Sample{}, east/weights, north/weights)
+	point.SetCe(math.Sqrt(1 / weights))
+	if haeWeights > 0 {
+		point.SetHae(hae / haeWeights)
+		point.SetLe(math.Sqrt(1 / haeWeights))
+	}
+
+	e := cot.NewEvent(eventType, f.UID)
+	e.SetTime(at).SetStart(at).SetStale(at.Add(c.config.StaleTime))
+	e.How = HowFused
+	e.SetPoint(point)
+	if latest.Detail.Contact != nil {
+		e.Detail.AddContact(latest.Detail.Contact.Callsign)
+	}
+	if track := f.latestTrack(); track != nil {
+		e.Detail.AddTrack().SetCourse(track.Course).SetSpeed(track.Speed)
+	}
+	for _, uid := range f.SourceUIDs() {
+		src := f.Sources[uid]
+		callsign := ""
+		if src.Detail.Contact != nil {
+			callsign = src.Detail.Contact.Callsign
+		}
+		e.Detail.AddParentLink(uid, src.Type, callsign, src.Time.Time())
+	}
+	return e
+}
+
+// Tracks returns the fused tracks with more than one source, ordered by UID
+func (c *Correlator) Tracks() []*FusedTrack {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result []*FusedTrack
+	for _, uid := range c.trackUIDs() {
+		if f := c.tracks[uid]; len(f.Sources) > 1 {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// TrackOf returns the UID of the fused track a source UID belongs to
+func (c *Correlator) TrackOf(source string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	uid, ok := c.bySource[source]
+	return uid, ok
+}
+
+// Remove forgets a source UID, for example when it is deleted or goes stale
+func (c *Correlator) Remove(source string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if uid, ok := c.bySource[source]; ok {
+		c.removeSource(uid, source)
+	}
+}
+
+// affiliation returns the affiliation letter of an atom type such as a-f-G, and
+// whether the type is an atom
+func affiliation(eventType string) (string, bool) {
+	parts := strings.Split(eventType, "-")
+	if len(parts) < 2 || parts[0] != "a" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// affiliationGroups maps an affiliation to the side it belongs to. Assumed friends
+// count as friends and suspects, jokers and fakers as hostile.
+var affiliationGroups = map[string]string{
+	"f": "f", "a": "f",
+	"h": "h", "s": "h", "j": "h", "k": "h",
+	"n": "n",
+}
+
+// TypesCompatible reports whether two atom types may describe the same item. Their
+// affiliations must be on the same side, unless one is unknown or pending, and their
+// type hierarchies must agree as far as both go, e.g. a-f-G-U-C and a-u-G.
+func TypesCompatible(a, b string) bool {
+	affA, okA := affiliation(a)
+	affB, okB := affiliation(b)
+	if !okA || !okB {
+		return false
+	}
+	groupA, knownA := affiliationGroups[affA]
+	groupB, knownB := affiliationGroups[affB]
+	if knownA && knownB && groupA != groupB {
+		return false
+	}
+
+	partsA := strings.Split(a, "-")[2:]
+	partsB := strings.Split(b, "-")[2:]
+	for i := 0; i < len(partsA) && i < len(partsB); i++ {
+		if partsA[i] != partsB[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// moreSpecificType returns the type that says more about an item: a known affiliation
+// beats an unknown one, then the deeper type hierarchy wins
+func moreSpecificType(a, b string) string {
+	if a == "" {
+		return b
+	}
+	affA, _ := affiliation(a)
+	affB, _ := affiliation(b)
+	_, knownA := affiliationGroups[affA]
+	_, knownB := affiliationGroups[affB]
+	if knownA != knownB {
+		if knownA {
+			return a
+		}
+		return b
+	}
+	if strings.Count(b, "-") > strings.Count(a, "-") {
+		return b
+	}
+	return a
+}
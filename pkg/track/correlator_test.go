@@ -0,0 +1,146 @@
+package track
+
+import (
+	"testing"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func report(uid, eventType string, seconds int, p cot.Point, ce float64) *cot.Event {
+	e := cot.NewEvent(eventType, uid)
+	e.SetTime(start.Add(time.Duration(seconds) * time.Second))
+	p.SetCe(ce)
+	e.SetPoint(p)
+	return e
+}
+
+func TestTypesCompatible(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"a-f-G-U-C", "a-f-G-U-C", true},
+		{"a-f-G-U-C", "a-u-G", true},
+		{"a-p-G", "a-h-G-E-V", true},
+		{"a-f-G", "a-a-G-U", true},
+		{"a-h-G", "a-s-G", true},
+		{"a-f-G", "a-h-G", false},
+		{"a-n-G", "a-f-G", false},
+		{"a-f-G", "a-f-A", false},
+		{"a-f-G-U-C", "a-f-G-E-V", false},
+		{"b-m-r", "a-f-G", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, TypesCompatible(tt.a, tt.b), "%s / %s", tt.a, tt.b)
+	}
+}
+
+func TestCorrelatorFusesDuplicateReports(t *testing.T) {
+	c := NewCorrelator(DefaultCorrelatorConfig())
+
+	first := c.Correlate(report("radar-1", "a-u-G", 0, origin, 20))
+	assert.Equal(t, "fused-radar-1", first.UID)
+	assert.Len(t, first.Detail.Links, 1)
+
+	nearby := origin.Destination(30, 90)
+	fused := c.Correlate(report("uav-7", "a-h-G-E-V", 2, nearby, 10))
+
+	assert.Equal(t, "fused-radar-1", fused.UID)
+	assert.Equal(t, "a-h-G-E-V", fused.Type, "the more specific type wins")
+	assert.Equal(t, HowFused, fused.How)
+	assert.Equal(t, start.Add(2*time.Second), fused.Time.Time())
+
+	// The fused position is weighted toward the more precise report
+	assert.Less(t, fused.Point.DistanceTo(nearby), fused.Point.DistanceTo(origin))
+	require.NotNil(t, fused.Point.Ce)
+	assert.Less(t, *fused.Point.Ce, 10.0)
+
+	require.Len(t, fused.Detail.Links, 2)
+	assert.Equal(t, "radar-1", fused.Detail.Links[0].UID)
+	assert.Equal(t, "a-u-G", fused.Detail.Links[0].Type)
+	assert.Equal(t, "p-p", fused.Detail.Links[0].Relation)
+	assert.Equal(t, "uav-7", fused.Detail.Links[1].UID)
+
+	tracks := c.Tracks()
+	require.Len(t, tracks, 1)
+	assert.Equal(t, []string{"radar-1", "uav-7"}, tracks[0].SourceUIDs())
+	uid, ok := c.TrackOf("uav-7")
+	require.True(t, ok)
+	assert.Equal(t, "fused-radar-1", uid)
+}
+
+func TestCorrelatorKeepsSeparateItemsApart(t *testing.T) {
+	c := NewCorrelator(DefaultCorrelatorConfig())
+	c.Correlate(report("a", "a-f-G", 0, origin, 10))
+
+	tests := []struct {
+		name  string
+		event *cot.Event
+	}{
+		{"too far", report("far", "a-f-G", 1, origin.Destination(500, 0), 10)},
+		{"incompatible affiliation", report("hostile", "a-h-G", 1, origin, 10)},
+		{"too late", report("late", "a-f-G", 120, origin, 10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := c.Correlate(tt.event)
+			assert.Equal(t, "fused-"+tt.event.UID, e.UID)
+		})
+	}
+	assert.Empty(t, c.Tracks())
+}
+
+func TestCorrelatorDeadReckonsSources(t *testing.T) {
+	c := NewCorrelator(DefaultCorrelatorConfig())
+	moving := report("radar-1", "a-f-G", 0, origin, 5)
+	moving.Detail.AddTrack().SetCourse(90).SetSpeed(20)
+	c.Correlate(moving)
+
+	// Ten seconds later the vehicle is 200 m east, too far without dead reckoning
+	fused := c.Correlate(report("uav-7", "a-f-G", 10, origin.Destination(200, 90), 5))
+	assert.Equal(t, "fused-radar-1", fused.UID)
+	assert.InDelta(t, 200, origin.DistanceTo(fused.Point), 1)
+	require.NotNil(t, fused.Detail.Track)
+}
+
+func TestCorrelatorSplitsAndRemoves(t *testing.T) {
+	c := NewCorrelator(DefaultCorrelatorConfig())
+	c.Correlate(report("a", "a-f-G", 0, origin, 10))
+	c.Correlate(report("b", "a-f-G", 1, origin, 10))
+	require.Len(t, c.Tracks(), 1)
+
+	// b moves away and leaves the cluster
+	moved := c.Correlate(report("b", "a-f-G", 2, origin.Destination(1000, 0), 10))
+	assert.Equal(t, "fused-b", moved.UID)
+	assert.Empty(t, c.Tracks())
+
+	c.Remove("a")
+	_, ok := c.TrackOf("a")
+	assert.False(t, ok)
+	c.Remove("unknown")
+}
+
+func TestCorrelatorSplitKeepsClusterUID(t *testing.T) {
+	c := NewCorrelator(DefaultCorrelatorConfig())
+	c.Correlate(report("a", "a-f-G", 0, origin, 10))
+	fused := c.Correlate(report("b", "a-f-G", 1, origin, 10))
+	require.Equal(t, "fused-a", fused.UID)
+
+	// a leaves the cluster that was named after it, which b still holds
+	moved := c.Correlate(report("a", "a-f-G", 2, origin.Destination(111000, 90), 10))
+	assert.NotEqual(t, "fused-a", moved.UID)
+	uid, ok := c.TrackOf("b")
+	require.True(t, ok)
+	assert.Equal(t, "fused-a", uid)
+
+	// Removing a leaves b's track in place
+	c.Remove("a")
+	var remaining *cot.Event
+	require.NotPanics(t, func() { remaining = c.Correlate(report("b", "a-f-G", 3, origin, 10)) })
+	uid, ok = c.TrackOf("b")
+	require.True(t, ok)
+	assert.Equal(t, remaining.UID, uid)
+}
@@ -0,0 +1,309 @@
+// Package track keeps the recent position history of moving CoT items and estimates
+// where they are between and after reports.
+package track
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/angry-kivi/gotak/pkg/sa"
+)
+
+// DefaultCapacity is the number of samples kept per UID by default
+const DefaultCapacity = 64
+
+var (
+	// ErrUnknownUID is returned for a UID without history
+	ErrUnknownUID = errors.New("no history for uid")
+	// ErrBeforeHistory is returned for a time before the oldest sample
+	ErrBeforeHistory = errors.New("time is before the oldest sample")
+	// ErrBeyondDeadReckoning is returned for a time further past the latest sample than
+	// the history will dead-reckon
+	ErrBeyondDeadReckoning = errors.New("time is beyond the dead reckoning limit")
+)
+
+// Sample is a reported position of an item
+type Sample struct {
+	Time  time.Time
+	Point cot.Point
+	// Course is the direction of travel in degrees from true north
+	Course float64
+	// Speed is the ground speed in meters per second
+	Speed float64
+	// Slope is the vertical path angle in degrees
+	Slope float64
+	// HasVelocity is set when course and speed were reported in a track detail
+	HasVelocity bool
+}
+
+// SampleFromEvent returns the sample reported by an event. The track timestamp is used
+// when present, otherwise the event time.
+func SampleFromEvent(e *cot.Event) Sample {
+	s := Sample{Time: e.Time.Time(), Point: e.Point}
+	if t := e.Detail.Track; t != nil {
+		s.Course, s.Speed, s.Slope = t.Course, t.Speed, t.Slope
+		s.HasVelocity = true
+		if !t.TimeStamp.IsZero() {
+			s.Time = t.TimeStamp
+		}
+	}
+	return s
+}
+
+// ring is a fixed size buffer of samples ordered by time
+type ring struct {
+	samples []Sample
+	start   int
+	n       int
+}
+
+func newRing(capacity int) *ring {
+	return &ring{samples: make([]Sample, capacity)}
+}
+
+// at returns the i-th oldest sample
+func (r *ring) at(i int) Sample {
+	return r.samples[(r.start+i)%len(r.samples)]
+}
+
+func (r *ring) set(i int, s Sample) {
+	r.samples[(r.start+i)%len(r.samples)] = s
+}
+
+// add inserts a sample in time order, dropping the oldest sample when full. It reports
+// false for a sample older than all held samples of a full buffer or with the same time
+// as a held sample.
+func (r *ring) add(s Sample) bool {
+	// Find the insert position, normally the end
+	i := r.n
+	for i > 0 && r.at(i-1).Time.After(s.Time) {
+		i--
+	}
+	if i > 0 && r.at(i-1).Time.Equal(s.Time) {
+		return false
+	}
+
+	if r.n == len(r.samples) {
+		if i == 0 {
+			return false
+		}
+		// Drop the oldest sample to make room
+		r.start = (r.start + 1) % len(r.samples)
+		r.n--
+		i--
+	}
+	for j := r.n; j > i; j-- {
+		r.set(j, r.at(j-1))
+	}
+	r.set(i, s)
+	r.n++
+	return true
+}
+
+// slice returns the samples, oldest first
+func (r *ring) slice() []Sample {
+	result := make([]Sample, r.n)
+	for i := range result {
+		result[i] = r.at(i)
+	}
+	return result
+}
+
+// History stores the latest positions of every UID in a ring buffer. It is safe for
+// concurrent use.
+type History struct {
+	mu            sync.RWMutex
+	capacity      int
+	maxDeadReckon time.Duration
+	tracks        map[string]*ring
+}
+
+// NewHistory creates a history keeping up to capacity samples per UID. A capacity below
+// 2 uses DefaultCapacity.
+func NewHistory(capacity int) *History {
+	if capacity < 2 {
+		capacity = DefaultCapacity
+	}
+	return &History{
+		capacity: capacity,
+		tracks:   make(map[string]*ring),
+	}
+}
+
+// SetMaxDeadReckon limits how far past the latest sample PositionAt will dead-reckon.
+// Zero, the default, places no limit.
+func (h *History) SetMaxDeadReckon(d time.Duration) *History {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.maxDeadReckon = d
+	return h
+}
+
+// Add records a sample for a UID and reports whether it was kept
+func (h *History) Add(uid string, s Sample) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r, ok := h.tracks[uid]
+	if !ok {
+		r = newRing(h.capacity)
+		h.tracks[uid] = r
+	}
+	return r.add(s)
+}
+
+// Record records the position reported by an event
+func (h *History) Record(e *cot.Event) bool {
+	return h.Add(e.UID, SampleFromEvent(e))
+}
+
+// Remove forgets the history of a UID
+func (h *History) Remove(uid string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.tracks, uid)
+}
+
+// UIDs returns the UIDs with history, sorted
+func (h *History) UIDs() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	uids := make([]string, 0, len(h.tracks))
+	for uid := range h.tracks {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	return uids
+}
+
+// Samples returns the samples held for a UID, oldest first
+func (h *History) Samples(uid string) []Sample {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	r, ok := h.tracks[uid]
+	if !ok {
+		return nil
+	}
+	return r.slice()
+}
+
+// Latest returns the most recent sample of a UID
+func (h *History) Latest(uid string) (Sample, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	r, ok := h.tracks[uid]
+	if !ok || r.n == 0 {
+		return Sample{}, false
+	}
+	return r.at(r.n - 1), true
+}
+
+// PositionAt estimates the position of a UID at t. Between samples the position is
+// interpolated along the geodesic; after the latest sample it is dead-reckoned.
+func (h *History) PositionAt(uid string, t time.Time) (cot.Point, error) {
+	h.mu.RLock()
+	r, ok := h.tracks[uid]
+	if !ok || r.n == 0 {
+		h.mu.RUnlock()
+		return cot.Point{}, ErrUnknownUID
+	}
+	samples := r.slice()
+	maxDeadReckon := h.maxDeadReckon
+	h.mu.RUnlock()
+
+	if t.Before(samples[0].Time) {
+		return cot.Point{}, ErrBeforeHistory
+	}
+
+	// Find the first sample at or after t
+	i := sort.Search(len(samples), func(i int) bool { return !samples[i].Time.Before(t) })
+	if i < len(samples) {
+		if samples[i].Time.Equal(t) {
+			return samples[i].Point, nil
+		}
+		return Interpolate(samples[i-1], samples[i], t), nil
+	}
+
+	last := samples[len(samples)-1]
+	if maxDeadReckon > 0 && t.Sub(last.Time) > maxDeadReckon {
+		return cot.Point{}, ErrBeyondDeadReckoning
+	}
+	if !last.HasVelocity && len(samples) > 1 {
+		last = withEstimatedVelocity(samples[len(samples)-2], last)
+	}
+	return DeadReckon(last, t), nil
+}
+
+// Interpolate returns the position at t on the geodesic between two samples. The hae is
+// interpolated linearly when both samples have one.
+func Interpolate(a, b Sample, t time.Time) cot.Point {
+	span := b.Time.Sub(a.Time)
+	if span <= 0 {
+		return b.Point
+	}
+	fraction := float64(t.Sub(a.Time)) / float64(span)
+
+	distance, bearing, _ := cot.Inverse(a.Point, b.Point)
+	p := a.Point.Destination(distance*fraction, bearing)
+	p.Hae, p.Ce, p.Le = nil, nil, nil
+	if h1, ok := knownValue(a.Point.Hae); ok {
+		if h2, ok := knownValue(b.Point.Hae); ok {
+			p.SetHae(h1 + (h2-h1)*fraction)
+		}
+	}
+	return p
+}
+
+// DeadReckon projects a sample to t along its course at its speed. A known hae changes
+// with the slope. Samples without velocity stay where they were reported.
+func DeadReckon(s Sample, t time.Time) cot.Point {
+	if !s.HasVelocity || s.Speed == 0 {
+		return s.Point
+	}
+	distance := s.Speed * t.Sub(s.Time).Seconds()
+	p := s.Point.Destination(distance, s.Course)
+	if hae, ok := knownValue(s.Point.Hae); ok && s.Slope != 0 {
+		p.SetHae(hae + distance*math.Tan(s.Slope*math.Pi/180))
+	}
+	return p
+}
+
+// withEstimatedVelocity returns b with the course and speed of the move from a to b
+func withEstimatedVelocity(a, b Sample) Sample {
+	seconds := b.Time.Sub(a.Time).Seconds()
+	if seconds <= 0 {
+		return b
+	}
+	distance, _, final := cot.Inverse(a.Point, b.Point)
+	b.Course, b.Speed = final, distance/seconds
+	b.HasVelocity = true
+	return b
+}
+
+// knownValue returns an optional point attribute and whether it is known
+func knownValue(v *float64) (float64, bool) {
+	if v == nil || *v >= cot.DefaultValue {
+		return 0, false
+	}
+	return *v, true
+}
+
+// Attach records every event added to or updated in a store, and forgets UIDs removed
+// from it. It returns a function that stops following the store.
+func (h *History) Attach(store *sa.Store) (detach func()) {
+	detach = store.Subscribe(func(c sa.Change) {
+		switch c.Type {
+		case sa.ChangeAdded, sa.ChangeUpdated:
+			h.Record(c.Event)
+		case sa.ChangeRemoved:
+			h.Remove(c.Event.UID)
+		}
+	})
+	for _, e := range store.All() {
+		h.Record(e)
+	}
+	return detach
+}
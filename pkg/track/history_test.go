@@ -0,0 +1,187 @@
+package track
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+	"github.com/angry-kivi/gotak/pkg/sa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	start  = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	origin = cot.NewPoint(38.85, -77.05)
+)
+
+func sampleAt(seconds int, p cot.Point) Sample {
+	return Sample{Time: start.Add(time.Duration(seconds) * time.Second), Point: p}
+}
+
+func TestSampleFromEvent(t *testing.T) {
+	e := cot.NewEvent("a-f-G-U-C", "unit")
+	e.SetTime(start)
+	e.SetPoint(origin)
+
+	s := SampleFromEvent(e)
+	assert.Equal(t, start, s.Time)
+	assert.False(t, s.HasVelocity)
+
+	e.Detail.AddTrack().SetCourse(90).SetSpeed(10).SetTimeStamp(start.Add(-time.Second))
+	s = SampleFromEvent(e)
+	assert.True(t, s.HasVelocity)
+	assert.Equal(t, 90.0, s.Course)
+	assert.Equal(t, 10.0, s.Speed)
+	assert.Equal(t, start.Add(-time.Second), s.Time)
+}
+
+func TestHistoryRingBuffer(t *testing.T) {
+	h := NewHistory(3)
+	for i := 0; i < 5; i++ {
+		require.True(t, h.Add("a", sampleAt(i*10, origin)))
+	}
+
+	samples := h.Samples("a")
+	require.Len(t, samples, 3)
+	assert.Equal(t, start.Add(20*time.Second), samples[0].Time)
+	assert.Equal(t, start.Add(40*time.Second), samples[2].Time)
+
+	// Out of order samples are inserted in order, older than the buffer are dropped
+	assert.True(t, h.Add("a", sampleAt(35, origin)))
+	assert.False(t, h.Add("a", sampleAt(5, origin)))
+	assert.False(t, h.Add("a", sampleAt(40, origin)), "duplicate time")
+
+	samples = h.Samples("a")
+	require.Len(t, samples, 3)
+	assert.Equal(t, []time.Time{
+		start.Add(30 * time.Second), start.Add(35 * time.Second), start.Add(40 * time.Second),
+	}, []time.Time{samples[0].Time, samples[1].Time, samples[2].Time})
+
+	latest, ok := h.Latest("a")
+	require.True(t, ok)
+	assert.Equal(t, start.Add(40*time.Second), latest.Time)
+
+	assert.Equal(t, []string{"a"}, h.UIDs())
+	h.Remove("a")
+	assert.Empty(t, h.UIDs())
+	_, ok = h.Latest("a")
+	assert.False(t, ok)
+}
+
+func TestPositionAtInterpolates(t *testing.T) {
+	h := NewHistory(0)
+	a, b := origin, origin.Destination(1000, 45)
+	a.SetHae(100)
+	b.SetHae(200)
+	h.Add("a", sampleAt(0, a))
+	h.Add("a", sampleAt(100, b))
+
+	p, err := h.PositionAt("a", start.Add(25*time.Second))
+	require.NoError(t, err)
+	assert.InDelta(t, 250, origin.DistanceTo(p), 0.01)
+	assert.InDelta(t, 45, origin.BearingTo(p), 0.01)
+	require.NotNil(t, p.Hae)
+	assert.InDelta(t, 125, *p.Hae, 1e-9)
+
+	p, err = h.PositionAt("a", start.Add(100*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, b, p)
+
+	_, err = h.PositionAt("a", start.Add(-time.Second))
+	assert.ErrorIs(t, err, ErrBeforeHistory)
+	_, err = h.PositionAt("b", start)
+	assert.ErrorIs(t, err, ErrUnknownUID)
+}
+
+func TestPositionAtDeadReckons(t *testing.T) {
+	h := NewHistory(0)
+	s := sampleAt(0, origin)
+	s.Course, s.Speed, s.HasVelocity = 90, 10, true
+	h.Add("a", s)
+
+	p, err := h.PositionAt("a", start.Add(60*time.Second))
+	require.NoError(t, err)
+	assert.InDelta(t, 600, origin.DistanceTo(p), 0.01)
+	assert.InDelta(t, 90, origin.BearingTo(p), 0.01)
+
+	h.SetMaxDeadReckon(30 * time.Second)
+	_, err = h.PositionAt("a", start.Add(60*time.Second))
+	assert.ErrorIs(t, err, ErrBeyondDeadReckoning)
+}
+
+func TestPositionAtEstimatesVelocity(t *testing.T) {
+	h := NewHistory(0)
+	h.Add("a", sampleAt(0, origin))
+	h.Add("a", sampleAt(10, origin.Destination(100, 45)))
+
+	p, err := h.PositionAt("a", start.Add(20*time.Second))
+	require.NoError(t, err)
+	assert.InDelta(t, 200, origin.DistanceTo(p), 0.1)
+	assert.InDelta(t, 45, origin.BearingTo(p), 0.01)
+}
+
+func TestDeadReckonSlope(t *testing.T) {
+	p := origin
+	p.SetHae(100)
+	s := Sample{Time: start, Point: p, Course: 0, Speed: 10, Slope: 45, HasVelocity: true}
+
+	got := DeadReckon(s, start.Add(10*time.Second))
+	require.NotNil(t, got.Hae)
+	assert.InDelta(t, 200, *got.Hae, 1e-9)
+
+	still := Sample{Time: start, Point: origin}
+	assert.Equal(t, origin, DeadReckon(still, start.Add(time.Hour)))
+}
+
+func TestSmoothReducesNoise(t *testing.T) {
+	// A straight track north at 10 m/s with 15 m of position noise
+	r := rand.New(rand.NewSource(1))
+	var noisy []Sample
+	var truth []cot.Point
+	for i := 0; i < 120; i++ {
+		actual := origin.Destination(float64(i)*10, 0)
+		truth = append(truth, actual)
+		measured := actual.Destination(r.Float64()*15, r.Float64()*360)
+		noisy = append(noisy, sampleAt(i, measured))
+	}
+
+	smoothed := Smooth(noisy, 0.3, 0.05)
+	require.Len(t, smoothed, len(noisy))
+
+	// Compare errors once the filter has settled
+	var rawError, smoothError float64
+	for i := 40; i < len(noisy); i++ {
+		rawError += truth[i].DistanceTo(noisy[i].Point)
+		smoothError += truth[i].DistanceTo(smoothed[i].Point)
+	}
+	assert.Less(t, smoothError, rawError*0.8)
+
+	last := smoothed[len(smoothed)-1]
+	assert.True(t, last.HasVelocity)
+	assert.InDelta(t, 10, last.Speed, 1.5)
+	assert.InDelta(t, 0, math.Mod(last.Course+180, 360)-180, 10)
+
+	assert.Nil(t, Smooth(nil, DefaultAlpha, DefaultBeta))
+}
+
+func TestHistoryAttach(t *testing.T) {
+	store := sa.NewStore()
+	h := NewHistory(0)
+	detach := h.Attach(store)
+	defer detach()
+
+	for i := 0; i < 3; i++ {
+		e := cot.NewEvent("a-f-G-U-C", "unit")
+		now := time.Now().Add(time.Duration(i) * time.Second)
+		e.SetTime(now).SetStale(now.Add(time.Minute))
+		e.SetPoint(origin.Destination(float64(i)*10, 0))
+		require.True(t, store.Ingest(e))
+	}
+	assert.Len(t, h.Samples("unit"), 3)
+
+	store.Remove("unit")
+	assert.Empty(t, h.Samples("unit"))
+}
@@ -0,0 +1,83 @@
+package track
+
+import (
+	"math"
+
+	"github.com/angry-kivi/gotak/pkg/cot"
+)
+
+// Default gains of the alpha-beta filter used by Smooth
+const (
+	DefaultAlpha = 0.5
+	DefaultBeta  = 0.1
+)
+
+// Smooth filters noisy positions with an alpha-beta filter and returns smoothed samples
+// with the estimated course and speed. Alpha, between 0 and 1, is the weight given to a
+// measured position over the prediction; beta, between 0 and 1, the weight given to the
+// velocity it implies. Lower gains smooth more but lag behind turns.
+func Smooth(samples []Sample, alpha, beta float64) []Sample {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	// Filter in meters east and north of the first sample
+	origin := samples[0].Point
+	result := make([]Sample, len(samples))
+	result[0] = samples[0]
+
+	var x, y, vx, vy float64
+	if samples[0].HasVelocity {
+		vx, vy = velocityComponents(samples[0].Course, samples[0].Speed)
+	}
+	for i := 1; i < len(samples); i++ {
+		s := samples[i]
+		dt := s.Time.Sub(samples[i-1].Time).Seconds()
+
+		// Predict, then correct with the measured position
+		px, py := x+vx*dt, y+vy*dt
+		mx, my := toPlane(origin, s)
+		rx, ry := mx-px, my-py
+		x, y = px+alpha*rx, py+alpha*ry
+		if dt > 0 {
+			vx += beta * rx / dt
+			vy += beta * ry / dt
+		}
+
+		smoothed := s
+		smoothed.Point = fromPlane(origin, s, x, y)
+		smoothed.Course = math.Mod(math.Atan2(vx, vy)*180/math.Pi+360, 360)
+		smoothed.Speed = math.Hypot(vx, vy)
+		smoothed.HasVelocity = true
+		result[i] = smoothed
+	}
+	return result
+}
+
+// Smoothed returns the smoothed history of a UID using the default gains
+func (h *History) Smoothed(uid string) []Sample {
+	return Smooth(h.Samples(uid), DefaultAlpha, DefaultBeta)
+}
+
+// velocityComponents returns the east and north components of a course and speed
+func velocityComponents(course, speed float64) (east, north float64) {
+	sin, cos := math.Sincos(course * math.Pi / 180)
+	return speed * sin, speed * cos
+}
+
+// toPlane returns the position of a sample in meters east and north of origin
+func toPlane(origin cot.Point, s Sample) (east, north float64) {
+	distance := origin.DistanceTo(s.Point)
+	if distance == 0 {
+		return 0, 0
+	}
+	return velocityComponents(origin.BearingTo(s.Point), distance)
+}
+
+// fromPlane returns the point east and north meters from origin, keeping the hae, ce
+// and le of s
+func fromPlane(origin cot.Point, s Sample, east, north float64) cot.Point {
+	p := origin.Destination(math.Hypot(east, north), math.Atan2(east, north)*180/math.Pi)
+	p.Hae, p.Ce, p.Le = s.Point.Hae, s.Point.Ce, s.Point.Le
+	return p
+}
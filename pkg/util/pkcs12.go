@@ -0,0 +1,30 @@
+package util
+
+import (
+	"crypto"
+	"crypto/x509"
+	"errors"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// EncodeP12 writes a private key, its certificate and the CA chain to a password
+// protected PKCS#12 file. The file uses the AES-256 and SHA-256 algorithms of current
+// OpenSSL and Java keytool versions, which ATAK, TAK Server and LoadTLSConfigFromP12 all
+// read.
+func EncodeP12(key crypto.PrivateKey, cert *x509.Certificate, caCerts []*x509.Certificate, password string) ([]byte, error) {
+	if cert == nil {
+		return nil, errors.New("a certificate is required")
+	}
+	return pkcs12.Modern.Encode(key, cert, caCerts, password)
+}
+
+// EncodeTrustStoreP12 writes CA certificates to a password protected PKCS#12
+// truststore, the format of the truststore-*.p12 files TAK Server hands out. The
+// certificates carry the Java trusted certificate attribute, as written by keytool.
+func EncodeTrustStoreP12(certs []*x509.Certificate, password string) ([]byte, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("at least one certificate is required")
+	}
+	return pkcs12.Modern.EncodeTrustStore(certs, password)
+}
@@ -0,0 +1,372 @@
+package util
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// p12MaxIterations is the largest key derivation or MAC iteration count DecodeP12
+// accepts. OpenSSL writes 2048 and Java keytool 10000; the cap stops a crafted file
+// from tying up the CPU while its password is checked.
+const p12MaxIterations = 1 << 20
+
+var (
+	oidDataContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidShroudedKeyBag           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidPKCS12PBE                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1}
+	oidPBES2                    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBMAC1                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 14}
+	oidPBKDF2                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA256           = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+)
+
+// ErrP12Password is returned when a PKCS#12 file does not decode with the given password
+var ErrP12Password = errors.New("incorrect P12 password")
+
+// ASN.1 structures of RFC 7292 and RFC 8018 that carry iteration counts
+
+type p12ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type p12EncryptedData struct {
+	Version              int
+	EncryptedContentInfo p12EncryptedContentInfo
+}
+
+type p12EncryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type p12SafeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue `asn1:"tag:0,explicit"`
+	Attributes asn1.RawValue `asn1:"optional"`
+}
+
+type p12EncryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type p12PBEParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+// p12PBES2Params also matches PBMAC1 parameters, whose second field is the MAC scheme
+type p12PBES2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type p12PBKDF2Params struct {
+	Salt       asn1.RawValue
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+type p12DigestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type p12MacData struct {
+	Mac        p12DigestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type p12PFX struct {
+	Version  int
+	AuthSafe p12ContentInfo
+	MacData  p12MacData `asn1:"optional"`
+}
+
+// P12Contents is the content of a PKCS#12 file
+type P12Contents struct {
+	// Key is the private key, nil for truststores
+	Key crypto.PrivateKey
+	// Certificate is the certificate of Key, nil for truststores
+	Certificate *x509.Certificate
+	// CACertificates are the remaining certificates in file order
+	CACertificates []*x509.Certificate
+}
+
+// DecodeP12 decodes a PKCS#12 key store or truststore with go-pkcs12, which reads the
+// PBES2/AES encryption and SHA-2 MACs of current OpenSSL and Java keytool versions as
+// well as the legacy 3DES and RC2 algorithms. Truststores are read whether or not their
+// certificates carry the Java trusted certificate attribute keytool writes. Files asking
+// for more than p12MaxIterations iterations are rejected before any key is derived.
+func DecodeP12(data []byte, password string) (*P12Contents, error) {
+	if err := p12CheckIterations(data); err != nil {
+		return nil, err
+	}
+
+	key, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			return nil, ErrP12Password
+		}
+		certs, trustErr := pkcs12.DecodeTrustStore(data, password)
+		if trustErr != nil {
+			// OpenSSL writes truststores without the Java attribute
+			var certErr error
+			if certs, certErr = p12Certificates(data, password); certErr != nil {
+				return nil, fmt.Errorf("failed to decode P12 data as a key store (%v) or a truststore: %w", err, certErr)
+			}
+		}
+		return &P12Contents{CACertificates: certs}, nil
+	}
+
+	// go-pkcs12 takes the first certificate as the one of the key; the certificate of the
+	// key is the one with the matching public key
+	result := &P12Contents{Key: key}
+	for _, c := range append([]*x509.Certificate{cert}, caCerts...) {
+		if result.Certificate == nil && publicKeyMatches(key, c.PublicKey) {
+			result.Certificate = c
+			continue
+		}
+		result.CACertificates = append(result.CACertificates, c)
+	}
+	if result.Certificate == nil {
+		return nil, errors.New("no certificate in the P12 file matches its private key")
+	}
+	return result, nil
+}
+
+// publicKeyMatches reports whether pub is the public key of key
+func publicKeyMatches(key crypto.PrivateKey, pub crypto.PublicKey) bool {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return false
+	}
+	own, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	return ok && own.Equal(pub)
+}
+
+// p12Certificates returns the certificates of a PKCS#12 file holding certificate bags
+// only. go-pkcs12 reads certificates without the Java trusted certificate attribute only
+// with ToPEM, which expects exactly two safe contents. Truststores written by
+// openssl pkcs12 -export -nokeys have one, so an empty second one is added under a new
+// PBMAC1 MAC; DecodeChain has verified the password against the original MAC before.
+func p12Certificates(data []byte, password string) ([]*x509.Certificate, error) {
+	var pfx p12PFX
+	if _, err := asn1.Unmarshal(data, &pfx); err != nil {
+		return nil, fmt.Errorf("failed to decode P12 data: %w", err)
+	}
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, fmt.Errorf("failed to decode P12 data: %w", err)
+	}
+	var contents []asn1.RawValue
+	if _, err := asn1.Unmarshal(authSafe, &contents); err != nil {
+		return nil, fmt.Errorf("failed to decode P12 data: %w", err)
+	}
+
+	if len(contents) == 1 {
+		var err error
+		if data, err = p12AddEmptySafe(contents[0], password); err != nil {
+			return nil, err
+		}
+	}
+	blocks, err := pkcs12.ToPEM(data, password)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	for _, block := range blocks {
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected %s in P12 truststore", strings.ToLower(block.Type))
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse P12 certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found in P12 data")
+	}
+	return certs, nil
+}
+
+// p12AddEmptySafe returns a PFX holding the content info and an empty safe contents,
+// authenticated with a PBMAC1 MAC under the password
+func p12AddEmptySafe(content asn1.RawValue, password string) ([]byte, error) {
+	emptySafe, err := asn1.Marshal([]asn1.RawValue{})
+	if err != nil {
+		return nil, err
+	}
+	if emptySafe, err = asn1.Marshal(emptySafe); err != nil {
+		return nil, err
+	}
+	empty, err := asn1.Marshal(p12ContentInfo{ContentType: oidDataContentType, Content: p12Explicit(emptySafe)})
+	if err != nil {
+		return nil, err
+	}
+	authSafe, err := asn1.Marshal([]asn1.RawValue{content, {FullBytes: empty}})
+	if err != nil {
+		return nil, err
+	}
+	authSafeData, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, err
+	}
+
+	// The MAC only has to satisfy go-pkcs12, so a single iteration will do
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	saltData, err := asn1.Marshal(salt)
+	if err != nil {
+		return nil, err
+	}
+	sha256HMAC := pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256}
+	kdfParams, err := asn1.Marshal(p12PBKDF2Params{Salt: asn1.RawValue{FullBytes: saltData}, Iterations: 1, KeyLength: sha256.Size, PRF: sha256HMAC})
+	if err != nil {
+		return nil, err
+	}
+	macParams, err := asn1.Marshal(p12PBES2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  sha256HMAC,
+	})
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, pbkdf2.Key([]byte(password), salt, 1, sha256.Size, sha256.New))
+	mac.Write(authSafe)
+
+	return asn1.Marshal(p12PFX{
+		Version:  3,
+		AuthSafe: p12ContentInfo{ContentType: oidDataContentType, Content: p12Explicit(authSafeData)},
+		MacData: p12MacData{
+			Mac: p12DigestInfo{
+				Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidPBMAC1, Parameters: asn1.RawValue{FullBytes: macParams}},
+				Digest:    mac.Sum(nil),
+			},
+			MacSalt:    salt,
+			Iterations: 1,
+		},
+	})
+}
+
+// p12Explicit wraps DER in the [0] EXPLICIT tag of a content info. encoding/asn1 writes
+// RawValue fields verbatim and ignores their explicit tag.
+func p12Explicit(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+// p12CheckIterations rejects a PKCS#12 file whose MAC, encrypted safe contents or
+// shrouded key bags ask for more than p12MaxIterations iterations. Key bags inside
+// encrypted safe contents are only visible after decryption and are not checked, but
+// reaching them takes a password that passes the MAC.
+func p12CheckIterations(data []byte) error {
+	var pfx p12PFX
+	if _, err := asn1.Unmarshal(data, &pfx); err != nil {
+		return fmt.Errorf("failed to decode P12 data: %w", err)
+	}
+	if err := p12CheckAlgorithm(pfx.MacData.Mac.Algorithm); err != nil {
+		return err
+	}
+	if pfx.MacData.Iterations > p12MaxIterations {
+		return p12IterationsError(pfx.MacData.Iterations)
+	}
+
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return fmt.Errorf("failed to decode P12 data: %w", err)
+	}
+	var contents []p12ContentInfo
+	if _, err := asn1.Unmarshal(authSafe, &contents); err != nil {
+		return fmt.Errorf("failed to decode P12 data: %w", err)
+	}
+	for _, ci := range contents {
+		switch {
+		case ci.ContentType.Equal(oidEncryptedDataContentType):
+			var encrypted p12EncryptedData
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &encrypted); err != nil {
+				return fmt.Errorf("failed to decode P12 data: %w", err)
+			}
+			if err := p12CheckAlgorithm(encrypted.EncryptedContentInfo.ContentEncryptionAlgorithm); err != nil {
+				return err
+			}
+		case ci.ContentType.Equal(oidDataContentType):
+			var safeContents []byte
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &safeContents); err != nil {
+				return fmt.Errorf("failed to decode P12 data: %w", err)
+			}
+			var bags []p12SafeBag
+			if _, err := asn1.Unmarshal(safeContents, &bags); err != nil {
+				return fmt.Errorf("failed to decode P12 safe contents: %w", err)
+			}
+			for _, bag := range bags {
+				if !bag.ID.Equal(oidShroudedKeyBag) {
+					continue
+				}
+				var info p12EncryptedPrivateKeyInfo
+				if _, err := asn1.Unmarshal(bag.Value.Bytes, &info); err != nil {
+					return fmt.Errorf("failed to decode P12 key bag: %w", err)
+				}
+				if err := p12CheckAlgorithm(info.Algorithm); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// p12CheckAlgorithm rejects a PKCS#12 PBE, PBES2 or PBMAC1 algorithm with more than
+// p12MaxIterations iterations. Other algorithms are left to go-pkcs12.
+func p12CheckAlgorithm(algorithm pkix.AlgorithmIdentifier) error {
+	oid := algorithm.Algorithm
+	var iterations int
+	switch {
+	case oid.Equal(oidPBES2), oid.Equal(oidPBMAC1):
+		var params p12PBES2Params
+		if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+			return fmt.Errorf("failed to decode P12 algorithm parameters: %w", err)
+		}
+		if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+			return nil
+		}
+		var kdf p12PBKDF2Params
+		if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+			return fmt.Errorf("failed to decode P12 algorithm parameters: %w", err)
+		}
+		iterations = kdf.Iterations
+	case len(oid) == len(oidPKCS12PBE)+1 && oid[:len(oidPKCS12PBE)].Equal(oidPKCS12PBE):
+		var params p12PBEParams
+		if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+			return fmt.Errorf("failed to decode P12 algorithm parameters: %w", err)
+		}
+		iterations = params.Iterations
+	}
+	if iterations > p12MaxIterations {
+		return p12IterationsError(iterations)
+	}
+	return nil
+}
+
+func p12IterationsError(iterations int) error {
+	return fmt.Errorf("P12 iteration count %d exceeds the limit of %d", iterations, p12MaxIterations)
+}
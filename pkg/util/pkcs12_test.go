@@ -0,0 +1,208 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestCertificate creates a certificate for key, signed by parent or self-signed
+func newTestCertificate(t *testing.T, name string, key, parentKey any, parent *x509.Certificate, isCA bool) *x509.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, publicKey(key), parentKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+func publicKey(key any) any {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &k.PublicKey
+	case *ecdsa.PrivateKey:
+		return &k.PublicKey
+	}
+	return nil
+}
+
+func TestEncodeP12RoundTrip(t *testing.T) {
+	// Given
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca := newTestCertificate(t, "Test CA", caKey, nil, nil, true)
+	clientKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	client := newTestCertificate(t, "bot-1", clientKey, caKey, ca, false)
+
+	// When
+	data, err := EncodeP12(clientKey, client, []*x509.Certificate{ca}, "atakatak")
+	if err != nil {
+		t.Fatalf("EncodeP12 failed: %v", err)
+	}
+
+	// Then
+	contents, err := DecodeP12(data, "atakatak")
+	if err != nil {
+		t.Fatalf("Failed to decode P12: %v", err)
+	}
+	if contents.Key == nil || !contents.Certificate.Equal(client) || len(contents.CACertificates) != 1 {
+		t.Errorf("Unexpected P12 content. Got: key %v, certificate %v and %d CA certificates, Expected: key, bot-1 and 1", contents.Key != nil, contents.Certificate.Subject, len(contents.CACertificates))
+	}
+	if _, err := DecodeP12(data, "wrong"); !errors.Is(err, ErrP12Password) {
+		t.Errorf("Unexpected error for the wrong password. Got: %v, Expected: %v", err, ErrP12Password)
+	}
+
+	// When
+	path := filepath.Join(t.TempDir(), "bot-1.p12")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadTLSConfigFromP12(path, "atakatak", "", false)
+
+	// Then
+	if err != nil {
+		t.Fatalf("LoadTLSConfigFromP12 failed: %v", err)
+	}
+	if got := config.Certificates[0].Leaf.Subject.CommonName; got != "bot-1" {
+		t.Errorf("Unexpected client certificate. Got: %v, Expected: bot-1", got)
+	}
+	if _, err := client.Verify(x509.VerifyOptions{Roots: config.RootCAs, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Errorf("Client certificate does not verify against the P12 CA: %v", err)
+	}
+}
+
+func TestEncodeP12RequiresCertificate(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if _, err := EncodeP12(key, nil, nil, "pw"); err == nil {
+		t.Error("Expected an error without a certificate")
+	}
+}
+
+func TestDecodeP12(t *testing.T) {
+	// Files written by OpenSSL 3 with its default and legacy algorithms, whose
+	// truststores lack the Java trusted certificate attribute, and a truststore with the
+	// attribute as keytool writes it
+	tests := []struct {
+		file    string
+		withKey bool
+	}{
+		{"testdata/bot-1-aes.p12", true},
+		{"testdata/bot-1-legacy.p12", true},
+		{"testdata/truststore-aes.p12", false},
+		{"testdata/truststore-rc2.p12", false},
+		{"testdata/truststore-keytool.p12", false},
+	}
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			// Given
+			data, err := os.ReadFile(test.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// When
+			contents, err := DecodeP12(data, "atakatak")
+
+			// Then
+			if err != nil {
+				t.Fatalf("DecodeP12 failed: %v", err)
+			}
+			if test.withKey {
+				if contents.Key == nil || contents.Certificate == nil || contents.Certificate.Subject.CommonName != "bot-1" {
+					t.Errorf("Unexpected key store content. Got: key %v, certificate %v", contents.Key != nil, contents.Certificate)
+				}
+			} else if contents.Key != nil || contents.Certificate != nil {
+				t.Errorf("Unexpected key in truststore")
+			}
+			if len(contents.CACertificates) != 1 || contents.CACertificates[0].Subject.CommonName != "TAK Test CA" {
+				t.Errorf("Unexpected CA certificates. Got: %v, Expected: TAK Test CA", contents.CACertificates)
+			}
+
+			// When
+			_, err = DecodeP12(data, "wrong")
+
+			// Then
+			if !errors.Is(err, ErrP12Password) {
+				t.Errorf("Unexpected error for the wrong password. Got: %v, Expected: %v", err, ErrP12Password)
+			}
+		})
+	}
+}
+
+func TestDecodeP12MatchesKeyToCertificate(t *testing.T) {
+	// Given a key store with an empty password
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca := newTestCertificate(t, "Test CA", caKey, nil, nil, true)
+	clientKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	client := newTestCertificate(t, "bot-1", clientKey, caKey, ca, false)
+	data, err := EncodeP12(clientKey, client, []*x509.Certificate{ca}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	contents, err := DecodeP12(data, "")
+
+	// Then
+	if err != nil {
+		t.Fatalf("DecodeP12 failed: %v", err)
+	}
+	if !contents.Certificate.Equal(client) || len(contents.CACertificates) != 1 || !contents.CACertificates[0].Equal(ca) {
+		t.Errorf("Unexpected certificates. Got: %v and %v, Expected: bot-1 and Test CA", contents.Certificate.Subject, contents.CACertificates)
+	}
+}
+
+func TestDecodeP12RejectsLargeIterationCounts(t *testing.T) {
+	// Given a key store whose MAC asks for more iterations than allowed
+	data, err := os.ReadFile("testdata/bot-1-aes.p12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pfx p12PFX
+	if _, err := asn1.Unmarshal(data, &pfx); err != nil {
+		t.Fatal(err)
+	}
+	pfx.AuthSafe.Content = p12Explicit(pfx.AuthSafe.Content.FullBytes)
+	pfx.MacData.Iterations = p12MaxIterations + 1
+	if data, err = asn1.Marshal(pfx); err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	start := time.Now()
+	_, err = DecodeP12(data, "atakatak")
+
+	// Then
+	if err == nil || errors.Is(err, ErrP12Password) {
+		t.Errorf("Unexpected error for a large iteration count. Got: %v, Expected: an iteration limit error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("DecodeP12 derived keys before rejecting the file. Took: %v", elapsed)
+	}
+}
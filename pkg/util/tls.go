@@ -3,12 +3,11 @@ package util
 import (
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/pem"
 	"errors"
 	"fmt"
 	"os"
-
-	"golang.org/x/crypto/pkcs12"
+	"path/filepath"
+	"strings"
 )
 
 // LoadTLSConfigAuto automatically determines the certificate type (PEM or P12)
@@ -45,16 +44,10 @@ func LoadTLSConfig(certFile, keyFile, caFile string, skipVerify bool) (*tls.Conf
 
 	// Load CA certificate if provided
 	if caFile != "" {
-		caCert, err := os.ReadFile(caFile)
+		caCertPool, err := LoadCAFile(caFile, "")
 		if err != nil {
-			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
-		}
-
-		caCertPool := x509.NewCertPool()
-		if !caCertPool.AppendCertsFromPEM(caCert) {
-			return nil, errors.New("failed to append CA certificate to pool")
+			return nil, err
 		}
-
 		config.RootCAs = caCertPool
 	}
 
@@ -73,89 +66,215 @@ func LoadTLSConfigFromP12(p12File string, password string, caFile string, skipVe
 		return nil, fmt.Errorf("failed to read P12 file: %w", err)
 	}
 
-	// Try to decode all certificates from the P12 file
-	blocks, err := pkcs12.ToPEM(p12Data, password)
+	cert, caCerts, err := decodeP12(p12Data, password)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode P12 data: %w", err)
+		return nil, err
 	}
 
-	if len(blocks) == 0 {
-		return nil, fmt.Errorf("no certificates or keys found in P12 file")
-	}
-
-	// Variables to hold our certificate components
-	var certDERBlock []byte
-	var keyDERBlock []byte
-	var caCertDERBlocks [][]byte
+	config.Certificates = []tls.Certificate{cert}
 
-	// Extract the certificate, private key, and CA certificates
-	for _, block := range blocks {
-		switch block.Type {
-		case "CERTIFICATE":
-			// The first certificate is assumed to be the client certificate,
-			// any subsequent ones are assumed to be CA certificates
-			if certDERBlock == nil {
-				certDERBlock = block.Bytes
-			} else {
-				caCertDERBlocks = append(caCertDERBlocks, block.Bytes)
+	// Trust the CA certificates from the P12 file, but never the client certificate itself
+	if caFile == "" {
+		if len(caCerts) > 0 {
+			config.RootCAs = x509.NewCertPool()
+			for _, caCert := range caCerts {
+				config.RootCAs.AddCert(caCert)
 			}
-		case "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
-			keyDERBlock = block.Bytes
 		}
+		return config, nil
+	}
+
+	// A CA file replaces the chain from the P12 file; a .p12 truststore is opened with
+	// the same password
+	if config.RootCAs, err = LoadCAFile(caFile, password); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// decodeP12 returns the client certificate and the CA certificates of a PKCS#12 file
+func decodeP12(p12Data []byte, password string) (tls.Certificate, []*x509.Certificate, error) {
+	contents, err := DecodeP12(p12Data, password)
+	if err != nil {
+		return tls.Certificate{}, nil, err
 	}
 
 	// Ensure we have both the certificate and private key
-	if certDERBlock == nil || keyDERBlock == nil {
-		return nil, fmt.Errorf("P12 file must contain both a certificate and a private key")
+	if contents.Key == nil {
+		return tls.Certificate{}, nil, fmt.Errorf("P12 file must contain both a certificate and a private key")
 	}
 
-	// Create a certificate from the PEM blocks
-	cert, err := tls.X509KeyPair(
-		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDERBlock}),
-		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDERBlock}),
-	)
+	cert := tls.Certificate{
+		Certificate: [][]byte{contents.Certificate.Raw},
+		PrivateKey:  contents.Key,
+		Leaf:        contents.Certificate,
+	}
+	return cert, contents.CACertificates, nil
+}
+
+// TLSConfigFromP12 creates a TLS configuration from PKCS#12 data. Servers are verified
+// against the trustStore pool only, so neither the client certificate nor its chain is
+// trusted as a server CA.
+func TLSConfigFromP12(p12Data []byte, password string, trustStore *x509.CertPool, skipVerify bool) (*tls.Config, error) {
+	cert, _, err := decodeP12(p12Data, password)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create X509 key pair: %w", err)
+		return nil, err
 	}
+	return &tls.Config{
+		Certificates:       []tls.Certificate{cert},
+		RootCAs:            trustStore,
+		InsecureSkipVerify: skipVerify,
+	}, nil
+}
 
-	// Parse the client certificate for additional information
-	clientCert, err := x509.ParseCertificate(certDERBlock)
+// LoadTLSConfigFromP12WithTrustStore creates a TLS configuration from a client .p12 file
+// and a separate truststore .p12 file, the layout TAK Server uses for its certificates
+func LoadTLSConfigFromP12WithTrustStore(p12File, password, trustStoreFile, trustStorePassword string, skipVerify bool) (*tls.Config, error) {
+	p12Data, err := os.ReadFile(p12File)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse client certificate: %w", err)
+		return nil, fmt.Errorf("failed to read P12 file: %w", err)
+	}
+	trustStore, err := LoadTrustStoreP12(trustStoreFile, trustStorePassword)
+	if err != nil {
+		return nil, err
 	}
-	cert.Leaf = clientCert
+	return TLSConfigFromP12(p12Data, password, trustStore, skipVerify)
+}
 
-	config.Certificates = []tls.Certificate{cert}
+// LoadTrustStoreP12 reads the CA certificates of a PKCS#12 truststore into a pool
+func LoadTrustStoreP12(trustStoreFile, password string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(trustStoreFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read truststore: %w", err)
+	}
+	certs, err := ParseTrustStoreP12(data, password)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return pool, nil
+}
 
-	// Create a certificate pool for CA certificates
-	certPool := x509.NewCertPool()
+// ParseTrustStoreP12 returns the certificates of a PKCS#12 truststore. Truststores hold
+// certificates only; a key in the file is ignored.
+func ParseTrustStoreP12(data []byte, password string) ([]*x509.Certificate, error) {
+	contents, err := DecodeP12(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode truststore: %w", err)
+	}
+
+	var certs []*x509.Certificate
+	if contents.Certificate != nil {
+		certs = append(certs, contents.Certificate)
+	}
+	certs = append(certs, contents.CACertificates...)
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found in truststore")
+	}
+	return certs, nil
+}
 
-	// Add the client certificate to the pool
-	certPool.AddCert(clientCert)
+// isP12File reports whether a file name has a PKCS#12 extension
+func isP12File(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".p12" || ext == ".pfx"
+}
 
-	// Add any CA certificates from the P12 file
-	for _, caCertDER := range caCertDERBlocks {
-		caCert, err := x509.ParseCertificate(caCertDER)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+// LoadCAFile reads trusted CA certificates from a PEM file or a PKCS#12 truststore
+func LoadCAFile(caFile, password string) (*x509.CertPool, error) {
+	if isP12File(caFile) {
+		return LoadTrustStoreP12(caFile, password)
+	}
+
+	caCert, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	caCertPool := x509.NewCertPool()
+	if !caCertPool.AppendCertsFromPEM(caCert) {
+		return nil, errors.New("failed to append CA certificate to pool")
+	}
+	return caCertPool, nil
+}
+
+// TLSOptions describes a TLS client configuration for NewTLSConfig
+type TLSOptions struct {
+	// CertFile is the client certificate, PEM or .p12/.pfx; optional
+	CertFile string
+	// KeyFile is the PEM client key, not needed for .p12 files
+	KeyFile string
+	// Password protects a .p12 client certificate
+	Password string
+
+	// CAFile holds the trusted CAs, PEM or a .p12 truststore. Without it the CA chain of
+	// a .p12 client certificate is trusted, or else the system roots.
+	CAFile string
+	// CAPassword protects a .p12 truststore
+	CAPassword string
+
+	// ServerName is the name the server certificate is verified against and sent for
+	// SNI, when it differs from the address connected to
+	ServerName string
+	// Pins are SHA-256 public key hashes (see SPKIHash) of which the server chain must
+	// contain one
+	Pins []string
+
+	// SkipVerify disables chain and name verification. Pins are still checked.
+	SkipVerify bool
+}
+
+// NewTLSConfig creates a TLS client configuration from options
+func NewTLSConfig(options TLSOptions) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         options.ServerName,
+		InsecureSkipVerify: options.SkipVerify,
+	}
+
+	var chain []*x509.Certificate
+	if options.CertFile != "" {
+		var cert tls.Certificate
+		if isP12File(options.CertFile) {
+			data, err := os.ReadFile(options.CertFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read P12 file: %w", err)
+			}
+			if cert, chain, err = decodeP12(data, options.Password); err != nil {
+				return nil, err
+			}
+		} else {
+			if options.KeyFile == "" {
+				return nil, errors.New("PEM certificate format requires a key file")
+			}
+			var err error
+			if cert, err = tls.LoadX509KeyPair(options.CertFile, options.KeyFile); err != nil {
+				return nil, fmt.Errorf("failed to load client certificate: %w", err)
+			}
 		}
-		certPool.AddCert(caCert)
+		config.Certificates = []tls.Certificate{cert}
 	}
 
-	// Load additional CA certificate if provided
-	if caFile != "" {
-		caCert, err := os.ReadFile(caFile)
+	switch {
+	case options.CAFile != "":
+		roots, err := LoadCAFile(options.CAFile, options.CAPassword)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+			return nil, err
 		}
-
-		if !certPool.AppendCertsFromPEM(caCert) {
-			return nil, errors.New("failed to append CA certificate to pool")
+		config.RootCAs = roots
+	case len(chain) > 0:
+		config.RootCAs = x509.NewCertPool()
+		for _, cert := range chain {
+			config.RootCAs.AddCert(cert)
 		}
 	}
 
-	// Set the RootCAs to our certPool
-	config.RootCAs = certPool
-
+	if len(options.Pins) > 0 {
+		if err := ApplyPins(config, options.Pins); err != nil {
+			return nil, err
+		}
+	}
 	return config, nil
 }
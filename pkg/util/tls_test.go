@@ -0,0 +1,106 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTrustStoreP12RoundTrip(t *testing.T) {
+	// Given
+	rootKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	root := newTestCertificate(t, "Root CA", rootKey, nil, nil, true)
+	intermediateKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	intermediate := newTestCertificate(t, "Intermediate CA", intermediateKey, rootKey, root, true)
+
+	// When
+	data, err := EncodeTrustStoreP12([]*x509.Certificate{root, intermediate}, "atakatak")
+	if err != nil {
+		t.Fatalf("EncodeTrustStoreP12 failed: %v", err)
+	}
+	certs, err := ParseTrustStoreP12(data, "atakatak")
+
+	// Then
+	if err != nil {
+		t.Fatalf("ParseTrustStoreP12 failed: %v", err)
+	}
+	if len(certs) != 2 || !certs[0].Equal(root) || !certs[1].Equal(intermediate) {
+		t.Errorf("Unexpected truststore content. Got: %d certificates, Expected: root and intermediate", len(certs))
+	}
+	if _, err := ParseTrustStoreP12(data, "wrong"); err == nil {
+		t.Error("Expected an error decoding with the wrong password")
+	}
+	if _, err := EncodeTrustStoreP12(nil, "atakatak"); err == nil {
+		t.Error("Expected an error encoding an empty truststore")
+	}
+}
+
+func TestLoadTLSConfigFromP12WithTrustStore(t *testing.T) {
+	// Given
+	dir := t.TempDir()
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca := newTestCertificate(t, "TAK CA", caKey, nil, nil, true)
+	clientKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	client := newTestCertificate(t, "bot-1", clientKey, nil, nil, false)
+	serverKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	server := newTestCertificate(t, "takserver", serverKey, caKey, ca, false)
+
+	clientP12, _ := EncodeP12(clientKey, client, nil, "client-pass")
+	trustStore, _ := EncodeTrustStoreP12([]*x509.Certificate{ca}, "trust-pass")
+	clientFile := filepath.Join(dir, "bot-1.p12")
+	trustFile := filepath.Join(dir, "truststore-root.p12")
+	os.WriteFile(clientFile, clientP12, 0600)
+	os.WriteFile(trustFile, trustStore, 0600)
+
+	// When
+	config, err := LoadTLSConfigFromP12WithTrustStore(clientFile, "client-pass", trustFile, "trust-pass", false)
+
+	// Then
+	if err != nil {
+		t.Fatalf("LoadTLSConfigFromP12WithTrustStore failed: %v", err)
+	}
+	if got := config.Certificates[0].Leaf.Subject.CommonName; got != "bot-1" {
+		t.Errorf("Unexpected client certificate. Got: %v, Expected: bot-1", got)
+	}
+	opts := x509.VerifyOptions{Roots: config.RootCAs, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}
+	if _, err := server.Verify(opts); err != nil {
+		t.Errorf("Server certificate does not verify against the truststore: %v", err)
+	}
+	if _, err := client.Verify(opts); err == nil {
+		t.Error("Expected the self-signed client certificate not to be trusted")
+	}
+
+	// When
+	_, err = LoadTLSConfigFromP12WithTrustStore(clientFile, "client-pass", trustFile, "wrong", false)
+
+	// Then
+	if err == nil {
+		t.Error("Expected an error with the wrong truststore password")
+	}
+}
+
+func TestLoadTLSConfigFromP12DoesNotTrustClientCertificate(t *testing.T) {
+	// Given a self-signed client certificate without a CA chain
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	cert := newTestCertificate(t, "bot-1", key, nil, nil, false)
+	data, _ := EncodeP12(key, cert, nil, "atakatak")
+	path := filepath.Join(t.TempDir(), "bot-1.p12")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	config, err := LoadTLSConfigFromP12(path, "atakatak", "", false)
+
+	// Then
+	if err != nil {
+		t.Fatalf("LoadTLSConfigFromP12 failed: %v", err)
+	}
+	if config.RootCAs != nil {
+		t.Error("Expected the client certificate not to be trusted as a server CA")
+	}
+}
@@ -0,0 +1,202 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrPinMismatch is returned when no certificate of the server chain matches a pin
+var ErrPinMismatch = errors.New("server certificate does not match any pinned public key")
+
+// SPKIHash returns the base64 SHA-256 hash of a certificate's SubjectPublicKeyInfo, the
+// pin format of HPKP, as printed by
+//
+//	openssl x509 -pubkey -noout | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// ParsePin decodes a SHA-256 public key pin given in base64, optionally prefixed with
+// "sha256/", or in hex with optional colons
+func ParsePin(pin string) ([]byte, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil && len(b) == sha256.Size {
+		return b, nil
+	}
+	if b, err := hex.DecodeString(strings.ReplaceAll(s, ":", "")); err == nil && len(b) == sha256.Size {
+		return b, nil
+	}
+	return nil, fmt.Errorf("invalid SHA-256 public key pin %q", pin)
+}
+
+// PinVerifier returns a tls.Config VerifyConnection function that accepts a connection
+// when any certificate the server presented, or any certificate of a verified chain,
+// has one of the pinned public keys
+func PinVerifier(pins []string) (func(tls.ConnectionState) error, error) {
+	var hashes [][]byte
+	for _, pin := range pins {
+		hash, err := ParsePin(pin)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	if len(hashes) == 0 {
+		return nil, errors.New("no public key pins given")
+	}
+
+	return func(state tls.ConnectionState) error {
+		certs := append([]*x509.Certificate(nil), state.PeerCertificates...)
+		for _, chain := range state.VerifiedChains {
+			certs = append(certs, chain...)
+		}
+		for _, cert := range certs {
+			sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			for _, hash := range hashes {
+				if bytes.Equal(sum[:], hash) {
+					return nil
+				}
+			}
+		}
+		return &PinError{Certificates: state.PeerCertificates}
+	}, nil
+}
+
+// ApplyPins makes a TLS configuration require one of the pinned public keys. With
+// InsecureSkipVerify set the pins replace chain verification, which allows servers with
+// self-signed certificates without trusting every server.
+func ApplyPins(config *tls.Config, pins []string) error {
+	verify, err := PinVerifier(pins)
+	if err != nil {
+		return err
+	}
+	if previous := config.VerifyConnection; previous != nil {
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			if err := previous(state); err != nil {
+				return err
+			}
+			return verify(state)
+		}
+	} else {
+		config.VerifyConnection = verify
+	}
+	return nil
+}
+
+// PinError reports a server whose certificates match no pinned public key
+type PinError struct {
+	Certificates []*x509.Certificate
+}
+
+func (e *PinError) Error() string {
+	var presented []string
+	for _, cert := range e.Certificates {
+		presented = append(presented, fmt.Sprintf("%q sha256/%s", cert.Subject.String(), SPKIHash(cert)))
+	}
+	return fmt.Sprintf("%v; the server presented %s", ErrPinMismatch, strings.Join(presented, ", "))
+}
+
+func (e *PinError) Unwrap() error {
+	return ErrPinMismatch
+}
+
+// VerificationError is a TLS handshake error with an explanation of its likely cause
+type VerificationError struct {
+	Err  error
+	Hint string
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Err, e.Hint)
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.Err
+}
+
+// DiagnoseTLSError explains a TLS handshake error, returning a VerificationError with
+// a hint on how to fix it. Errors it has no explanation for are returned unchanged.
+func DiagnoseTLSError(err error, serverName string) error {
+	if err == nil {
+		return nil
+	}
+	if hint := tlsErrorHint(err, serverName); hint != "" {
+		return &VerificationError{Err: err, Hint: hint}
+	}
+	return err
+}
+
+// tlsErrorHint returns the explanation of a TLS error, or an empty string
+func tlsErrorHint(err error, serverName string) string {
+	var pinErr *PinError
+	if errors.As(err, &pinErr) {
+		return "check the pins or pin one of the presented keys"
+	}
+
+	var verifyErr *tls.CertificateVerificationError
+	var leaf *x509.Certificate
+	if errors.As(err, &verifyErr) && len(verifyErr.UnverifiedCertificates) > 0 {
+		leaf = verifyErr.UnverifiedCertificates[0]
+	}
+
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &unknownAuthority):
+		cert := unknownAuthority.Cert
+		if cert == nil {
+			cert = leaf
+		}
+		if cert == nil {
+			return "the server certificate is not issued by a trusted CA; provide the server CA or truststore"
+		}
+		return fmt.Sprintf("the server certificate %q is issued by %q, which is not a trusted CA; provide that CA or the TAK Server truststore as the CA file, or pin the server key sha256/%s",
+			cert.Subject.String(), cert.Issuer.String(), SPKIHash(cert))
+	case errors.As(err, &hostnameErr):
+		cert := hostnameErr.Certificate
+		var names []string
+		names = append(names, cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			names = append(names, ip.String())
+		}
+		if len(names) == 0 {
+			return fmt.Sprintf("the server certificate %q has no subject alternative names; set the server name to a name it is valid for, or pin the server key sha256/%s",
+				cert.Subject.String(), SPKIHash(cert))
+		}
+		return fmt.Sprintf("the server certificate is valid for %s, not %q; connect with one of these names or set the server name explicitly",
+			strings.Join(names, ", "), hostnameErr.Host)
+	case errors.As(err, &invalidErr):
+		cert := invalidErr.Cert
+		switch invalidErr.Reason {
+		case x509.Expired:
+			return fmt.Sprintf("the certificate %q is valid from %s to %s but the local time is %s; check the clock or renew the certificate",
+				cert.Subject.String(), cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339), time.Now().Format(time.RFC3339))
+		case x509.IncompatibleUsage:
+			return fmt.Sprintf("the certificate %q is not valid for TLS server authentication", cert.Subject.String())
+		case x509.NotAuthorizedToSign:
+			return fmt.Sprintf("the certificate %q signed another certificate but is not a CA", cert.Subject.String())
+		}
+	}
+
+	// Alerts sent by the server about the client certificate
+	msg := err.Error()
+	for _, alert := range []string{"bad certificate", "certificate required", "unknown certificate authority", "certificate expired", "certificate revoked", "certificate unknown"} {
+		if strings.Contains(msg, "remote error: tls: "+alert) {
+			return "the server rejected the client certificate; check that it is issued by the server's CA, not expired or revoked, and that the right certificate and password are used"
+		}
+	}
+	if serverName != "" && strings.Contains(msg, "remote error: tls: unrecognized name") {
+		return fmt.Sprintf("the server does not know the server name %q", serverName)
+	}
+	return ""
+}
@@ -0,0 +1,170 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePin(t *testing.T) {
+	// Given
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	cert := newTestCertificate(t, "takserver", key, nil, nil, false)
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	for _, pin := range []string{SPKIHash(cert), "sha256/" + SPKIHash(cert), hex.EncodeToString(sum[:]), strings.ToUpper(hex.EncodeToString(sum[:2])) + ":" + hex.EncodeToString(sum[2:])} {
+		// When
+		got, err := ParsePin(pin)
+
+		// Then
+		if err != nil || string(got) != string(sum[:]) {
+			t.Errorf("Unexpected pin for %q. Got: %x (%v), Expected: %x", pin, got, err, sum)
+		}
+	}
+	for _, pin := range []string{"", "abc", "sha256/AAAA"} {
+		if _, err := ParsePin(pin); err == nil {
+			t.Errorf("Expected an error for pin %q", pin)
+		}
+	}
+}
+
+func TestPinVerifier(t *testing.T) {
+	// Given
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca := newTestCertificate(t, "TAK CA", caKey, nil, nil, true)
+	serverKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	server := newTestCertificate(t, "takserver", serverKey, caKey, ca, false)
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	other := newTestCertificate(t, "other", otherKey, nil, nil, false)
+
+	tests := []struct {
+		name   string
+		pins   []string
+		state  tls.ConnectionState
+		accept bool
+	}{
+		{"Leaf pinned", []string{SPKIHash(server)}, tls.ConnectionState{PeerCertificates: []*x509.Certificate{server}}, true},
+		{"CA pinned in verified chain", []string{SPKIHash(ca)}, tls.ConnectionState{PeerCertificates: []*x509.Certificate{server}, VerifiedChains: [][]*x509.Certificate{{server, ca}}}, true},
+		{"One of several pins", []string{SPKIHash(other), SPKIHash(server)}, tls.ConnectionState{PeerCertificates: []*x509.Certificate{server}}, true},
+		{"No pin matches", []string{SPKIHash(other)}, tls.ConnectionState{PeerCertificates: []*x509.Certificate{server}}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// When
+			verify, err := PinVerifier(test.pins)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = verify(test.state)
+
+			// Then
+			if test.accept && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if !test.accept && !errors.Is(err, ErrPinMismatch) {
+				t.Errorf("Unexpected error. Got: %v, Expected: %v", err, ErrPinMismatch)
+			}
+		})
+	}
+
+	if _, err := PinVerifier(nil); err == nil {
+		t.Error("Expected an error without pins")
+	}
+}
+
+func TestApplyPinsKeepsVerifyConnection(t *testing.T) {
+	// Given
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	cert := newTestCertificate(t, "takserver", key, nil, nil, false)
+	called := false
+	config := &tls.Config{VerifyConnection: func(tls.ConnectionState) error {
+		called = true
+		return nil
+	}}
+
+	// When
+	if err := ApplyPins(config, []string{SPKIHash(cert)}); err != nil {
+		t.Fatal(err)
+	}
+	err := config.VerifyConnection(tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}})
+
+	// Then
+	if err != nil || !called {
+		t.Errorf("Unexpected verification. Got: %v, called %v, Expected: nil, called true", err, called)
+	}
+}
+
+func TestDiagnoseTLSError(t *testing.T) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca := newTestCertificate(t, "TAK CA", caKey, nil, nil, true)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	serverCert := func(names []string, notAfter time.Time) *x509.Certificate {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(time.Now().UnixNano()),
+			Subject:      pkix.Name{CommonName: "takserver"},
+			DNSNames:     names,
+			NotBefore:    notAfter.Add(-2 * time.Hour),
+			NotAfter:     notAfter,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, _ := x509.ParseCertificate(der)
+		return cert
+	}
+	verify := func(cert *x509.Certificate, roots *x509.CertPool, name string) error {
+		_, err := cert.Verify(x509.VerifyOptions{Roots: roots, DNSName: name})
+		return &tls.CertificateVerificationError{UnverifiedCertificates: []*x509.Certificate{cert}, Err: err}
+	}
+
+	valid := serverCert([]string{"takserver.example.com"}, time.Now().Add(time.Hour))
+	tests := []struct {
+		name string
+		err  error
+		hint string
+	}{
+		{"Unknown authority", verify(valid, x509.NewCertPool(), "takserver.example.com"), `issued by "CN=TAK CA"`},
+		{"Wrong host name", verify(valid, roots, "10.0.0.5"), "valid for takserver.example.com"},
+		{"Expired", verify(serverCert([]string{"takserver.example.com"}, time.Now().Add(-time.Hour)), roots, "takserver.example.com"), "check the clock or renew"},
+		{"Client certificate rejected", errors.New("remote error: tls: bad certificate"), "server rejected the client certificate"},
+		{"Pin mismatch", &PinError{Certificates: []*x509.Certificate{valid}}, "sha256/" + SPKIHash(valid)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// When
+			err := DiagnoseTLSError(test.err, "")
+
+			// Then
+			var verificationErr *VerificationError
+			if !errors.As(err, &verificationErr) || !errors.Is(err, test.err) {
+				t.Fatalf("Unexpected error type. Got: %T, Expected: *VerificationError wrapping the original", err)
+			}
+			if !strings.Contains(err.Error(), test.hint) {
+				t.Errorf("Unexpected diagnosis. Got: %v, Expected it to contain: %v", err, test.hint)
+			}
+		})
+	}
+
+	// Other errors are returned unchanged
+	plain := errors.New("connection refused")
+	if err := DiagnoseTLSError(plain, ""); err != plain {
+		t.Errorf("Unexpected error. Got: %v, Expected: %v", err, plain)
+	}
+	if DiagnoseTLSError(nil, "") != nil {
+		t.Error("Expected nil for no error")
+	}
+}